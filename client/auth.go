@@ -0,0 +1,29 @@
+package client
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Metadata header the server reads credentials from when AUTH_PROVIDER is set
+const AuthorizationHeader = "authorization"
+
+// Send token as a bearer credential on every unary call, e.g. an API key or
+// a JWT: grpc.WithChainUnaryInterceptor(client.BearerTokenInterceptor(token))
+func BearerTokenInterceptor(token string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, AuthorizationHeader, "Bearer "+token)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Streaming variant of BearerTokenInterceptor, needed for Subscribe, Import
+// and the other streams: grpc.WithChainStreamInterceptor(client.BearerTokenStreamInterceptor(token))
+func BearerTokenStreamInterceptor(token string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx = metadata.AppendToOutgoingContext(ctx, AuthorizationHeader, "Bearer "+token)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Version, optional features and limits of a server
+type Capabilities struct {
+	// Semantic version of the server, empty if it predates ServerCapabilities
+	Version string
+	// Names of the optional features it supports, e.g. "ttl"
+	Features []string
+	// Largest value it accepts, 0 if unlimited or unknown
+	MaxValueSizeMB int32
+}
+
+// Report whether the feature called name is supported
+func (c Capabilities) Supports(name string) bool {
+	return slices.Contains(c.Features, name)
+}
+
+// Ask the server what it supports, typically once after connecting so
+// unsupported features can be left unused. A server too old to answer is
+// reported as supporting no optional features rather than as an error
+func (c *Client) Capabilities(ctx context.Context, opts ...grpc.CallOption) (Capabilities, error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.ServerCapabilities(ctx, &pb.CapabilitiesRequest{}, opts...)
+	if status.Code(err) == codes.Unimplemented {
+		return Capabilities{}, nil
+	}
+	if err != nil {
+		return Capabilities{}, err
+	}
+	return Capabilities{
+		Version:        resp.Version,
+		Features:       resp.SupportedFeatures,
+		MaxValueSizeMB: resp.MaxValueSizeMb,
+	}, nil
+}
@@ -0,0 +1,246 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Metadata header carrying a caller-chosen ID for correlating logs
+const RequestIDHeader = "x-request-id"
+
+// Typed wrapper around a KeyValueStore connection. Every method accepts gRPC
+// call options, including WithDeadline and WithRequestID
+type Client struct {
+	kv pb.KeyValueStoreClient
+
+	// In-flight GetOrCreate calls by key
+	creating singleflight.Group
+}
+
+// Create a client over an existing connection
+func New(cc grpc.ClientConnInterface) *Client {
+	return &Client{kv: pb.NewKeyValueStoreClient(cc)}
+}
+
+// Underlying generated client, for RPCs without a typed wrapper
+func (c *Client) KV() pb.KeyValueStoreClient {
+	return c.kv
+}
+
+// Retrieve the value of key, found is false if it does not exist
+func (c *Client) Get(ctx context.Context, key string, opts ...grpc.CallOption) (value string, found bool, err error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.Get(ctx, &pb.GetRequest{Key: key}, opts...)
+	if err != nil {
+		return "", false, err
+	}
+	return resp.Value, resp.Found, nil
+}
+
+// Store value under key, returning the key's new version
+func (c *Client) Set(ctx context.Context, key, value string, opts ...grpc.CallOption) (int64, error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.Set(ctx, &pb.SetRequest{Key: key, Value: value}, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+// Store value under key only if the key does not exist, failing with
+// codes.AlreadyExists otherwise. Returns the key's new version
+func (c *Client) SetIfNotExists(ctx context.Context, key, value string, opts ...grpc.CallOption) (int64, error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.Set(ctx, &pb.SetRequest{
+		Key:            key,
+		Value:          value,
+		ConflictPolicy: pb.ConflictPolicy_POLICY_FWW,
+	}, opts...)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+// Retrieve the value of key with its version, 0 if it does not exist
+func (c *Client) GetWithVersion(ctx context.Context, key string, opts ...grpc.CallOption) (value string, version int64, err error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.GetWithVersion(ctx, &pb.GetWithVersionRequest{Key: key}, opts...)
+	if err != nil {
+		return "", 0, err
+	}
+	return resp.Value, resp.Version, nil
+}
+
+// Store value under key if it is still at expectedVersion, or unconditionally
+// when expectedVersion is 0. ok is false if another write got there first, in
+// which case version is the key's current version
+func (c *Client) SetWithVersion(ctx context.Context, key, value string, expectedVersion int64, opts ...grpc.CallOption) (version int64, ok bool, err error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.SetWithVersion(ctx, &pb.SetWithVersionRequest{
+		Key:             key,
+		Value:           value,
+		ExpectedVersion: expectedVersion,
+	}, opts...)
+	if err != nil {
+		return 0, false, err
+	}
+	return resp.Version, resp.Success, nil
+}
+
+// Apply an RFC 7396 JSON Merge Patch to the JSON value of key, returning the
+// patched value and the key's new version
+func (c *Client) MergePatch(ctx context.Context, key, patch string, opts ...grpc.CallOption) (value string, version int64, err error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.MergePatch(ctx, &pb.MergePatchRequest{Key: key, Patch: patch}, opts...)
+	if err != nil {
+		return "", 0, err
+	}
+	return resp.NewValue, resp.Version, nil
+}
+
+// Retrieve the values of keys as they all were at one instant, e.g. related
+// config keys at startup. Keys that do not exist are left out of values
+func (c *Client) GetSnapshot(ctx context.Context, keys []string, opts ...grpc.CallOption) (values map[string]string, at time.Time, err error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.GetSnapshot(ctx, &pb.GetSnapshotRequest{Keys: keys}, opts...)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	values = make(map[string]string, len(resp.Results))
+	for _, result := range resp.Results {
+		if result.Found {
+			values[result.Key] = result.Value
+		}
+	}
+	return values, time.Unix(0, resp.SnapshotTimestamp), nil
+}
+
+// Replace the labels attached to key, which must exist. Empty labels clear them
+func (c *Client) SetMeta(ctx context.Context, key string, labels map[string]string, opts ...grpc.CallOption) error {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	_, err := c.kv.SetMeta(ctx, &pb.SetMetaRequest{Key: key, Meta: labels}, opts...)
+	return err
+}
+
+// Retrieve the labels attached to key
+func (c *Client) GetMeta(ctx context.Context, key string, opts ...grpc.CallOption) (map[string]string, error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.GetMeta(ctx, &pb.GetMetaRequest{Key: key}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Meta, nil
+}
+
+// Find the keys carrying every one of labels, in key order
+func (c *Client) SearchByMeta(ctx context.Context, labels map[string]string, opts ...grpc.CallOption) ([]string, error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.SearchByMeta(ctx, &pb.SearchMetaRequest{Labels: labels}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// Remove key, reporting whether it existed
+func (c *Client) Delete(ctx context.Context, key string, opts ...grpc.CallOption) (bool, error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.Delete(ctx, &pb.DeleteRequest{Key: key}, opts...)
+	if err != nil {
+		return false, err
+	}
+	return resp.Deleted, nil
+}
+
+// Report whether key exists without fetching its value
+func (c *Client) Exists(ctx context.Context, key string, opts ...grpc.CallOption) (bool, error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	resp, err := c.kv.Exists(ctx, &pb.ExistsRequest{Key: key}, opts...)
+	if err != nil {
+		return false, err
+	}
+	return resp.Exists, nil
+}
+
+// Fail the call if it takes longer than d
+func WithDeadline(d time.Duration) grpc.CallOption {
+	return deadlineOption{timeout: d}
+}
+
+// Send id in the x-request-id header so the call can be found in server logs
+func WithRequestID(id string) grpc.CallOption {
+	return requestIDOption{id: id}
+}
+
+// Apply WithDeadline and WithRequestID on calls made through the generated
+// client directly, e.g. grpc.WithUnaryInterceptor(client.CallOptionInterceptor())
+func CallOptionInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel := applyCallOptions(ctx, opts)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Options applied to the call context, gRPC itself ignores them
+type deadlineOption struct {
+	grpc.EmptyCallOption
+	timeout time.Duration
+}
+
+type requestIDOption struct {
+	grpc.EmptyCallOption
+	id string
+}
+
+// Derive the call context from the deadline and request ID options in opts.
+// The options are safe to apply twice, a second deadline only shortens the first
+func applyCallOptions(ctx context.Context, opts []grpc.CallOption) (context.Context, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case deadlineOption:
+			prev := cancel
+			var next context.CancelFunc
+			ctx, next = context.WithTimeout(ctx, o.timeout)
+			cancel = func() { next(); prev() }
+		case requestIDOption:
+			md, _ := metadata.FromOutgoingContext(ctx)
+			if len(md.Get(RequestIDHeader)) == 0 {
+				ctx = metadata.AppendToOutgoingContext(ctx, RequestIDHeader, o.id)
+			}
+		}
+	}
+	return ctx, cancel
+}
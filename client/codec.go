@@ -0,0 +1,23 @@
+package client
+
+import "encoding/json"
+
+// Converts between typed values and the strings the store holds
+type Codec[T any] interface {
+	Marshal(value T) (string, error)
+	Unmarshal(data string) (T, error)
+}
+
+// Store values as JSON
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(value T) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+func (JSONCodec[T]) Unmarshal(data string) (T, error) {
+	var value T
+	err := json.Unmarshal([]byte(data), &value)
+	return value, err
+}
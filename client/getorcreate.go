@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Rounds GetOrCreate makes when a created key keeps disappearing before it can be read
+const maxGetOrCreateAttempts = 3
+
+// Longest a shared GetOrCreate lookup may run, since no single caller's
+// context bounds it
+const getOrCreateTimeout = 30 * time.Second
+
+// Return the value of key, first storing the result of defaultFn if the key
+// does not exist. Concurrent calls for the same key through one Client share
+// a single lookup and receive the same value. The lookup runs detached from
+// any caller's cancellation, bounded by getOrCreateTimeout, so a caller that
+// gives up returns ctx.Err() without failing the others. Callers in other
+// processes race on the write: one wins and the rest return the winner's
+// value, discarding their own default
+func GetOrCreate[T any](ctx context.Context, c *Client, key string, defaultFn func() (T, error), codec Codec[T], opts ...grpc.CallOption) (T, error) {
+	var zero T
+	results := c.creating.DoChan(key, func() (any, error) {
+		shared, cancel := context.WithTimeout(context.WithoutCancel(ctx), getOrCreateTimeout)
+		defer cancel()
+		return getOrCreate(shared, c, key, defaultFn, codec, opts...)
+	})
+
+	var res singleflight.Result
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res = <-results:
+	}
+	if res.Err != nil {
+		return zero, res.Err
+	}
+	// Concurrent calls for the key with a different T cannot share the result
+	if value, ok := res.Val.(T); ok {
+		return value, nil
+	}
+	return getOrCreate(ctx, c, key, defaultFn, codec, opts...)
+}
+
+func getOrCreate[T any](ctx context.Context, c *Client, key string, defaultFn func() (T, error), codec Codec[T], opts ...grpc.CallOption) (T, error) {
+	var zero T
+	for range maxGetOrCreateAttempts {
+		data, found, err := c.Get(ctx, key, opts...)
+		if err != nil {
+			return zero, err
+		}
+		if found {
+			return codec.Unmarshal(data)
+		}
+
+		value, err := defaultFn()
+		if err != nil {
+			return zero, err
+		}
+		data, err = codec.Marshal(value)
+		if err != nil {
+			return zero, fmt.Errorf("marshal default for %q: %w", key, err)
+		}
+
+		_, err = c.SetIfNotExists(ctx, key, data, opts...)
+		if err == nil {
+			return value, nil
+		}
+		// Another caller created it first, read theirs on the next round
+		if status.Code(err) != codes.AlreadyExists {
+			return zero, err
+		}
+	}
+	return zero, status.Errorf(codes.Aborted, "key %q was deleted while being created", key)
+}
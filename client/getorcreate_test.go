@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrCreateCallsDefaultOnce(t *testing.T) {
+	c := New(newTestConn(t))
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	defaultFn := func() (string, error) {
+		calls.Add(1)
+		// Long enough for the other callers to join the lookup
+		time.Sleep(50 * time.Millisecond)
+		return "created", nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := GetOrCreate(ctx, c, "config", defaultFn, JSONCodec[string]{})
+			if err == nil && value != "created" {
+				err = errors.New("got value " + value)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("GetOrCreate: %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("defaultFn called %d times, want 1", n)
+	}
+}
+
+func TestGetOrCreateSurvivesFirstCallerCancel(t *testing.T) {
+	c := New(newTestConn(t))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defaultFn := func() (string, error) {
+		close(started)
+		<-release
+		return "created", nil
+	}
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := GetOrCreate(first, c, "config", defaultFn, JSONCodec[string]{})
+		firstErr <- err
+	}()
+	<-started
+
+	second := make(chan error, 1)
+	go func() {
+		value, err := GetOrCreate(context.Background(), c, "config", defaultFn, JSONCodec[string]{})
+		if err == nil && value != "created" {
+			err = errors.New("got value " + value)
+		}
+		second <- err
+	}()
+
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled caller got %v, want context.Canceled", err)
+	}
+	close(release)
+	if err := <-second; err != nil {
+		t.Errorf("caller sharing the lookup failed: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Bytes sent per SetStream chunk, matching the server's default for GetStream
+const largeValueChunkSize = 1 << 20
+
+// Read the value of key as it streams in, for values too large for Get.
+// Fails with codes.NotFound if the key does not exist. The call stays open
+// until the reader returns io.EOF or an error, cancel ctx to abandon it early
+func (c *Client) GetLargeValue(ctx context.Context, key string, opts ...grpc.CallOption) (io.Reader, error) {
+	ctx, cancel := applyCallOptions(ctx, opts)
+
+	stream, err := c.kv.GetStream(ctx, &pb.GetStreamRequest{Key: key}, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The first chunk surfaces a missing key as an error here, not on Read
+	first, err := stream.Recv()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if first.Offset != 0 {
+		cancel()
+		return nil, fmt.Errorf("first chunk at offset %d, expected 0", first.Offset)
+	}
+	return &chunkReader{stream: stream, cancel: cancel, chunk: first}, nil
+}
+
+// Store the contents of r under key with SetStream, for values too large for
+// Set. The value is only written once r is fully sent
+func (c *Client) SetLargeValue(ctx context.Context, key string, r io.Reader, opts ...grpc.CallOption) error {
+	ctx, cancel := applyCallOptions(ctx, opts)
+	defer cancel()
+
+	stream, err := c.kv.SetStream(ctx, opts...)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, largeValueChunkSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			stream.CloseSend()
+			return fmt.Errorf("read value: %w", readErr)
+		}
+		// The first chunk names the key even when the value is empty
+		if n > 0 || offset == 0 {
+			chunk := &pb.SetStreamChunk{Data: buf[:n], Offset: offset}
+			if offset == 0 {
+				chunk.Key = key
+			}
+			if err := stream.Send(chunk); err != nil {
+				// The server's status is reported by CloseAndRecv
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			offset += int64(n)
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+// Presents a GetStream as an io.Reader, checking the chunks arrive in order
+type chunkReader struct {
+	stream grpc.ServerStreamingClient[pb.GetStreamChunk]
+	cancel context.CancelFunc
+
+	// Current chunk and how much of it has been read
+	chunk *pb.GetStreamChunk
+	pos   int
+	// Bytes of the value delivered before the current chunk
+	offset int64
+	err    error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for r.err == nil && r.pos == len(r.chunk.Data) {
+		if r.chunk.Eof {
+			r.fail(io.EOF)
+			break
+		}
+		next, err := r.stream.Recv()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			r.fail(err)
+			break
+		}
+		r.offset += int64(len(r.chunk.Data))
+		if next.Offset != r.offset {
+			r.fail(fmt.Errorf("chunk at offset %d, expected %d", next.Offset, r.offset))
+			break
+		}
+		r.chunk, r.pos = next, 0
+	}
+	if r.pos == len(r.chunk.Data) {
+		return 0, r.err
+	}
+
+	n := copy(p, r.chunk.Data[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+// Remember the error every later Read returns and end the call
+func (r *chunkReader) fail(err error) {
+	r.err = err
+	r.cancel()
+}
@@ -0,0 +1,253 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Must match the headers set by the server when resuming
+	oldestSequenceHeader = "x-kvstore-oldest-sequence"
+	latestSequenceHeader = "x-kvstore-latest-sequence"
+
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Returned by a stream whose server announced it is shutting down
+var errServerShutdown = errors.New("server shutting down")
+
+// Called when events between requested and oldest were no longer held by the server
+type GapHandler func(requested, oldest int64)
+
+// Subscription that reconnects on stream errors and resumes from the last sequence seen
+type ReliableSubscriber struct {
+	kv  pb.KeyValueStoreClient
+	req *pb.SubscribeRequest
+
+	lastSeq    int64
+	noReplay   bool
+	onGap      GapHandler
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Configure a ReliableSubscriber
+type ReliableOption func(*ReliableSubscriber)
+
+// Resume after this sequence number on the first connection, e.g. from a state file
+func WithStartSequence(seq int64) ReliableOption {
+	return func(r *ReliableSubscriber) {
+		r.lastSeq = seq
+	}
+}
+
+// Reconnect with live events only, never requesting a replay
+func WithoutReplay() ReliableOption {
+	return func(r *ReliableSubscriber) {
+		r.noReplay = true
+	}
+}
+
+// Be told when a resume could not cover every missed event
+func WithGapHandler(fn GapHandler) ReliableOption {
+	return func(r *ReliableSubscriber) {
+		r.onGap = fn
+	}
+}
+
+// Bounds of the exponential reconnect delay
+func WithBackoff(min, max time.Duration) ReliableOption {
+	return func(r *ReliableSubscriber) {
+		if min > 0 && max >= min {
+			r.minBackoff, r.maxBackoff = min, max
+		}
+	}
+}
+
+// Create a subscriber for req, which is reused on every reconnect
+func NewReliableSubscriber(kv pb.KeyValueStoreClient, req *pb.SubscribeRequest, opts ...ReliableOption) *ReliableSubscriber {
+	r := &ReliableSubscriber{
+		kv:         kv,
+		req:        req,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Sequence number of the last event delivered
+func (r *ReliableSubscriber) LastSequence() int64 {
+	return r.lastSeq
+}
+
+// Deliver events to handle until ctx is done, handle fails, or the server rejects the request
+func (r *ReliableSubscriber) Run(ctx context.Context, handle func(*pb.ChangeEvent) error) error {
+	backoff := r.minBackoff
+	for {
+		received, err := r.runOnce(ctx, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var handlerErr *handlerError
+		if errors.As(err, &handlerErr) {
+			return handlerErr.err
+		}
+		if permanent(err) {
+			return err
+		}
+
+		// A planned shutdown is no failure, move to another instance right away
+		if errors.Is(err, errServerShutdown) {
+			slog.Info("server shutting down, reconnecting", "pattern", r.req.KeyPattern, "last_sequence", r.lastSeq)
+			backoff = r.minBackoff
+			continue
+		}
+
+		// A connection that delivered events was healthy, start backing off afresh
+		if received {
+			backoff = r.minBackoff
+		}
+		slog.Warn("subscription interrupted, reconnecting", "pattern", r.req.KeyPattern, "last_sequence", r.lastSeq, "retry_in", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, r.maxBackoff)
+	}
+}
+
+// Wraps errors returned by the caller's handler so Run stops instead of retrying
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+	return e.err.Error()
+}
+
+// Run a single stream, reporting whether any event arrived
+func (r *ReliableSubscriber) runOnce(ctx context.Context, handle func(*pb.ChangeEvent) error) (bool, error) {
+	// Ends the stream when leaving early, e.g. on a shutdown announcement
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req := r.request()
+	stream, err := r.kv.Subscribe(ctx, req)
+	if err != nil {
+		return false, err
+	}
+
+	if req.ResumeFromSequence > 0 {
+		if err := r.checkGap(stream, req.ResumeFromSequence); err != nil {
+			return false, err
+		}
+	}
+
+	received := false
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return received, errors.New("stream closed by server")
+		}
+		if err != nil {
+			return received, err
+		}
+		received = true
+
+		if event.ChangeType == pb.ChangeEvent_SERVER_SHUTDOWN {
+			return received, errServerShutdown
+		}
+
+		// Replays may overlap events already delivered
+		if event.Sequence != 0 && event.Sequence <= r.lastSeq {
+			continue
+		}
+		if err := handle(event); err != nil {
+			return received, &handlerError{err: err}
+		}
+		if event.Sequence > r.lastSeq {
+			r.lastSeq = event.Sequence
+		}
+	}
+}
+
+// Copy of the request with the resume position for this connection
+func (r *ReliableSubscriber) request() *pb.SubscribeRequest {
+	req := &pb.SubscribeRequest{
+		KeyPattern:   r.req.KeyPattern,
+		AllowedTypes: r.req.AllowedTypes,
+		MaxDlqSize:   r.req.MaxDlqSize,
+	}
+	if !r.noReplay && r.lastSeq > 0 {
+		req.ResumeFromSequence = r.lastSeq
+	} else {
+		req.ReplayExisting = r.req.ReplayExisting
+	}
+	return req
+}
+
+// Report events the server could no longer replay
+func (r *ReliableSubscriber) checkGap(stream grpc.ServerStreamingClient[pb.ChangeEvent], requested int64) error {
+	header, err := stream.Header()
+	if err != nil {
+		return err
+	}
+	oldest := sequenceHeader(header, oldestSequenceHeader)
+	latest := sequenceHeader(header, latestSequenceHeader)
+
+	// Numbering restarted, e.g. after a server restart, so everything may be missed
+	if latest < requested {
+		slog.Warn("server sequence numbers restarted, events may have been missed", "requested", requested, "latest", latest)
+		r.lastSeq = 0
+		if r.onGap != nil {
+			r.onGap(requested, oldest)
+		}
+		return nil
+	}
+
+	// Nothing is missing if the server still holds the event right after ours
+	if oldest == 0 || oldest <= requested+1 {
+		return nil
+	}
+
+	slog.Warn("events may have been missed, resuming from oldest available sequence", "requested", requested, "oldest", oldest)
+	if r.onGap != nil {
+		r.onGap(requested, oldest)
+	}
+	return nil
+}
+
+func sequenceHeader(header metadata.MD, key string) int64 {
+	values := header.Get(key)
+	if len(values) == 0 {
+		return 0
+	}
+	n, _ := strconv.ParseInt(values[0], 10, 64)
+	return n
+}
+
+// Errors that a reconnect cannot fix
+func permanent(err error) bool {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.NotFound, codes.Unimplemented, codes.PermissionDenied, codes.Unauthenticated:
+		return true
+	}
+	return false
+}
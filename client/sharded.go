@@ -0,0 +1,265 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/ring"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Pairs fetched per Range call while looking for keys to move
+	rebalancePageSize = 10000
+	rebalanceTimeout  = 30 * time.Second
+)
+
+// Client spreading keys over several servers with a consistent hash ring, so
+// adding or removing a server moves only the keys in the ranges that change
+// owner
+type ShardedClient struct {
+	mu     sync.RWMutex
+	vnodes int
+	ring   *ring.Ring
+	shards map[string]*Client
+
+	keysPending prometheus.Gauge
+	keysMoved   *prometheus.CounterVec
+}
+
+// Configure a ShardedClient
+type ShardedOption func(*ShardedClient)
+
+// Register rebalance progress metrics with reg, they are not exported otherwise
+func WithMetricsRegisterer(reg prometheus.Registerer) ShardedOption {
+	return func(c *ShardedClient) {
+		reg.MustRegister(c.keysPending, c.keysMoved)
+	}
+}
+
+// Create a client over connections to each shard by name. Names place the
+// shards on the ring, so keep them stable across restarts. vnodes is the
+// number of ring points per shard, ring.DefaultVNodes if 0
+func NewSharded(shards map[string]grpc.ClientConnInterface, vnodes int, opts ...ShardedOption) *ShardedClient {
+	c := &ShardedClient{
+		vnodes: vnodes,
+		ring:   ring.New(vnodes),
+		shards: make(map[string]*Client, len(shards)),
+		keysPending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "kvstore",
+			Name:      "rebalance_keys_pending",
+			Help:      "Keys found on shards losing hash ranges that the running rebalance has not yet checked.",
+		}),
+		keysMoved: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "kvstore",
+			Name:      "rebalance_keys_total",
+			Help:      "Total keys a sharded client rebalance tried to move to their new shard, by result: moved, kept or failed.",
+		}, []string{"result"}),
+	}
+	for name, cc := range shards {
+		c.shards[name] = New(cc)
+		c.ring.Add(name)
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Client for the shard owning key
+func (c *ShardedClient) Shard(key string) *Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.shards[c.ring.NodeFor(key)]
+}
+
+// Retrieve the value of key from its shard
+func (c *ShardedClient) Get(ctx context.Context, key string, opts ...grpc.CallOption) (value string, found bool, err error) {
+	return c.Shard(key).Get(ctx, key, opts...)
+}
+
+// Store value under key on its shard, returning the key's new version
+func (c *ShardedClient) Set(ctx context.Context, key, value string, opts ...grpc.CallOption) (int64, error) {
+	return c.Shard(key).Set(ctx, key, value, opts...)
+}
+
+// Remove key from its shard, reporting whether it existed
+func (c *ShardedClient) Delete(ctx context.Context, key string, opts ...grpc.CallOption) (bool, error) {
+	return c.Shard(key).Delete(ctx, key, opts...)
+}
+
+// Switch to a new set of shards, moving the keys whose owner changes. Each is
+// copied with its TTL and labels to the new shard, unless that shard already
+// has the key, then deleted from the old one if unchanged since it was read.
+// Versions are not carried over, the new shard numbers its own. Only shards
+// losing hash ranges are scanned. Until it returns, keys already moved are
+// not found through this client, so pause writers while rebalancing. On
+// error the client keeps the old shards, and calling Rebalance again picks up
+// the keys not yet moved
+func (c *ShardedClient) Rebalance(ctx context.Context, shards map[string]grpc.ClientConnInterface) (moved int, err error) {
+	c.mu.RLock()
+	oldRing := c.ring
+	clients := make(map[string]*Client, len(c.shards)+len(shards))
+	for name, client := range c.shards {
+		clients[name] = client
+	}
+	c.mu.RUnlock()
+
+	newRing := ring.New(c.vnodes)
+	newShards := make(map[string]*Client, len(shards))
+	for name, cc := range shards {
+		if _, ok := clients[name]; !ok {
+			clients[name] = New(cc)
+		}
+		newShards[name] = clients[name]
+		newRing.Add(name)
+	}
+
+	added, removed := ring.Diff(oldRing, newRing)
+	losing := make(map[string][]ring.Assignment)
+	for _, a := range removed {
+		losing[a.Node] = append(losing[a.Node], a)
+	}
+	slog.Info("rebalance started", "shard_count", len(shards), "moving_ranges", len(added), "losing_shards", len(losing))
+
+	// Find every key to move before moving any, so progress has a total
+	type move struct {
+		key, from string
+	}
+	var moves []move
+	for _, name := range oldRing.Nodes() {
+		ranges := losing[name]
+		if len(ranges) == 0 {
+			continue
+		}
+		keys, err := scanKeys(ctx, clients[name].kv)
+		if err != nil {
+			return 0, fmt.Errorf("list keys on shard %s: %w", name, err)
+		}
+		for _, key := range keys {
+			h := ring.Hash(key)
+			for _, a := range ranges {
+				if a.Contains(h) {
+					moves = append(moves, move{key: key, from: name})
+					break
+				}
+			}
+		}
+	}
+
+	c.keysPending.Set(float64(len(moves)))
+	defer c.keysPending.Set(0)
+	for _, m := range moves {
+		to := newRing.NodeFor(m.key)
+		copied, err := moveKey(ctx, clients[m.from], clients[to], m.key)
+		if err != nil {
+			c.keysMoved.WithLabelValues("failed").Inc()
+			return moved, fmt.Errorf("move %q from shard %s to %s: %w", m.key, m.from, to, err)
+		}
+		c.keysPending.Dec()
+		if !copied {
+			c.keysMoved.WithLabelValues("kept").Inc()
+			continue
+		}
+		c.keysMoved.WithLabelValues("moved").Inc()
+		moved++
+	}
+
+	c.mu.Lock()
+	c.ring = newRing
+	c.shards = newShards
+	c.mu.Unlock()
+
+	slog.Info("rebalance completed", "moved_count", moved)
+	return moved, nil
+}
+
+// Copy key with its TTL and labels from one shard to another, then delete it
+// from the first if it was not written in between. A key deleted since it was
+// listed is skipped, and one the target already holds is left there as the
+// newer copy. Reports whether the key was copied
+func moveKey(ctx context.Context, from, to *Client, key string) (bool, error) {
+	got, err := from.kv.Get(ctx, &pb.GetRequest{Key: key})
+	if err != nil || !got.Found {
+		return false, err
+	}
+	info, err := from.kv.InspectKey(ctx, &pb.InspectKeyRequest{Key: key})
+	if err != nil {
+		return false, err
+	}
+	if !info.Exists {
+		return false, nil
+	}
+	labels, err := from.GetMeta(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	// Version 0 only matches a key the target does not have
+	req := &pb.SetRequest{Key: key, Value: got.Value, ConflictPolicy: pb.ConflictPolicy_POLICY_CAS}
+	if info.TtlRemainingMs >= 0 {
+		// Already expired, let the source's reaper remove it
+		if info.TtlRemainingMs == 0 {
+			return false, nil
+		}
+		req.TtlMs = &info.TtlRemainingMs
+	}
+	copied := true
+	if _, err := to.kv.Set(ctx, req); status.Code(err) == codes.Aborted {
+		copied = false
+	} else if err != nil {
+		return false, err
+	}
+	if copied && len(labels) > 0 {
+		if err := to.SetMeta(ctx, key, labels); err != nil {
+			return false, err
+		}
+	}
+
+	// A write to the source after the read would be lost by deleting it. Drop
+	// the stale copy instead, so a later Rebalance copies the new value
+	current, err := from.kv.Get(ctx, &pb.GetRequest{Key: key})
+	if err != nil {
+		return copied, err
+	}
+	if current.Found && current.Version != got.Version {
+		if copied {
+			if _, err := to.Delete(ctx, key); err != nil {
+				return false, err
+			}
+		}
+		return false, fmt.Errorf("key changed on the source shard during the move")
+	}
+	_, err = from.Delete(ctx, key)
+	return copied, err
+}
+
+// List every key on a shard, paging through Range in key order
+func scanKeys(ctx context.Context, kv pb.KeyValueStoreClient) ([]string, error) {
+	var keys []string
+	start := ""
+	for {
+		pageCtx, cancel := context.WithTimeout(ctx, rebalanceTimeout)
+		resp, err := kv.Range(pageCtx, &pb.RangeRequest{StartKey: start, Limit: rebalancePageSize})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range resp.Pairs {
+			keys = append(keys, pair.Key)
+		}
+		if !resp.Truncated || len(resp.Pairs) == 0 {
+			return keys, nil
+		}
+		// Smallest key after the last one returned
+		start = resp.Pairs[len(resp.Pairs)-1].Key + "\x00"
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/ring"
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Serve a fresh store over an in-process connection, closed when the test ends
+func newTestConn(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+	svc := service.NewKVStoreService(service.WithConfig(config.Default()))
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterKeyValueStoreServer(srv, svc)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+		svc.Close()
+	})
+	return conn
+}
+
+func TestRebalanceKeepsTTLAndLabels(t *testing.T) {
+	ctx := context.Background()
+	a, b := newTestConn(t), newTestConn(t)
+	sharded := NewSharded(map[string]grpc.ClientConnInterface{"a": a}, 0)
+
+	ttl := int64(3_600_000)
+	for i := range 50 {
+		key := fmt.Sprintf("user:%d", i)
+		if _, err := New(a).kv.Set(ctx, &pb.SetRequest{Key: key, Value: "old", TtlMs: &ttl}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if err := New(a).SetMeta(ctx, key, map[string]string{"team": "core"}); err != nil {
+			t.Fatalf("SetMeta: %v", err)
+		}
+		// The new shard already has a newer value for every key
+		if _, err := New(b).Set(ctx, key, "newer"); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	// Except for even keys, which are copied in full
+	newRing := ring.New(0)
+	newRing.Add("a")
+	newRing.Add("b")
+	for i := 0; i < 50; i += 2 {
+		if _, err := New(b).Delete(ctx, fmt.Sprintf("user:%d", i)); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+
+	if _, err := sharded.Rebalance(ctx, map[string]grpc.ClientConnInterface{"a": a, "b": b}); err != nil {
+		t.Fatalf("Rebalance: %v", err)
+	}
+
+	checked := 0
+	for i := range 50 {
+		key := fmt.Sprintf("user:%d", i)
+		if newRing.NodeFor(key) != "b" {
+			continue
+		}
+		value, _, err := sharded.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get %s: %v", key, err)
+		}
+		if i%2 == 1 {
+			if value != "newer" {
+				t.Errorf("%s = %q, the newer value on the target was overwritten", key, value)
+			}
+			continue
+		}
+
+		checked++
+		if value != "old" {
+			t.Errorf("%s = %q, want %q", key, value, "old")
+		}
+		info, err := New(b).kv.InspectKey(ctx, &pb.InspectKeyRequest{Key: key})
+		if err != nil {
+			t.Fatalf("InspectKey: %v", err)
+		}
+		if info.TtlRemainingMs <= 0 {
+			t.Errorf("%s lost its TTL", key)
+		}
+		labels, err := New(b).GetMeta(ctx, key)
+		if err != nil {
+			t.Fatalf("GetMeta: %v", err)
+		}
+		if labels["team"] != "core" {
+			t.Errorf("%s labels = %v, want team=core", key, labels)
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no even key moved to the new shard")
+	}
+}
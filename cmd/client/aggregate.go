@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executeAggregate(kv pb.KeyValueStoreClient, out Formatter, pattern, match, operation, valueType string) {
+	if pattern == "" || operation == "" {
+		log.Fatal("Error: -pattern and -agg flags are required for agg operation")
+	}
+
+	mode, ok := pb.MatchMode_value["MATCH_"+strings.ToUpper(match)]
+	if !ok {
+		log.Fatalf("Error: invalid match mode '%s'. Must be: prefix, glob, or regex", match)
+	}
+	op, ok := pb.AggregateOp_value["AGGREGATE_"+strings.ToUpper(operation)]
+	if !ok || op == int32(pb.AggregateOp_AGGREGATE_UNSPECIFIED) {
+		log.Fatalf("Error: invalid aggregate '%s'. Must be: sum, count, min, max, or avg", operation)
+	}
+	typ, ok := pb.ValueType_value["VALUE_TYPE_"+strings.ToUpper(valueType)]
+	if !ok || typ == int32(pb.ValueType_VALUE_TYPE_UNSPECIFIED) {
+		log.Fatalf("Error: invalid number type '%s'. Must be: int64 or float64", valueType)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.Aggregate(ctx, &pb.AggregateRequest{
+		Pattern:   pattern,
+		MatchMode: pb.MatchMode(mode),
+		Operation: pb.AggregateOp(op),
+		ValueType: pb.ValueType(typ),
+	})
+	if err != nil {
+		// Name the keys holding values of the wrong type
+		if st := status.Convert(err); st.Code() == codes.FailedPrecondition {
+			for _, detail := range st.Details() {
+				if failure, ok := detail.(*errdetails.PreconditionFailure); ok {
+					for _, v := range failure.Violations {
+						fmt.Fprintf(os.Stderr, "Not %s: %s\n", strings.ToLower(valueType), v.Subject)
+					}
+				}
+			}
+		}
+		log.Fatalf("Aggregate failed: %v", err)
+	}
+
+	writeResult(out, aggregateLine{
+		Pattern:      pattern,
+		Operation:    strings.ToLower(operation),
+		Result:       resp.Result,
+		MatchedCount: resp.MatchedCount,
+	})
+}
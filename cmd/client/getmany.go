@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executeGetMany(kv pb.KeyValueStoreClient, out Formatter, pattern, match string) {
+	if pattern == "" {
+		log.Fatal("Error: -pattern flag is required for getmany operation")
+	}
+
+	mode, ok := pb.MatchMode_value["MATCH_"+strings.ToUpper(match)]
+	if !ok {
+		log.Fatalf("Error: invalid match mode '%s'. Must be: prefix, glob, or regex", match)
+	}
+
+	// Stream so large result sets are not capped by the server default
+	stream, err := kv.GetManyStream(context.Background(), &pb.GetManyRequest{
+		Pattern:   pattern,
+		MatchMode: pb.MatchMode(mode),
+	})
+	if err != nil {
+		log.Fatalf("GetMany failed: %v", err)
+	}
+
+	for {
+		pair, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatalf("GetMany failed: %v", err)
+		}
+		writeResult(out, pairLine{Key: pair.Key, Value: pair.Value})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Follow KEY_IDLE events for keys under pattern, showing only keys unused for
+// at least idleAfter. How soon a key counts as idle is up to the server
+func executeWatchIdle(client pb.KeyValueStoreClient, out Formatter, pattern string, idleAfter time.Duration) {
+	if pattern == "" {
+		log.Fatal("Error: -pattern flag is required for watch-idle operation")
+	}
+
+	stream, err := client.Subscribe(context.Background(), &pb.SubscribeRequest{
+		KeyPattern:   pattern,
+		AllowedTypes: []pb.ChangeEvent_ChangeType{pb.ChangeEvent_KEY_IDLE},
+	})
+	if err != nil {
+		log.Fatalf("Subscribe failed: %v", err)
+	}
+
+	_, text := out.(TextFormatter)
+	if text {
+		fmt.Printf("Watching for idle keys under: %s\n", pattern)
+		fmt.Printf("Listening for idle keys (Ctrl+C to exit)\n\n")
+	}
+
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			if text {
+				fmt.Println("Stream closed by server")
+			}
+			return
+		}
+		if err != nil {
+			log.Fatalf("Error receiving event: %v", err)
+		}
+		if event.ChangeType == pb.ChangeEvent_SERVER_SHUTDOWN {
+			if text {
+				fmt.Println("Server shutting down")
+			}
+			return
+		}
+		if time.Since(time.UnixMilli(event.LastAccessedMs)) < idleAfter {
+			continue
+		}
+
+		writeResult(out, newWatchEvent(event))
+	}
+}
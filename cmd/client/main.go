@@ -1,32 +1,86 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
+	kvclient "github.com/amillerrr/distributed-kv-store/client"
 	pb "github.com/amillerrr/distributed-kv-store/proto"
+	"github.com/amillerrr/distributed-kv-store/signing"
 )
 
 const (
 	defaultServerAddr = "localhost:50051"
 	defaultTimeout    = 5 * time.Second
+	defaultStateFile  = ".kvstore-watch-state"
+	// Server's wait for acknowledgments when -ack-timeout is not set
+	defaultAckTimeout = 5 * time.Second
+	// Must match the header the server returns to ack_mode subscribers
+	subscriptionIDHeader = "x-kvstore-subscription-id"
 )
 
 func main() {
 	// Define command-line flags
 	serverAddr := flag.String("server", defaultServerAddr, "Server address (host:port)")
-	operation := flag.String("op", "", "Operation: get, set, or subscribe")
-	key := flag.String("key", "", "Key for get/set operations")
-	value := flag.String("value", "", "Value for set operation")
-	pattern := flag.String("pattern", "", "Key pattern for subscribe operation")
+	operation := flag.String("op", "", "Operation: get, mget, snapshot, getmany, agg, range, random, deleterange, exists, inspect, set, expire, append, patch, setmeta, getmeta, search, zadd, zrange, zrem, zscore, prefix-stats, import, migrate, subscribe, watch, watch-idle, wait-for, or capabilities")
+	key := flag.String("key", "", "Key for get, exists, inspect, set, and sorted set operations, or key prefix for wait-for")
+	keys := flag.String("keys", "", "Comma-separated keys for mget and snapshot")
+	fieldMask := flag.String("field", "", "Dot-separated JSON path to return from a get, e.g. user.address.city")
+	ifNoneMatch := flag.String("if-none-match", "", "ETag from an earlier get; the value is left out if it still matches")
+	value := flag.String("value", "", "Value for set and append operations, or the JSON Merge Patch for patch")
+	separator := flag.String("separator", "", "Text inserted before -value when appending to an existing key")
+	ttl := flag.Duration("ttl", 0, "Expire the key after this long on set or expire, e.g. 30s (default: no expiry)")
+	pattern := flag.String("pattern", "", "Key pattern for getmany, agg, subscribe, watch, and watch-idle operations, or key prefix for random")
+	count := flag.Int("count", 1, "Keys returned by random")
+	withReplacement := flag.Bool("with-replacement", false, "Let random return a key more than once, drawing each key independently")
+	member := flag.String("member", "", "Sorted set member for zadd, zrem, and zscore")
+	score := flag.Float64("score", 0, "Score of -member for zadd")
+	minScore := flag.String("min", "", "Lowest score returned by zrange, inclusive (default: no lower bound)")
+	maxScore := flag.String("max", "", "Highest score returned by zrange, inclusive (default: no upper bound)")
+	startKey := flag.String("start", "", "First key included by range and deleterange")
+	endKey := flag.String("end", "", "First key excluded by range and deleterange (default: no upper bound)")
+	dryRun := flag.Bool("dry-run", false, "Report how many keys deleterange would delete without deleting them")
+	limit := flag.Int("limit", 0, "Most pairs returned by range, or members by zrange (default: server default of 1000)")
+	reverse := flag.Bool("reverse", false, "Return range results in descending key order")
+	matchMode := flag.String("match", "glob", "How getmany and agg interpret -pattern: prefix, glob, or regex")
+	prefixes := flag.String("prefixes", "", "Comma-separated prefixes for prefix-stats (default: every top-level namespace, split on ':')")
+	topN := flag.Int("top-n", 10, "Prefixes shown by prefix-stats, largest total value size first, 0 for all")
+	aggregate := flag.String("agg", "", "Aggregate computed by agg over the values of the keys matching -pattern: sum, count, min, max, or avg")
+	numberType := flag.String("number-type", "float64", "How agg parses values: int64 or float64")
+	meta := flag.String("meta", "", "Comma-separated name=value labels for setmeta and search, or to filter subscribe by, e.g. env=prod,owner=alice")
+	eventTypes := flag.String("event-types", "", "Comma-separated change types to receive on subscribe, e.g. SET,DELETE (default: all)")
+	valueFilter := flag.String("value-filter", "", "Only receive SET events whose JSON value is truthy at this path on subscribe, e.g. active")
+	idleAfter := flag.Duration("idle-after", 0, "Only show keys unused for at least this long on watch-idle, e.g. 1h (default: every key the server reports idle)")
+	ttlWarn := flag.Duration("ttl-warn", 0, "Receive a TTL_WARNING when a matching key has less than this long to live on subscribe, e.g. 10s")
+	ack := flag.Bool("ack", false, "Acknowledge each event received on subscribe, so sets with -wait-for-ack wait for this subscriber")
+	waitForAck := flag.Bool("wait-for-ack", false, "Return from set only once every subscriber using -ack has acknowledged the change")
+	ackTimeout := flag.Duration("ack-timeout", 0, "Longest set waits for acknowledgments with -wait-for-ack (default: server default of 5s)")
+	streamTimeout := flag.Duration("stream-timeout", 0, "Have the server end a subscription after this long, e.g. 10m (default: no limit)")
+	valueContains := flag.String("value-contains", "", "Only receive SET events whose value contains this text on subscribe and wait-for")
+	waitTimeout := flag.Duration("timeout", 0, "How long wait-for waits for a change, at most 60s")
+	stateFile := flag.String("state-file", defaultStateFile, "File recording the last sequence seen by watch, so it resumes across restarts")
+	file := flag.String("file", "", "JSON lines of {\"key\",\"value\"} objects to import (default: stdin)")
+	fromPrefix := flag.String("from", "", "Key prefix migrate copies from, e.g. old:")
+	toPrefix := flag.String("to", "", "Key prefix migrate copies to, e.g. new:")
+	deleteSource := flag.Bool("delete-source", false, "Delete each key migrate copies, moving rather than copying the prefix")
+	progressEvery := flag.Int("progress-every", 1000, "Pairs between import progress updates on stderr, 0 disables them")
+	signingKeyFile := flag.String("signing-key-file", "", "File holding the HMAC key used to sign unary requests (default: unsigned)")
+	signingKeyID := flag.String("signing-key-id", signing.DefaultKeyID, "ID of the signing key, as configured on the server")
+	noReplay := flag.Bool("no-replay", false, "Watch live events only, without resuming from the last sequence seen")
+	tokenFile := flag.String("token-file", "", "File holding an API key or JWT sent as a bearer token, for a server with AUTH_PROVIDER (default: none)")
+	valueFormatName := flag.String("value-format", "text", "How values are shown and -value is read for set and append: text, hex, base64, or json")
+	output := flag.String("output", "", "Output format: text, json, or table (default: json for mget, snapshot, getmany, range, and watch, text otherwise)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
@@ -35,10 +89,65 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  # Set a value\n")
 		fmt.Fprintf(os.Stderr, "  %s -op=set -key=user:123 -value=\"John Doe\"\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Set a value that expires after 30 seconds\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=set -key=session:abc -value=token -ttl=30s\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Give an existing key a new TTL, or remove its TTL with -ttl=0\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=expire -key=session:abc -ttl=10m\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Append a line to a log-style value\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=append -key=log:app1 -value=\"started\" -separator=\",\"\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Change one field of a JSON value and remove another\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=patch -key=user:123 -value='{\"email\":\"a@example.com\",\"phone\":null}'\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Import JSON lines of key/value pairs, showing progress every 500 pairs\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=import -file=pairs.jsonl -progress-every=500\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Move every key under old: to the same key under new:\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=migrate -from=old: -to=new: -delete-source\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Get a value\n")
 		fmt.Fprintf(os.Stderr, "  %s -op=get -key=user:123\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Store and show a value as hex, or show a JSON value indented\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=set -key=blob:1 -value=0x68656c6c6f -value-format=hex\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -op=get -key=user:123 -value-format=json\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Get several values as JSON lines, one per key in request order\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=mget -keys=user:123,user:456\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Get several values as they all were at one instant\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=snapshot -keys=config:db,config:cache\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Show several values as an aligned table\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=mget -keys=user:123,user:456 -output=table\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Check whether a key exists without fetching its value\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=exists -key=user:123\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Show a key's size, TTL, version, access counts and matching subscriptions\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=inspect -key=user:123\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Label a key, then find keys by label\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=setmeta -key=user:123 -meta=owner=alice,env=production\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -op=search -meta=env=production\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Score players on a leaderboard, then list those scoring 100 or more\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=zadd -key=leaderboard -member=alice -score=120\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -op=zrange -key=leaderboard -min=100\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Show the 5 namespaces holding the most value bytes\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=prefix-stats -top-n=5 -output=table\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Get all matching pairs as JSON lines\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=getmany -pattern='user:*'\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Sum the integer values of every counter without fetching them\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=agg -pattern='counter:*' -agg=sum -number-type=int64\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Get pairs in key order between two keys as JSON lines\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=range -start=event:2024-01-01 -end=event:2024-02-01\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Count the keys between two keys, then delete them\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=deleterange -start=event:2024-01-01 -end=event:2024-02-01 -dry-run\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -op=deleterange -start=event:2024-01-01 -end=event:2024-02-01\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  # Subscribe to changes\n")
 		fmt.Fprintf(os.Stderr, "  %s -op=subscribe -pattern=user:\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Subscribe to deletes only\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=subscribe -pattern=user: -event-types=DELETE\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Subscribe to users whose JSON value has \"active\": true\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=subscribe -pattern=user: -value-filter=active\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Acknowledge changes, and set a value once every acknowledging subscriber has it\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=subscribe -pattern=order: -ack\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -op=set -key=order:1 -value=paid -wait-for-ack -ack-timeout=2s\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Watch changes as JSON lines, reconnecting and resuming automatically\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=watch -pattern=user:\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Report cache keys nobody has read or written for an hour, on a server with IDLE_KEY_AFTER set\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=watch-idle -pattern=cache: -idle-after=1h\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Wait up to a minute for a deploy to report completion, exiting 1 if it does not\n")
+		fmt.Fprintf(os.Stderr, "  %s -op=wait-for -key=deploy:status -value-contains=complete -timeout=60s\n\n", os.Args[0])
 	}
 
 	flag.Parse()
@@ -50,14 +159,59 @@ func main() {
 		os.Exit(1)
 	}
 
+	values, err := parseValueFormat(*valueFormatName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	out, err := newFormatter(*output, *operation, values)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Patches are JSON Merge Patch documents, never encoded
+	if *operation == "set" || *operation == "append" {
+		if *value, err = values.decode(*value); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -value: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if *tokenFile != "" {
+		token, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			log.Fatalf("Failed to read token: %v", err)
+		}
+		bearer := strings.TrimSpace(string(token))
+		dialOpts = append(dialOpts,
+			grpc.WithChainUnaryInterceptor(kvclient.BearerTokenInterceptor(bearer)),
+			grpc.WithChainStreamInterceptor(kvclient.BearerTokenStreamInterceptor(bearer)))
+	}
+
+	// Signed last so the signature covers the token
+	if *signingKeyFile != "" {
+		key, err := os.ReadFile(*signingKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to read signing key: %v", err)
+		}
+		key = []byte(strings.TrimSpace(string(key)))
+		dialOpts = append(dialOpts,
+			grpc.WithChainUnaryInterceptor(signing.NewRequestSigner(key, signing.WithKeyID(*signingKeyID))),
+			grpc.WithChainStreamInterceptor(signing.NewStreamRequestSigner(key, signing.WithKeyID(*signingKeyID))))
+	}
+
 	// Create gRPC connection
-	conn, err := grpc.NewClient(*serverAddr,grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(*serverAddr, dialOpts...)
 	if err != nil {
 		log.Fatalf("Failed to connect to server: %v", err)
 	}
 	defer conn.Close()
 
-	fmt.Printf("Connected to server: %s\n", *serverAddr)
+	// JSON and table output may be piped, keep stdout to results only
+	if _, ok := out.(TextFormatter); ok {
+		fmt.Printf("Connected to server: %s\n", *serverAddr)
+	}
 
 	// Create client
 	client := pb.NewKeyValueStoreClient(conn)
@@ -65,18 +219,80 @@ func main() {
 	// Execute operation
 	switch *operation {
 	case "get":
-		executeGet(client, *key)
+		executeGet(client, out, *key, *fieldMask, *ifNoneMatch)
+	case "mget":
+		executeMGet(client, out, *keys)
+	case "snapshot":
+		executeSnapshot(client, out, *keys)
+	case "range":
+		executeRange(client, out, *startKey, *endKey, *limit, *reverse)
+	case "random":
+		executeRandomKeys(client, out, *pattern, *count, *withReplacement)
+	case "deleterange":
+		executeDeleteRange(client, out, *startKey, *endKey, *dryRun)
+	case "exists":
+		executeExists(client, out, *key)
+	case "inspect":
+		executeInspect(client, out, *key)
+	case "setmeta":
+		executeSetMeta(client, out, *key, *meta)
+	case "getmeta":
+		executeGetMeta(client, out, *key)
+	case "search":
+		executeSearchMeta(client, out, *meta)
+	case "zadd":
+		executeZAdd(client, out, *key, *member, *score)
+	case "zrange":
+		executeZRange(client, out, *key, *minScore, *maxScore, *limit)
+	case "zrem":
+		executeZRem(client, out, *key, *member)
+	case "zscore":
+		executeZScore(client, out, *key, *member)
+	case "prefix-stats":
+		executePrefixStats(pb.NewAdminServiceClient(conn), out, *prefixes, *topN)
+	case "getmany":
+		executeGetMany(client, out, *pattern, *matchMode)
+	case "agg":
+		executeAggregate(client, out, *pattern, *matchMode, *aggregate, *numberType)
 	case "set":
-		executeSet(client, *key, *value)
+		executeSet(client, out, *key, *value, *ttl, *waitForAck, *ackTimeout)
+	case "expire":
+		executeExpire(client, out, *key, *ttl)
+	case "append":
+		executeAppend(client, out, *key, *value, *separator)
+	case "patch":
+		executePatch(client, out, *key, *value)
+	case "import":
+		executeImport(client, out, *file, *progressEvery)
+	case "migrate":
+		executeMigrate(client, out, *fromPrefix, *toPrefix, *deleteSource)
 	case "subscribe":
-		executeSubscribe(client, *pattern)
+		executeSubscribe(client, out, *pattern, *eventTypes, *valueFilter, *valueContains, *meta, *ttlWarn, *streamTimeout, *ack)
+	case "watch":
+		executeWatch(client, out, *pattern, *eventTypes, *stateFile, *noReplay)
+	case "watch-idle":
+		executeWatchIdle(client, out, *pattern, *idleAfter)
+	case "wait-for":
+		executeWaitFor(client, out, *key, *valueContains, *waitTimeout)
+	case "capabilities":
+		executeCapabilities(client, out)
 	default:
-		fmt.Fprintf(os.Stderr, "Error: invalid operation '%s'. Must be: get, set, or subscribe\n", *operation)
+		fmt.Fprintf(os.Stderr, "Error: invalid operation '%s'. Must be: get, mget, snapshot, getmany, agg, range, random, deleterange, exists, inspect, set, expire, append, patch, setmeta, getmeta, search, zadd, zrange, zrem, zscore, prefix-stats, import, migrate, subscribe, watch, watch-idle, wait-for, or capabilities\n", *operation)
 		os.Exit(1)
 	}
+	if err := flushOutput(out); err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
 }
 
-func executeGet(client pb.KeyValueStoreClient, key string) {
+// Write a result to stdout, exiting if stdout is gone
+func writeResult(out Formatter, v any) {
+	if err := out.Format(os.Stdout, v); err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+}
+
+func executeGet(client pb.KeyValueStoreClient, out Formatter, key, fieldMask, ifNoneMatch string) {
 	if key == "" {
 		log.Fatal("Error: -key flag is required for get operation")
 	}
@@ -84,83 +300,375 @@ func executeGet(client pb.KeyValueStoreClient, key string) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
-	resp, err := client.Get(ctx, &pb.GetRequest{Key: key})
+	resp, err := client.Get(ctx, &pb.GetRequest{Key: key, FieldMask: fieldMask, IfNoneMatch: ifNoneMatch})
 	if err != nil {
 		log.Fatalf("Get failed: %v", err)
 	}
 
-	if resp.Found {
-		fmt.Printf("Key found\n")
-		fmt.Printf("  Key:   %s\n", key)
-		fmt.Printf("  Value: %s\n", resp.Value)
-	} else {
-		fmt.Printf("Key not found: %s\n", key)
+	writeResult(out, getResultLine{Key: key, Value: resp.Value, Found: resp.Found, Version: resp.Version, ETag: resp.Etag, NotModified: resp.NotModified})
+}
+
+func executeExists(client pb.KeyValueStoreClient, out Formatter, key string) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for exists operation")
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := client.Exists(ctx, &pb.ExistsRequest{Key: key})
+	if err != nil {
+		log.Fatalf("Exists failed: %v", err)
+	}
+
+	writeResult(out, existsLine{Key: key, Exists: resp.Exists})
 }
 
-func executeSet(client pb.KeyValueStoreClient, key, value string) {
+func executeInspect(client pb.KeyValueStoreClient, out Formatter, key string) {
 	if key == "" {
-		log.Fatal("Error: -key flag is required for set operation")
+		log.Fatal("Error: -key flag is required for inspect operation")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
-	resp, err := client.Set(ctx, &pb.SetRequest{
-		Key:   key,
-		Value: value,
+	resp, err := client.InspectKey(ctx, &pb.InspectKeyRequest{Key: key})
+	if err != nil {
+		log.Fatalf("InspectKey failed: %v", err)
+	}
+
+	writeResult(out, inspectLine{
+		Key:                  key,
+		Exists:               resp.Exists,
+		ValueSizeBytes:       resp.ValueSizeBytes,
+		TTLRemainingMs:       resp.TtlRemainingMs,
+		Version:              resp.Version,
+		CreatedAtMs:          resp.CreatedAtMs,
+		LastModifiedMs:       resp.LastModifiedMs,
+		LastAccessedMs:       resp.LastAccessedMs,
+		GetCount:             resp.GetCount,
+		SetCount:             resp.SetCount,
+		SubscriberMatchCount: resp.SubscriberMatchCount,
+		StatsEnabled:         resp.StatsEnabled,
 	})
+}
+
+func executeCapabilities(client pb.KeyValueStoreClient, out Formatter) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := client.ServerCapabilities(ctx, &pb.CapabilitiesRequest{})
+	if err != nil {
+		log.Fatalf("Capabilities failed: %v", err)
+	}
+
+	writeResult(out, capabilitiesLine{Version: resp.Version, Features: resp.SupportedFeatures, MaxValueSizeMB: resp.MaxValueSizeMb})
+}
+
+func executeSet(client pb.KeyValueStoreClient, out Formatter, key, value string, ttl time.Duration, waitForAck bool, ackTimeout time.Duration) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for set operation")
+	}
+
+	// Leave room for the server to wait for acknowledgments
+	timeout := defaultTimeout
+	if waitForAck {
+		if ackTimeout > 0 {
+			timeout += ackTimeout
+		} else {
+			timeout += defaultAckTimeout
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req := &pb.SetRequest{
+		Key:          key,
+		Value:        value,
+		WaitForAck:   waitForAck,
+		AckTimeoutMs: ackTimeout.Milliseconds(),
+	}
+	if ttl > 0 {
+		ttlMs := ttl.Milliseconds()
+		req.TtlMs = &ttlMs
+	}
+
+	resp, err := client.Set(ctx, req)
 	if err != nil {
 		log.Fatalf("Set failed: %v", err)
 	}
 
-	if resp.Success {
-		fmt.Printf("Key stored successfully\n")
-		fmt.Printf("  Key:   %s\n", key)
-		fmt.Printf("  Value: %s\n", value)
-		fmt.Printf("  Message: %s\n", resp.Message)
-	} else {
-		fmt.Printf("Set failed: %s\n", resp.Message)
+	writeResult(out, setLine{
+		Key:         key,
+		Value:       value,
+		Success:     resp.Success,
+		Message:     resp.Message,
+		Version:     resp.Version,
+		ExpiresAtMs: resp.ExpiresAtMs,
+	})
+}
+
+func executeExpire(client pb.KeyValueStoreClient, out Formatter, key string, ttl time.Duration) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for expire operation")
+	}
+	if ttl < 0 {
+		log.Fatal("Error: -ttl cannot be negative")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := client.SetExpiry(ctx, &pb.SetExpiryRequest{Key: key, TtlMs: ttl.Milliseconds()})
+	if err != nil {
+		log.Fatalf("SetExpiry failed: %v", err)
+	}
+
+	writeResult(out, expiryLine{Key: key, Updated: resp.Updated, ExpiresAtMs: resp.ExpiresAtMs})
+}
+
+func executeAppend(client pb.KeyValueStoreClient, out Formatter, key, value, separator string) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for append operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := client.Append(ctx, &pb.AppendRequest{Key: key, Value: value, Separator: separator})
+	if err != nil {
+		log.Fatalf("Append failed: %v", err)
 	}
+
+	writeResult(out, appendLine{Key: key, NewLength: resp.NewLength})
 }
 
-func executeSubscribe(client pb.KeyValueStoreClient, pattern string) {
+func executePatch(client pb.KeyValueStoreClient, out Formatter, key, patch string) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for patch operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := client.MergePatch(ctx, &pb.MergePatchRequest{Key: key, Patch: patch})
+	if err != nil {
+		log.Fatalf("Patch failed: %v", err)
+	}
+
+	writeResult(out, patchLine{Key: key, Value: resp.NewValue, Version: resp.Version})
+}
+
+func executeImport(client pb.KeyValueStoreClient, out Formatter, path string, progressEvery int) {
+	input := os.Stdin
+	total := int64(0)
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to open import file: %v", err)
+		}
+		defer f.Close()
+		input = f
+		// A file can be read twice, so the progress bar knows its length
+		if progressEvery > 0 {
+			total = countPairs(f)
+		}
+	}
+
+	stream, err := client.Import(context.Background())
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	if err := stream.Send(&pb.ImportRequest{Request: &pb.ImportRequest_Config{Config: &pb.ImportConfig{ReportEvery: int32(progressEvery)}}}); err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	// Read replies while sending, or progress updates would stall the import
+	bar := newProgressBar(os.Stderr, "Importing", total)
+	type result struct {
+		complete *pb.ImportComplete
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		for {
+			reply, err := stream.Recv()
+			if err != nil {
+				done <- result{err: err}
+				return
+			}
+			if progress := reply.GetProgress(); progress != nil {
+				bar.update(progress.ProcessedCount, progress.FailedCount, progress.CurrentKey)
+				continue
+			}
+			done <- result{complete: reply.GetComplete()}
+			return
+		}
+	}()
+
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var pair struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &pair); err != nil {
+			log.Fatalf("Invalid pair on line %d: %v", line, err)
+		}
+		if err := stream.Send(&pb.ImportRequest{Request: &pb.ImportRequest_Pair{Pair: &pb.KeyValuePair{Key: pair.Key, Value: pair.Value}}}); err != nil {
+			// The real cause is reported by Recv
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed to read import input: %v", err)
+	}
+	stream.CloseSend()
+
+	res := <-done
+	bar.finish()
+	if res.err != nil {
+		log.Fatalf("Import failed: %v", res.err)
+	}
+	resp := res.complete
+
+	writeResult(out, importLine{Imported: resp.ImportedCount, Failed: resp.FailedCount, Errors: resp.Errors})
+}
+
+func executeSubscribe(client pb.KeyValueStoreClient, out Formatter, pattern, eventTypes, valueFilter, valueContains, meta string, ttlWarn, streamTimeout time.Duration, ack bool) {
 	if pattern == "" {
 		log.Fatal("Error: -pattern flag is required for subscribe operation")
 	}
 
+	allowedTypes, err := parseEventTypes(eventTypes)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	metaFilter, err := parseLabels(meta)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
 	ctx := context.Background()
 
 	stream, err := client.Subscribe(ctx, &pb.SubscribeRequest{
-		KeyPattern: pattern,
+		KeyPattern:         pattern,
+		AllowedTypes:       allowedTypes,
+		ValueFilter:        valueFilter,
+		ValueContains:      valueContains,
+		TtlWarnThresholdMs: ttlWarn.Milliseconds(),
+		StreamTimeoutMs:    streamTimeout.Milliseconds(),
+		AckMode:            ack,
+		MetaFilter:         metaFilter,
 	})
 	if err != nil {
 		log.Fatalf("Subscribe failed: %v", err)
 	}
 
-	fmt.Printf("Subscribed to pattern: %s\n", pattern)
-	fmt.Printf("Listening for changes (Ctrl+C to exit)\n\n")
+	var subscriptionID string
+	if ack {
+		header, err := stream.Header()
+		if err != nil {
+			log.Fatalf("Subscribe failed: %v", err)
+		}
+		if ids := header.Get(subscriptionIDHeader); len(ids) > 0 {
+			subscriptionID = ids[0]
+		}
+		if subscriptionID == "" {
+			log.Fatal("Subscribe failed: server did not return a subscription ID")
+		}
+	}
+
+	_, text := out.(TextFormatter)
+	if text {
+		fmt.Printf("Subscribed to pattern: %s\n", pattern)
+		fmt.Printf("Listening for changes (Ctrl+C to exit)\n\n")
+	}
 
 	// Receive events
 	for {
 		event, err := stream.Recv()
 		if err == io.EOF {
-			fmt.Println("Stream closed by server")
+			if text {
+				fmt.Println("Stream closed by server")
+			}
 			break
 		}
 		if err != nil {
 			log.Fatalf("Error receiving event: %v", err)
 		}
 
-		// Format timestamp
-		timestamp := time.UnixMilli(event.Timestamp).Format(time.RFC3339)
+		writeResult(out, newWatchEvent(event))
 
-		// Print event
-		fmt.Printf("─────────────────────────────────────────\n")
-		fmt.Printf("Event: %s\n", event.ChangeType)
-		fmt.Printf("  Key:       %s\n", event.Key)
-		fmt.Printf("  Value:     %s\n", event.Value)
-		fmt.Printf("  Timestamp: %s\n", timestamp)
-		fmt.Printf("\n")
+		// Leave before the server cuts the stream, watch reconnects instead
+		if event.ChangeType == pb.ChangeEvent_SERVER_SHUTDOWN {
+			if text {
+				fmt.Println("Server shutting down, use -op=watch to reconnect automatically")
+			}
+			return
+		}
+
+		// Acknowledge once the event is written, replayed and unsequenced
+		// events need none
+		if subscriptionID != "" && event.Sequence > 0 {
+			ackCtx, cancel := context.WithTimeout(ctx, defaultTimeout)
+			_, err := client.Acknowledge(ackCtx, &pb.AcknowledgeRequest{SubscriptionId: subscriptionID, Sequence: event.Sequence})
+			cancel()
+			if err != nil {
+				log.Printf("Acknowledge failed: %v", err)
+			}
+		}
+	}
+}
+
+func executeWaitFor(client pb.KeyValueStoreClient, out Formatter, key, valueContains string, timeout time.Duration) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for wait-for operation")
+	}
+	if timeout <= 0 {
+		log.Fatal("Error: -timeout flag is required for wait-for operation")
+	}
+
+	// The server answers when the timeout passes, leave room for the reply
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+defaultTimeout)
+	defer cancel()
+
+	resp, err := client.SubscribeOnce(ctx, &pb.SubscribeOnceRequest{
+		KeyPattern:    key,
+		ValueContains: valueContains,
+		TimeoutMs:     timeout.Milliseconds(),
+	})
+	if err != nil {
+		log.Fatalf("Wait failed: %v", err)
+	}
+	if resp.TimedOut {
+		fmt.Fprintf(os.Stderr, "Timed out after %s waiting for %s\n", timeout, key)
+		os.Exit(1)
+	}
+
+	writeResult(out, newWatchEvent(resp.Event))
+}
+
+// Parse a comma-separated list of change type names
+func parseEventTypes(list string) ([]pb.ChangeEvent_ChangeType, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var types []pb.ChangeEvent_ChangeType
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value, ok := pb.ChangeEvent_ChangeType_value[name]
+		if !ok || value == int32(pb.ChangeEvent_UNKNOWN) {
+			return nil, fmt.Errorf("invalid event type '%s'", name)
+		}
+		types = append(types, pb.ChangeEvent_ChangeType(value))
 	}
+	return types, nil
 }
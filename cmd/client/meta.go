@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executeSetMeta(kv pb.KeyValueStoreClient, out Formatter, key, meta string) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for setmeta operation")
+	}
+	labels, err := parseLabels(meta)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if _, err := kv.SetMeta(ctx, &pb.SetMetaRequest{Key: key, Meta: labels}); err != nil {
+		log.Fatalf("SetMeta failed: %v", err)
+	}
+
+	writeResult(out, metaLine{Key: key, Meta: labels})
+}
+
+func executeGetMeta(kv pb.KeyValueStoreClient, out Formatter, key string) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for getmeta operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.GetMeta(ctx, &pb.GetMetaRequest{Key: key})
+	if err != nil {
+		log.Fatalf("GetMeta failed: %v", err)
+	}
+
+	writeResult(out, metaLine{Key: key, Meta: resp.Meta})
+}
+
+func executeSearchMeta(kv pb.KeyValueStoreClient, out Formatter, meta string) {
+	labels, err := parseLabels(meta)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if len(labels) == 0 {
+		log.Fatal("Error: -meta flag is required for search operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.SearchByMeta(ctx, &pb.SearchMetaRequest{Labels: labels})
+	if err != nil {
+		log.Fatalf("SearchByMeta failed: %v", err)
+	}
+
+	for _, key := range resp.Keys {
+		writeResult(out, keyLine{Key: key})
+	}
+}
+
+// Parse comma-separated name=value labels
+func parseLabels(list string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if list == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(list, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid label '%s', expected name=value", pair)
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executeMGet(kv pb.KeyValueStoreClient, out Formatter, keys string) {
+	if keys == "" {
+		log.Fatal("Error: -keys flag is required for mget operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.MGet(ctx, &pb.MGetRequest{Keys: strings.Split(keys, ",")})
+	if err != nil {
+		log.Fatalf("MGet failed: %v", err)
+	}
+
+	for _, result := range resp.Results {
+		writeResult(out, getResultLine{
+			Key:     result.Key,
+			Value:   result.Value,
+			Found:   result.Found,
+			Version: result.Version,
+			Error:   result.Error,
+		})
+	}
+}
+
+func executeSnapshot(kv pb.KeyValueStoreClient, out Formatter, keys string) {
+	if keys == "" {
+		log.Fatal("Error: -keys flag is required for snapshot operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.GetSnapshot(ctx, &pb.GetSnapshotRequest{Keys: strings.Split(keys, ",")})
+	if err != nil {
+		log.Fatalf("GetSnapshot failed: %v", err)
+	}
+
+	for _, result := range resp.Results {
+		writeResult(out, getResultLine{
+			Key:     result.Key,
+			Value:   result.Value,
+			Found:   result.Found,
+			Version: result.Version,
+		})
+	}
+}
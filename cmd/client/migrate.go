@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executeMigrate(client pb.KeyValueStoreClient, out Formatter, from, to string, deleteSource bool) {
+	if from == "" || to == "" {
+		log.Fatal("Error: -from and -to flags are required for migrate operation")
+	}
+
+	stream, err := client.Migrate(context.Background(), &pb.MigrateRequest{
+		FromPrefix:   from,
+		ToPrefix:     to,
+		DeleteSource: deleteSource,
+	})
+	if err != nil {
+		log.Fatalf("Migrate failed: %v", err)
+	}
+
+	// The number of keys is only known once the server reports progress
+	bar := newProgressBar(os.Stderr, "Migrating", 0)
+	for {
+		reply, err := stream.Recv()
+		if err != nil {
+			bar.finish()
+			log.Fatalf("Migrate failed: %v", err)
+		}
+		if progress := reply.GetProgress(); progress != nil {
+			bar.total = progress.TotalEstimate
+			bar.update(progress.MigratedCount+progress.FailedCount, progress.FailedCount, progress.CurrentKey)
+			continue
+		}
+		bar.finish()
+		resp := reply.GetComplete()
+		writeResult(out, migrateLine{Migrated: resp.MigratedCount, Failed: resp.FailedCount, Errors: resp.Errors})
+		return
+	}
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Longest value shown in a table cell before it is cut short
+const maxTableValue = 60
+
+// Write one result of an operation, e.g. a pair or an event
+type Formatter interface {
+	Format(w io.Writer, v any) error
+}
+
+// Formatter for the -output flag, showing values in format values. An empty
+// name selects the operation's own default: JSON lines for mget, snapshot,
+// getmany, range and watch, text otherwise
+func newFormatter(name, operation string, values valueFormat) (Formatter, error) {
+	if name == "" {
+		switch operation {
+		case "mget", "snapshot", "getmany", "range", "zrange", "watch":
+			name = "json"
+		default:
+			name = "text"
+		}
+	}
+	switch name {
+	case "text":
+		return TextFormatter{values: values}, nil
+	case "json":
+		return JSONFormatter{values: values}, nil
+	case "table":
+		return &TableFormatter{values: values}, nil
+	default:
+		return nil, fmt.Errorf("invalid output '%s'. Must be: text, json, or table", name)
+	}
+}
+
+// Write anything a formatter still holds, such as the rows of a table
+func flushOutput(f Formatter) error {
+	if flusher, ok := f.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// Result of get, or of one key in mget or snapshot
+type getResultLine struct {
+	Key         string `json:"key"`
+	Value       string `json:"value,omitempty"`
+	Found       bool   `json:"found"`
+	Version     int64  `json:"version,omitempty"`
+	ETag        string `json:"etag,omitempty"`
+	NotModified bool   `json:"not_modified,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Single pair of getmany and range output
+type pairLine struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Change received by subscribe or watch
+type watchEvent struct {
+	Sequence  int64             `json:"sequence"`
+	Type      string            `json:"type"`
+	Key       string            `json:"key"`
+	Value     string            `json:"value,omitempty"`
+	StartKey  string            `json:"start_key,omitempty"`
+	EndKey    string            `json:"end_key,omitempty"`
+	Version   int64             `json:"version,omitempty"`
+	Timestamp int64             `json:"timestamp"`
+	Meta      map[string]string `json:"meta,omitempty"`
+	// New expiry of an EXPIRY_UPDATED or TTL_WARNING as Unix ms
+	ExpiresAtMs int64 `json:"expires_at_ms,omitempty"`
+	// Sorted set member of a ZADD or ZREM, and its new score on a ZADD
+	Member string  `json:"member,omitempty"`
+	Score  float64 `json:"score,omitempty"`
+	// Last read or write of a KEY_IDLE key as Unix ms
+	LastAccessedMs int64 `json:"last_accessed_ms,omitempty"`
+}
+
+func newWatchEvent(event *pb.ChangeEvent) watchEvent {
+	return watchEvent{
+		Sequence:       event.Sequence,
+		Type:           event.ChangeType.String(),
+		Key:            event.Key,
+		Value:          event.Value,
+		StartKey:       event.StartKey,
+		EndKey:         event.EndKey,
+		Version:        event.Version,
+		Timestamp:      event.Timestamp,
+		Meta:           event.Meta,
+		ExpiresAtMs:    event.ExpiresAtMs,
+		Member:         event.Member,
+		Score:          event.Score,
+		LastAccessedMs: event.LastAccessedMs,
+	}
+}
+
+// Labels of a key, from setmeta or getmeta
+type metaLine struct {
+	Key  string            `json:"key"`
+	Meta map[string]string `json:"meta"`
+}
+
+// Single key found by search
+type keyLine struct {
+	Key string `json:"key"`
+}
+
+// Member of a sorted set, one per line from zrange
+type scoredMemberLine struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// Usage of one prefix, from prefix-stats
+type prefixStatLine struct {
+	Prefix         string `json:"prefix"`
+	KeyCount       int64  `json:"key_count"`
+	ValueBytes     int64  `json:"total_value_size_bytes"`
+	KeyBytes       int64  `json:"total_key_size_bytes"`
+	OldestModified int64  `json:"oldest_modified_ms,omitempty"`
+	NewestModified int64  `json:"newest_modified_ms,omitempty"`
+}
+
+type zaddLine struct {
+	Key       string  `json:"key"`
+	Member    string  `json:"member"`
+	Score     float64 `json:"score"`
+	NewMember bool    `json:"new_member"`
+}
+
+type zremLine struct {
+	Key     string `json:"key"`
+	Member  string `json:"member"`
+	Removed bool   `json:"removed"`
+}
+
+type zscoreLine struct {
+	Key    string  `json:"key"`
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+	Found  bool    `json:"found"`
+}
+
+// Result of agg over the keys matching a pattern
+type aggregateLine struct {
+	Pattern      string  `json:"pattern"`
+	Operation    string  `json:"operation"`
+	Result       float64 `json:"result"`
+	MatchedCount int64   `json:"matched_count"`
+}
+
+type existsLine struct {
+	Key    string `json:"key"`
+	Exists bool   `json:"exists"`
+}
+
+type inspectLine struct {
+	Key                  string `json:"key"`
+	Exists               bool   `json:"exists"`
+	ValueSizeBytes       int64  `json:"value_size_bytes"`
+	TTLRemainingMs       int64  `json:"ttl_remaining_ms"`
+	Version              int64  `json:"version"`
+	CreatedAtMs          int64  `json:"created_at_ms"`
+	LastModifiedMs       int64  `json:"last_modified_ms"`
+	LastAccessedMs       int64  `json:"last_accessed_ms"`
+	GetCount             int64  `json:"get_count"`
+	SetCount             int64  `json:"set_count"`
+	SubscriberMatchCount int32  `json:"subscriber_match_count"`
+	StatsEnabled         bool   `json:"stats_enabled"`
+}
+
+type setLine struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Success     bool   `json:"success"`
+	Message     string `json:"message"`
+	Version     int64  `json:"version,omitempty"`
+	ExpiresAtMs int64  `json:"expires_at_ms,omitempty"`
+}
+
+type expiryLine struct {
+	Key         string `json:"key"`
+	Updated     bool   `json:"updated"`
+	ExpiresAtMs int64  `json:"expires_at_ms,omitempty"`
+}
+
+type appendLine struct {
+	Key       string `json:"key"`
+	NewLength int64  `json:"new_length"`
+}
+
+type patchLine struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Version int64  `json:"version"`
+}
+
+type importLine struct {
+	Imported int64    `json:"imported"`
+	Failed   int64    `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+type migrateLine struct {
+	Migrated int64    `json:"migrated"`
+	Failed   int64    `json:"failed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+type capabilitiesLine struct {
+	Version        string   `json:"version"`
+	Features       []string `json:"supported_features"`
+	MaxValueSizeMB int32    `json:"max_value_size_mb"`
+}
+
+type deleteRangeLine struct {
+	Deleted int64 `json:"deleted"`
+	DryRun  bool  `json:"dry_run,omitempty"`
+}
+
+// Human-readable output, one block per result
+type TextFormatter struct {
+	values valueFormat
+}
+
+func (t TextFormatter) Format(w io.Writer, v any) error {
+	var err error
+	switch r := t.values.apply(v).(type) {
+	case getResultLine:
+		switch {
+		case r.Error != "":
+			_, err = fmt.Fprintf(w, "Key failed: %s: %s\n", r.Key, r.Error)
+		case r.NotModified:
+			_, err = fmt.Fprintf(w, "Key not modified: %s\n", r.Key)
+		case r.Found:
+			_, err = fmt.Fprintf(w, "Key found\n  Key:   %s\n  Value: %s\n", r.Key, r.Value)
+		default:
+			_, err = fmt.Fprintf(w, "Key not found: %s\n", r.Key)
+		}
+	case pairLine:
+		_, err = fmt.Fprintf(w, "%s = %s\n", r.Key, r.Value)
+	case watchEvent:
+		var b strings.Builder
+		fmt.Fprintf(&b, "─────────────────────────────────────────\n")
+		fmt.Fprintf(&b, "Event: %s\n", r.Type)
+		switch r.Type {
+		case pb.ChangeEvent_DELETE_RANGE.String():
+			fmt.Fprintf(&b, "  Start:     %s\n", r.StartKey)
+			fmt.Fprintf(&b, "  End:       %s\n", r.EndKey)
+		case pb.ChangeEvent_EXPIRY_UPDATED.String():
+			fmt.Fprintf(&b, "  Key:       %s\n", r.Key)
+			fmt.Fprintf(&b, "  Expires:   %s\n", formatExpiry(r.ExpiresAtMs))
+		case pb.ChangeEvent_ZADD.String():
+			fmt.Fprintf(&b, "  Key:       %s\n", r.Key)
+			fmt.Fprintf(&b, "  Member:    %s\n", r.Member)
+			fmt.Fprintf(&b, "  Score:     %g\n", r.Score)
+		case pb.ChangeEvent_ZREM.String():
+			fmt.Fprintf(&b, "  Key:       %s\n", r.Key)
+			fmt.Fprintf(&b, "  Member:    %s\n", r.Member)
+		case pb.ChangeEvent_KEY_IDLE.String():
+			fmt.Fprintf(&b, "  Key:       %s\n", r.Key)
+			fmt.Fprintf(&b, "  Last used: %s\n", formatTime(r.LastAccessedMs))
+		default:
+			fmt.Fprintf(&b, "  Key:       %s\n", r.Key)
+			fmt.Fprintf(&b, "  Value:     %s\n", r.Value)
+		}
+		if len(r.Meta) > 0 {
+			fmt.Fprintf(&b, "  Meta:      %s\n", formatLabels(r.Meta))
+		}
+		fmt.Fprintf(&b, "  Timestamp: %s\n\n", time.Unix(0, r.Timestamp).Format(time.RFC3339Nano))
+		_, err = io.WriteString(w, b.String())
+	case metaLine:
+		_, err = fmt.Fprintf(w, "Key: %s\n  Meta: %s\n", r.Key, formatLabels(r.Meta))
+	case keyLine:
+		_, err = fmt.Fprintln(w, r.Key)
+	case scoredMemberLine:
+		_, err = fmt.Fprintf(w, "%s = %g\n", r.Member, r.Score)
+	case prefixStatLine:
+		_, err = fmt.Fprintf(w, "Prefix: %q\n  Keys:          %d\n  Value bytes:   %d\n  Key bytes:     %d\n  Oldest write:  %s\n  Newest write:  %s\n",
+			r.Prefix, r.KeyCount, r.ValueBytes, r.KeyBytes, formatTime(r.OldestModified), formatTime(r.NewestModified))
+	case zaddLine:
+		verb := "Score updated"
+		if r.NewMember {
+			verb = "Member added"
+		}
+		_, err = fmt.Fprintf(w, "%s\n  Key:    %s\n  Member: %s\n  Score:  %g\n", verb, r.Key, r.Member, r.Score)
+	case zremLine:
+		if r.Removed {
+			_, err = fmt.Fprintf(w, "Member removed: %s from %s\n", r.Member, r.Key)
+		} else {
+			_, err = fmt.Fprintf(w, "Member not found: %s in %s\n", r.Member, r.Key)
+		}
+	case zscoreLine:
+		if r.Found {
+			_, err = fmt.Fprintf(w, "Key: %s\n  Member: %s\n  Score:  %g\n", r.Key, r.Member, r.Score)
+		} else {
+			_, err = fmt.Fprintf(w, "Member not found: %s in %s\n", r.Member, r.Key)
+		}
+	case aggregateLine:
+		_, err = fmt.Fprintf(w, "%s of %d keys matching %s: %g\n", r.Operation, r.MatchedCount, r.Pattern, r.Result)
+	case existsLine:
+		if r.Exists {
+			_, err = fmt.Fprintf(w, "Key exists: %s\n", r.Key)
+		} else {
+			_, err = fmt.Fprintf(w, "Key not found: %s\n", r.Key)
+		}
+	case inspectLine:
+		if !r.Exists {
+			_, err = fmt.Fprintf(w, "Key not found: %s\n  Subscribers:   %d\n", r.Key, r.SubscriberMatchCount)
+			break
+		}
+		ttl := "none"
+		if r.TTLRemainingMs >= 0 {
+			ttl = (time.Duration(r.TTLRemainingMs) * time.Millisecond).String()
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "Key: %s\n", r.Key)
+		fmt.Fprintf(&b, "  Size:          %d bytes\n", r.ValueSizeBytes)
+		fmt.Fprintf(&b, "  TTL:           %s\n", ttl)
+		fmt.Fprintf(&b, "  Version:       %d\n", r.Version)
+		fmt.Fprintf(&b, "  Subscribers:   %d\n", r.SubscriberMatchCount)
+		if !r.StatsEnabled {
+			fmt.Fprintf(&b, "  Access stats:  not tracked by the server\n")
+		} else {
+			fmt.Fprintf(&b, "  Created:       %s\n", formatTime(r.CreatedAtMs))
+			fmt.Fprintf(&b, "  Last modified: %s\n", formatTime(r.LastModifiedMs))
+			fmt.Fprintf(&b, "  Last accessed: %s\n", formatTime(r.LastAccessedMs))
+			fmt.Fprintf(&b, "  Gets:          %d\n", r.GetCount)
+			fmt.Fprintf(&b, "  Sets:          %d\n", r.SetCount)
+		}
+		_, err = io.WriteString(w, b.String())
+	case setLine:
+		if !r.Success {
+			_, err = fmt.Fprintf(w, "Set failed: %s\n", r.Message)
+			break
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "Key stored successfully\n")
+		fmt.Fprintf(&b, "  Key:   %s\n", r.Key)
+		fmt.Fprintf(&b, "  Value: %s\n", r.Value)
+		fmt.Fprintf(&b, "  Message: %s\n", r.Message)
+		if r.ExpiresAtMs > 0 {
+			fmt.Fprintf(&b, "  Expires: %s\n", time.UnixMilli(r.ExpiresAtMs).Format(time.RFC3339))
+		}
+		_, err = io.WriteString(w, b.String())
+	case expiryLine:
+		if !r.Updated {
+			_, err = fmt.Fprintf(w, "Key already has no expiry: %s\n", r.Key)
+			break
+		}
+		_, err = fmt.Fprintf(w, "Expiry updated\n  Key:     %s\n  Expires: %s\n", r.Key, formatExpiry(r.ExpiresAtMs))
+	case appendLine:
+		_, err = fmt.Fprintf(w, "Value appended\n  Key:        %s\n  New length: %d\n", r.Key, r.NewLength)
+	case patchLine:
+		_, err = fmt.Fprintf(w, "Value patched\n  Key:   %s\n  Value: %s\n", r.Key, r.Value)
+	case importLine:
+		var b strings.Builder
+		fmt.Fprintf(&b, "Import complete\n")
+		fmt.Fprintf(&b, "  Imported: %d\n", r.Imported)
+		fmt.Fprintf(&b, "  Failed:   %d\n", r.Failed)
+		for _, msg := range r.Errors {
+			fmt.Fprintf(&b, "    %s\n", msg)
+		}
+		_, err = io.WriteString(w, b.String())
+	case migrateLine:
+		var b strings.Builder
+		fmt.Fprintf(&b, "Migrate complete\n")
+		fmt.Fprintf(&b, "  Migrated: %d\n", r.Migrated)
+		fmt.Fprintf(&b, "  Failed:   %d\n", r.Failed)
+		for _, msg := range r.Errors {
+			fmt.Fprintf(&b, "    %s\n", msg)
+		}
+		_, err = io.WriteString(w, b.String())
+	case deleteRangeLine:
+		if r.DryRun {
+			_, err = fmt.Fprintf(w, "Would delete %d keys\n", r.Deleted)
+		} else {
+			_, err = fmt.Fprintf(w, "Deleted %d keys\n", r.Deleted)
+		}
+	case capabilitiesLine:
+		maxValue := "unlimited"
+		if r.MaxValueSizeMB > 0 {
+			maxValue = fmt.Sprintf("%d MB", r.MaxValueSizeMB)
+		}
+		_, err = fmt.Fprintf(w, "Server capabilities\n  Version:   %s\n  Features:  %s\n  Max value: %s\n",
+			r.Version, strings.Join(r.Features, ", "), maxValue)
+	default:
+		_, err = fmt.Fprintf(w, "%v\n", v)
+	}
+	return err
+}
+
+// One JSON object per line, for scripts and jq
+type JSONFormatter struct {
+	values valueFormat
+}
+
+func (j JSONFormatter) Format(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(j.values.apply(v))
+}
+
+// Aligned columns with a header row. Rows are held until Flush so columns
+// fit every value, except events, which are written as they arrive
+type TableFormatter struct {
+	tw     *tabwriter.Writer
+	header string
+	values valueFormat
+}
+
+func (t *TableFormatter) Format(w io.Writer, v any) error {
+	var header, row string
+	stream := false
+	switch r := t.values.apply(v).(type) {
+	case getResultLine:
+		header = "KEY\tVALUE\tVERSION"
+		switch {
+		case r.Error != "":
+			row = fmt.Sprintf("%s\t%s\t-", tableCell(r.Key), "error: "+tableCell(r.Error))
+		case r.NotModified:
+			row = fmt.Sprintf("%s\t%s\t%d", tableCell(r.Key), "(not modified)", r.Version)
+		case r.Found:
+			row = fmt.Sprintf("%s\t%s\t%d", tableCell(r.Key), tableCell(r.Value), r.Version)
+		default:
+			row = fmt.Sprintf("%s\t%s\t-", tableCell(r.Key), "(not found)")
+		}
+	case pairLine:
+		header = "KEY\tVALUE"
+		row = fmt.Sprintf("%s\t%s", tableCell(r.Key), tableCell(r.Value))
+	case scoredMemberLine:
+		header = "MEMBER\tSCORE"
+		row = fmt.Sprintf("%s\t%g", tableCell(r.Member), r.Score)
+	case prefixStatLine:
+		header = "PREFIX\tKEYS\tVALUE BYTES\tKEY BYTES\tNEWEST WRITE"
+		// Keys without a namespace are grouped under the empty prefix
+		prefix := tableCell(r.Prefix)
+		if r.Prefix == "" {
+			prefix = `""`
+		}
+		row = fmt.Sprintf("%s\t%d\t%d\t%d\t%s", prefix, r.KeyCount, r.ValueBytes, r.KeyBytes, formatTime(r.NewestModified))
+	case watchEvent:
+		header = "TIME\tTYPE\tKEY\tVERSION\tVALUE"
+		key := r.Key
+		if r.Type == pb.ChangeEvent_DELETE_RANGE.String() {
+			key = r.StartKey + ".." + r.EndKey
+		}
+		version := "-"
+		if r.Version > 0 {
+			version = fmt.Sprint(r.Version)
+		}
+		value := r.Value
+		switch r.Type {
+		case pb.ChangeEvent_ZADD.String():
+			value = fmt.Sprintf("%s=%g", r.Member, r.Score)
+		case pb.ChangeEvent_ZREM.String():
+			value = r.Member
+		case pb.ChangeEvent_KEY_IDLE.String():
+			value = "last used " + formatTime(r.LastAccessedMs)
+		}
+		row = fmt.Sprintf("%s\t%s\t%s\t%s\t%s", time.Unix(0, r.Timestamp).Format("15:04:05.000"),
+			r.Type, tableCell(key), version, tableCell(value))
+		stream = true
+	default:
+		// Single results read fine as text
+		if err := t.Flush(); err != nil {
+			return err
+		}
+		return TextFormatter{values: t.values}.Format(w, v)
+	}
+
+	if t.tw == nil {
+		// Streamed rows cannot be measured ahead, so pad every column generously
+		minWidth := 0
+		if stream {
+			minWidth = 16
+		}
+		t.tw = tabwriter.NewWriter(w, minWidth, 4, 2, ' ', 0)
+	}
+	if header != t.header {
+		t.header = header
+		fmt.Fprintln(t.tw, header)
+	}
+	fmt.Fprintln(t.tw, row)
+	if stream {
+		return t.tw.Flush()
+	}
+	return nil
+}
+
+// Write the rows held so far
+func (t *TableFormatter) Flush() error {
+	if t.tw == nil {
+		return nil
+	}
+	return t.tw.Flush()
+}
+
+// Labels as sorted name=value pairs
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"="+value)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ", ")
+}
+
+// Keep a value on one line and within maxTableValue characters
+func tableCell(s string) string {
+	s = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`).Replace(s)
+	if utf8.RuneCountInString(s) <= maxTableValue {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:maxTableValue-3]) + "..."
+}
+
+// Time of an access as a timestamp, or never if there was none
+func formatTime(ms int64) string {
+	if ms == 0 {
+		return "never"
+	}
+	return time.UnixMilli(ms).Format(time.RFC3339)
+}
+
+// Expiry as a timestamp, or never for a key without one
+func formatExpiry(ms int64) string {
+	if ms == 0 {
+		return "never"
+	}
+	return time.UnixMilli(ms).Format(time.RFC3339)
+}
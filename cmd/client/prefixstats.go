@@ -0,0 +1,54 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"slices"
+	"strings"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executePrefixStats(admin pb.AdminServiceClient, out Formatter, prefixes string, topN int) {
+	if topN < 0 {
+		log.Fatal("Error: -top-n cannot be negative")
+	}
+	req := &pb.PrefixStatsRequest{}
+	if prefixes != "" {
+		req.Prefixes = strings.Split(prefixes, ",")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := admin.PrefixStats(ctx, req)
+	if err != nil {
+		log.Fatalf("PrefixStats failed: %v", err)
+	}
+
+	lines := make([]prefixStatLine, 0, len(resp.Stats))
+	for prefix, stat := range resp.Stats {
+		lines = append(lines, prefixStatLine{
+			Prefix:         prefix,
+			KeyCount:       stat.KeyCount,
+			ValueBytes:     stat.TotalValueSizeBytes,
+			KeyBytes:       stat.TotalKeySizeBytes,
+			OldestModified: stat.OldestModifiedMs,
+			NewestModified: stat.NewestModifiedMs,
+		})
+	}
+	// Largest first, so -top-n keeps the prefixes using the most memory
+	slices.SortFunc(lines, func(a, b prefixStatLine) int {
+		if c := cmp.Compare(b.ValueBytes, a.ValueBytes); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Prefix, b.Prefix)
+	})
+	if topN > 0 && len(lines) > topN {
+		lines = lines[:topN]
+	}
+	for _, line := range lines {
+		writeResult(out, line)
+	}
+}
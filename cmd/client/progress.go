@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Width of the filled part of the progress bar
+const progressBarWidth = 30
+
+// Import or migrate progress on stderr: a bar redrawn in place on a
+// terminal, plain lines otherwise
+type progressBar struct {
+	w        *os.File
+	label    string
+	total    int64
+	terminal bool
+	drawn    bool
+}
+
+// label names what is in progress, e.g. Importing. total is the expected
+// number of keys, 0 if unknown
+func newProgressBar(w *os.File, label string, total int64) *progressBar {
+	info, err := w.Stat()
+	return &progressBar{w: w, label: label, total: total, terminal: err == nil && info.Mode()&os.ModeCharDevice != 0}
+}
+
+func (p *progressBar) update(processed, failed int64, currentKey string) {
+	counts := fmt.Sprintf("%d processed, %d failed", processed, failed)
+	if p.total > 0 {
+		done := min(processed, p.total)
+		filled := int(done * progressBarWidth / p.total)
+		counts = fmt.Sprintf("[%s%s] %3d%% %d/%d, %d failed",
+			strings.Repeat("#", filled), strings.Repeat(".", progressBarWidth-filled),
+			done*100/p.total, processed, p.total, failed)
+	}
+
+	if !p.terminal {
+		fmt.Fprintf(p.w, "%s: %s, at %s\n", p.label, counts, currentKey)
+		return
+	}
+	// Clear the rest of the line in case the previous key was longer
+	fmt.Fprintf(p.w, "\r%s %s\033[K", counts, currentKey)
+	p.drawn = true
+}
+
+// End the bar's line so later output starts on a fresh one
+func (p *progressBar) finish() {
+	if p.drawn {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// Count the non-blank lines of f and rewind it
+func countPairs(f *os.File) int64 {
+	defer f.Seek(0, io.SeekStart)
+
+	var n int64
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			n++
+		}
+	}
+	if scanner.Err() != nil {
+		return 0
+	}
+	return n
+}
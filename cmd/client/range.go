@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executeRange(kv pb.KeyValueStoreClient, out Formatter, start, end string, limit int, reverse bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.Range(ctx, &pb.RangeRequest{
+		StartKey: start,
+		EndKey:   end,
+		Limit:    int32(limit),
+		Reverse:  reverse,
+	})
+	if err != nil {
+		log.Fatalf("Range failed: %v", err)
+	}
+
+	for _, pair := range resp.Pairs {
+		writeResult(out, pairLine{Key: pair.Key, Value: pair.Value})
+	}
+	if resp.Truncated {
+		log.Printf("Warning: more keys in range, showing the first %d", len(resp.Pairs))
+	}
+}
+
+func executeRandomKeys(kv pb.KeyValueStoreClient, out Formatter, prefix string, count int, withReplacement bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.RandomKeys(ctx, &pb.RandomKeysRequest{
+		Count:           int32(count),
+		Prefix:          prefix,
+		WithReplacement: withReplacement,
+	})
+	if err != nil {
+		log.Fatalf("RandomKeys failed: %v", err)
+	}
+
+	for _, key := range resp.Keys {
+		writeResult(out, keyLine{Key: key})
+	}
+}
+
+func executeDeleteRange(kv pb.KeyValueStoreClient, out Formatter, start, end string, dryRun bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.DeleteRange(ctx, &pb.DeleteRangeRequest{
+		StartKey: start,
+		EndKey:   end,
+		DryRun:   dryRun,
+	})
+	if err != nil {
+		log.Fatalf("DeleteRange failed: %v", err)
+	}
+
+	writeResult(out, deleteRangeLine{Deleted: resp.DeletedCount, DryRun: dryRun})
+}
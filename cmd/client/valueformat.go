@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// How values are shown, and how -value is read, selected by -value-format
+type valueFormat string
+
+const (
+	valueText   valueFormat = "text"
+	valueHex    valueFormat = "hex"
+	valueBase64 valueFormat = "base64"
+	valueJSON   valueFormat = "json"
+)
+
+func parseValueFormat(name string) (valueFormat, error) {
+	switch f := valueFormat(name); f {
+	case valueText, valueHex, valueBase64, valueJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid value format '%s'. Must be: text, hex, base64, or json", name)
+	}
+}
+
+// Render a stored value for display. JSON that does not parse is shown as is
+func (f valueFormat) encode(value string) string {
+	switch f {
+	case valueHex:
+		return "0x" + hex.EncodeToString([]byte(value))
+	case valueBase64:
+		return base64.StdEncoding.EncodeToString([]byte(value))
+	case valueJSON:
+		var b bytes.Buffer
+		if json.Indent(&b, []byte(value), "", "  ") == nil {
+			return b.String()
+		}
+	}
+	return value
+}
+
+// Turn -value into the value to store. Values are protobuf strings, so the
+// decoded bytes must still be valid UTF-8
+func (f valueFormat) decode(value string) (string, error) {
+	var raw []byte
+	var err error
+	switch f {
+	case valueHex:
+		digits := strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+		raw, err = hex.DecodeString(digits)
+	case valueBase64:
+		raw, err = base64.StdEncoding.DecodeString(value)
+	case valueJSON:
+		if !json.Valid([]byte(value)) {
+			return "", errors.New("value is not valid JSON")
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("value is not valid %s: %w", f, err)
+	}
+	if !utf8.Valid(raw) {
+		return "", errors.New("decoded value is not valid UTF-8, which the server cannot store")
+	}
+	return string(raw), nil
+}
+
+// Result with its values rendered in format f
+func (f valueFormat) apply(v any) any {
+	if f == "" || f == valueText {
+		return v
+	}
+	switch r := v.(type) {
+	case getResultLine:
+		if r.Found {
+			r.Value = f.encode(r.Value)
+		}
+		return r
+	case pairLine:
+		r.Value = f.encode(r.Value)
+		return r
+	case watchEvent:
+		if r.Value != "" {
+			r.Value = f.encode(r.Value)
+		}
+		return r
+	case setLine:
+		r.Value = f.encode(r.Value)
+		return r
+	case patchLine:
+		r.Value = f.encode(r.Value)
+		return r
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/amillerrr/distributed-kv-store/client"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executeWatch(kv pb.KeyValueStoreClient, out Formatter, pattern, eventTypes, stateFile string, noReplay bool) {
+	if pattern == "" {
+		log.Fatal("Error: -pattern flag is required for watch operation")
+	}
+
+	allowedTypes, err := parseEventTypes(eventTypes)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	opts := []client.ReliableOption{
+		client.WithGapHandler(func(requested, oldest int64) {
+			log.Printf("Warning: events after sequence %d may have been missed, resuming from %d", requested, oldest)
+		}),
+	}
+	if noReplay {
+		opts = append(opts, client.WithoutReplay())
+	} else if seq := readWatchState(stateFile); seq > 0 {
+		log.Printf("Resuming after sequence %d from %s", seq, stateFile)
+		opts = append(opts, client.WithStartSequence(seq))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	sub := client.NewReliableSubscriber(kv, &pb.SubscribeRequest{
+		KeyPattern:   pattern,
+		AllowedTypes: allowedTypes,
+	}, opts...)
+
+	err = sub.Run(ctx, func(event *pb.ChangeEvent) error {
+		if err := out.Format(os.Stdout, newWatchEvent(event)); err != nil {
+			return err
+		}
+		if noReplay {
+			return nil
+		}
+		return writeWatchState(stateFile, event.Sequence)
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Fatalf("Watch failed: %v", err)
+	}
+}
+
+// Last sequence recorded by a previous run, 0 if there is none
+func readWatchState(path string) int64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: ignoring unreadable state file %s: %v", path, err)
+		}
+		return 0
+	}
+	seq, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		log.Printf("Warning: ignoring malformed state file %s: %v", path, err)
+		return 0
+	}
+	return seq
+}
+
+// Record the last sequence seen, replacing the file so it is never half written
+func writeWatchState(path string, seq int64) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(seq, 10)+"\n"), 0o644); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func executeZAdd(kv pb.KeyValueStoreClient, out Formatter, key, member string, score float64) {
+	if key == "" || member == "" {
+		log.Fatal("Error: -key and -member flags are required for zadd operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.ZAdd(ctx, &pb.ZAddRequest{Key: key, Member: member, Score: score})
+	if err != nil {
+		log.Fatalf("ZAdd failed: %v", err)
+	}
+
+	writeResult(out, zaddLine{Key: key, Member: member, Score: score, NewMember: resp.NewMember})
+}
+
+func executeZRange(kv pb.KeyValueStoreClient, out Formatter, key, min, max string, limit int) {
+	if key == "" {
+		log.Fatal("Error: -key flag is required for zrange operation")
+	}
+	req := &pb.ZRangeRequest{Key: key, Limit: int32(limit)}
+	var err error
+	if req.MinScore, err = parseScoreBound("-min", min); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if req.MaxScore, err = parseScoreBound("-max", max); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.ZRange(ctx, req)
+	if err != nil {
+		log.Fatalf("ZRange failed: %v", err)
+	}
+
+	for _, m := range resp.Members {
+		writeResult(out, scoredMemberLine{Member: m.Member, Score: m.Score})
+	}
+}
+
+func executeZRem(kv pb.KeyValueStoreClient, out Formatter, key, member string) {
+	if key == "" || member == "" {
+		log.Fatal("Error: -key and -member flags are required for zrem operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.ZRem(ctx, &pb.ZRemRequest{Key: key, Member: member})
+	if err != nil {
+		log.Fatalf("ZRem failed: %v", err)
+	}
+
+	writeResult(out, zremLine{Key: key, Member: member, Removed: resp.Removed})
+}
+
+func executeZScore(kv pb.KeyValueStoreClient, out Formatter, key, member string) {
+	if key == "" || member == "" {
+		log.Fatal("Error: -key and -member flags are required for zscore operation")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resp, err := kv.ZScore(ctx, &pb.ZScoreRequest{Key: key, Member: member})
+	if err != nil {
+		log.Fatalf("ZScore failed: %v", err)
+	}
+
+	writeResult(out, zscoreLine{Key: key, Member: member, Score: resp.Score, Found: resp.Found})
+}
+
+// Parse a score bound, nil when empty for no bound. Accepts -inf and +inf
+func parseScoreBound(flagName, s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	score, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: must be a number", flagName, s)
+	}
+	return &score, nil
+}
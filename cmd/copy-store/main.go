@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	defaultBatchSize = 500
+	// Most pairs SetMulti accepts in one call
+	maxBatchSize = 1000
+	callTimeout  = 30 * time.Second
+	// Source changes held while the copy runs. The server keeps as many again
+	// aside if the tool falls behind reading them
+	followBuffer = 10000
+)
+
+func main() {
+	sourceAddr := flag.String("source", "", "Address of the server to copy from (host:port)")
+	destAddr := flag.String("dest", "", "Address of the server to copy to (host:port)")
+	prefix := flag.String("prefix", "", "Only copy keys starting with this prefix (default: all keys)")
+	overwrite := flag.Bool("overwrite", false, "Replace keys that already exist on the destination instead of skipping them")
+	dryRun := flag.Bool("dry-run", false, "Count the keys that would be copied and skipped without writing anything")
+	progress := flag.Bool("progress", false, "Log running counts after each batch")
+	batchSize := flag.Int("batch-size", defaultBatchSize, "Pairs read and written per call, at most 1000")
+	follow := flag.Duration("follow", 0, "After copying, keep replaying source changes under -prefix to the destination for this long, e.g. 30s (default: none)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s -source=host:port -dest=host:port [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Copy the keys of one server to another, e.g. before cutting clients over.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *sourceAddr == "" || *destAddr == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *batchSize < 1 || *batchSize > maxBatchSize {
+		log.Fatalf("Error: -batch-size must be between 1 and %d", maxBatchSize)
+	}
+	// Subscriptions need a non-empty pattern
+	if *follow > 0 && *prefix == "" {
+		log.Fatal("Error: -follow requires -prefix")
+	}
+	if *follow > 0 && *dryRun {
+		log.Fatal("Error: -follow cannot be combined with -dry-run")
+	}
+
+	source, err := grpc.NewClient(*sourceAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to source: %v", err)
+	}
+	defer source.Close()
+	dest, err := grpc.NewClient(*destAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to destination: %v", err)
+	}
+	defer dest.Close()
+
+	c := &copier{
+		src:       pb.NewKeyValueStoreClient(source),
+		dst:       pb.NewKeyValueStoreClient(dest),
+		overwrite: *overwrite,
+		dryRun:    *dryRun,
+		progress:  *progress,
+	}
+
+	// Subscribed before the copy starts so changes made during it are kept
+	var changes <-chan *pb.ChangeEvent
+	if *follow > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if changes, err = c.subscribe(ctx, *prefix); err != nil {
+			log.Fatalf("Failed to subscribe to source: %v", err)
+		}
+	}
+
+	err = c.copyAll(*prefix, *batchSize)
+	if err == nil && changes != nil {
+		log.Printf("Copy finished, replaying changes for %s", *follow)
+		c.replay(changes, *follow)
+	}
+
+	if c.dryRun {
+		log.Printf("Dry run: would copy %d keys, skip %d that already exist, %d failed", c.copied, c.skipped, c.failed)
+	} else {
+		log.Printf("Copied %d keys, skipped %d that already exist, %d failed, replayed %d changes", c.copied, c.skipped, c.failed, c.replayed)
+	}
+	if n := c.dropped.Load(); n > 0 {
+		log.Printf("Dropped %d source changes that did not fit the %d change buffer, the destination may be stale; rerun with -overwrite", n, followBuffer)
+	}
+	if err != nil {
+		log.Fatalf("Copy stopped: %v", err)
+	}
+	if c.failed > 0 || c.dropped.Load() > 0 {
+		os.Exit(1)
+	}
+}
+
+// Copies pairs from one server to another and counts the outcome
+type copier struct {
+	src, dst  pb.KeyValueStoreClient
+	overwrite bool
+	dryRun    bool
+	progress  bool
+
+	copied, skipped, failed, replayed int
+	// Changes received while the buffer was full, counted by the subscription
+	dropped atomic.Int64
+}
+
+// Copy every key under prefix in batches, paging through a consistent scan
+// so keys written meanwhile are neither skipped nor repeated. Reads do not
+// expose TTLs, so copied keys never expire
+func (c *copier) copyAll(prefix string, batchSize int) error {
+	var cursor string
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		resp, err := c.src.ConsistentScan(ctx, &pb.ConsistentScanRequest{Prefix: prefix, Limit: int32(batchSize), Cursor: cursor})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("scan source: %w", err)
+		}
+
+		c.copyBatch(resp.Pairs)
+		if c.progress {
+			log.Printf("Progress: %d copied, %d skipped, %d failed", c.copied, c.skipped, c.failed)
+		}
+		if resp.NextCursor == "" {
+			return nil
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+// Write one batch to the destination, leaving out keys it already has unless
+// overwriting. A failed call counts the whole batch as failed
+func (c *copier) copyBatch(pairs []*pb.KeyValuePair) {
+	if !c.overwrite && len(pairs) > 0 {
+		keys := make([]string, len(pairs))
+		for i, pair := range pairs {
+			keys[i] = pair.Key
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+		resp, err := c.dst.ExistsMany(ctx, &pb.ExistsManyRequest{Keys: keys})
+		cancel()
+		if err != nil {
+			log.Printf("Failed to check %d keys on destination: %v", len(pairs), err)
+			c.failed += len(pairs)
+			return
+		}
+
+		missing := pairs[:0]
+		for _, pair := range pairs {
+			if resp.Results[pair.Key] {
+				c.skipped++
+				continue
+			}
+			missing = append(missing, pair)
+		}
+		pairs = missing
+	}
+	if len(pairs) == 0 {
+		return
+	}
+	if c.dryRun {
+		c.copied += len(pairs)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	_, err := c.dst.SetMulti(ctx, &pb.SetMultiRequest{Pairs: pairs})
+	cancel()
+	if err != nil {
+		log.Printf("Failed to write %d keys from %q to %q: %v", len(pairs), pairs[0].Key, pairs[len(pairs)-1].Key, err)
+		c.failed += len(pairs)
+		return
+	}
+	c.copied += len(pairs)
+}
+
+// Collect every change to keys under prefix on the source until ctx is done.
+// The stream is always read so the server never drops changes silently;
+// those that do not fit the buffer are counted in dropped instead. The
+// channel is closed if the stream ends
+func (c *copier) subscribe(ctx context.Context, prefix string) (<-chan *pb.ChangeEvent, error) {
+	// No allowed types subscribes to all of them, so expiries and TTL changes
+	// are followed as well as writes
+	stream, err := c.src.Subscribe(ctx, &pb.SubscribeRequest{
+		KeyPattern: prefix,
+		MaxDlqSize: followBuffer,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan *pb.ChangeEvent, followBuffer)
+	go func() {
+		defer close(changes)
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+					log.Printf("Source subscription ended: %v", err)
+				}
+				return
+			}
+			select {
+			case changes <- event:
+			default:
+				if c.dropped.Add(1) == 1 {
+					log.Printf("Change buffer full, dropping source changes until replay catches up")
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// Apply source changes to the destination in order until window has passed
+// or the subscription ends. Changes are newer than the copy, so they replace
+// existing keys even without -overwrite
+func (c *copier) replay(changes <-chan *pb.ChangeEvent, window time.Duration) {
+	deadline := time.After(window)
+	for {
+		select {
+		case event, ok := <-changes:
+			if !ok {
+				return
+			}
+			if event.ChangeType == pb.ChangeEvent_SERVER_SHUTDOWN {
+				log.Printf("Source is shutting down, stopped replaying")
+				return
+			}
+			if err := c.apply(event); err != nil {
+				log.Printf("Failed to replay %s of %q: %v", event.ChangeType, event.Key, err)
+				c.failed++
+				continue
+			}
+			c.replayed++
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// Make one source change on the destination. An APPEND carries the full new
+// value, so it is written like a SET, and a key expiring on the source
+// arrives as a DELETE. Writes keep the TTL the source key has
+func (c *copier) apply(event *pb.ChangeEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+
+	switch event.ChangeType {
+	case pb.ChangeEvent_SET, pb.ChangeEvent_APPEND:
+		req := &pb.SetRequest{Key: event.Key, Value: event.Value}
+		if event.ExpiresAtMs > 0 {
+			ttlMs := max(time.Until(time.UnixMilli(event.ExpiresAtMs)).Milliseconds(), 1)
+			req.TtlMs = &ttlMs
+		}
+		_, err := c.dst.Set(ctx, req)
+		return err
+	case pb.ChangeEvent_DELETE:
+		_, err := c.dst.Delete(ctx, &pb.DeleteRequest{Key: event.Key})
+		return err
+	case pb.ChangeEvent_EXPIRY_UPDATED:
+		var ttlMs int64
+		if event.ExpiresAtMs > 0 {
+			ttlMs = max(time.Until(time.UnixMilli(event.ExpiresAtMs)).Milliseconds(), 1)
+		}
+		_, err := c.dst.SetExpiry(ctx, &pb.SetExpiryRequest{Key: event.Key, TtlMs: ttlMs})
+		return err
+	case pb.ChangeEvent_ZADD:
+		_, err := c.dst.ZAdd(ctx, &pb.ZAddRequest{Key: event.Key, Member: event.Member, Score: event.Score})
+		return err
+	case pb.ChangeEvent_ZREM:
+		_, err := c.dst.ZRem(ctx, &pb.ZRemRequest{Key: event.Key, Member: event.Member})
+		return err
+	default:
+		// A range may cover destination keys that never came from the source
+		return fmt.Errorf("not replayed, delete [%q, %q) on the destination by hand if needed", event.StartKey, event.EndKey)
+	}
+}
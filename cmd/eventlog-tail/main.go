@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/eventlog"
+)
+
+const defaultPollInterval = 500 * time.Millisecond
+
+func main() {
+	path := flag.String("path", "", "Event log base path as passed to the server via EVENT_LOG_PATH")
+	follow := flag.Bool("follow", true, "Keep printing new entries as they are written")
+	interval := flag.Duration("interval", defaultPollInterval, "How often to poll for new entries")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s -path=/var/log/kvstore/events.log [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *path == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if err := tail(*path, *follow, *interval); err != nil {
+		log.Fatalf("Failed to tail event log: %v", err)
+	}
+}
+
+// Print today's log, then poll for new entries and follow daily rotation
+func tail(base string, follow bool, interval time.Duration) error {
+	current := eventlog.PathForDay(base, time.Now())
+	file, err := os.Open(current)
+	if err != nil {
+		return err
+	}
+	defer func() { file.Close() }()
+
+	// Holds a trailing partial entry until the rest of it is written
+	var pending []byte
+	var format eventlog.Format
+	detected := false
+	for {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", current, err)
+		}
+		pending = append(pending, data...)
+		if !detected && len(pending) > 0 {
+			var header int
+			if format, header, err = eventlog.DetectFormat(pending); err != nil {
+				return fmt.Errorf("read %s: %w", current, err)
+			}
+			pending, detected = pending[header:], true
+		}
+		if detected {
+			pending = printEntries(format, pending)
+		}
+
+		if !follow {
+			return nil
+		}
+
+		// Switch once the writer has moved on to the next day's file
+		if next := eventlog.PathForDay(base, time.Now()); next != current {
+			if nextFile, err := os.Open(next); err == nil {
+				file.Close()
+				file, current, pending, detected = nextFile, next, nil, false
+				continue
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+// Print complete entries and return any unfinished remainder
+func printEntries(format eventlog.Format, data []byte) []byte {
+	for {
+		entry, n, err := eventlog.Decode(format, data)
+		if n == 0 {
+			return data
+		}
+		if err != nil {
+			fmt.Printf("malformed entry: %v\n", err)
+		} else {
+			printEntry(entry)
+		}
+		data = data[n:]
+	}
+}
+
+func printEntry(entry eventlog.Entry) {
+	ts := time.Unix(0, entry.Timestamp).Format(time.RFC3339Nano)
+	caller := entry.Caller
+	if caller == "" {
+		caller = "-"
+	}
+	if entry.Value != "" {
+		fmt.Printf("%-30s  %-7s  %-22s  %s = %s\n", ts, entry.Op, caller, entry.Key, entry.Value)
+	} else {
+		fmt.Printf("%-30s  %-7s  %-22s  %s\n", ts, entry.Op, caller, entry.Key)
+	}
+}
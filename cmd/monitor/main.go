@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const defaultServerAddr = "localhost:50051"
+
+// Move the cursor home and clear the screen
+const clearScreen = "\033[H\033[2J"
+
+func main() {
+	serverAddr := flag.String("server", defaultServerAddr, "Server address (host:port)")
+	interval := flag.Duration("interval", time.Second, "Time between updates, at least 100ms")
+	metrics := flag.String("metrics", "", "Comma-separated metrics to show: key_count, subscriber_count, hot_keys, memory, latency (default: all)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Live dashboard of a server's stats. While running, type a command and press Enter:\n")
+		fmt.Fprintf(os.Stderr, "  interval 500ms          change the update interval\n")
+		fmt.Fprintf(os.Stderr, "  metrics hot_keys,memory change the metrics shown\n")
+		fmt.Fprintf(os.Stderr, "  quit                    exit\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	first, err := parseCommand(fmt.Sprintf("interval %s", *interval))
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if *metrics != "" {
+		cmd, err := parseCommand("metrics " + *metrics)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		first.Metrics = cmd.Metrics
+	}
+
+	conn, err := grpc.NewClient(*serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	stream, err := pb.NewAdminServiceClient(conn).MonitorStream(ctx)
+	if err != nil {
+		log.Fatalf("Monitor failed: %v", err)
+	}
+	if err := stream.Send(first); err != nil {
+		log.Fatalf("Monitor failed: %v", err)
+	}
+
+	// Forward typed commands while updates render
+	status := make(chan string, 1)
+	go readCommands(os.Stdin, stream, status, stop)
+
+	lastStatus := ""
+	for {
+		update, err := stream.Recv()
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Fatalf("Monitor failed: %v", err)
+		}
+		select {
+		case lastStatus = <-status:
+		default:
+		}
+		render(os.Stdout, *serverAddr, update, lastStatus)
+	}
+}
+
+// Send each valid command line to the server, reporting the outcome on status
+func readCommands(r io.Reader, stream pb.AdminService_MonitorStreamClient, status chan string, quit func()) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == "quit" || line == "q" {
+			quit()
+			return
+		}
+
+		msg := "ok: " + line
+		cmd, err := parseCommand(line)
+		if err == nil {
+			err = stream.Send(cmd)
+		}
+		if err != nil {
+			msg = "error: " + err.Error()
+		}
+		// Keep only the latest outcome
+		select {
+		case <-status:
+		default:
+		}
+		status <- msg
+	}
+}
+
+// Parse "interval <duration>" or "metrics <name>,..."
+func parseCommand(line string) (*pb.MonitorCommand, error) {
+	verb, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch verb {
+	case "interval":
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval '%s'", arg)
+		}
+		if d < 100*time.Millisecond {
+			return nil, fmt.Errorf("interval must be at least 100ms")
+		}
+		return &pb.MonitorCommand{TickIntervalMs: d.Milliseconds()}, nil
+	case "metrics":
+		cmd := &pb.MonitorCommand{}
+		for _, name := range strings.Split(arg, ",") {
+			name = strings.ToUpper(strings.TrimSpace(name))
+			value, ok := pb.MonitorMetric_value["METRIC_"+name]
+			if !ok || value == int32(pb.MonitorMetric_METRIC_UNSPECIFIED) {
+				return nil, fmt.Errorf("invalid metric '%s'", strings.ToLower(name))
+			}
+			cmd.Metrics = append(cmd.Metrics, pb.MonitorMetric(value))
+		}
+		return cmd, nil
+	default:
+		return nil, fmt.Errorf("unknown command '%s', expected interval, metrics or quit", verb)
+	}
+}
+
+// Redraw the dashboard for one update
+func render(w io.Writer, server string, u *pb.MonitorUpdate, status string) {
+	var b strings.Builder
+	b.WriteString(clearScreen)
+	fmt.Fprintf(&b, "kvstore monitor  %s  %s  every %s\n\n", server,
+		time.UnixMilli(u.TimestampMs).Format(time.TimeOnly), time.Duration(u.TickIntervalMs)*time.Millisecond)
+
+	shown := func(metric pb.MonitorMetric) bool {
+		return len(u.Metrics) == 0 || slices.Contains(u.Metrics, metric)
+	}
+
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	if shown(pb.MonitorMetric_METRIC_KEY_COUNT) {
+		fmt.Fprintf(tw, "Keys\t%d\n", u.KeyCount)
+	}
+	if shown(pb.MonitorMetric_METRIC_SUBSCRIBER_COUNT) {
+		fmt.Fprintf(tw, "Subscribers\t%d\n", u.SubscriberCount)
+	}
+	if shown(pb.MonitorMetric_METRIC_MEMORY) {
+		fmt.Fprintf(tw, "Heap\t%s in use, %s reserved\n", formatBytes(u.HeapAllocBytes), formatBytes(u.HeapSysBytes))
+	}
+	if shown(pb.MonitorMetric_METRIC_LATENCY) {
+		fmt.Fprintf(tw, "Requests\t%d since last update\n", u.RequestCount)
+		fmt.Fprintf(tw, "P99 latency\t%.3f ms\n", u.P99LatencyMs)
+	}
+	tw.Flush()
+
+	if shown(pb.MonitorMetric_METRIC_HOT_KEYS) {
+		b.WriteString("\nHot keys\n")
+		if len(u.HotKeys) == 0 {
+			b.WriteString("  (none, or key stats disabled on the server)\n")
+		}
+		tw = tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+		for _, hk := range u.HotKeys {
+			fmt.Fprintf(tw, "  %s\t%d\n", hk.Key, hk.AccessCount)
+		}
+		tw.Flush()
+	}
+
+	if status != "" {
+		fmt.Fprintf(&b, "\n%s\n", status)
+	}
+	b.WriteString("\n> ")
+	io.WriteString(w, b.String())
+}
+
+// Human-readable byte count, e.g. 12.3 MiB
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
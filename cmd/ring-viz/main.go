@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/amillerrr/distributed-kv-store/internal/ring"
+)
+
+// Letters marking each node's share of the ring, in node name order
+const nodeMarks = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+func main() {
+	nodes := flag.String("nodes", "", "Comma-separated node names on the ring, e.g. kv-1,kv-2,kv-3")
+	vnodes := flag.Int("vnodes", ring.DefaultVNodes, "Virtual nodes per node")
+	add := flag.String("add", "", "Comma-separated nodes joining, to show which ranges move")
+	remove := flag.String("remove", "", "Comma-separated nodes leaving, to show which ranges move")
+	radius := flag.Int("radius", 10, "Radius of the drawn ring in lines")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Draw the consistent hash ring used by client.ShardedClient.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  # Show how three nodes share the ring\n")
+		fmt.Fprintf(os.Stderr, "  %s -nodes=kv-1,kv-2,kv-3\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Show what moves when a fourth node joins\n")
+		fmt.Fprintf(os.Stderr, "  %s -nodes=kv-1,kv-2,kv-3 -add=kv-4\n", os.Args[0])
+	}
+	flag.Parse()
+
+	names := splitList(*nodes)
+	if len(names) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: -nodes flag is required\n\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *radius < 2 {
+		log.Fatal("Error: -radius must be at least 2")
+	}
+
+	old := ring.New(*vnodes)
+	old.Add(names...)
+	current := old.Clone()
+	current.Add(splitList(*add)...)
+	current.Remove(splitList(*remove)...)
+
+	// Marks follow every node seen so one node keeps its letter in both rings
+	all := old.Clone()
+	all.Add(current.Nodes()...)
+	marks := make(map[string]byte)
+	for i, node := range all.Nodes() {
+		if i < len(nodeMarks) {
+			marks[node] = nodeMarks[i]
+		} else {
+			marks[node] = '?'
+		}
+	}
+
+	changed := *add != "" || *remove != ""
+	if changed {
+		fmt.Println("Before:")
+		draw(old, nil, marks, *radius)
+		printShares(old, marks)
+		fmt.Println()
+		fmt.Println("After (lower case ranges changed owner):")
+	}
+	var moved []ring.Assignment
+	if changed {
+		_, moved = ring.Diff(old, current)
+	}
+	draw(current, moved, marks, *radius)
+	printShares(current, marks)
+	if changed {
+		fmt.Printf("\nMoved: %.1f%% of the ring\n", 100*share(moved))
+	}
+}
+
+// Draw the ring as a circle of node letters, hash 0 at the top and rising
+// clockwise. Positions inside a moved range are drawn in lower case
+func draw(r *ring.Ring, moved []ring.Assignment, marks map[string]byte, radius int) {
+	// Characters are about twice as tall as wide, so stretch horizontally
+	width, height := 4*radius+1, 2*radius+1
+	grid := make([][]byte, height)
+	for y := range grid {
+		grid[y] = []byte(strings.Repeat(" ", width))
+	}
+
+	steps := 16 * radius
+	for i := range steps {
+		turn := float64(i) / float64(steps)
+		angle := 2*math.Pi*turn - math.Pi/2
+		x := radius*2 + int(math.Round(2*float64(radius)*math.Cos(angle)))
+		y := radius + int(math.Round(float64(radius)*math.Sin(angle)))
+
+		h := uint64(turn * math.MaxUint64)
+		mark := marks[ownerOf(r, h)]
+		for _, a := range moved {
+			if a.Contains(h) {
+				mark = mark | 0x20
+				break
+			}
+		}
+		grid[y][x] = mark
+	}
+
+	label := fmt.Sprintf("%d nodes", len(r.Nodes()))
+	copy(grid[radius][2*radius-len(label)/2:], label)
+	fmt.Println(strings.Repeat(" ", 2*radius) + "0")
+	for _, line := range grid {
+		fmt.Println(strings.TrimRight(string(line), " "))
+	}
+}
+
+// Node owning hash h
+func ownerOf(r *ring.Ring, h uint64) string {
+	for _, a := range r.Assignments() {
+		if a.Contains(h) {
+			return a.Node
+		}
+	}
+	return ""
+}
+
+// Print each node's letter and share of the ring
+func printShares(r *ring.Ring, marks map[string]byte) {
+	byNode := make(map[string][]ring.Assignment)
+	for _, a := range r.Assignments() {
+		byNode[a.Node] = append(byNode[a.Node], a)
+	}
+	for _, node := range r.Nodes() {
+		fmt.Printf("  %c  %-20s %5.1f%%  %d ranges\n", marks[node], node, 100*share(byNode[node]), len(byNode[node]))
+	}
+}
+
+// Fraction of the ring covered by assignments
+func share(assignments []ring.Assignment) float64 {
+	var total float64
+	for _, a := range assignments {
+		if a.Start == a.End {
+			return 1
+		}
+		// Unsigned subtraction wraps, which sizes a range crossing zero too
+		total += float64(a.End - a.Start)
+	}
+	return total / math.MaxUint64
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
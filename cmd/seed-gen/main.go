@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	defaultServerAddr = "localhost:50051"
+	// Pairs fetched per Range call, the server's maximum
+	pageSize    = 10000
+	pageTimeout = 30 * time.Second
+)
+
+func main() {
+	serverAddr := flag.String("server", defaultServerAddr, "Server address (host:port)")
+	out := flag.String("out", "", "Seed file to write (default: stdout)")
+	start := flag.String("start", "", "First key exported (default: all keys)")
+	end := flag.String("end", "", "First key not exported (default: no upper bound)")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Export a server's keys as a seed file for SEED_FILE.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	conn, err := grpc.NewClient(*serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create seed file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	n, err := export(pb.NewKeyValueStoreClient(conn), bw, *start, *end)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		log.Fatalf("Export failed after %d keys: %v", n, err)
+	}
+	log.Printf("Exported %d keys", n)
+}
+
+// Write every pair in [start, end) as a seed entry, paging through Range in
+// key order. Reads do not expose TTLs, so entries never expire
+func export(kv pb.KeyValueStoreClient, w io.Writer, start, end string) (int, error) {
+	enc := json.NewEncoder(w)
+	count := 0
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), pageTimeout)
+		resp, err := kv.Range(ctx, &pb.RangeRequest{StartKey: start, EndKey: end, Limit: pageSize})
+		cancel()
+		if err != nil {
+			return count, err
+		}
+
+		for _, pair := range resp.Pairs {
+			if err := enc.Encode(service.SeedEntry{Key: pair.Key, Value: pair.Value}); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if !resp.Truncated || len(resp.Pairs) == 0 {
+			return count, nil
+		}
+		// Smallest key after the last one returned
+		start = resp.Pairs[len(resp.Pairs)-1].Key + "\x00"
+	}
+}
@@ -2,147 +2,98 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
-
-	pb "github.com/amillerrr/distributed-kv-store/proto"
-	"github.com/amillerrr/distributed-kv-store/internal/service"
-)
-
-const (
-	defaultGRPCPort = "50051"
-	defaultHTTPPort = "8080"
+	"github.com/amillerrr/distributed-kv-store/internal/circuitbreaker"
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/server"
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	"github.com/amillerrr/distributed-kv-store/internal/version"
 )
 
 func main() {
+	// Load and validate environment config before anything listens
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		var problems config.ConfigErrors
+		if errors.As(err, &problems) {
+			for _, problem := range problems {
+				fmt.Fprintf(os.Stderr, "  %s\n", problem)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "  %s\n", err)
+		}
+		os.Exit(2)
+	}
+
 	// Initialize JSON logger
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+		Level: cfg.LogLevel,
 	}))
 	slog.SetDefault(logger)
 
-	// Get environment config
-	grpcPort := getEnv("GRPC_PORT", defaultGRPCPort)
-	httpPort := getEnv("HTTP_PORT", defaultHTTPPort)
-
-	slog.Info("starting distributed KV store server", "grpc_port", grpcPort, "http_port", httpPort)
+	buildInfo := version.Get()
+	slog.Info("starting distributed KV store server", "grpc_port", cfg.GRPCPort, "http_port", cfg.HTTPPort, "dynamic_port", cfg.DynamicPort, "version", buildInfo.Version, "commit", buildInfo.Commit)
 
-	// Create TCP listener for gRPC
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcPort))
+	store, err := newStorage(cfg)
 	if err != nil {
-		slog.Error("failed to listen", "error", err, "port", grpcPort)
+		slog.Error("failed to open storage", "error", err, "backend", cfg.StorageBackend)
 		os.Exit(1)
 	}
 
-	// Create gRPC server
-	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(loggingInterceptor))
-
-	// Register the KV store service
-	kvStore := service.NewKVStoreService()
-	pb.RegisterKeyValueStoreServer(grpcServer, kvStore)
-
-	// Register reflection service
-	reflection.Register(grpcServer)
-
-	// Create HTTP server for health checks
-	healthMux := http.NewServeMux()
-	healthMux.HandleFunc("/health/live", livenessHandler)
-	healthMux.HandleFunc("/health/ready", readinessHandler(kvStore))
-
-	httpServer := &http.Server{
-		Addr: fmt.Sprintf(":%s", httpPort),
-		Handler: healthMux,
+	opts := []server.ServerOption{server.WithConfig(cfg), server.WithStorage(store)}
+	if cfg.DynamicPort {
+		opts = append(opts, server.WithDynamicPort())
 	}
-
-	// Channel to listen for errors
-	serverErrors := make(chan error, 1)
-	
-	go func() {
-		slog.Info("gRPC server listening", "address", lis.Addr().String())
-		serverErrors <- grpcServer.Serve(lis)
-	}()
-
-	go func() {
-		slog.Info("HTTP health server listening", "address", httpServer.Addr)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			serverErrors <- err
-		}
-	}()
+	if cfg.ServiceName != "" {
+		opts = append(opts, server.WithServiceRegistration(cfg.ServiceName, cfg.AdvertiseHost))
+	}
+	if len(cfg.CORSOrigins) > 0 {
+		opts = append(opts, server.WithCORSOrigins(cfg.CORSOrigins))
+	}
+	srv := server.New(opts...)
 
 	// Signal handling for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-
-	// Block until signal or error received
-	select {
-	case err := <-serverErrors:
-		slog.Error("server error", "error", err)
-	case sig := <-sigChan:
+	go func() {
+		sig := <-sigChan
 		slog.Info("received shutdown signal", "signal", sig.String())
-	}
-
-	slog.Info("initiating graceful shutdown")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+		cancel()
+	}()
 
-	if err := httpServer.Shutdown(ctx); err != nil {
-		slog.Error("HTTP server shutdown error", "error", err)
-	} else {
-		slog.Info("HTTP server stopped gracefully")
+	if err := srv.Start(ctx); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
 	}
-
-	grpcServer.GracefulStop()
-	slog.Info("gRPC server stopped gracefully")
-	slog.Info("shutdown complete")
 }
 
-// Log incoming gRPC requests
-func loggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
-	slog.Info("gRPC request", "method", info.FullMethod)
-
-	resp, err := handler(ctx, req)
-
-	if err != nil {
-		slog.Error("gRPC request failed", "method", info.FullMethod, "error", err)
+// Open the configured storage backend, behind a circuit breaker if enabled
+func newStorage(cfg *config.ServerConfig) (storage.Backend, error) {
+	var backend storage.Backend
+	if cfg.StorageBackend == config.StorageTiered {
+		tiered, err := storage.NewTiered(cfg.TieredColdPath, cfg.TieredHotKeys)
+		if err != nil {
+			return nil, err
+		}
+		backend = tiered
 	} else {
-		slog.Info("gRPC request completed", "method", info.FullMethod)
-	}
-
-	return resp, err
-}
-
-// Indicate whether the service is running
-func livenessHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"alive"}`))
-}
-
-// Indicate if the service is ready
-func readinessHandler(kvStore *service.KVStoreService) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// In production, might check db connections, dependant service availability, or resource availability
-		// Report ready since using in-memory for test
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ready"}`))
+		backend = storage.NewMemory()
 	}
-}
-
-// Retrieve environment variable or use default
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if cfg.CircuitBreakerEnabled {
+		backend = circuitbreaker.Wrap(backend,
+			circuitbreaker.WithSlowCallThreshold(cfg.CircuitBreakerSlowCall),
+			circuitbreaker.WithFailureThreshold(cfg.CircuitBreakerThreshold),
+			circuitbreaker.WithWindow(cfg.CircuitBreakerWindow),
+			circuitbreaker.WithOpenTimeout(cfg.CircuitBreakerOpenTimeout),
+		)
 	}
-	return defaultValue
+	return backend, nil
 }
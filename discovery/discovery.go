@@ -0,0 +1,180 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// URL scheme handled by Resolver, e.g. kv:///my-service
+const Scheme = "kv"
+
+const (
+	// Backoff between attempts to re-subscribe, doubling up to the max
+	initialBackoff = time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// Returned by watch when registrations must be re-read from scratch
+var errRangeDeleted = errors.New("registrations were range deleted")
+
+// Returned by watch when the registry server announces it is shutting down
+var errRegistryShutdown = errors.New("registry server shutting down")
+
+// Key holding the address of one instance of a service
+func InstanceKey(serviceName, instanceID string) string {
+	return Prefix(serviceName) + instanceID
+}
+
+// Prefix shared by the keys of every instance of a service
+func Prefix(serviceName string) string {
+	return serviceName + "/"
+}
+
+// Resolve kv:///<service> targets to the addresses instances registered in the
+// store kv is connected to, following registrations as they change. A target
+// without a service name resolves serviceName
+func Resolver(kv pb.KeyValueStoreClient, serviceName string) resolver.Builder {
+	return &builder{kv: kv, serviceName: serviceName}
+}
+
+type builder struct {
+	kv          pb.KeyValueStoreClient
+	serviceName string
+}
+
+func (b *builder) Scheme() string {
+	return Scheme
+}
+
+func (b *builder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	serviceName := strings.TrimPrefix(target.Endpoint(), "/")
+	if serviceName == "" {
+		serviceName = b.serviceName
+	}
+	if serviceName == "" {
+		return nil, fmt.Errorf("discovery: target %q names no service", target.URL.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &kvResolver{
+		kv:        b.kv,
+		cc:        cc,
+		prefix:    Prefix(serviceName),
+		cancel:    cancel,
+		instances: make(map[string]string),
+	}
+	r.wg.Add(1)
+	go r.run(ctx)
+	return r, nil
+}
+
+// Follows one service's registrations and reports them to a gRPC channel
+type kvResolver struct {
+	kv     pb.KeyValueStoreClient
+	cc     resolver.ClientConn
+	prefix string
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// Address by registration key, owned by run
+	instances map[string]string
+}
+
+// Addresses are pushed as registrations change, there is nothing to poll
+func (r *kvResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *kvResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+// Watch registrations until closed, re-subscribing with backoff on failure
+func (r *kvResolver) run(ctx context.Context) {
+	defer r.wg.Done()
+
+	backoff := initialBackoff
+	for {
+		err := r.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, errRangeDeleted) || errors.Is(err, errRegistryShutdown) {
+			backoff = initialBackoff
+			continue
+		}
+		slog.Warn("discovery watch failed, retrying", "prefix", r.prefix, "backoff", backoff, "error", err)
+		r.cc.ReportError(err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Subscribe to the service's keys, replaying current registrations first
+func (r *kvResolver) watch(ctx context.Context) error {
+	// Ends the stream when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := r.kv.Subscribe(ctx, &pb.SubscribeRequest{
+		KeyPattern:     r.prefix,
+		ReplayExisting: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	// The replay restates every live registration
+	clear(r.instances)
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		switch event.ChangeType {
+		case pb.ChangeEvent_SET, pb.ChangeEvent_APPEND:
+			if r.instances[event.Key] == event.Value {
+				continue
+			}
+			r.instances[event.Key] = event.Value
+		case pb.ChangeEvent_DELETE:
+			if _, ok := r.instances[event.Key]; !ok {
+				continue
+			}
+			delete(r.instances, event.Key)
+		case pb.ChangeEvent_DELETE_RANGE:
+			// The event does not say which keys went, so start over
+			return errRangeDeleted
+		case pb.ChangeEvent_SERVER_SHUTDOWN:
+			return errRegistryShutdown
+		default:
+			continue
+		}
+		r.update()
+	}
+}
+
+// Report the current addresses to the channel
+func (r *kvResolver) update() {
+	addrs := make([]resolver.Address, 0, len(r.instances))
+	for _, addr := range r.instances {
+		addrs = append(addrs, resolver.Address{Addr: addr})
+	}
+	if err := r.cc.UpdateState(resolver.State{Addresses: addrs}); err != nil {
+		slog.Debug("discovery state update rejected", "prefix", r.prefix, "error", err)
+	}
+}
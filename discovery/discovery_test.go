@@ -0,0 +1,158 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Client of a registry store served over bufconn
+func newRegistry(t *testing.T) pb.KeyValueStoreClient {
+	t.Helper()
+	svc := service.NewKVStoreService(service.WithConfig(config.Default()))
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterKeyValueStoreServer(srv, svc)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+		svc.Close()
+	})
+	return pb.NewKeyValueStoreClient(conn)
+}
+
+// ClientConn recording the addresses the resolver reports
+type fakeClientConn struct {
+	resolver.ClientConn
+	states chan []string
+}
+
+func (c *fakeClientConn) UpdateState(state resolver.State) error {
+	addrs := make([]string, 0, len(state.Addresses))
+	for _, a := range state.Addresses {
+		addrs = append(addrs, a.Addr)
+	}
+	slices.Sort(addrs)
+	c.states <- addrs
+	return nil
+}
+
+func (c *fakeClientConn) ReportError(error) {}
+
+// Build a resolver for target and close it when the test ends
+func buildResolver(t *testing.T, kv pb.KeyValueStoreClient, target string) *fakeClientConn {
+	t.Helper()
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatalf("parse %s: %v", target, err)
+	}
+	cc := &fakeClientConn{states: make(chan []string, 100)}
+	r, err := Resolver(kv, "fallback").Build(resolver.Target{URL: *u}, cc, resolver.BuildOptions{})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	t.Cleanup(r.Close)
+	return cc
+}
+
+// Wait for the resolver to report exactly want
+func waitAddrs(t *testing.T, cc *fakeClientConn, want ...string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-cc.states:
+			if slices.Equal(got, want) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("resolver never reported %q", want)
+		}
+	}
+}
+
+func set(t *testing.T, kv pb.KeyValueStoreClient, key, value string) {
+	t.Helper()
+	if _, err := kv.Set(context.Background(), &pb.SetRequest{Key: key, Value: value}); err != nil {
+		t.Fatalf("Set %s: %v", key, err)
+	}
+}
+
+func TestResolverFollowsRegistrations(t *testing.T) {
+	kv := newRegistry(t)
+	set(t, kv, InstanceKey("orders", "a"), "10.0.0.1:50051")
+
+	cc := buildResolver(t, kv, "kv:///orders")
+	waitAddrs(t, cc, "10.0.0.1:50051")
+
+	set(t, kv, InstanceKey("orders", "b"), "10.0.0.2:50051")
+	waitAddrs(t, cc, "10.0.0.1:50051", "10.0.0.2:50051")
+
+	// Another service's registrations are not reported
+	set(t, kv, InstanceKey("billing", "a"), "10.0.1.1:50051")
+	if _, err := kv.Delete(context.Background(), &pb.DeleteRequest{Key: InstanceKey("orders", "a")}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	select {
+	case got := <-cc.states:
+		if !slices.Equal(got, []string{"10.0.0.2:50051"}) {
+			t.Errorf("after Delete, resolved %q, want only 10.0.0.2:50051", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no update after Delete")
+	}
+}
+
+func TestResolverRereadsAfterRangeDelete(t *testing.T) {
+	kv := newRegistry(t)
+	set(t, kv, InstanceKey("orders", "a"), "10.0.0.1:50051")
+	set(t, kv, InstanceKey("orders", "b"), "10.0.0.2:50051")
+
+	cc := buildResolver(t, kv, "kv:///orders")
+	waitAddrs(t, cc, "10.0.0.1:50051", "10.0.0.2:50051")
+
+	_, err := kv.DeleteRange(context.Background(), &pb.DeleteRangeRequest{
+		StartKey: InstanceKey("orders", "a"),
+		EndKey:   InstanceKey("orders", "b"),
+	})
+	if err != nil {
+		t.Fatalf("DeleteRange: %v", err)
+	}
+	waitAddrs(t, cc, "10.0.0.2:50051")
+}
+
+func TestResolverUsesDefaultService(t *testing.T) {
+	kv := newRegistry(t)
+	set(t, kv, InstanceKey("fallback", "a"), "10.0.0.1:50051")
+
+	cc := buildResolver(t, kv, "kv:///")
+	waitAddrs(t, cc, "10.0.0.1:50051")
+}
+
+func TestResolverRequiresService(t *testing.T) {
+	u, _ := url.Parse("kv:///")
+	_, err := Resolver(newRegistry(t), "").Build(resolver.Target{URL: *u}, &fakeClientConn{}, resolver.BuildOptions{})
+	if err == nil {
+		t.Error("Build succeeded for a target naming no service")
+	}
+}
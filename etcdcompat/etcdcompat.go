@@ -0,0 +1,181 @@
+// Package etcdcompat offers a subset of the etcd clientv3 API on top of the
+// KV store so code written against etcd can migrate by swapping imports.
+//
+// Supported: Put, Get, Delete, Watch and Txn with optimistic locking. Leases,
+// cluster and maintenance operations, auth and historical reads are not.
+// ModRevision is the store's event sequence number, reported only when the
+// server keeps an event history, and Version is the per-key version the store
+// checks for compare-and-set.
+package etcdcompat
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Returned for etcd features with no equivalent in the KV store
+var ErrUnsupported = errors.New("etcdcompat: operation not supported")
+
+const (
+	// Partition delimiter, prefix reads and deletes must end with it
+	prefixDelimiter = ":"
+
+	// Must match the header the server sets on Set and Get
+	sequenceHeader = "x-kvstore-sequence"
+)
+
+// etcd-style client backed by a KeyValueStore connection
+type Client struct {
+	kv pb.KeyValueStoreClient
+}
+
+// Wrap a KV store client in the etcd-compatible API
+func NewEtcdCompatClient(kvClient pb.KeyValueStoreClient) *Client {
+	return &Client{kv: kvClient}
+}
+
+// Stored key with its revision metadata
+type KeyValue struct {
+	Key   []byte
+	Value []byte
+	// Sequence number of the key's last write, 0 when the server keeps no
+	// event history
+	ModRevision int64
+	// Per-key version, compared by Txn
+	Version int64
+}
+
+type PutResponse struct {
+	// ModRevision of the key after the write
+	Revision int64
+}
+
+type GetResponse struct {
+	Kvs   []*KeyValue
+	Count int64
+}
+
+type DeleteResponse struct {
+	Deleted int64
+}
+
+// Modify a single operation
+type OpOption func(*op)
+
+type op struct {
+	prefix bool
+}
+
+// Treat the key as a prefix, e.g. Get(ctx, "user:", WithPrefix())
+func WithPrefix() OpOption {
+	return func(o *op) { o.prefix = true }
+}
+
+func applyOpts(opts []OpOption) op {
+	var o op
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Store a value unconditionally
+func (c *Client) Put(ctx context.Context, key, val string, opts ...OpOption) (*PutResponse, error) {
+	if applyOpts(opts).prefix {
+		return nil, ErrUnsupported
+	}
+	var header metadata.MD
+	if _, err := c.kv.Set(ctx, &pb.SetRequest{Key: key, Value: val}, grpc.Header(&header)); err != nil {
+		return nil, err
+	}
+	return &PutResponse{Revision: sequence(header)}, nil
+}
+
+// Retrieve a key, or every key in a colon-delimited partition with WithPrefix
+func (c *Client) Get(ctx context.Context, key string, opts ...OpOption) (*GetResponse, error) {
+	if applyOpts(opts).prefix {
+		if !strings.HasSuffix(key, prefixDelimiter) {
+			return nil, ErrUnsupported
+		}
+		resp, err := c.kv.Partition(ctx, &pb.PartitionRequest{Prefix: key})
+		if err != nil {
+			return nil, err
+		}
+		out := &GetResponse{}
+		for _, pair := range resp.Pairs {
+			// Partitions include the bare prefix key, etcd prefixes do not
+			if !strings.HasPrefix(pair.Key, key) {
+				continue
+			}
+			out.Kvs = append(out.Kvs, &KeyValue{Key: []byte(pair.Key), Value: []byte(pair.Value)})
+		}
+		out.Count = int64(len(out.Kvs))
+		return out, nil
+	}
+
+	var header metadata.MD
+	resp, err := c.kv.Get(ctx, &pb.GetRequest{Key: key}, grpc.Header(&header))
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Found {
+		return &GetResponse{}, nil
+	}
+	return &GetResponse{
+		Kvs: []*KeyValue{{
+			Key:         []byte(key),
+			Value:       []byte(resp.Value),
+			ModRevision: sequence(header),
+			Version:     resp.Version,
+		}},
+		Count: 1,
+	}, nil
+}
+
+// Remove a key, or every key in a colon-delimited partition with WithPrefix
+func (c *Client) Delete(ctx context.Context, key string, opts ...OpOption) (*DeleteResponse, error) {
+	if applyOpts(opts).prefix {
+		if !strings.HasSuffix(key, prefixDelimiter) {
+			return nil, ErrUnsupported
+		}
+		resp, err := c.kv.DeletePartition(ctx, &pb.DeletePartitionRequest{Prefix: key})
+		if err != nil {
+			return nil, err
+		}
+		return &DeleteResponse{Deleted: resp.DeletedCount}, nil
+	}
+
+	resp, err := c.kv.Delete(ctx, &pb.DeleteRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Deleted {
+		return &DeleteResponse{Deleted: 1}, nil
+	}
+	return &DeleteResponse{}, nil
+}
+
+// Sequence number the server sent in a response header, 0 if none
+func sequence(header metadata.MD) int64 {
+	values := header.Get(sequenceHeader)
+	if len(values) == 0 {
+		return 0
+	}
+	seq, _ := strconv.ParseInt(values[0], 10, 64)
+	return seq
+}
+
+// Reports whether a status error means a CAS precondition failed
+func isConflict(err error) bool {
+	code := status.Code(err)
+	return code == codes.Aborted || code == codes.AlreadyExists
+}
@@ -0,0 +1,343 @@
+package etcdcompat
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func newTestClient(t *testing.T, opts ...service.Option) (*Client, pb.KeyValueStoreClient) {
+	t.Helper()
+	svc := service.NewKVStoreService(append([]service.Option{service.WithConfig(config.Default())}, opts...)...)
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterKeyValueStoreServer(srv, svc)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+		svc.Close()
+	})
+	kv := pb.NewKeyValueStoreClient(conn)
+	return NewEtcdCompatClient(kv), kv
+}
+
+func TestPutGetDelete(t *testing.T) {
+	cli, _ := newTestClient(t)
+	ctx := context.Background()
+
+	first, err := cli.Put(ctx, "config:flag", "off")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	second, err := cli.Put(ctx, "config:flag", "on")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if first.Revision <= 0 || second.Revision <= first.Revision {
+		t.Errorf("revisions %d then %d, want increasing sequence numbers", first.Revision, second.Revision)
+	}
+
+	resp, err := cli.Get(ctx, "config:flag")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if resp.Count != 1 || string(resp.Kvs[0].Value) != "on" {
+		t.Fatalf("Get = %+v, want config:flag=on", resp.Kvs)
+	}
+	if resp.Kvs[0].ModRevision != second.Revision {
+		t.Errorf("ModRevision = %d, want %d from the last Put", resp.Kvs[0].ModRevision, second.Revision)
+	}
+	if resp.Kvs[0].Version <= 0 {
+		t.Errorf("Version = %d, want the key's version", resp.Kvs[0].Version)
+	}
+
+	if resp, err := cli.Get(ctx, "config:missing"); err != nil || resp.Count != 0 {
+		t.Errorf("Get missing key = %+v, %v, want no keys", resp, err)
+	}
+
+	del, err := cli.Delete(ctx, "config:flag")
+	if err != nil || del.Deleted != 1 {
+		t.Fatalf("Delete = %+v, %v, want 1 deleted", del, err)
+	}
+	if del, err := cli.Delete(ctx, "config:flag"); err != nil || del.Deleted != 0 {
+		t.Errorf("second Delete = %+v, %v, want 0 deleted", del, err)
+	}
+}
+
+func TestPrefixGetAndDelete(t *testing.T) {
+	cli, _ := newTestClient(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"user:1", "user:2", "order:1"} {
+		if _, err := cli.Put(ctx, key, "v"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	resp, err := cli.Get(ctx, "user:", WithPrefix())
+	if err != nil {
+		t.Fatalf("Get prefix: %v", err)
+	}
+	if resp.Count != 2 {
+		t.Errorf("Get prefix returned %d keys, want 2", resp.Count)
+	}
+	if _, err := cli.Get(ctx, "user", WithPrefix()); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Get prefix without delimiter: err = %v, want ErrUnsupported", err)
+	}
+
+	del, err := cli.Delete(ctx, "user:", WithPrefix())
+	if err != nil || del.Deleted != 2 {
+		t.Errorf("Delete prefix = %+v, %v, want 2 deleted", del, err)
+	}
+	if resp, _ := cli.Get(ctx, "order:1"); resp.Count != 1 {
+		t.Error("Delete prefix removed a key outside the prefix")
+	}
+}
+
+func TestModRevisionNeedsEventHistory(t *testing.T) {
+	cli, _ := newTestClient(t, service.WithEventHistory(0))
+	ctx := context.Background()
+
+	put, err := cli.Put(ctx, "config:flag", "on")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if put.Revision != 0 {
+		t.Errorf("Revision = %d without history, want 0", put.Revision)
+	}
+	resp, err := cli.Get(ctx, "config:flag")
+	if err != nil || resp.Count != 1 {
+		t.Fatalf("Get = %+v, %v", resp, err)
+	}
+	if resp.Kvs[0].ModRevision != 0 || resp.Kvs[0].Version <= 0 {
+		t.Errorf("ModRevision, Version = %d, %d, want 0 and the key's version", resp.Kvs[0].ModRevision, resp.Kvs[0].Version)
+	}
+}
+
+func TestTxnPutsWhenVersionMatches(t *testing.T) {
+	cli, _ := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := cli.Put(ctx, "config:flag", "off"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	current, err := cli.Get(ctx, "config:flag")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	resp, err := cli.Txn(ctx).
+		If(Compare(Version("config:flag"), "=", current.Kvs[0].Version)).
+		Then(OpPut("config:flag", "on")).
+		Commit()
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if !resp.Succeeded || len(resp.Responses) != 1 || resp.Responses[0].Put == nil {
+		t.Fatalf("Commit = %+v, want a successful Put", resp)
+	}
+
+	after, _ := cli.Get(ctx, "config:flag")
+	if string(after.Kvs[0].Value) != "on" {
+		t.Errorf("value = %q, want on", after.Kvs[0].Value)
+	}
+	if after.Kvs[0].ModRevision != resp.Responses[0].Put.Revision {
+		t.Errorf("ModRevision = %d, want %d from the transaction", after.Kvs[0].ModRevision, resp.Responses[0].Put.Revision)
+	}
+}
+
+func TestTxnRunsElseOnConflict(t *testing.T) {
+	cli, _ := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := cli.Put(ctx, "config:flag", "off"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	stale, _ := cli.Get(ctx, "config:flag")
+	if _, err := cli.Put(ctx, "config:flag", "other"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	resp, err := cli.Txn(ctx).
+		If(Compare(Version("config:flag"), "=", stale.Kvs[0].Version)).
+		Then(OpPut("config:flag", "on")).
+		Else(OpGet("config:flag")).
+		Commit()
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if resp.Succeeded {
+		t.Fatal("Commit succeeded against a stale version")
+	}
+	if len(resp.Responses) != 1 || resp.Responses[0].Get == nil || string(resp.Responses[0].Get.Kvs[0].Value) != "other" {
+		t.Errorf("Else responses = %+v, want the current value", resp.Responses)
+	}
+}
+
+func TestTxnCreatesOnlyAbsentKeys(t *testing.T) {
+	cli, _ := newTestClient(t)
+	ctx := context.Background()
+
+	create := func() *TxnResponse {
+		t.Helper()
+		resp, err := cli.Txn(ctx).
+			If(Compare(CreateRevision("lock:job"), "=", 0)).
+			Then(OpPut("lock:job", "owner")).
+			Commit()
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		return resp
+	}
+	if !create().Succeeded {
+		t.Fatal("create of an absent key failed")
+	}
+	if create().Succeeded {
+		t.Error("create of an existing key succeeded")
+	}
+}
+
+func TestTxnRejectsUnsupportedComparisons(t *testing.T) {
+	cli, _ := newTestClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		cmps []Cmp
+		then []Op
+	}{
+		{"mod revision", []Cmp{Compare(ModRevision("k"), "=", 1)}, []Op{OpPut("k", "v")}},
+		{"greater than", []Cmp{Compare(Version("k"), ">", 1)}, []Op{OpPut("k", "v")}},
+		{"non-integer value", []Cmp{Compare(Version("k"), "=", "1")}, []Op{OpPut("k", "v")}},
+		{"create revision not zero", []Cmp{Compare(CreateRevision("k"), "=", 5)}, []Op{OpPut("k", "v")}},
+		{"two comparisons", []Cmp{Compare(Version("k"), "=", 0), Compare(Version("j"), "=", 0)}, []Op{OpPut("k", "v")}},
+		{"get", []Cmp{Compare(Version("k"), "=", 0)}, []Op{OpGet("k")}},
+		{"other key", []Cmp{Compare(Version("k"), "=", 0)}, []Op{OpPut("j", "v")}},
+		{"two operations", []Cmp{Compare(Version("k"), "=", 0)}, []Op{OpPut("k", "v"), OpPut("k", "w")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cli.Txn(ctx).If(tt.cmps...).Then(tt.then...).Commit()
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("err = %v, want ErrUnsupported", err)
+			}
+		})
+	}
+
+	if resp, _ := cli.Get(ctx, "k"); resp.Count != 0 {
+		t.Error("an unsupported transaction wrote its Put")
+	}
+}
+
+// Wait until the server has registered a watch covering key
+func waitWatching(t *testing.T, kv pb.KeyValueStoreClient, key string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := kv.InspectKey(context.Background(), &pb.InspectKeyRequest{Key: key})
+		if err != nil {
+			t.Fatalf("InspectKey: %v", err)
+		}
+		if resp.SubscriberMatchCount > 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no watch registered for %s", key)
+}
+
+func nextWatch(t *testing.T, ch WatchChan) WatchResponse {
+	t.Helper()
+	select {
+	case wresp, ok := <-ch:
+		if !ok {
+			t.Fatal("watch channel closed")
+		}
+		return wresp
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a watch response")
+		return WatchResponse{}
+	}
+}
+
+func TestWatchReportsPutsAndDeletes(t *testing.T) {
+	cli, kv := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := cli.Watch(ctx, "user:1")
+	waitWatching(t, kv, "user:1")
+
+	put, err := cli.Put(ctx, "user:1", "alice")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// A longer key matching the subscription pattern is not part of an exact watch
+	if _, err := cli.Put(ctx, "user:10", "bob"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := cli.Delete(ctx, "user:1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	wresp := nextWatch(t, ch)
+	ev := wresp.Events[0]
+	if ev.Type != EventTypePut || string(ev.Kv.Key) != "user:1" || string(ev.Kv.Value) != "alice" {
+		t.Errorf("first event = %v %s=%s, want PUT user:1=alice", ev.Type, ev.Kv.Key, ev.Kv.Value)
+	}
+	if ev.Kv.ModRevision != put.Revision || ev.Kv.Version <= 0 {
+		t.Errorf("ModRevision, Version = %d, %d, want %d and the key's version", ev.Kv.ModRevision, ev.Kv.Version, put.Revision)
+	}
+
+	wresp = nextWatch(t, ch)
+	if ev := wresp.Events[0]; ev.Type != EventTypeDelete || string(ev.Kv.Key) != "user:1" {
+		t.Errorf("second event = %v %s, want DELETE user:1", ev.Type, ev.Kv.Key)
+	}
+}
+
+func TestWatchWithPrefix(t *testing.T) {
+	cli, kv := newTestClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch := cli.Watch(ctx, "user:", WithPrefix())
+	waitWatching(t, kv, "user:")
+
+	for _, key := range []string{"user:1", "user:2"} {
+		if _, err := cli.Put(ctx, key, "v"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+	for _, want := range []string{"user:1", "user:2"} {
+		if ev := nextWatch(t, ch).Events[0]; string(ev.Kv.Key) != want {
+			t.Errorf("event for %s, want %s", ev.Kv.Key, want)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("watch sent a response after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch channel not closed after cancel")
+	}
+}
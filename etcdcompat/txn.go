@@ -0,0 +1,216 @@
+package etcdcompat
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+type compareTarget int
+
+const (
+	targetModRevision compareTarget = iota
+	targetVersion
+	targetCreateRevision
+)
+
+// Condition on a key's revision, built with ModRevision, Version or CreateRevision
+type Cmp struct {
+	key    string
+	target compareTarget
+	result string
+	value  int64
+}
+
+// Compare the key's last modification revision. Txn cannot check it
+// atomically and returns ErrUnsupported, compare Version instead
+func ModRevision(key string) Cmp {
+	return Cmp{key: key, target: targetModRevision}
+}
+
+// Compare the key's version, 0 if it does not exist
+func Version(key string) Cmp {
+	return Cmp{key: key, target: targetVersion}
+}
+
+// Compare the key's creation revision, only "= 0" (key absent) is supported
+func CreateRevision(key string) Cmp {
+	return Cmp{key: key, target: targetCreateRevision}
+}
+
+// Complete a comparison, only "=" with an integer is supported
+func Compare(cmp Cmp, result string, v any) Cmp {
+	cmp.result = result
+	switch n := v.(type) {
+	case int:
+		cmp.value = int64(n)
+	case int64:
+		cmp.value = n
+	default:
+		cmp.value = -1
+	}
+	return cmp
+}
+
+// Expected version for a CAS Set, false if the comparison has no equivalent.
+// The store checks versions, so sequence numbers cannot be compared
+func (cmp Cmp) expectedVersion() (int64, bool) {
+	if cmp.result != "=" || cmp.value < 0 {
+		return 0, false
+	}
+	switch cmp.target {
+	case targetVersion:
+		return cmp.value, true
+	case targetCreateRevision:
+		// A key that does not exist has version 0
+		return 0, cmp.value == 0
+	default:
+		return 0, false
+	}
+}
+
+type opType int
+
+const (
+	opGet opType = iota
+	opPut
+	opDelete
+)
+
+// Operation run inside a transaction
+type Op struct {
+	typ  opType
+	key  string
+	val  string
+	opts []OpOption
+}
+
+func OpGet(key string, opts ...OpOption) Op {
+	return Op{typ: opGet, key: key, opts: opts}
+}
+
+func OpPut(key, val string, opts ...OpOption) Op {
+	return Op{typ: opPut, key: key, val: val, opts: opts}
+}
+
+func OpDelete(key string, opts ...OpOption) Op {
+	return Op{typ: opDelete, key: key, opts: opts}
+}
+
+// Result of a single operation, exactly one field is set
+type ResponseOp struct {
+	Get    *GetResponse
+	Put    *PutResponse
+	Delete *DeleteResponse
+}
+
+type TxnResponse struct {
+	Succeeded bool
+	Responses []*ResponseOp
+}
+
+// Optimistic-locking transaction. Only a single comparison guarding a single
+// Put to the same key is atomic; Else operations run after the failed attempt
+type Txn interface {
+	If(cs ...Cmp) Txn
+	Then(ops ...Op) Txn
+	Else(ops ...Op) Txn
+	Commit() (*TxnResponse, error)
+}
+
+type txn struct {
+	ctx    context.Context
+	c      *Client
+	cmps   []Cmp
+	thenOp []Op
+	elseOp []Op
+}
+
+// Start a transaction
+func (c *Client) Txn(ctx context.Context) Txn {
+	return &txn{ctx: ctx, c: c}
+}
+
+func (t *txn) If(cs ...Cmp) Txn {
+	t.cmps = append(t.cmps, cs...)
+	return t
+}
+
+func (t *txn) Then(ops ...Op) Txn {
+	t.thenOp = append(t.thenOp, ops...)
+	return t
+}
+
+func (t *txn) Else(ops ...Op) Txn {
+	t.elseOp = append(t.elseOp, ops...)
+	return t
+}
+
+func (t *txn) Commit() (*TxnResponse, error) {
+	if len(t.cmps) == 0 {
+		responses, err := t.run(t.thenOp)
+		if err != nil {
+			return nil, err
+		}
+		return &TxnResponse{Succeeded: true, Responses: responses}, nil
+	}
+
+	// The store can only check a version atomically as part of a Set
+	if len(t.cmps) != 1 || len(t.thenOp) != 1 || t.thenOp[0].typ != opPut || t.thenOp[0].key != t.cmps[0].key {
+		return nil, fmt.Errorf("%w: transactions support one comparison guarding one Put to the same key", ErrUnsupported)
+	}
+	expected, ok := t.cmps[0].expectedVersion()
+	if !ok {
+		return nil, fmt.Errorf("%w: only \"=\" comparisons on Version, or CreateRevision = 0, are supported", ErrUnsupported)
+	}
+
+	put := t.thenOp[0]
+	var header metadata.MD
+	_, err := t.c.kv.Set(t.ctx, &pb.SetRequest{
+		Key:             put.key,
+		Value:           put.val,
+		ConflictPolicy:  pb.ConflictPolicy_POLICY_CAS,
+		ExpectedVersion: expected,
+	}, grpc.Header(&header))
+	if err == nil {
+		return &TxnResponse{
+			Succeeded: true,
+			Responses: []*ResponseOp{{Put: &PutResponse{Revision: sequence(header)}}},
+		}, nil
+	}
+	if !isConflict(err) {
+		return nil, err
+	}
+
+	responses, err := t.run(t.elseOp)
+	if err != nil {
+		return nil, err
+	}
+	return &TxnResponse{Succeeded: false, Responses: responses}, nil
+}
+
+// Run operations in order, stopping at the first failure
+func (t *txn) run(ops []Op) ([]*ResponseOp, error) {
+	responses := make([]*ResponseOp, 0, len(ops))
+	for _, o := range ops {
+		var r ResponseOp
+		var err error
+		switch o.typ {
+		case opGet:
+			r.Get, err = t.c.Get(t.ctx, o.key, o.opts...)
+		case opPut:
+			r.Put, err = t.c.Put(t.ctx, o.key, o.val, o.opts...)
+		case opDelete:
+			r.Delete, err = t.c.Delete(t.ctx, o.key, o.opts...)
+		}
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, &r)
+	}
+	return responses, nil
+}
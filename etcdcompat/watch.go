@@ -0,0 +1,105 @@
+package etcdcompat
+
+import (
+	"context"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+type EventType int32
+
+const (
+	EventTypePut    EventType = 0
+	EventTypeDelete EventType = 1
+)
+
+func (t EventType) String() string {
+	if t == EventTypeDelete {
+		return "DELETE"
+	}
+	return "PUT"
+}
+
+type Event struct {
+	Type EventType
+	Kv   *KeyValue
+}
+
+// Batch of events delivered on a WatchChan
+type WatchResponse struct {
+	Events   []*Event
+	Canceled bool
+
+	err error
+}
+
+// Error that ended the watch, nil while it is live
+func (wr WatchResponse) Err() error {
+	return wr.err
+}
+
+type WatchChan <-chan WatchResponse
+
+// Stream changes to a key, or to every key under a prefix with WithPrefix.
+// The channel closes when ctx is cancelled or the stream fails
+func (c *Client) Watch(ctx context.Context, key string, opts ...OpOption) WatchChan {
+	prefix := applyOpts(opts).prefix
+	out := make(chan WatchResponse)
+
+	go func() {
+		defer close(out)
+
+		stream, err := c.kv.Subscribe(ctx, &pb.SubscribeRequest{KeyPattern: key})
+		if err != nil {
+			sendWatch(ctx, out, WatchResponse{Canceled: true, err: err})
+			return
+		}
+
+		for {
+			event, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					sendWatch(ctx, out, WatchResponse{Canceled: true, err: err})
+				}
+				return
+			}
+			// Range deletes name no keys, so there is nothing to report per key,
+			// etcd reports no event when a lease changes, and it has no sorted sets
+			switch event.ChangeType {
+			case pb.ChangeEvent_DELETE_RANGE, pb.ChangeEvent_EXPIRY_UPDATED, pb.ChangeEvent_ZADD, pb.ChangeEvent_ZREM:
+				continue
+			}
+			// Subscribe matches by prefix, an exact watch drops longer keys
+			if !prefix && event.Key != key {
+				continue
+			}
+
+			ev := &Event{
+				Type: EventTypePut,
+				Kv: &KeyValue{
+					Key:         []byte(event.Key),
+					Value:       []byte(event.Value),
+					ModRevision: event.Sequence,
+					Version:     event.Version,
+				},
+			}
+			if event.ChangeType == pb.ChangeEvent_DELETE {
+				ev.Type = EventTypeDelete
+			}
+			if !sendWatch(ctx, out, WatchResponse{Events: []*Event{ev}}) {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+func sendWatch(ctx context.Context, out chan<- WatchResponse, resp WatchResponse) bool {
+	select {
+	case out <- resp:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/amillerrr/distributed-kv-store/internal/eventlog"
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+)
+
+// Entries buffered per forwarder when no size is given
+const DefaultBufferSize = 4096
+
+// Least time between warnings about dropped entries
+const dropWarnInterval = 10 * time.Second
+
+// Reasons recorded on the dropped entries metric
+const (
+	dropBufferFull     = "buffer_full"
+	dropDeliveryFailed = "delivery_failed"
+)
+
+// Bounded queue in front of a forwarder, so a slow destination never blocks
+// the caller. Entries are dropped once it is full
+type queue struct {
+	name    string
+	entries chan eventlog.Entry
+
+	// Guards entries against sends after close
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	// Limits warnings about dropped entries, which come in floods
+	dropWarn rate.Sometimes
+}
+
+func newQueue(name string, size int) *queue {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &queue{
+		name:     name,
+		entries:  make(chan eventlog.Entry, size),
+		done:     make(chan struct{}),
+		dropWarn: rate.Sometimes{Interval: dropWarnInterval},
+	}
+}
+
+// Queue an entry without blocking, returning false if the buffer is full or closed
+func (q *queue) Append(entry eventlog.Entry) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+
+	select {
+	case q.entries <- entry:
+		return true
+	default:
+		q.dropped(dropBufferFull, 1)
+		q.dropWarn.Do(func() {
+			slog.Warn("audit forwarder falling behind, dropping entries, see kvstore_audit_entries_dropped_total", "forwarder", q.name, "op", entry.Op, "key", entry.Key)
+		})
+		return false
+	}
+}
+
+// Stop accepting entries and wait for the forwarder to drain the rest
+func (q *queue) close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.entries)
+	}
+	q.mu.Unlock()
+
+	<-q.done
+}
+
+func (q *queue) dropped(reason string, n int) {
+	metrics.AuditEntriesDropped.WithLabelValues(q.name, reason).Add(float64(n))
+}
@@ -0,0 +1,60 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"log/syslog"
+)
+
+// Tag on every syslog message
+const syslogTag = "kvstore-audit"
+
+// Forward entries to a syslog daemon, one JSON message per entry
+type Syslog struct {
+	*queue
+
+	writer *syslog.Writer
+}
+
+// Connect to the syslog daemon at addr over network, e.g. udp and
+// siem.internal:514. Empty network and addr use the local daemon. At most
+// bufferSize entries wait before new ones are dropped, 0 for DefaultBufferSize
+func NewSyslog(network, addr string, bufferSize int) (*Syslog, error) {
+	writer, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_LOCAL0, syslogTag)
+	if err != nil {
+		return nil, fmt.Errorf("connect to syslog %s %s: %w", network, addr, err)
+	}
+
+	s := &Syslog{queue: newQueue("syslog", bufferSize), writer: writer}
+	go s.run()
+	slog.Info("audit syslog enabled", "network", network, "addr", addr)
+	return s, nil
+}
+
+// Send the remaining entries and disconnect
+func (s *Syslog) Close() error {
+	s.close()
+	return s.writer.Close()
+}
+
+// Write entries until closed
+func (s *Syslog) run() {
+	defer close(s.done)
+
+	for entry := range s.entries {
+		msg, err := json.Marshal(entry)
+		if err != nil {
+			slog.Error("failed to encode audit entry", "error", err)
+			s.dropped(dropDeliveryFailed, 1)
+			continue
+		}
+		// The writer reconnects once on failure before giving up
+		if err := s.writer.Info(string(msg)); err != nil {
+			slog.Error("failed to write audit entry to syslog", "error", err)
+			s.dropped(dropDeliveryFailed, 1)
+		}
+	}
+}
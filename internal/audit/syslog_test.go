@@ -0,0 +1,48 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogSendsJSONEntries(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	s, err := NewSyslog("udp", conn.LocalAddr().String(), 0)
+	if err != nil {
+		t.Fatalf("NewSyslog: %v", err)
+	}
+	s.Append(entry(1))
+	s.Append(entry(2))
+	// Close sends whatever is still queued
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 4096)
+	for _, key := range []string{"user:1", "user:2"} {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		msg := string(buf[:n])
+		if !strings.Contains(msg, syslogTag) || !strings.Contains(msg, `"key":"`+key+`"`) {
+			t.Errorf("message %q, want tag %s and key %s", msg, syslogTag, key)
+		}
+	}
+}
+
+func TestSyslogDialFailure(t *testing.T) {
+	if _, err := NewSyslog("tcp", "127.0.0.1:1", 0); err == nil {
+		t.Error("NewSyslog succeeded without a daemon")
+	}
+}
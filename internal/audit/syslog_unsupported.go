@@ -0,0 +1,19 @@
+//go:build windows || plan9
+
+package audit
+
+import "errors"
+
+// Syslog forwarding is unavailable on this platform
+type Syslog struct {
+	*queue
+}
+
+// Always fails, log/syslog is not supported on this platform
+func NewSyslog(network, addr string, bufferSize int) (*Syslog, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (s *Syslog) Close() error {
+	return nil
+}
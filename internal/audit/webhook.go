@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/eventlog"
+)
+
+const (
+	// Attempts per batch before it is dropped
+	maxWebhookAttempts = 5
+
+	// Backoff between attempts, doubling up to the max
+	initialWebhookBackoff = 500 * time.Millisecond
+	maxWebhookBackoff     = 8 * time.Second
+
+	// Longest a single POST may take
+	webhookTimeout = 10 * time.Second
+
+	// Flush interval used when none is given
+	defaultFlushInterval = 5 * time.Second
+)
+
+// Forward entries to an HTTP endpoint as JSON arrays, batched by size and time
+type Webhook struct {
+	*queue
+
+	url           string
+	batchSize     int
+	flushInterval time.Duration
+	header        http.Header
+	client        *http.Client
+}
+
+// Configure optional Webhook behavior
+type WebhookOption func(*Webhook)
+
+// Send a header with every POST, e.g. Authorization
+func WithHeader(key, value string) WebhookOption {
+	return func(w *Webhook) {
+		w.header.Set(key, value)
+	}
+}
+
+// Send entries to url in batches of up to batchSize, at least every
+// flushInterval while entries are waiting. At most bufferSize entries wait
+// before new ones are dropped, 0 for DefaultBufferSize
+func NewWebhook(url string, batchSize int, flushInterval time.Duration, bufferSize int, opts ...WebhookOption) *Webhook {
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	w := &Webhook{
+		queue:         newQueue("webhook", bufferSize),
+		url:           url,
+		batchSize:     max(batchSize, 1),
+		flushInterval: flushInterval,
+		header:        make(http.Header),
+		client:        &http.Client{Timeout: webhookTimeout},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	go w.run()
+	slog.Info("audit webhook enabled", "url", redact(url), "batch_size", w.batchSize, "flush_interval", flushInterval)
+	return w
+}
+
+// URL safe to log, without any password or query string
+func redact(rawURL string) string {
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "invalid URL"
+	}
+	u.RawQuery = ""
+	return u.Redacted()
+}
+
+// Send the remaining entries and stop
+func (w *Webhook) Close() error {
+	w.close()
+	return nil
+}
+
+// Batch entries until closed, sending whatever is left on the way out
+func (w *Webhook) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]eventlog.Entry, 0, w.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		w.send(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry, ok := <-w.entries:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) == w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// POST a batch, retrying with backoff on network errors and 5xx responses
+func (w *Webhook) send(batch []eventlog.Entry) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		slog.Error("failed to encode audit batch", "error", err)
+		w.dropped(dropDeliveryFailed, len(batch))
+		return
+	}
+
+	backoff := initialWebhookBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == maxWebhookAttempts {
+			slog.Error("audit webhook delivery failed, dropping batch", "url", redact(w.url), "entries", len(batch), "attempts", attempt, "error", err)
+			w.dropped(dropDeliveryFailed, len(batch))
+			return
+		}
+
+		slog.Warn("audit webhook delivery failed, retrying", "url", redact(w.url), "attempt", attempt, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxWebhookBackoff)
+	}
+}
+
+// Make one delivery attempt, reporting whether a failure is worth retrying
+func (w *Webhook) post(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header = w.header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return false, nil
+}
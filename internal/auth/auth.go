@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata header carrying the caller's credential as "Bearer <token>"
+const AuthorizationHeader = "authorization"
+
+// Returned by providers when a token is not valid, the interceptor never
+// passes provider errors on to callers
+var ErrInvalidToken = errors.New("invalid token")
+
+// Who a request was made by
+type Identity struct {
+	SubjectID string
+	Roles     []string
+	// Extra attributes from the credential, e.g. JWT claims
+	Metadata map[string]string
+}
+
+// Verify a caller's token and resolve it to an identity
+type Provider interface {
+	Authenticate(ctx context.Context, token string) (Identity, error)
+}
+
+type identityKey struct{}
+
+// Identity of the caller, set by the interceptor once authenticated
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Reject unary requests without a token provider accepts, and make the
+// caller's identity available to handlers through FromContext
+func NewAPIKeyInterceptor(provider Provider) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		token, err := bearerToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		id, err := provider.Authenticate(ctx, token)
+		if err != nil {
+			// The reason is logged, not returned, so callers cannot probe keys
+			slog.Warn("request authentication failed", "method", info.FullMethod, "error", err)
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		return handler(context.WithValue(ctx, identityKey{}, id), req)
+	}
+}
+
+// Streaming variant of NewAPIKeyInterceptor. The token is checked once when
+// the stream opens, and the identity is available through the stream's context
+func NewStreamAPIKeyInterceptor(provider Provider) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		token, err := bearerToken(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := provider.Authenticate(ctx, token)
+		if err != nil {
+			slog.Warn("stream authentication failed", "method", info.FullMethod, "error", err)
+			return status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		return handler(srv, &identityStream{ServerStream: ss, ctx: context.WithValue(ctx, identityKey{}, id)})
+	}
+}
+
+// Server stream whose context carries the caller's identity
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// Token from the authorization header
+func bearerToken(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(AuthorizationHeader)
+	if len(values) == 0 {
+		return "", status.Errorf(codes.Unauthenticated, "%s header is required", AuthorizationHeader)
+	}
+	token, ok := parseBearer(values[0])
+	if !ok {
+		return "", status.Errorf(codes.Unauthenticated, "%s header must be \"Bearer <token>\"", AuthorizationHeader)
+	}
+	return token, nil
+}
+
+// Token from a "Bearer <token>" header value
+func parseBearer(value string) (string, bool) {
+	scheme, token, ok := strings.Cut(value, " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// HTTP counterpart of NewAPIKeyInterceptor, answering 401 to requests
+// without a token provider accepts
+func HTTPMiddleware(provider Provider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := parseBearer(r.Header.Get(AuthorizationHeader))
+			if !ok {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "authorization header must be \"Bearer <token>\"", http.StatusUnauthorized)
+				return
+			}
+			id, err := provider.Authenticate(r.Context(), token)
+			if err != nil {
+				slog.Warn("request authentication failed", "path", r.URL.Path, "error", err)
+				http.Error(w, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, id)))
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// How long fetched signing keys are trusted before they are refetched
+	DefaultJWKSTTL = time.Hour
+
+	// Claim holding the caller's roles when no other is configured
+	DefaultRolesClaim = "roles"
+
+	// Shortest gap between fetches triggered by tokens with unknown key IDs
+	minJWKSRefetch = time.Minute
+
+	jwksFetchTimeout = 10 * time.Second
+)
+
+// Signature algorithms accepted in tokens. HMAC is excluded so a public key
+// can never be used as a shared secret
+var jwtMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Configure a JWTProvider
+type JWTOption func(*JWTProvider)
+
+// Require tokens to list audience in their aud claim
+func WithAudience(audience string) JWTOption {
+	return func(p *JWTProvider) {
+		p.audience = audience
+	}
+}
+
+// Require tokens to be issued by issuer
+func WithIssuer(issuer string) JWTOption {
+	return func(p *JWTProvider) {
+		p.issuer = issuer
+	}
+}
+
+// Read roles from claim instead of DefaultRolesClaim. The claim may be a list
+// of strings or a space-separated string such as an OAuth scope
+func WithRolesClaim(claim string) JWTOption {
+	return func(p *JWTProvider) {
+		p.rolesClaim = claim
+	}
+}
+
+// Refetch signing keys every ttl instead of DefaultJWKSTTL
+func WithJWKSTTL(ttl time.Duration) JWTOption {
+	return func(p *JWTProvider) {
+		p.ttl = ttl
+	}
+}
+
+// Fetch signing keys with client instead of a default client
+func WithHTTPClient(client *http.Client) JWTOption {
+	return func(p *JWTProvider) {
+		p.client = client
+	}
+}
+
+// Accepts JWTs signed by a key published at a JWKS URL
+type JWTProvider struct {
+	jwksURL    string
+	audience   string
+	issuer     string
+	rolesClaim string
+	ttl        time.Duration
+	client     *http.Client
+	parser     *jwt.Parser
+
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	// Serializes fetches so a burst of unknown key IDs causes one request
+	fetchMu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Validate tokens against the keys published at jwksURL. The keys are fetched
+// once now and refreshed in the background every TTL until Close
+func NewJWTProvider(jwksURL string, opts ...JWTOption) (*JWTProvider, error) {
+	p := &JWTProvider{
+		jwksURL:    jwksURL,
+		rolesClaim: DefaultRolesClaim,
+		ttl:        DefaultJWKSTTL,
+		client:     &http.Client{Timeout: jwksFetchTimeout},
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	parserOpts := []jwt.ParserOption{jwt.WithValidMethods(jwtMethods), jwt.WithExpirationRequired()}
+	if p.audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(p.audience))
+	}
+	if p.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(p.issuer))
+	}
+	p.parser = jwt.NewParser(parserOpts...)
+
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	go p.run()
+	return p, nil
+}
+
+func (p *JWTProvider) Authenticate(_ context.Context, token string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	if _, err := p.parser.ParseWithClaims(token, claims, p.keyFunc); err != nil {
+		return Identity{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return Identity{}, fmt.Errorf("%w: missing sub claim", ErrInvalidToken)
+	}
+	id := Identity{SubjectID: sub, Roles: rolesFrom(claims[p.rolesClaim]), Metadata: map[string]string{}}
+	for name, value := range claims {
+		if s, ok := value.(string); ok && name != "sub" && name != p.rolesClaim {
+			id.Metadata[name] = s
+		}
+	}
+	return id, nil
+}
+
+// Stop the background refresh
+func (p *JWTProvider) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
+
+// Public key for a token's kid, refetching once if the kid is new, as it is
+// after the identity provider rotates keys
+func (p *JWTProvider) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := p.key(kid); ok {
+		return key, nil
+	}
+
+	p.mu.RLock()
+	stale := time.Since(p.fetchedAt) >= minJWKSRefetch
+	p.mu.RUnlock()
+	if stale {
+		if err := p.refresh(); err != nil {
+			slog.Warn("JWKS refetch for unknown key ID failed", "kid", kid, "error", err)
+		}
+		if key, ok := p.key(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+// Key with the given ID. A token without a kid matches only a JWKS with a
+// single key
+func (p *JWTProvider) key(kid string) (crypto.PublicKey, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// Refetch the keys every TTL, keeping the old set if a fetch fails
+func (p *JWTProvider) run() {
+	ticker := time.NewTicker(p.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.refresh(); err != nil {
+				slog.Warn("JWKS refresh failed, keeping previous keys", "url", p.jwksURL, "error", err)
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Fetch the JWKS and replace the cached keys
+func (p *JWTProvider) refresh() error {
+	p.fetchMu.Lock()
+	defer p.fetchMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.jwksURL, nil)
+	if err != nil {
+		return fmt.Errorf("build JWKS request: %w", err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		// Keys for encryption or unsupported types are not ours to verify with
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			slog.Warn("skipping JWKS key", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("JWKS holds no usable signing keys")
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.fetchedAt = time.Now()
+	p.mu.Unlock()
+	slog.Info("JWKS loaded", "url", p.jwksURL, "key_count", len(keys))
+	return nil
+}
+
+// Single entry of a JWKS, RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// Roles from a claim holding a list or a space-separated string
+func rolesFrom(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		roles := make([]string, 0, len(v))
+		for _, role := range v {
+			if s, ok := role.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+		return roles
+	default:
+		return nil
+	}
+}
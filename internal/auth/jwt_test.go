@@ -0,0 +1,315 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// JWKS endpoint serving the public halves of keys, or 500 while failing
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string]*ecdsa.PrivateKey
+	failing bool
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, kids ...string) *jwksServer {
+	t.Helper()
+	s := &jwksServer{keys: make(map[string]*ecdsa.PrivateKey)}
+	for _, kid := range kids {
+		s.addKey(t, kid)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.failing {
+			http.Error(w, "unavailable", http.StatusInternalServerError)
+			return
+		}
+		var set struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		for kid, key := range s.keys {
+			set.Keys = append(set.Keys, map[string]string{
+				"kty": "EC",
+				"kid": kid,
+				"use": "sig",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+				"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) addKey(t *testing.T, kid string) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+	return key
+}
+
+func (s *jwksServer) key(kid string) *ecdsa.PrivateKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[kid]
+}
+
+func (s *jwksServer) setFailing(failing bool) {
+	s.mu.Lock()
+	s.failing = failing
+	s.mu.Unlock()
+}
+
+func newTestProvider(t *testing.T, url string, opts ...JWTOption) *JWTProvider {
+	t.Helper()
+	p, err := NewJWTProvider(url, opts...)
+	if err != nil {
+		t.Fatalf("NewJWTProvider: %v", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// Claims of a valid token for subject alice, with extra merged in
+func claims(extra jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+	for name, value := range extra {
+		c[name] = value
+	}
+	return c
+}
+
+// ES256 token over c signed by key, with kid in its header unless empty
+func signToken(t *testing.T, key *ecdsa.PrivateKey, kid string, c jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestJWTAcceptsValidToken(t *testing.T) {
+	jwks := newJWKSServer(t, "k1")
+	p := newTestProvider(t, jwks.URL)
+
+	token := signToken(t, jwks.key("k1"), "k1", claims(jwt.MapClaims{"roles": []string{"admin", "reader"}, "email": "a@example.com"}))
+	id, err := p.Authenticate(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if id.SubjectID != "alice" || !slices.Equal(id.Roles, []string{"admin", "reader"}) || id.Metadata["email"] != "a@example.com" {
+		t.Errorf("identity = %+v", id)
+	}
+}
+
+func TestJWTRolesFromSpaceSeparatedClaim(t *testing.T) {
+	jwks := newJWKSServer(t, "k1")
+	p := newTestProvider(t, jwks.URL, WithRolesClaim("scope"))
+
+	id, err := p.Authenticate(context.Background(), signToken(t, jwks.key("k1"), "k1", claims(jwt.MapClaims{"scope": "read write"})))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if !slices.Equal(id.Roles, []string{"read", "write"}) {
+		t.Errorf("roles = %v, want [read write]", id.Roles)
+	}
+}
+
+func TestJWTRejectsDisallowedAlgorithms(t *testing.T) {
+	jwks := newJWKSServer(t, "k1")
+	p := newTestProvider(t, jwks.URL)
+
+	// The public key used as an HMAC secret, the classic algorithm confusion
+	public, err := x509.MarshalPKIXPublicKey(&jwks.key("k1").PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	hmacToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil))
+	hmacToken.Header["kid"] = "k1"
+	hs256, err := hmacToken.SignedString(public)
+	if err != nil {
+		t.Fatalf("sign HS256: %v", err)
+	}
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("sign none: %v", err)
+	}
+
+	for alg, token := range map[string]string{"HS256": hs256, "none": none} {
+		if _, err := p.Authenticate(context.Background(), token); err == nil {
+			t.Errorf("%s token accepted", alg)
+		}
+	}
+}
+
+func TestJWTRequiredClaims(t *testing.T) {
+	jwks := newJWKSServer(t, "k1")
+	p := newTestProvider(t, jwks.URL, WithAudience("kvstore"), WithIssuer("https://idp.example.com"))
+	key := jwks.key("k1")
+	valid := jwt.MapClaims{"aud": "kvstore", "iss": "https://idp.example.com"}
+
+	if _, err := p.Authenticate(context.Background(), signToken(t, key, "k1", claims(valid))); err != nil {
+		t.Fatalf("Authenticate with matching aud and iss: %v", err)
+	}
+
+	noExp := claims(valid)
+	delete(noExp, "exp")
+	tests := map[string]jwt.MapClaims{
+		"no exp":       noExp,
+		"expired":      claims(jwt.MapClaims{"aud": "kvstore", "iss": "https://idp.example.com", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"other aud":    claims(jwt.MapClaims{"aud": "other", "iss": "https://idp.example.com"}),
+		"other iss":    claims(jwt.MapClaims{"aud": "kvstore", "iss": "https://evil.example.com"}),
+		"no sub claim": claims(jwt.MapClaims{"aud": "kvstore", "iss": "https://idp.example.com", "sub": ""}),
+	}
+	for name, c := range tests {
+		if _, err := p.Authenticate(context.Background(), signToken(t, key, "k1", c)); err == nil {
+			t.Errorf("token with %s accepted", name)
+		}
+	}
+}
+
+func TestJWTWithoutKidNeedsSingleKey(t *testing.T) {
+	jwks := newJWKSServer(t, "k1")
+	single := newTestProvider(t, jwks.URL)
+	token := signToken(t, jwks.key("k1"), "", claims(nil))
+	if _, err := single.Authenticate(context.Background(), token); err != nil {
+		t.Errorf("token without kid against one key: %v", err)
+	}
+
+	jwks.addKey(t, "k2")
+	multiple := newTestProvider(t, jwks.URL)
+	if _, err := multiple.Authenticate(context.Background(), token); err == nil {
+		t.Error("token without kid accepted against several keys")
+	}
+}
+
+func TestJWTRefetchesUnknownKidAtMostEveryMinute(t *testing.T) {
+	jwks := newJWKSServer(t, "k1")
+	p := newTestProvider(t, jwks.URL)
+	rotated := signToken(t, jwks.addKey(t, "k2"), "k2", claims(nil))
+
+	// Fetched just now, so the new kid is not looked up yet
+	if _, err := p.Authenticate(context.Background(), rotated); err == nil {
+		t.Error("token with a kid fetched too recently to refetch was accepted")
+	}
+	if n := jwks.fetches.Load(); n != 1 {
+		t.Errorf("%d fetches, want 1", n)
+	}
+
+	p.mu.Lock()
+	p.fetchedAt = time.Now().Add(-minJWKSRefetch)
+	p.mu.Unlock()
+	if _, err := p.Authenticate(context.Background(), rotated); err != nil {
+		t.Errorf("token with a rotated kid after the refetch gap: %v", err)
+	}
+	if _, err := p.Authenticate(context.Background(), signToken(t, jwks.key("k1"), "k3", claims(nil))); err == nil {
+		t.Error("token with an unknown kid accepted")
+	}
+	if n := jwks.fetches.Load(); n != 2 {
+		t.Errorf("%d fetches, want 2", n)
+	}
+}
+
+func TestJWTKeepsKeysWhenRefreshFails(t *testing.T) {
+	jwks := newJWKSServer(t, "k1")
+	p := newTestProvider(t, jwks.URL, WithJWKSTTL(10*time.Millisecond))
+	jwks.setFailing(true)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for jwks.fetches.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("JWKS never refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := p.Authenticate(context.Background(), signToken(t, jwks.key("k1"), "k1", claims(nil))); err != nil {
+		t.Errorf("token rejected after failed refreshes: %v", err)
+	}
+}
+
+// Server stream that only carries a context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }
+
+func TestInterceptorsPassIdentity(t *testing.T) {
+	jwks := newJWKSServer(t, "k1")
+	p := newTestProvider(t, jwks.URL)
+	bearer := "Bearer " + signToken(t, jwks.key("k1"), "k1", claims(jwt.MapClaims{"roles": "admin"}))
+	withToken := func(value string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(AuthorizationHeader, value))
+	}
+	checkIdentity := func(ctx context.Context) {
+		id, ok := FromContext(ctx)
+		if !ok || id.SubjectID != "alice" || !slices.Equal(id.Roles, []string{"admin"}) {
+			t.Errorf("identity = %+v, %v", id, ok)
+		}
+	}
+
+	t.Run("unary", func(t *testing.T) {
+		intercept := NewAPIKeyInterceptor(p)
+		info := &grpc.UnaryServerInfo{FullMethod: "/kvstore.KeyValueStore/Get"}
+		handler := func(ctx context.Context, _ any) (any, error) {
+			checkIdentity(ctx)
+			return nil, nil
+		}
+		if _, err := intercept(withToken(bearer), nil, info, handler); err != nil {
+			t.Errorf("valid token: %v", err)
+		}
+		if _, err := intercept(withToken("Bearer forged"), nil, info, handler); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("forged token = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		intercept := NewStreamAPIKeyInterceptor(p)
+		info := &grpc.StreamServerInfo{FullMethod: "/kvstore.KeyValueStore/Subscribe"}
+		handler := func(_ any, ss grpc.ServerStream) error {
+			checkIdentity(ss.Context())
+			return nil
+		}
+		if err := intercept(nil, &contextStream{ctx: withToken(bearer)}, info, handler); err != nil {
+			t.Errorf("valid token: %v", err)
+		}
+		if err := intercept(nil, &contextStream{ctx: context.Background()}, info, handler); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("no token = %v, want Unauthenticated", err)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Accepts a fixed set of API keys, each mapped to an identity
+type StaticKeyProvider struct {
+	// Keyed by the SHA-256 of the API key so lookups do not leak key prefixes
+	identities map[[sha256.Size]byte]Identity
+}
+
+// Accept the API keys in keys, mapped to their identities
+func NewStaticKeyProvider(keys map[string]Identity) *StaticKeyProvider {
+	p := &StaticKeyProvider{identities: make(map[[sha256.Size]byte]Identity, len(keys))}
+	for key, id := range keys {
+		p.identities[sha256.Sum256([]byte(key))] = id
+	}
+	return p
+}
+
+// Load API keys from a file with one "<key> <subject> [role,role...]" per
+// line. Blank lines and lines starting with # are skipped
+func LoadStaticKeyFile(path string) (*StaticKeyProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open API key file: %w", err)
+	}
+	defer f.Close()
+
+	keys := make(map[string]Identity)
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		// Errors name the line only, never its contents
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("API key file line %d: expected \"<key> <subject> [roles]\"", line)
+		}
+		if _, dup := keys[fields[0]]; dup {
+			return nil, fmt.Errorf("API key file line %d: duplicate key", line)
+		}
+		id := Identity{SubjectID: fields[1]}
+		if len(fields) == 3 {
+			id.Roles = strings.Split(fields[2], ",")
+		}
+		keys[fields[0]] = id
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read API key file: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("API key file %s holds no keys", path)
+	}
+	return NewStaticKeyProvider(keys), nil
+}
+
+func (p *StaticKeyProvider) Authenticate(_ context.Context, token string) (Identity, error) {
+	id, ok := p.identities[sha256.Sum256([]byte(token))]
+	if !ok {
+		return Identity{}, ErrInvalidToken
+	}
+	return id, nil
+}
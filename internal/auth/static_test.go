@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadStaticKeyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	content := "# service keys\n\nkey-a alice admin,reader\nkey-b bob\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	p, err := LoadStaticKeyFile(path)
+	if err != nil {
+		t.Fatalf("LoadStaticKeyFile: %v", err)
+	}
+
+	id, err := p.Authenticate(context.Background(), "key-a")
+	if err != nil || id.SubjectID != "alice" || !slices.Equal(id.Roles, []string{"admin", "reader"}) {
+		t.Errorf("key-a = %+v, %v", id, err)
+	}
+	if id, err := p.Authenticate(context.Background(), "key-b"); err != nil || id.SubjectID != "bob" || id.Roles != nil {
+		t.Errorf("key-b = %+v, %v", id, err)
+	}
+	if _, err := p.Authenticate(context.Background(), "key-c"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown key = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLoadStaticKeyFileRejectsBadLines(t *testing.T) {
+	for name, content := range map[string]string{
+		"missing subject": "key-a\n",
+		"extra field":     "key-a alice admin extra\n",
+		"duplicate key":   "key-a alice\nkey-a bob\n",
+		"empty":           "# nothing\n",
+	} {
+		path := filepath.Join(t.TempDir(), "keys")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write key file: %v", err)
+		}
+		if _, err := LoadStaticKeyFile(path); err == nil {
+			t.Errorf("%s: loaded without error", name)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package capabilities
+
+import (
+	"slices"
+	"sync"
+)
+
+// Names of optional features reported by ServerCapabilities. Clients match
+// on them, so a name is never changed or reused for a different feature
+const (
+	// Set accepts ttl_ms and SetExpiry changes a key's TTL
+	TTL = "ttl"
+	// Get and GetMany accept field_mask
+	FieldMask = "field_mask"
+	// Get returns ETags and honors if_none_match
+	ETag = "etag"
+	// Append adds to a value in place
+	Append = "append"
+	// MergePatch applies RFC 7396 patches to JSON values
+	MergePatch = "merge_patch"
+	// SetMeta, GetMeta and SearchByMeta manage key labels
+	Metadata = "metadata"
+	// InspectKey describes a key for debugging
+	InspectKey = "inspect_key"
+	// Range and ConsistentScan read keys in order
+	Range = "range"
+	// RandomKeys samples the keyspace
+	RandomKeys = "random_keys"
+	// Migrate copies or moves the keys under one prefix to another
+	Migrate = "migrate"
+	// Aggregate sums, counts and compares numeric values on the server
+	Aggregate = "aggregate"
+	// GetStream and SetStream move values in chunks
+	ValueStream = "value_stream"
+	// DeleteRange removes keys in bulk
+	DeleteRange = "delete_range"
+	// ZAdd, ZRange, ZRem and ZScore manage sorted sets
+	SortedSets = "sorted_sets"
+	// MultiWatch follows several patterns over one stream
+	MultiWatch = "multi_watch"
+	// SetBarrier and WaitBarrier coordinate clients
+	Barriers = "barriers"
+	// Subscribe can resume from a sequence number and replay recent events
+	EventHistory = "event_history"
+	// Subscribe accepts ack_mode and Set accepts wait_for_ack
+	AckMode = "ack_mode"
+	// Events of a busy key are coalesced to a per-key rate
+	KeyEventRateLimit = "key_event_rate_limit"
+	// Subscribers can ask for KEY_IDLE events about unused keys
+	IdleKeyEvents = "idle_key_events"
+)
+
+// Set of features a server supports, safe for concurrent use
+type Registry struct {
+	mu       sync.RWMutex
+	features map[string]bool
+}
+
+func New() *Registry {
+	return &Registry{features: make(map[string]bool)}
+}
+
+// Announce that the feature called name is supported. Registering a name
+// again has no effect
+func (r *Registry) Register(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.features[name] = true
+}
+
+// Report whether the feature called name was registered
+func (r *Registry) Supports(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.features[name]
+}
+
+// Names of the registered features in sorted order
+func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.features))
+	for name := range r.features {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,331 @@
+package circuitbreaker
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+)
+
+const (
+	// Calls the failure rate is measured over
+	DefaultWindow = 10
+
+	// Failure rate over the window that opens the circuit
+	DefaultFailureThreshold = 0.5
+
+	// Storage calls slower than this count as failures
+	DefaultSlowCallThreshold = time.Second
+
+	// How long the circuit stays open before a probe is let through
+	DefaultOpenTimeout = 30 * time.Second
+)
+
+// Position of the circuit
+type State int
+
+const (
+	// Calls pass through and their outcomes are tracked
+	Closed State = iota
+	// Requests are rejected without touching storage
+	Open
+	// One request is let through to probe whether storage has recovered
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "unknown"
+	}
+}
+
+// Returned to callers while the circuit is open
+var ErrOpen = status.Error(codes.Unavailable, "storage is unavailable, circuit breaker is open")
+
+// Configure a Backend
+type Option func(*Backend)
+
+// Measure the failure rate over the last n calls instead of DefaultWindow
+func WithWindow(n int) Option {
+	return func(b *Backend) {
+		b.window = n
+	}
+}
+
+// Open the circuit once this fraction of the window failed instead of
+// DefaultFailureThreshold
+func WithFailureThreshold(ratio float64) Option {
+	return func(b *Backend) {
+		b.threshold = ratio
+	}
+}
+
+// Count calls slower than d as failures instead of DefaultSlowCallThreshold
+func WithSlowCallThreshold(d time.Duration) Option {
+	return func(b *Backend) {
+		b.slowCall = d
+	}
+}
+
+// Keep the circuit open for d before probing instead of DefaultOpenTimeout
+func WithOpenTimeout(d time.Duration) Option {
+	return func(b *Backend) {
+		b.openTimeout = d
+	}
+}
+
+// Storage backend that stops traffic to a wrapped backend once too many of
+// its calls fail.
+//
+// Backend methods cannot return errors, so a call fails when it is slower
+// than the slow call threshold or panics, the symptoms of a saturated disk or
+// an unreachable remote store. For the same reason the circuit is enforced by
+// the interceptors rather than the storage methods: an open circuit rejects
+// requests with codes.Unavailable before they reach the service, while
+// background work such as TTL expiry still reaches the backend.
+//
+// Storage calls carry no context, so a half-open probe cannot be told apart
+// from background calls made at the same time. The probe is therefore judged
+// by the probe request itself, which fails if it panics or is slower than the
+// slow call threshold, and storage calls are not counted while half-open
+type Backend struct {
+	backend     storage.Backend
+	window      int
+	threshold   float64
+	slowCall    time.Duration
+	openTimeout time.Duration
+
+	mu    sync.Mutex
+	state State
+	// Ring of the last window outcomes, true for failures
+	outcomes []bool
+	next     int
+	count    int
+	failures int
+	openedAt time.Time
+	// Set while the half-open probe request is in flight
+	probing bool
+}
+
+// Wrap backend in a circuit breaker. Use storage.As to reach the returned
+// *Backend for its interceptors and state
+func Wrap(backend storage.Backend, opts ...Option) storage.Backend {
+	b := &Backend{
+		backend:     backend,
+		window:      DefaultWindow,
+		threshold:   DefaultFailureThreshold,
+		slowCall:    DefaultSlowCallThreshold,
+		openTimeout: DefaultOpenTimeout,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.window < 1 {
+		b.window = DefaultWindow
+	}
+	b.outcomes = make([]bool, b.window)
+	metrics.StorageCircuitState.Set(float64(Closed))
+	return b
+}
+
+func (b *Backend) Unwrap() storage.Backend {
+	return b.backend
+}
+
+func (b *Backend) Load(key string) (string, bool) {
+	defer b.observe("load", time.Now())
+	return b.backend.Load(key)
+}
+
+func (b *Backend) Store(key, value string) {
+	defer b.observe("store", time.Now())
+	b.backend.Store(key, value)
+}
+
+func (b *Backend) Delete(key string) {
+	defer b.observe("delete", time.Now())
+	b.backend.Delete(key)
+}
+
+func (b *Backend) LoadAndDelete(key string) (string, bool) {
+	defer b.observe("load_and_delete", time.Now())
+	return b.backend.LoadAndDelete(key)
+}
+
+// Range is not timed since its duration depends on fn, only panics count
+func (b *Backend) Range(fn func(key, value string) bool) {
+	defer b.observe("range", time.Time{})
+	b.backend.Range(fn)
+}
+
+func (b *Backend) Close() error {
+	return b.backend.Close()
+}
+
+// Current state. An open circuit whose timeout has passed reports HalfOpen,
+// since the next request will probe
+func (b *Backend) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && time.Since(b.openedAt) >= b.openTimeout {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Reject KV store and admin requests with ErrOpen while the circuit is open
+func (b *Backend) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !guarded(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		probe, err := b.allow()
+		if err != nil {
+			return nil, err
+		}
+		if probe {
+			defer b.finishProbe(time.Now())
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Streaming variant of UnaryServerInterceptor. Streams may outlive the probe
+// by far, e.g. a Subscribe that never touches storage, so they never probe:
+// they are rejected until a unary request has closed the circuit again
+func (b *Backend) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !guarded(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		if b.State() != Closed {
+			return ErrOpen
+		}
+		return handler(srv, ss)
+	}
+}
+
+// Only the store's own services touch storage, reflection and channelz do not
+func guarded(method string) bool {
+	return strings.HasPrefix(method, "/kvstore.")
+}
+
+// Decide whether a request may proceed, letting one through as the probe
+// once the open timeout has passed. Reports whether the request is the probe
+func (b *Backend) allow() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case Closed:
+		return false, nil
+	case Open:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false, ErrOpen
+		}
+		b.setState(HalfOpen)
+	}
+	if b.probing {
+		return false, ErrOpen
+	}
+	b.probing = true
+	return true, nil
+}
+
+// Close or re-open the circuit by the outcome of the probe request that
+// started at start, re-panicking after counting a panic. Deferred by the
+// interceptor so it can recover the handler's panic
+func (b *Backend) finishProbe(start time.Time) {
+	r := recover()
+	failed := r != nil || time.Since(start) > b.slowCall
+
+	b.mu.Lock()
+	b.probing = false
+	if failed {
+		b.trip()
+	} else {
+		b.reset()
+		b.setState(Closed)
+		slog.Info("storage circuit breaker closed, probe succeeded")
+	}
+	b.mu.Unlock()
+
+	if r != nil {
+		panic(r)
+	}
+}
+
+// Record the outcome of a storage call that started at start, re-panicking
+// after counting a panic. A zero start skips the slow call check
+func (b *Backend) observe(op string, start time.Time) {
+	r := recover()
+	failed := r != nil || (!start.IsZero() && time.Since(start) > b.slowCall)
+	if failed {
+		metrics.StorageCircuitFailures.WithLabelValues(op).Inc()
+	}
+	b.record(failed)
+	if r != nil {
+		panic(r)
+	}
+}
+
+func (b *Backend) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Calls from background work while open do not decide anything, and
+	// while half-open only the probe request does
+	if b.state != Closed {
+		return
+	}
+
+	if b.outcomes[b.next] {
+		b.failures--
+	}
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.outcomes)
+	if b.count < len(b.outcomes) {
+		b.count++
+	}
+
+	if b.count == len(b.outcomes) && float64(b.failures)/float64(b.count) >= b.threshold {
+		b.trip()
+	}
+}
+
+// Open the circuit. Caller must hold mu
+func (b *Backend) trip() {
+	slog.Warn("storage circuit breaker opened", "failures", b.failures, "window", b.count, "from", b.state.String(), "open_timeout", b.openTimeout)
+	b.reset()
+	b.openedAt = time.Now()
+	b.setState(Open)
+}
+
+// Forget the outcomes in the window. Caller must hold mu
+func (b *Backend) reset() {
+	clear(b.outcomes)
+	b.next, b.count, b.failures = 0, 0, 0
+}
+
+func (b *Backend) setState(state State) {
+	b.state = state
+	metrics.StorageCircuitState.Set(float64(state))
+}
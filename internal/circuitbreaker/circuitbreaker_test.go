@@ -0,0 +1,178 @@
+package circuitbreaker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+)
+
+const (
+	testSlowCall = 5 * time.Millisecond
+	testMethod   = "/kvstore.KeyValueStore/Get"
+)
+
+// Memory backend whose loads take delay, or panic if panics is set
+type slowBackend struct {
+	storage.Backend
+	delay  atomic.Int64
+	panics atomic.Bool
+}
+
+func (b *slowBackend) Load(key string) (string, bool) {
+	if b.panics.Load() {
+		panic("storage exploded")
+	}
+	time.Sleep(time.Duration(b.delay.Load()))
+	return b.Backend.Load(key)
+}
+
+func newTestBreaker(t *testing.T, opts ...Option) (*Backend, *slowBackend) {
+	t.Helper()
+	slow := &slowBackend{Backend: storage.NewMemory()}
+	opts = append([]Option{WithSlowCallThreshold(testSlowCall)}, opts...)
+	return Wrap(slow, opts...).(*Backend), slow
+}
+
+// Make a load that fails or succeeds by its duration
+func load(b *Backend, slow *slowBackend, failing bool) {
+	if failing {
+		slow.delay.Store(int64(2 * testSlowCall))
+	} else {
+		slow.delay.Store(0)
+	}
+	b.Load("k")
+}
+
+// Open b with a single slow call, b must use a window of 1
+func trip(t *testing.T, b *Backend, slow *slowBackend) {
+	t.Helper()
+	load(b, slow, true)
+	if state := b.State(); state != Open {
+		t.Fatalf("state after a failed call = %v, want open", state)
+	}
+}
+
+// Run a unary request through b's interceptor with handler
+func call(b *Backend, method string, handler grpc.UnaryHandler) error {
+	_, err := b.UnaryServerInterceptor()(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	return err
+}
+
+func ok(context.Context, any) (any, error) { return nil, nil }
+
+func TestTripsAtThresholdOverFullWindow(t *testing.T) {
+	b, slow := newTestBreaker(t, WithWindow(4), WithFailureThreshold(0.5))
+
+	// Two failures in a window not yet full do not trip
+	for _, failing := range []bool{true, true, false} {
+		load(b, slow, failing)
+	}
+	if state := b.State(); state != Closed {
+		t.Fatalf("state with a partial window = %v, want closed", state)
+	}
+	load(b, slow, false)
+	if state := b.State(); state != Open {
+		t.Errorf("state with 2 of 4 calls failed = %v, want open", state)
+	}
+}
+
+func TestOpenRejectsRequests(t *testing.T) {
+	b, slow := newTestBreaker(t, WithWindow(1), WithOpenTimeout(time.Hour))
+	trip(t, b, slow)
+
+	called := false
+	handler := func(context.Context, any) (any, error) {
+		called = true
+		return nil, nil
+	}
+	if err := call(b, testMethod, handler); status.Code(err) != codes.Unavailable || called {
+		t.Errorf("request while open = %v, handler called %v, want Unavailable", err, called)
+	}
+	if err := call(b, "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", ok); err != nil {
+		t.Errorf("unguarded request while open: %v", err)
+	}
+	stream := func(any, grpc.ServerStream) error { return nil }
+	info := &grpc.StreamServerInfo{FullMethod: "/kvstore.KeyValueStore/Subscribe"}
+	if err := b.StreamServerInterceptor()(nil, nil, info, stream); status.Code(err) != codes.Unavailable {
+		t.Errorf("stream while open = %v, want Unavailable", err)
+	}
+}
+
+func TestSingleProbeDecidesHalfOpen(t *testing.T) {
+	b, slow := newTestBreaker(t, WithWindow(1), WithOpenTimeout(10*time.Millisecond))
+	trip(t, b, slow)
+	time.Sleep(20 * time.Millisecond)
+	// The probe waits on the test, which must not count as slow
+	b.slowCall = time.Minute
+
+	started, finish := make(chan struct{}), make(chan struct{})
+	probe := make(chan error, 1)
+	go func() {
+		probe <- call(b, testMethod, func(context.Context, any) (any, error) {
+			close(started)
+			<-finish
+			return nil, nil
+		})
+	}()
+	<-started
+
+	if err := call(b, testMethod, ok); status.Code(err) != codes.Unavailable {
+		t.Errorf("second request during the probe = %v, want Unavailable", err)
+	}
+	// Background calls while the probe runs do not decide it either way
+	slow.panics.Store(true)
+	func() {
+		defer func() { recover() }()
+		b.Load("k")
+	}()
+	slow.panics.Store(false)
+	load(b, slow, false)
+	if state := b.State(); state != HalfOpen {
+		t.Errorf("state after background calls = %v, want half_open", state)
+	}
+
+	close(finish)
+	if err := <-probe; err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if state := b.State(); state != Closed {
+		t.Errorf("state after a good probe = %v, want closed", state)
+	}
+}
+
+func TestFailedProbeReopens(t *testing.T) {
+	tests := map[string]grpc.UnaryHandler{
+		"slow": func(context.Context, any) (any, error) {
+			time.Sleep(2 * testSlowCall)
+			return nil, nil
+		},
+		"panic": func(context.Context, any) (any, error) {
+			panic("storage exploded")
+		},
+	}
+	for name, handler := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, slow := newTestBreaker(t, WithWindow(1), WithOpenTimeout(10*time.Millisecond))
+			trip(t, b, slow)
+			time.Sleep(20 * time.Millisecond)
+
+			func() {
+				defer func() { recover() }()
+				call(b, testMethod, handler)
+			}()
+			if state := b.State(); state != Open {
+				t.Errorf("state after a failed probe = %v, want open", state)
+			}
+			if err := call(b, testMethod, ok); status.Code(err) != codes.Unavailable {
+				t.Errorf("request after a failed probe = %v, want Unavailable", err)
+			}
+		})
+	}
+}
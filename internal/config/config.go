@@ -0,0 +1,659 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGRPCPort       = "50051"
+	defaultHTTPPort       = "8080"
+	defaultDebugHTTPPort  = "6060"
+	defaultDebugHTTPHost  = "127.0.0.1"
+	defaultMaxValueSizeMB = 4
+	defaultHotKeyInterval = time.Minute
+	defaultEventHistory   = 1000
+	defaultTieredHotKeys  = 100000
+	defaultSeqPersist     = 1000
+	defaultZSetMaxSize    = 1000000
+	defaultZSetMaxSets    = 100000
+	defaultS3Region       = "us-east-1"
+)
+
+// Supported values for Environment
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Supported values for StorageBackend
+const (
+	StorageMemory = "memory"
+	StorageTiered = "tiered"
+)
+
+// Supported values for KeyNormalizers
+const (
+	NormalizeLowercase = "lowercase"
+	NormalizeTrimSpace = "trimspace"
+)
+
+// Supported values for AuthProvider
+const (
+	AuthStatic = "static"
+	AuthJWT    = "jwt"
+)
+
+// Supported values for RateLimitKey
+const (
+	RateLimitByPeer      = "peer"
+	RateLimitByNamespace = "namespace"
+)
+
+// Supported values for EventLogFormat
+const (
+	EventLogJSON  = "json"
+	EventLogProto = "proto"
+)
+
+// All tunable server parameters
+type ServerConfig struct {
+	GRPCPort string
+	HTTPPort string
+	// Bind to ports picked by the OS instead of GRPCPort and HTTPPort
+	DynamicPort bool
+	// Register this instance in its own store for discovery, disabled if empty
+	ServiceName string
+	// Host in the registered address, the machine's hostname if empty
+	AdvertiseHost string
+
+	LogLevel slog.Level
+
+	// Deployment environment, selects defaults for security-sensitive settings
+	Environment string
+	// Serve the gRPC reflection service, on by default outside production
+	ReflectionEnabled bool
+
+	// Largest value accepted by Set, 0 disables the limit
+	MaxValueSizeMB int
+	StorageBackend string
+
+	// Count storage calls and sample heap and GC figures into /metrics
+	StoreMetricsEnabled bool
+	// Fraction of GOMEMLIMIT above which writes are rejected, disabled when 0
+	LoadShedThreshold float64
+
+	// Tiered storage keeps this many keys in memory and the rest in a file
+	TieredHotKeys  int
+	TieredColdPath string
+
+	// Serve gRPC over TLS when both are set
+	TLSCertFile string
+	TLSKeyFile  string
+
+	DebugSampleRate float64
+	DebugLogValues  bool
+
+	// Serve net/http/pprof on DebugHTTPPort, always on in builds tagged debug
+	DebugProfilingEnabled bool
+	DebugHTTPPort         string
+	// Interface the pprof listener binds, loopback unless set
+	DebugHTTPHost string
+
+	// Hot key reporting is disabled when HotKeyTopN is 0
+	HotKeyTopN     int
+	HotKeyInterval time.Duration
+
+	// Keys unused this long are announced as idle, disabled when 0, and
+	// deleted as well when IdleKeyDelete is set
+	IdleKeyAfter  time.Duration
+	IdleKeyDelete bool
+
+	// Key normalizers applied in order, e.g. trimspace then lowercase
+	KeyNormalizers []string
+
+	// Browser origins allowed to call the HTTP endpoints, "*" for any
+	CORSOrigins []string
+
+	// Newline-delimited JSON pairs loaded before serving, disabled if empty
+	SeedFile string
+	// Readiness fails until POST /admin/mark-ready, for a seed written by another process
+	SeedExternal bool
+	// Seeding replaces keys that already hold a value instead of skipping them
+	SeedOverwrite bool
+
+	// How long subscribers are warned of a shutdown before streams close, 0 disables the warning
+	ShutdownDrainSignalLeadTime time.Duration
+
+	// Export per-pattern notify loop timings and enqueue counts
+	NotifyMetricsEnabled bool
+
+	// Allocate subscriber channels on first event rather than on subscribe
+	SubscriberLazyChannels bool
+	// Reject requests while storage calls keep failing
+	CircuitBreakerEnabled bool
+	// Storage calls slower than this count as failures
+	CircuitBreakerSlowCall time.Duration
+	// Failure rate over the last CircuitBreakerWindow calls that opens the circuit
+	CircuitBreakerThreshold float64
+	CircuitBreakerWindow    int
+	// How long the circuit stays open before a request probes storage again
+	CircuitBreakerOpenTimeout time.Duration
+
+	// Subscriber channels pre-allocated and reused across subscriptions, 0 disables pooling
+	SubscriberChannelPoolSize int
+	// Publish to a copy-on-write subscriber list instead of under a lock
+	SubscriberLockFree bool
+
+	// Events per second sent to subscribers for any one key, 0 disables the limit
+	KeyEventRateLimit float64
+	KeyEventRateBurst int
+
+	// How long an unused ConsistentScan session keeps its cursor valid
+	ScanSessionTTL time.Duration
+
+	// How long PrefixStats results are reused, 0 disables the cache
+	PrefixStatsCacheTTL time.Duration
+
+	// Most members one sorted set may hold, 0 for unlimited
+	ZSetMaxSize int
+	// Most sorted sets stored at once, 0 for unlimited
+	ZSetMaxSets int
+
+	// S3-compatible endpoint for admin snapshots, disabled if empty
+	S3Endpoint string
+	// Credentials and region requests to S3Endpoint are signed with, unsigned
+	// without an access key
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3Region          string
+
+	// Requests per second per bucket, rate limiting is disabled when 0
+	RateLimitRPS   float64
+	RateLimitBurst int
+	RateLimitKey   string
+	// Per-namespace overrides of RateLimitRPS
+	RateLimitNamespaceRPS map[string]float64
+
+	// Base path of the daily mutation log, disabled if empty
+	EventLogPath string
+	// Encoding of new event log files
+	EventLogFormat string
+
+	// Endpoint receiving batches of mutation records as JSON arrays, disabled if empty
+	AuditWebhookURL           string
+	AuditWebhookBatchSize     int
+	AuditWebhookFlushInterval time.Duration
+	// Header sent with every webhook request, as "Name: value"
+	AuditWebhookAuthHeader string
+	// Syslog daemon receiving mutation records, disabled if empty
+	AuditSyslogAddr    string
+	AuditSyslogNetwork string
+	// Records each forwarder holds before dropping new ones
+	AuditBufferSize int
+
+	// Recent events kept for subscribers resuming by sequence, 0 disables resume
+	EventHistorySize int
+	// Events kept per key when the history is compacted every interval, 0 disables compaction
+	HistoryCompactRetainLast int
+	HistoryCompactInterval   time.Duration
+	// File keeping sequence numbers increasing across restarts, disabled if empty
+	SequenceFile string
+	// Sequence numbers reserved per write, at most this many are skipped after a crash
+	SequencePersistInterval int
+
+	// HMAC keys by key ID for verifying signed requests, verification is disabled when empty
+	RequestSigningKeys map[string]string
+	// Oldest request signature accepted
+	RequestSigningMaxAge time.Duration
+
+	// How unary requests are authenticated, disabled if empty
+	AuthProvider string
+	// API keys for the static provider, one "<key> <subject> [roles]" per line
+	AuthKeyFile string
+	// Signing keys for the jwt provider, and the aud and iss tokens must carry
+	AuthJWKSURL     string
+	AuthJWTAudience string
+	AuthJWTIssuer   string
+
+	// Identifies this instance to sync peers, random per process if empty
+	NodeID string
+	// gRPC address of a peer to sync changes with, disabled if empty
+	SyncPeerAddr string
+	// Bearer token sent to the sync peer and upstream store, none if empty
+	PeerTokenFile string
+	// gRPC address of a store Get falls back to on a miss, disabled if empty
+	UpstreamAddr string
+	// How long values filled from the upstream store live, 0 for no expiry
+	UpstreamFillTTL time.Duration
+}
+
+// Defaults used for any unset variable
+func Default() *ServerConfig {
+	return &ServerConfig{
+		GRPCPort:       defaultGRPCPort,
+		HTTPPort:       defaultHTTPPort,
+		DebugHTTPPort:  defaultDebugHTTPPort,
+		DebugHTTPHost:  defaultDebugHTTPHost,
+		LogLevel:       slog.LevelInfo,
+		Environment:    EnvDevelopment,
+		MaxValueSizeMB: defaultMaxValueSizeMB,
+		StorageBackend: StorageMemory,
+		TieredHotKeys:  defaultTieredHotKeys,
+		HotKeyInterval: defaultHotKeyInterval,
+		S3Region:       defaultS3Region,
+		RateLimitBurst: 1,
+		RateLimitKey:   RateLimitByPeer,
+		EventLogFormat: EventLogJSON,
+
+		EventHistorySize:        defaultEventHistory,
+		HistoryCompactInterval:  time.Minute,
+		SequencePersistInterval: defaultSeqPersist,
+		RequestSigningMaxAge:    30 * time.Second,
+
+		ShutdownDrainSignalLeadTime: 5 * time.Second,
+
+		CircuitBreakerSlowCall:    time.Second,
+		CircuitBreakerThreshold:   0.5,
+		CircuitBreakerWindow:      10,
+		CircuitBreakerOpenTimeout: 30 * time.Second,
+
+		AuditWebhookBatchSize:     100,
+		AuditWebhookFlushInterval: 5 * time.Second,
+		AuditSyslogNetwork:        "udp",
+		AuditBufferSize:           4096,
+
+		KeyEventRateBurst:   10,
+		ScanSessionTTL:      time.Minute,
+		PrefixStatsCacheTTL: 5 * time.Second,
+		ZSetMaxSize:         defaultZSetMaxSize,
+		ZSetMaxSets:         defaultZSetMaxSets,
+	}
+}
+
+// Read the server configuration from environment variables and validate it.
+// Fails with ConfigErrors listing every problem, not just the first
+func Load() (*ServerConfig, error) {
+	cfg := Default()
+	var errs []ConfigError
+
+	cfg.GRPCPort = getEnv("GRPC_PORT", cfg.GRPCPort)
+	cfg.HTTPPort = getEnv("HTTP_PORT", cfg.HTTPPort)
+	cfg.DebugHTTPPort = getEnv("DEBUG_HTTP_PORT", cfg.DebugHTTPPort)
+	cfg.DebugHTTPHost = getEnv("DEBUG_HTTP_HOST", cfg.DebugHTTPHost)
+	cfg.Environment = getEnv("ENVIRONMENT", cfg.Environment)
+	cfg.ReflectionEnabled = cfg.Environment != EnvProduction
+	cfg.StorageBackend = getEnv("STORAGE_BACKEND", cfg.StorageBackend)
+	cfg.TieredColdPath = os.Getenv("TIERED_COLD_PATH")
+	cfg.TLSCertFile = os.Getenv("TLS_CERT_FILE")
+	cfg.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
+	cfg.S3Endpoint = os.Getenv("S3_ENDPOINT")
+	cfg.S3AccessKeyID = os.Getenv("S3_ACCESS_KEY_ID")
+	cfg.S3SecretAccessKey = os.Getenv("S3_SECRET_ACCESS_KEY")
+	cfg.S3Region = getEnv("S3_REGION", cfg.S3Region)
+	cfg.SeedFile = os.Getenv("SEED_FILE")
+	cfg.AuthProvider = os.Getenv("AUTH_PROVIDER")
+	cfg.AuthKeyFile = os.Getenv("AUTH_KEY_FILE")
+	cfg.AuthJWKSURL = os.Getenv("AUTH_JWKS_URL")
+	cfg.AuthJWTAudience = os.Getenv("AUTH_JWT_AUDIENCE")
+	cfg.AuthJWTIssuer = os.Getenv("AUTH_JWT_ISSUER")
+	cfg.RateLimitKey = getEnv("RATE_LIMIT_KEY", cfg.RateLimitKey)
+	cfg.EventLogPath = os.Getenv("EVENT_LOG_PATH")
+	cfg.EventLogFormat = getEnv("EVENT_LOG_FORMAT", cfg.EventLogFormat)
+	cfg.SequenceFile = os.Getenv("SEQUENCE_FILE")
+	cfg.NodeID = os.Getenv("NODE_ID")
+	cfg.SyncPeerAddr = os.Getenv("SYNC_PEER_ADDR")
+	cfg.UpstreamAddr = os.Getenv("UPSTREAM_ADDR")
+	cfg.PeerTokenFile = os.Getenv("PEER_TOKEN_FILE")
+	cfg.ServiceName = os.Getenv("SERVICE_NAME")
+	cfg.AdvertiseHost = os.Getenv("ADVERTISE_HOST")
+	cfg.AuditWebhookURL = os.Getenv("AUDIT_WEBHOOK_URL")
+	cfg.AuditWebhookAuthHeader = os.Getenv("AUDIT_WEBHOOK_AUTH_HEADER")
+	cfg.AuditSyslogAddr = os.Getenv("AUDIT_SYSLOG_ADDR")
+	cfg.AuditSyslogNetwork = getEnv("AUDIT_SYSLOG_NETWORK", cfg.AuditSyslogNetwork)
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.CORSOrigins = append(cfg.CORSOrigins, origin)
+			}
+		}
+	}
+	if v := os.Getenv("KEY_NORMALIZER"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			cfg.KeyNormalizers = append(cfg.KeyNormalizers, strings.TrimSpace(name))
+		}
+	}
+
+	// Parsed by hand so errors never echo the secrets
+	if v := os.Getenv("REQUEST_SIGNING_KEYS"); v != "" {
+		keys, err := parseStringMap(v)
+		if err != nil {
+			errs = append(errs, ConfigError{Field: "REQUEST_SIGNING_KEYS", Problem: err.Error(), Suggestion: "set to comma-separated keyID=secret pairs"})
+		}
+		cfg.RequestSigningKeys = keys
+	}
+
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := cfg.LogLevel.UnmarshalText([]byte(v)); err != nil {
+			errs = append(errs, ConfigError{Field: "LOG_LEVEL", Value: v, Problem: "unknown log level", Suggestion: "set to debug, info, warn or error"})
+		}
+	}
+
+	parseEnv(&errs, "GRPC_REFLECTION_ENABLED", &cfg.ReflectionEnabled, strconv.ParseBool)
+	parseEnv(&errs, "DYNAMIC_PORT", &cfg.DynamicPort, strconv.ParseBool)
+	parseEnv(&errs, "MAX_VALUE_SIZE_MB", &cfg.MaxValueSizeMB, strconv.Atoi)
+	parseEnv(&errs, "STORE_METRICS_ENABLED", &cfg.StoreMetricsEnabled, strconv.ParseBool)
+	parseEnv(&errs, "LOAD_SHED_THRESHOLD", &cfg.LoadShedThreshold, parseFloat)
+	parseEnv(&errs, "TIERED_HOT_KEYS", &cfg.TieredHotKeys, strconv.Atoi)
+	parseEnv(&errs, "DEBUG_SAMPLE_RATE", &cfg.DebugSampleRate, parseFloat)
+	parseEnv(&errs, "DEBUG_LOG_VALUES", &cfg.DebugLogValues, strconv.ParseBool)
+	parseEnv(&errs, "DEBUG_PROFILING_ENABLED", &cfg.DebugProfilingEnabled, strconv.ParseBool)
+	parseEnv(&errs, "HOT_KEY_TOP_N", &cfg.HotKeyTopN, strconv.Atoi)
+	parseEnv(&errs, "HOT_KEY_INTERVAL", &cfg.HotKeyInterval, time.ParseDuration)
+	parseEnv(&errs, "IDLE_KEY_AFTER", &cfg.IdleKeyAfter, time.ParseDuration)
+	parseEnv(&errs, "IDLE_KEY_DELETE", &cfg.IdleKeyDelete, strconv.ParseBool)
+	parseEnv(&errs, "RATE_LIMIT_RPS", &cfg.RateLimitRPS, parseFloat)
+	parseEnv(&errs, "RATE_LIMIT_BURST", &cfg.RateLimitBurst, strconv.Atoi)
+	parseEnv(&errs, "RATE_LIMIT_NAMESPACE_RPS", &cfg.RateLimitNamespaceRPS, parseFloatMap)
+	parseEnv(&errs, "EVENT_HISTORY_SIZE", &cfg.EventHistorySize, strconv.Atoi)
+	parseEnv(&errs, "EVENT_HISTORY_COMPACT_RETAIN_LAST", &cfg.HistoryCompactRetainLast, strconv.Atoi)
+	parseEnv(&errs, "EVENT_HISTORY_COMPACT_INTERVAL", &cfg.HistoryCompactInterval, time.ParseDuration)
+	parseEnv(&errs, "REQUEST_SIGNING_MAX_AGE", &cfg.RequestSigningMaxAge, time.ParseDuration)
+	parseEnv(&errs, "SEQUENCE_PERSIST_INTERVAL", &cfg.SequencePersistInterval, strconv.Atoi)
+	parseEnv(&errs, "AUDIT_WEBHOOK_BATCH_SIZE", &cfg.AuditWebhookBatchSize, strconv.Atoi)
+	parseEnv(&errs, "AUDIT_WEBHOOK_FLUSH_INTERVAL", &cfg.AuditWebhookFlushInterval, time.ParseDuration)
+	parseEnv(&errs, "AUDIT_BUFFER_SIZE", &cfg.AuditBufferSize, strconv.Atoi)
+	parseEnv(&errs, "SHUTDOWN_DRAIN_SIGNAL_LEAD_TIME", &cfg.ShutdownDrainSignalLeadTime, time.ParseDuration)
+	parseEnv(&errs, "STORAGE_CIRCUIT_BREAKER", &cfg.CircuitBreakerEnabled, strconv.ParseBool)
+	parseEnv(&errs, "STORAGE_CIRCUIT_BREAKER_SLOW_CALL", &cfg.CircuitBreakerSlowCall, time.ParseDuration)
+	parseEnv(&errs, "STORAGE_CIRCUIT_BREAKER_THRESHOLD", &cfg.CircuitBreakerThreshold, parseFloat)
+	parseEnv(&errs, "STORAGE_CIRCUIT_BREAKER_WINDOW", &cfg.CircuitBreakerWindow, strconv.Atoi)
+	parseEnv(&errs, "STORAGE_CIRCUIT_BREAKER_OPEN_TIMEOUT", &cfg.CircuitBreakerOpenTimeout, time.ParseDuration)
+	parseEnv(&errs, "UPSTREAM_FILL_TTL", &cfg.UpstreamFillTTL, time.ParseDuration)
+	parseEnv(&errs, "NOTIFY_METRICS_ENABLED", &cfg.NotifyMetricsEnabled, strconv.ParseBool)
+	parseEnv(&errs, "SEED_EXTERNAL", &cfg.SeedExternal, strconv.ParseBool)
+	parseEnv(&errs, "SEED_OVERWRITE", &cfg.SeedOverwrite, strconv.ParseBool)
+	parseEnv(&errs, "SUBSCRIBER_LAZY_CHANNELS", &cfg.SubscriberLazyChannels, strconv.ParseBool)
+	parseEnv(&errs, "SUBSCRIBER_CHANNEL_POOL_SIZE", &cfg.SubscriberChannelPoolSize, strconv.Atoi)
+	parseEnv(&errs, "SUBSCRIBER_LOCK_FREE", &cfg.SubscriberLockFree, strconv.ParseBool)
+	parseEnv(&errs, "KEY_EVENT_RATE_LIMIT", &cfg.KeyEventRateLimit, parseFloat)
+	parseEnv(&errs, "KEY_EVENT_RATE_BURST", &cfg.KeyEventRateBurst, strconv.Atoi)
+	parseEnv(&errs, "SCAN_SESSION_TTL", &cfg.ScanSessionTTL, time.ParseDuration)
+	parseEnv(&errs, "PREFIX_STATS_CACHE_TTL", &cfg.PrefixStatsCacheTTL, time.ParseDuration)
+	parseEnv(&errs, "ZSET_MAX_SIZE", &cfg.ZSetMaxSize, strconv.Atoi)
+	parseEnv(&errs, "ZSET_MAX_SETS", &cfg.ZSetMaxSets, strconv.Atoi)
+
+	errs = append(errs, cfg.Validate()...)
+	if len(errs) > 0 {
+		return nil, ConfigErrors(errs)
+	}
+	return cfg, nil
+}
+
+// Check value ranges and combinations, returning every problem found
+func (c *ServerConfig) Validate() []ConfigError {
+	var errs []ConfigError
+	add := func(field string, value any, problem, suggestion string) {
+		errs = append(errs, ConfigError{Field: field, Value: fmt.Sprint(value), Problem: problem, Suggestion: suggestion})
+	}
+
+	ports := []struct{ name, port string }{{"GRPC_PORT", c.GRPCPort}, {"HTTP_PORT", c.HTTPPort}, {"DEBUG_HTTP_PORT", c.DebugHTTPPort}}
+	for _, p := range ports {
+		if n, err := strconv.Atoi(p.port); err != nil || n < 1 || n > 65535 {
+			add(p.name, p.port, "not a valid port", "set to a number from 1 to 65535")
+		}
+	}
+	if c.Environment != EnvDevelopment && c.Environment != EnvProduction {
+		add("ENVIRONMENT", c.Environment, "unknown environment", fmt.Sprintf("set to %q or %q", EnvDevelopment, EnvProduction))
+	}
+	if c.MaxValueSizeMB < 0 {
+		add("MAX_VALUE_SIZE_MB", c.MaxValueSizeMB, "must not be negative", fmt.Sprintf("set to a positive integer like %d, or 0 for no limit", defaultMaxValueSizeMB))
+	}
+	switch c.StorageBackend {
+	case StorageMemory:
+	case StorageTiered:
+		if c.TieredColdPath == "" {
+			add("TIERED_COLD_PATH", "", "required for the tiered backend", "set to a directory for keys evicted from memory")
+		}
+		if c.TieredHotKeys < 1 {
+			add("TIERED_HOT_KEYS", c.TieredHotKeys, "must be at least 1", fmt.Sprintf("set to the keys to keep in memory, such as %d", defaultTieredHotKeys))
+		}
+	default:
+		add("STORAGE_BACKEND", c.StorageBackend, "unsupported backend", fmt.Sprintf("set to %q or %q", StorageMemory, StorageTiered))
+	}
+	if c.TLSCertFile == "" && c.TLSKeyFile != "" {
+		add("TLS_CERT_FILE", "", "required when TLS_KEY_FILE is set", "set both to enable TLS, or neither")
+	}
+	if c.TLSKeyFile == "" && c.TLSCertFile != "" {
+		add("TLS_KEY_FILE", "", "required when TLS_CERT_FILE is set", "set both to enable TLS, or neither")
+	}
+	if c.DebugSampleRate < 0 || c.DebugSampleRate > 1 {
+		add("DEBUG_SAMPLE_RATE", c.DebugSampleRate, "must be between 0 and 1", "set to a fraction such as 0.01 to log 1% of requests")
+	}
+	if c.HotKeyTopN < 0 {
+		add("HOT_KEY_TOP_N", c.HotKeyTopN, "must not be negative", "set to the number of keys to report, or 0 to disable")
+	}
+	if c.HotKeyTopN > 0 && c.HotKeyInterval <= 0 {
+		add("HOT_KEY_INTERVAL", c.HotKeyInterval, "must be positive", fmt.Sprintf("set to a duration such as %s", defaultHotKeyInterval))
+	}
+	if c.IdleKeyAfter < 0 {
+		add("IDLE_KEY_AFTER", c.IdleKeyAfter, "must not be negative", "set to a duration such as 1h, or 0 to disable")
+	}
+	if c.IdleKeyDelete && c.IdleKeyAfter <= 0 {
+		add("IDLE_KEY_DELETE", c.IdleKeyDelete, "requires IDLE_KEY_AFTER", "set IDLE_KEY_AFTER to the idle period, such as 1h")
+	}
+
+	for _, name := range c.KeyNormalizers {
+		if name != NormalizeLowercase && name != NormalizeTrimSpace {
+			add("KEY_NORMALIZER", name, "unknown normalizer", fmt.Sprintf("use %q and %q, comma-separated", NormalizeTrimSpace, NormalizeLowercase))
+		}
+	}
+
+	if c.LoadShedThreshold < 0 || c.LoadShedThreshold > 1 {
+		add("LOAD_SHED_THRESHOLD", c.LoadShedThreshold, "must be between 0 and 1", "set to a fraction of GOMEMLIMIT such as 0.9, or 0 to disable")
+	}
+
+	if c.RateLimitRPS < 0 {
+		add("RATE_LIMIT_RPS", c.RateLimitRPS, "must not be negative", "set to the requests per second allowed, or 0 to disable")
+	}
+	if c.RateLimitRPS > 0 && c.RateLimitBurst < 1 {
+		add("RATE_LIMIT_BURST", c.RateLimitBurst, "must be at least 1", "set to the requests allowed at once, such as 1")
+	}
+	if c.RateLimitKey != RateLimitByPeer && c.RateLimitKey != RateLimitByNamespace {
+		add("RATE_LIMIT_KEY", c.RateLimitKey, "unknown rate limit key", fmt.Sprintf("set to %q or %q", RateLimitByPeer, RateLimitByNamespace))
+	}
+
+	if c.EventHistorySize < 0 {
+		add("EVENT_HISTORY_SIZE", c.EventHistorySize, "must not be negative", fmt.Sprintf("set to the events to keep, such as %d, or 0 to disable resume", defaultEventHistory))
+	}
+	if c.HistoryCompactRetainLast < 0 {
+		add("EVENT_HISTORY_COMPACT_RETAIN_LAST", c.HistoryCompactRetainLast, "must not be negative", "set to the events to keep per key, or 0 to disable compaction")
+	}
+	if c.HistoryCompactRetainLast > 0 && c.HistoryCompactInterval <= 0 {
+		add("EVENT_HISTORY_COMPACT_INTERVAL", c.HistoryCompactInterval, "must be positive", "set to a duration such as 1m")
+	}
+	switch c.AuthProvider {
+	case "":
+	case AuthStatic:
+		if c.AuthKeyFile == "" {
+			add("AUTH_KEY_FILE", "", "required for the static auth provider", "set to a file of API keys")
+		}
+	case AuthJWT:
+		if c.AuthJWKSURL == "" {
+			add("AUTH_JWKS_URL", "", "required for the jwt auth provider", "set to the URL of the token issuer's JWKS")
+		}
+	default:
+		add("AUTH_PROVIDER", c.AuthProvider, "unknown auth provider", fmt.Sprintf("set to %q or %q, or leave unset to disable", AuthStatic, AuthJWT))
+	}
+	if len(c.RequestSigningKeys) > 0 && c.RequestSigningMaxAge <= 0 {
+		add("REQUEST_SIGNING_MAX_AGE", c.RequestSigningMaxAge, "must be positive", "set to a duration such as 30s")
+	}
+	if c.ShutdownDrainSignalLeadTime < 0 {
+		add("SHUTDOWN_DRAIN_SIGNAL_LEAD_TIME", c.ShutdownDrainSignalLeadTime, "cannot be negative", "set to a duration such as 5s, or 0 to disable")
+	}
+	if c.CircuitBreakerEnabled {
+		if c.CircuitBreakerSlowCall <= 0 {
+			add("STORAGE_CIRCUIT_BREAKER_SLOW_CALL", c.CircuitBreakerSlowCall, "must be positive", "set to a duration such as 1s")
+		}
+		if c.CircuitBreakerThreshold <= 0 || c.CircuitBreakerThreshold > 1 {
+			add("STORAGE_CIRCUIT_BREAKER_THRESHOLD", c.CircuitBreakerThreshold, "must be above 0 and at most 1", "set to a failure fraction such as 0.5")
+		}
+		if c.CircuitBreakerWindow < 1 {
+			add("STORAGE_CIRCUIT_BREAKER_WINDOW", c.CircuitBreakerWindow, "must be at least 1", "set to the calls to consider, such as 10")
+		}
+		if c.CircuitBreakerOpenTimeout <= 0 {
+			add("STORAGE_CIRCUIT_BREAKER_OPEN_TIMEOUT", c.CircuitBreakerOpenTimeout, "must be positive", "set to a duration such as 30s")
+		}
+	}
+	if c.UpstreamFillTTL < 0 {
+		add("UPSTREAM_FILL_TTL", c.UpstreamFillTTL, "cannot be negative", "set to a duration such as 5m, or 0 to keep filled values")
+	}
+	if c.SubscriberChannelPoolSize < 0 {
+		add("SUBSCRIBER_CHANNEL_POOL_SIZE", c.SubscriberChannelPoolSize, "cannot be negative", "set to the channels to pre-allocate, or 0 to disable pooling")
+	}
+	if c.KeyEventRateLimit < 0 {
+		add("KEY_EVENT_RATE_LIMIT", c.KeyEventRateLimit, "cannot be negative", "set to the events per second allowed per key, or 0 to disable")
+	}
+	if c.KeyEventRateBurst < 1 {
+		add("KEY_EVENT_RATE_BURST", c.KeyEventRateBurst, "must be at least 1", "set to the events allowed at once per key, such as 10")
+	}
+	if c.ScanSessionTTL <= 0 {
+		add("SCAN_SESSION_TTL", c.ScanSessionTTL, "must be positive", "set to a duration such as 1m")
+	}
+	if c.PrefixStatsCacheTTL < 0 {
+		add("PREFIX_STATS_CACHE_TTL", c.PrefixStatsCacheTTL, "must not be negative", "set to a duration such as 5s, or 0 to disable the cache")
+	}
+	if c.ZSetMaxSize < 0 {
+		add("ZSET_MAX_SIZE", c.ZSetMaxSize, "cannot be negative", fmt.Sprintf("set to a positive integer like %d, or 0 for no limit", defaultZSetMaxSize))
+	}
+	if c.ZSetMaxSets < 0 {
+		add("ZSET_MAX_SETS", c.ZSetMaxSets, "cannot be negative", fmt.Sprintf("set to a positive integer like %d, or 0 for no limit", defaultZSetMaxSets))
+	}
+	if c.SequencePersistInterval < 1 {
+		add("SEQUENCE_PERSIST_INTERVAL", c.SequencePersistInterval, "must be at least 1", fmt.Sprintf("set to the sequence numbers reserved per write, such as %d", defaultSeqPersist))
+	}
+	if c.SeedFile != "" {
+		if info, err := os.Stat(c.SeedFile); err != nil || info.IsDir() {
+			add("SEED_FILE", c.SeedFile, "not a readable file", "check the path, or leave unset to start empty")
+		}
+	}
+	if c.SequenceFile != "" {
+		if info, err := os.Stat(filepath.Dir(c.SequenceFile)); err != nil || !info.IsDir() {
+			add("SEQUENCE_FILE", c.SequenceFile, "directory does not exist", "create the directory or choose a path in an existing one")
+		}
+	}
+	if c.EventLogPath != "" {
+		if info, err := os.Stat(filepath.Dir(c.EventLogPath)); err != nil || !info.IsDir() {
+			add("EVENT_LOG_PATH", c.EventLogPath, "directory does not exist", "create the directory or choose a path in an existing one")
+		}
+	}
+	if c.EventLogFormat != EventLogJSON && c.EventLogFormat != EventLogProto {
+		add("EVENT_LOG_FORMAT", c.EventLogFormat, "unknown format", fmt.Sprintf("set to %q or %q", EventLogJSON, EventLogProto))
+	}
+
+	// Neither the URL nor the header is echoed, both may carry credentials
+	if c.AuditWebhookURL != "" {
+		if u, err := url.Parse(c.AuditWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("AUDIT_WEBHOOK_URL", "", "not an http or https URL", "set to a URL such as https://audit.example.com/ingest")
+		}
+		if c.AuditWebhookBatchSize < 1 {
+			add("AUDIT_WEBHOOK_BATCH_SIZE", c.AuditWebhookBatchSize, "must be at least 1", "set to the records sent per request, such as 100")
+		}
+		if c.AuditWebhookFlushInterval <= 0 {
+			add("AUDIT_WEBHOOK_FLUSH_INTERVAL", c.AuditWebhookFlushInterval, "must be positive", "set to a duration such as 5s")
+		}
+	}
+	if c.AuditWebhookAuthHeader != "" {
+		if name, _, ok := c.AuditWebhookHeader(); !ok || name == "" {
+			add("AUDIT_WEBHOOK_AUTH_HEADER", "", "not a header", `format as "Name: value", e.g. "Authorization: Bearer <token>"`)
+		}
+	}
+	if c.AuditSyslogAddr != "" && c.AuditSyslogNetwork != "tcp" && c.AuditSyslogNetwork != "udp" {
+		add("AUDIT_SYSLOG_NETWORK", c.AuditSyslogNetwork, "unknown network", `set to "tcp" or "udp"`)
+	}
+	if c.AuditBufferSize < 1 {
+		add("AUDIT_BUFFER_SIZE", c.AuditBufferSize, "must be at least 1", "set to the records each forwarder holds, such as 4096")
+	}
+
+	return errs
+}
+
+// Split AuditWebhookAuthHeader into its name and value
+func (c *ServerConfig) AuditWebhookHeader() (string, string, bool) {
+	name, value, ok := strings.Cut(c.AuditWebhookAuthHeader, ":")
+	return strings.TrimSpace(name), strings.TrimSpace(value), ok
+}
+
+// Report whether gRPC should be served over TLS
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// Largest accepted value in bytes, 0 if unlimited
+func (c *ServerConfig) MaxValueSizeBytes() int {
+	return c.MaxValueSizeMB * 1024 * 1024
+}
+
+// Parse an optional variable into dst, recording a parse failure
+func parseEnv[T any](errs *[]ConfigError, key string, dst *T, parse func(string) (T, error)) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parsed, err := parse(v)
+	if err != nil {
+		problem, suggestion := describeParseError(dst, err)
+		*errs = append(*errs, ConfigError{Field: key, Value: v, Problem: problem, Suggestion: suggestion})
+		return
+	}
+	*dst = parsed
+}
+
+func parseFloat(v string) (float64, error) {
+	return strconv.ParseFloat(v, 64)
+}
+
+// Parse a comma-separated list of name=number pairs
+func parseFloatMap(v string) (map[string]float64, error) {
+	result := make(map[string]float64)
+	for _, pair := range strings.Split(v, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("expected name=value, got %q", pair)
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		result[name] = n
+	}
+	return result, nil
+}
+
+// Parse a comma-separated list of name=value pairs, values may contain '='.
+// Errors identify entries by position only
+func parseStringMap(v string) (map[string]string, error) {
+	result := make(map[string]string)
+	for i, pair := range strings.Split(v, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("entry %d: expected name=value", i+1)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// Retrieve environment variable or use default
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Problem with one setting and how to fix it
+type ConfigError struct {
+	// Environment variable at fault
+	Field string
+	// What it was set to, empty if unset or if it may hold secrets
+	Value string
+	// What is wrong with it
+	Problem string
+	// How to fix it, empty when the problem says it all
+	Suggestion string
+}
+
+func (e ConfigError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Field)
+	if e.Value != "" {
+		fmt.Fprintf(&b, "=%q", e.Value)
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Problem)
+	if e.Suggestion != "" {
+		b.WriteString("; ")
+		b.WriteString(e.Suggestion)
+	}
+	return b.String()
+}
+
+// Every problem found in a configuration, one per line
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Explain why parseEnv rejected a value in terms of the type dst expects,
+// suggesting its current default where that makes a sensible example
+func describeParseError(dst any, err error) (problem, suggestion string) {
+	switch d := dst.(type) {
+	case *bool:
+		return "not a valid boolean", "set to true or false"
+	case *int:
+		return "not a valid integer", fmt.Sprintf("set to a whole number such as the default, %d", *d)
+	case *float64:
+		return "not a valid number", fmt.Sprintf("set to a number such as the default, %g", *d)
+	case *time.Duration:
+		return "not a valid duration", "set to a number with a unit such as 500ms, 30s or 5m"
+	case *map[string]float64:
+		return err.Error(), "set to comma-separated name=number pairs such as premium=500,trial=5"
+	default:
+		return err.Error(), ""
+	}
+}
@@ -0,0 +1,410 @@
+package eventlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Events buffered before Append starts dropping
+	defaultBufferSize = 4096
+
+	// How often the writer checks whether the day has changed
+	rotationCheckInterval = time.Minute
+
+	// Least time between warnings about dropped entries
+	dropWarnInterval = 10 * time.Second
+
+	dateLayout = "2006-01-02"
+)
+
+// Encoding of a log file, recorded in its first byte so readers can tell
+// formats apart
+type Format byte
+
+const (
+	// Length-prefixed EventLogEntry messages
+	FormatProto Format = 0x00
+	// One JSON object per line
+	FormatJSON Format = 0x01
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatProto:
+		return "proto"
+	case FormatJSON:
+		return "json"
+	default:
+		return fmt.Sprintf("unknown(%#x)", byte(f))
+	}
+}
+
+// Format of a log file from its first bytes and the length of its header.
+// Files written before the header was added start with '{' and are JSON
+// with no header
+func DetectFormat(data []byte) (Format, int, error) {
+	if len(data) == 0 {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	switch data[0] {
+	case byte(FormatProto), byte(FormatJSON):
+		return Format(data[0]), 1, nil
+	case '{':
+		return FormatJSON, 0, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown event log header %#x", data[0])
+	}
+}
+
+// Decode the first entry in data and return the number of bytes it took up,
+// 0 if data ends partway through it. A malformed entry still reports its
+// length so the reader can skip it
+func Decode(format Format, data []byte) (Entry, int, error) {
+	var entry Entry
+	if format == FormatJSON {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return entry, 0, nil
+		}
+		err := json.Unmarshal(data[:i], &entry)
+		return entry, i + 1, err
+	}
+
+	size, k := binary.Uvarint(data)
+	if k < 0 {
+		// Without a length the rest of the data cannot be split into entries
+		return entry, len(data), errors.New("invalid entry length")
+	}
+	if k == 0 || size > uint64(len(data)-k) {
+		return entry, 0, nil
+	}
+	end := k + int(size)
+	var msg pb.EventLogEntry
+	if err := proto.Unmarshal(data[k:end], &msg); err != nil {
+		return entry, end, err
+	}
+	return Entry{
+		Timestamp: msg.Ts,
+		Op:        msg.Op,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		StartKey:  msg.StartKey,
+		EndKey:    msg.EndKey,
+		Caller:    msg.Caller,
+	}, end, nil
+}
+
+// Single mutation record
+type Entry struct {
+	// Unix nanoseconds
+	Timestamp int64  `json:"ts"`
+	Op        string `json:"op"`
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`
+	StartKey  string `json:"start_key,omitempty"`
+	EndKey    string `json:"end_key,omitempty"`
+	Caller    string `json:"caller,omitempty"`
+}
+
+// Append-only event log written by a background goroutine, rotated daily
+type Writer struct {
+	base    string
+	format  Format
+	entries chan Entry
+
+	file *os.File
+	buf  *bufio.Writer
+	day  string
+	// Format of the open file, which may predate a change of format
+	fileFormat Format
+	enc        *json.Encoder
+
+	// Guards entries against sends after Close
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	// Limits warnings about dropped entries, which come in floods
+	dropWarn rate.Sometimes
+}
+
+// Path of the log file for a given day, e.g. events.log -> events-2024-01-02.log
+func PathForDay(base string, day time.Time) string {
+	ext := filepath.Ext(base)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(base, ext), day.Format(dateLayout), ext)
+}
+
+// Configure a Writer
+type Option func(*Writer)
+
+// Encode entries in new files with format, JSON by default. A file already
+// started in another format keeps it until the next rotation
+func WithFormat(format Format) Option {
+	return func(w *Writer) {
+		w.format = format
+	}
+}
+
+// Open today's log file in append mode and start the writer
+func Open(base string, opts ...Option) (*Writer, error) {
+	w := &Writer{
+		base:     base,
+		format:   FormatJSON,
+		entries:  make(chan Entry, defaultBufferSize),
+		done:     make(chan struct{}),
+		dropWarn: rate.Sometimes{Interval: dropWarnInterval},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if err := w.rotate(time.Now()); err != nil {
+		return nil, err
+	}
+
+	go w.run()
+	slog.Info("event log opened", "path", w.file.Name(), "format", w.fileFormat.String())
+	return w, nil
+}
+
+// Queue an entry without blocking, returning false if the buffer is full or
+// closed. Entries dropped for a full buffer are counted in
+// kvstore_event_log_dropped_total
+func (w *Writer) Append(entry Entry) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return false
+	}
+
+	select {
+	case w.entries <- entry:
+		metrics.EventLogLag.Set(float64(len(w.entries)))
+		return true
+	default:
+		metrics.EventLogDropped.Inc()
+		w.dropWarn.Do(func() {
+			slog.Warn("event log buffer full, dropping entries, see kvstore_event_log_dropped_total", "op", entry.Op, "key", entry.Key)
+		})
+		return false
+	}
+}
+
+// Flush buffered entries and close the file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.entries)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+	return w.file.Close()
+}
+
+// Write entries until closed, rotating when the day changes
+func (w *Writer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(rotationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case entry, ok := <-w.entries:
+			if !ok {
+				w.flush()
+				return
+			}
+			if err := w.write(entry); err != nil {
+				slog.Error("failed to write event log entry", "error", err)
+			}
+			// Flush once caught up so entries reach disk promptly
+			if len(w.entries) == 0 {
+				w.flush()
+			}
+			metrics.EventLogLag.Set(float64(len(w.entries)))
+		case now := <-ticker.C:
+			if now.Format(dateLayout) == w.day {
+				continue
+			}
+			w.flush()
+			if err := w.rotate(now); err != nil {
+				slog.Error("failed to rotate event log, continuing with current file", "error", err)
+				continue
+			}
+			slog.Info("event log rotated", "path", w.file.Name(), "format", w.fileFormat.String())
+		}
+	}
+}
+
+// Encode one entry in the format of the open file
+func (w *Writer) write(entry Entry) error {
+	if w.fileFormat == FormatJSON {
+		return w.enc.Encode(entry)
+	}
+
+	data, err := proto.Marshal(&pb.EventLogEntry{
+		Ts:       entry.Timestamp,
+		Op:       entry.Op,
+		Key:      entry.Key,
+		Value:    entry.Value,
+		StartKey: entry.StartKey,
+		EndKey:   entry.EndKey,
+		Caller:   entry.Caller,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := w.buf.Write(binary.AppendUvarint(nil, uint64(len(data)))); err != nil {
+		return err
+	}
+	_, err = w.buf.Write(data)
+	return err
+}
+
+// Switch to the file for the given day, writing the format header if it is
+// new and otherwise continuing in the format it was started with
+func (w *Writer) rotate(now time.Time) error {
+	path := PathForDay(w.base, now)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("open event log %s: %w", path, err)
+	}
+
+	format := w.format
+	header := make([]byte, 1)
+	if _, err := file.ReadAt(header, 0); err == nil {
+		var headerLen int
+		if format, headerLen, err = DetectFormat(header); err != nil {
+			file.Close()
+			return fmt.Errorf("open event log %s: %w", path, err)
+		}
+		if format != w.format {
+			slog.Warn("event log file was started in another format, keeping it until the next rotation", "path", path, "file_format", format.String(), "format", w.format.String())
+		}
+		if err := truncatePartial(file, format, int64(headerLen)); err != nil {
+			file.Close()
+			return fmt.Errorf("recover event log %s: %w", path, err)
+		}
+	} else if !errors.Is(err, io.EOF) {
+		file.Close()
+		return fmt.Errorf("read event log %s: %w", path, err)
+	} else if _, err := file.Write([]byte{byte(format)}); err != nil {
+		file.Close()
+		return fmt.Errorf("write event log header %s: %w", path, err)
+	}
+
+	if w.file != nil {
+		w.file.Close()
+	}
+	w.file = file
+	w.buf = bufio.NewWriter(file)
+	w.enc = json.NewEncoder(w.buf)
+	w.day = now.Format(dateLayout)
+	w.fileFormat = format
+	return nil
+}
+
+// Cut off an entry left incomplete by a crash, so entries appended next are
+// framed correctly
+func truncatePartial(file *os.File, format Format, headerLen int64) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	var end int64
+	if format == FormatJSON {
+		end, err = lastLineEnd(file, headerLen, size)
+	} else {
+		end, err = lastRecordEnd(file, headerLen, size)
+	}
+	if err != nil || end == size {
+		return err
+	}
+	slog.Warn("event log ends in a partial entry, truncating it", "path", file.Name(), "size", size, "truncated_bytes", size-end)
+	return file.Truncate(end)
+}
+
+// Offset just past the last newline at or after start, start if there is none
+func lastLineEnd(file *os.File, start, size int64) (int64, error) {
+	buf := make([]byte, 64<<10)
+	for end := size; end > start; {
+		n := min(int64(len(buf)), end-start)
+		chunk := buf[:n]
+		if _, err := file.ReadAt(chunk, end-n); err != nil {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			return end - n + int64(i) + 1, nil
+		}
+		end -= n
+	}
+	return start, nil
+}
+
+// Offset just past the last length-prefixed record that fits in the file,
+// walking the prefixes from start
+func lastRecordEnd(file *os.File, start, size int64) (int64, error) {
+	r := bufio.NewReader(io.NewSectionReader(file, start, size-start))
+	end := start
+	prefix := make([]byte, 0, binary.MaxVarintLen64)
+	for {
+		prefix = prefix[:0]
+		for len(prefix) == 0 || prefix[len(prefix)-1] >= 0x80 {
+			b, err := r.ReadByte()
+			if errors.Is(err, io.EOF) {
+				// Clean end, or a prefix cut off partway
+				return end, nil
+			}
+			if err != nil {
+				return 0, err
+			}
+			if len(prefix) == binary.MaxVarintLen64 {
+				return end, nil
+			}
+			prefix = append(prefix, b)
+		}
+		length, _ := binary.Uvarint(prefix)
+		recordEnd := end + int64(len(prefix)) + int64(length)
+		if length > uint64(size) || recordEnd > size {
+			return end, nil
+		}
+		if _, err := r.Discard(int(length)); err != nil {
+			return 0, err
+		}
+		end = recordEnd
+	}
+}
+
+// Write buffered entries to the file and sync it, so entries written before
+// a crash are kept
+func (w *Writer) flush() {
+	if err := w.buf.Flush(); err != nil {
+		slog.Error("failed to flush event log", "error", err)
+		return
+	}
+	if err := w.file.Sync(); err != nil {
+		slog.Error("failed to sync event log", "error", err)
+	}
+}
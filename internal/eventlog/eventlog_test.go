@@ -0,0 +1,186 @@
+package eventlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+)
+
+func sampleEntry(i int) Entry {
+	return Entry{
+		Timestamp: time.Now().UnixNano(),
+		Op:        "set",
+		Key:       fmt.Sprintf("user:%d", i),
+		Value:     `{"name":"alice","plan":"pro","visits":42}`,
+		Caller:    "10.0.0.1",
+	}
+}
+
+// Decode every entry in a log file, failing on any malformed or partial one
+func readAll(t *testing.T, path string) []Entry {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	format, n, err := DetectFormat(data)
+	if err != nil {
+		t.Fatalf("DetectFormat: %v", err)
+	}
+	data = data[n:]
+
+	var entries []Entry
+	for len(data) > 0 {
+		entry, n, err := Decode(format, data)
+		if err != nil {
+			t.Fatalf("entry %d: %v", len(entries), err)
+		}
+		if n == 0 {
+			t.Fatalf("entry %d: %d trailing bytes of a partial entry", len(entries), len(data))
+		}
+		entries = append(entries, entry)
+		data = data[n:]
+	}
+	return entries
+}
+
+func TestReopenTruncatesPartialEntry(t *testing.T) {
+	partials := map[Format][]byte{
+		// Length prefix promising more bytes than were written
+		FormatProto: {0x40, 0x08, 0x01},
+		FormatJSON:  []byte(`{"ts":1,"op":"se`),
+	}
+	for format, partial := range partials {
+		t.Run(format.String(), func(t *testing.T) {
+			base := filepath.Join(t.TempDir(), "events.log")
+			w, err := Open(base, WithFormat(format))
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			for i := range 3 {
+				w.Append(sampleEntry(i))
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			// Simulate a crash partway through writing an entry
+			path := PathForDay(base, time.Now())
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Write(partial)
+			f.Close()
+
+			w, err = Open(base, WithFormat(format))
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			w.Append(sampleEntry(3))
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			entries := readAll(t, path)
+			if len(entries) != 4 {
+				t.Fatalf("read %d entries, want 4", len(entries))
+			}
+			if entries[3].Key != "user:3" {
+				t.Errorf("entry after reopen has key %q, want user:3", entries[3].Key)
+			}
+		})
+	}
+}
+
+// Writer encoding straight into out, without a file or background goroutine
+func benchWriter(format Format, out *bytes.Buffer) *Writer {
+	w := &Writer{fileFormat: format, buf: bufio.NewWriter(out)}
+	w.enc = json.NewEncoder(w.buf)
+	return w
+}
+
+// Encode entries in each format, reporting bytes written per entry
+func BenchmarkEncode(b *testing.B) {
+	for _, format := range []Format{FormatJSON, FormatProto} {
+		b.Run(format.String(), func(b *testing.B) {
+			var out bytes.Buffer
+			w := benchWriter(format, &out)
+			entry := sampleEntry(1)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if out.Len() > 64<<20 {
+					w.buf.Flush()
+					out.Reset()
+				}
+				if err := w.write(entry); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			out.Reset()
+			w.write(entry)
+			w.buf.Flush()
+			b.ReportMetric(float64(out.Len()), "bytes/entry")
+		})
+	}
+}
+
+// Decode a log of 1M entries in each format
+func BenchmarkReplay(b *testing.B) {
+	const entries = 1_000_000
+	for _, format := range []Format{FormatJSON, FormatProto} {
+		b.Run(format.String(), func(b *testing.B) {
+			var out bytes.Buffer
+			w := benchWriter(format, &out)
+			for i := range entries {
+				if err := w.write(sampleEntry(i)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			w.buf.Flush()
+			log := out.Bytes()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				data := log
+				for len(data) > 0 {
+					_, n, err := Decode(format, data)
+					if err != nil || n == 0 {
+						b.Fatalf("decode: n=%d err=%v", n, err)
+					}
+					data = data[n:]
+				}
+			}
+			b.ReportMetric(float64(len(log)), "log_bytes")
+		})
+	}
+}
+
+func TestAppendCountsDroppedEntries(t *testing.T) {
+	// No writer goroutine drains entries, so the buffer stays full
+	w := &Writer{entries: make(chan Entry, 1), done: make(chan struct{})}
+	before := testutil.ToFloat64(metrics.EventLogDropped)
+
+	if !w.Append(sampleEntry(0)) {
+		t.Fatal("first Append dropped the entry")
+	}
+	for i := range 3 {
+		if w.Append(sampleEntry(i + 1)) {
+			t.Fatal("Append to a full buffer succeeded")
+		}
+	}
+	if got := testutil.ToFloat64(metrics.EventLogDropped) - before; got != 3 {
+		t.Errorf("dropped counter rose by %v, want 3", got)
+	}
+}
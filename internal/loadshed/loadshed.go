@@ -0,0 +1,75 @@
+package loadshed
+
+import (
+	"log/slog"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+)
+
+// How often heap usage is compared against the memory limit
+const checkInterval = time.Second
+
+// Tracks heap usage against the Go memory limit (GOMEMLIMIT) and reports
+// when writes should be shed to avoid running out of memory
+type Monitor struct {
+	threshold float64
+	limit     int64
+	shedding  atomic.Bool
+}
+
+// Create a monitor that sheds once the heap exceeds threshold, a fraction of
+// the memory limit. Returns nil if no memory limit is set
+func NewMonitor(threshold float64) *Monitor {
+	// A negative input only reads the limit, which the runtime takes from GOMEMLIMIT
+	limit := debug.SetMemoryLimit(-1)
+	if limit <= 0 || limit == math.MaxInt64 {
+		slog.Warn("load shedding disabled, GOMEMLIMIT is not set")
+		return nil
+	}
+	slog.Info("load shedding enabled", "threshold", threshold, "memory_limit_bytes", limit)
+	return &Monitor{threshold: threshold, limit: limit}
+}
+
+// Report whether new writes should currently be rejected
+func (m *Monitor) Shedding() bool {
+	return m != nil && m.shedding.Load()
+}
+
+// Check heap usage now and then every second until done is closed
+func (m *Monitor) Run(done <-chan struct{}) {
+	m.check()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.check()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (m *Monitor) check() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	utilization := float64(stats.HeapAlloc) / float64(m.limit)
+
+	shed := utilization > m.threshold
+	if m.shedding.Swap(shed) == shed {
+		return
+	}
+	if shed {
+		slog.Warn("load shedding started", "heap_alloc_bytes", stats.HeapAlloc, "utilization", utilization, "threshold", m.threshold)
+		metrics.LoadShedActive.Set(1)
+	} else {
+		slog.Info("load shedding ended", "heap_alloc_bytes", stats.HeapAlloc, "utilization", utilization, "threshold", m.threshold)
+		metrics.LoadShedActive.Set(0)
+	}
+}
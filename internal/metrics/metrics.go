@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "kvstore"
+
+// Sorted label names of every family registered by this package, by full
+// name, filled in as the collectors below are created
+var registered = make(map[string][]string)
+
+// Record the family a collector registers
+func family(namespace, subsystem, name string, labels []string) {
+	labels = slices.Clone(labels)
+	slices.Sort(labels)
+	registered[prometheus.BuildFQName(namespace, subsystem, name)] = labels
+}
+
+func newGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
+	family(opts.Namespace, opts.Subsystem, opts.Name, nil)
+	return promauto.NewGauge(opts)
+}
+
+func newGaugeVec(opts prometheus.GaugeOpts, labels []string) *prometheus.GaugeVec {
+	family(opts.Namespace, opts.Subsystem, opts.Name, labels)
+	return promauto.NewGaugeVec(opts, labels)
+}
+
+func newCounter(opts prometheus.CounterOpts) prometheus.Counter {
+	family(opts.Namespace, opts.Subsystem, opts.Name, nil)
+	return promauto.NewCounter(opts)
+}
+
+func newCounterVec(opts prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+	family(opts.Namespace, opts.Subsystem, opts.Name, labels)
+	return promauto.NewCounterVec(opts, labels)
+}
+
+func newHistogramVec(opts prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+	family(opts.Namespace, opts.Subsystem, opts.Name, labels)
+	return promauto.NewHistogramVec(opts, labels)
+}
+
+var (
+	// Fraction of the fullest event channel of a pattern's subscribers
+	SubscriberFillRatio = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "subscriber_channel_fill_ratio",
+		Help:      "Fraction of the event channel buffer in use by the fullest subscriber of each pattern, sampled every second.",
+	}, []string{"pattern"})
+
+	// Gets per key over the last hot key scan, limited to the top-N keys
+	HotKeyAccessCount = newGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "hot_key_access_count",
+		Help:      "Get count over the last scan interval for the hottest keys.",
+	}, []string{"key"})
+
+	// Keys unused for the idle period as of the last idle key scan
+	IdleKeys = newGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "idle_keys_total",
+		Help:      "Number of keys not read or written for the idle period at the last scan.",
+	})
+
+	// Events queued for the event log but not yet written
+	EventLogLag = newGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_log_lag",
+		Help:      "Number of events buffered awaiting write to the event log.",
+	})
+
+	// Entries dropped because the event log buffer was full
+	EventLogDropped = newCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_log_dropped_total",
+		Help:      "Total mutations left out of the event log because its buffer was full.",
+	})
+
+	// Panics recovered from gRPC handlers
+	HandlerPanics = newCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "handler_panics_total",
+		Help:      "Total panics recovered from gRPC handlers.",
+	}, []string{"method"})
+
+	// Events diverted to a subscriber's dead letter queue
+	DLQEvents = newCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dlq_events_total",
+		Help:      "Total events written to subscriber dead letter queues because the channel was full.",
+	}, []string{"pattern"})
+
+	// Audit entries not delivered to a forwarder, by forwarder and reason
+	AuditEntriesDropped = newCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_entries_dropped_total",
+		Help:      "Total audit entries dropped because a forwarder fell behind or delivery failed.",
+	}, []string{"forwarder", "reason"})
+
+	// 1 while writes are rejected because the heap is near the memory limit
+	LoadShedActive = newGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "load_shed_active",
+		Help:      "Whether writes are being shed due to memory pressure.",
+	})
+
+	// Storage circuit breaker state: 0 closed, 1 open, 2 half-open
+	StorageCircuitState = newGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "storage_circuit_state",
+		Help:      "State of the storage circuit breaker: 0 closed, 1 open, 2 half-open.",
+	})
+
+	// Storage calls counted as failures by the circuit breaker, by operation
+	StorageCircuitFailures = newCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_circuit_failures_total",
+		Help:      "Total storage calls the circuit breaker counted as failed because they were slow or panicked.",
+	}, []string{"op"})
+
+	// Time spent delivering one event to the subscribers of a pattern
+	NotifyLoopDuration = newHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "notify_loop_duration_seconds",
+		Help:      "Time taken to deliver an event to every subscriber of a pattern.",
+		Buckets:   prometheus.ExponentialBuckets(1e-6, 4, 10),
+	}, []string{"pattern"})
+
+	// Attempts to queue an event for a subscriber, by pattern and whether it was queued
+	EventEnqueueAttempts = newCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_enqueue_attempts_total",
+		Help:      "Total attempts to queue an event for a subscriber, by result: success or dropped.",
+	}, []string{"pattern", "result"})
+
+	// Gets answered with an upstream store configured, by result: hit, miss, fill or error
+	UpstreamCacheRequests = newCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_cache_requests_total",
+		Help:      "Total Gets on a server with an upstream store, by result: hit (served locally), miss (looked up upstream), fill (found upstream and stored) or error.",
+	}, []string{"result"})
+
+	// Events replaced by a newer event of their key while over the per-key rate limit
+	EventsRateLimited = newCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_rate_limited_total",
+		Help:      "Total change events never sent to subscribers because a newer event of the same key replaced them while over the per-key rate limit.",
+	})
+
+	// Get calls answered as not modified by if_none_match or if_modified_since_ms
+	ConditionalGetHits = newCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "conditional_get_hits_total",
+		Help:      "Total Get calls answered without the value because if_none_match matched its ETag or it was not modified since if_modified_since_ms.",
+	})
+)
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Families whose values may legitimately be NaN or infinite. Summary
+// quantiles are NaN until the first observation
+var nonFiniteAllowed = map[string]bool{
+	"go_gc_duration_seconds": true,
+}
+
+// Gather every metric family from reg and check that the families of this
+// package are present with the labels they were created with and that no
+// value is NaN or infinite. Families without labels are always exported,
+// vectors only once a label value has been seen. All problems found are
+// joined into the error
+func SelfTest(reg prometheus.Gatherer) error {
+	families, err := reg.Gather()
+	if err != nil {
+		return fmt.Errorf("gather metrics: %w", err)
+	}
+
+	var errs []error
+	seen := make(map[string]bool, len(families))
+	for _, family := range families {
+		name := family.GetName()
+		seen[name] = true
+		if labels, ok := registered[name]; ok {
+			errs = append(errs, checkLabels(name, labels, family.Metric)...)
+		}
+		if !nonFiniteAllowed[name] {
+			errs = append(errs, checkValues(name, family.Metric)...)
+		}
+	}
+	for name, labels := range registered {
+		if len(labels) == 0 && !seen[name] {
+			errs = append(errs, fmt.Errorf("%s: not registered", name))
+		}
+	}
+
+	slices.SortFunc(errs, func(a, b error) int {
+		return strings.Compare(a.Error(), b.Error())
+	})
+	return errors.Join(errs...)
+}
+
+// Every metric of a family must carry exactly the expected label names, each
+// with a value
+func checkLabels(name string, want []string, metrics []*dto.Metric) []error {
+	var errs []error
+	for _, m := range metrics {
+		got := make([]string, 0, len(m.Label))
+		for _, pair := range m.Label {
+			got = append(got, pair.GetName())
+			if pair.GetValue() == "" {
+				errs = append(errs, fmt.Errorf("%s: label %q is empty", name, pair.GetName()))
+			}
+		}
+		// Gathered labels are already sorted by name
+		if !slices.Equal(got, want) {
+			errs = append(errs, fmt.Errorf("%s: labels %v, want %v", name, got, want))
+			// Every other metric of the family is wrong the same way
+			break
+		}
+	}
+	return errs
+}
+
+// Values that are NaN or infinite, naming the metric by its labels
+func checkValues(name string, metrics []*dto.Metric) []error {
+	var errs []error
+	for _, m := range metrics {
+		var values []float64
+		switch {
+		case m.Gauge != nil:
+			values = append(values, m.Gauge.GetValue())
+		case m.Counter != nil:
+			values = append(values, m.Counter.GetValue())
+		case m.Untyped != nil:
+			values = append(values, m.Untyped.GetValue())
+		case m.Histogram != nil:
+			values = append(values, m.Histogram.GetSampleSum())
+		case m.Summary != nil:
+			values = append(values, m.Summary.GetSampleSum())
+			for _, q := range m.Summary.Quantile {
+				values = append(values, q.GetValue())
+			}
+		}
+		for _, v := range values {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				errs = append(errs, fmt.Errorf("%s%s: value is %v", name, labelString(m.Label), v))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+func labelString(pairs []*dto.LabelPair) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	parts := make([]string, len(pairs))
+	for i, pair := range pairs {
+		parts[i] = fmt.Sprintf("%s=%q", pair.GetName(), pair.GetValue())
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
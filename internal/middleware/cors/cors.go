@@ -0,0 +1,64 @@
+package cors
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	allowMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	allowHeaders = "Content-Type, Authorization"
+	// Seconds browsers may cache a preflight result
+	maxAge = "600"
+)
+
+// Let browsers on the given origins call the wrapped handler. Origins are
+// matched exactly, e.g. https://app.example.com, and ["*"] allows any origin.
+// Preflight OPTIONS requests are answered with 204 and never reach the handler
+func Middleware(origins []string) func(http.Handler) http.Handler {
+	allowAll := slices.Contains(origins, "*")
+	if allowAll {
+		slog.Warn("CORS allows every origin, only use this in development")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			// Same-origin and non-browser requests need no headers
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Responses differ by origin, so caches must not share them
+			w.Header().Add("Vary", "Origin")
+			allowed := allowAll || slices.Contains(origins, origin)
+			if allowed {
+				if allowAll {
+					w.Header().Set("Access-Control-Allow-Origin", "*")
+				} else {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+				w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+				w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+
+			if isPreflight(r) {
+				// Without the allow headers the browser rejects the real request
+				if allowed {
+					w.Header().Set("Access-Control-Max-Age", maxAge)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Report whether r is a CORS preflight rather than a plain OPTIONS request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && strings.TrimSpace(r.Header.Get("Access-Control-Request-Method")) != ""
+}
@@ -0,0 +1,76 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Wrap a handler answering 200 and recording whether it ran
+func serve(origins []string, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	called := false
+	handler := Middleware(origins)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec, called
+}
+
+func request(method, origin string, preflight bool) *http.Request {
+	r := httptest.NewRequest(method, "/v1/kv/user:1", nil)
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	if preflight {
+		r.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	}
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	allowed := []string{"https://app.example.com"}
+	tests := []struct {
+		name        string
+		origins     []string
+		req         *http.Request
+		wantStatus  int
+		wantOrigin  string
+		wantHandler bool
+		wantMaxAge  bool
+	}{
+		{"allowed origin echoed", allowed, request(http.MethodGet, "https://app.example.com", false), http.StatusOK, "https://app.example.com", true, false},
+		{"disallowed origin", allowed, request(http.MethodGet, "https://evil.example.com", false), http.StatusOK, "", true, false},
+		{"any origin", []string{"*"}, request(http.MethodGet, "https://anything.example.com", false), http.StatusOK, "*", true, false},
+		{"no origin", allowed, request(http.MethodGet, "", false), http.StatusOK, "", true, false},
+		{"preflight", allowed, request(http.MethodOptions, "https://app.example.com", true), http.StatusNoContent, "https://app.example.com", false, true},
+		{"preflight from disallowed origin", allowed, request(http.MethodOptions, "https://evil.example.com", true), http.StatusNoContent, "", false, false},
+		{"plain OPTIONS", allowed, request(http.MethodOptions, "https://app.example.com", false), http.StatusOK, "https://app.example.com", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, called := serve(tt.origins, tt.req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if called != tt.wantHandler {
+				t.Errorf("handler ran = %v, want %v", called, tt.wantHandler)
+			}
+			h := rec.Header()
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if tt.wantOrigin != "" && h.Get("Access-Control-Allow-Methods") != allowMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", h.Get("Access-Control-Allow-Methods"), allowMethods)
+			}
+			if got := h.Get("Access-Control-Max-Age") != ""; got != tt.wantMaxAge {
+				t.Errorf("Access-Control-Max-Age set = %v, want %v", got, tt.wantMaxAge)
+			}
+			// Only requests from browsers vary by origin
+			if wantVary := tt.req.Header.Get("Origin") != ""; (h.Get("Vary") == "Origin") != wantVary {
+				t.Errorf("Vary = %q, want Origin: %v", h.Get("Vary"), wantVary)
+			}
+		})
+	}
+}
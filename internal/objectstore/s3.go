@@ -0,0 +1,166 @@
+package objectstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4Service    = "s3"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateFormat   = "20060102T150405Z"
+)
+
+// Stream objects to an S3-compatible PutObject endpoint
+type S3Uploader struct {
+	endpoint string
+	client   *http.Client
+
+	accessKeyID     string
+	secretAccessKey string
+	region          string
+	now             func() time.Time
+}
+
+// Configure an S3Uploader
+type S3Option func(*S3Uploader)
+
+// Sign requests with AWS Signature Version 4
+func WithS3Credentials(accessKeyID, secretAccessKey, region string) S3Option {
+	return func(u *S3Uploader) {
+		u.accessKeyID = accessKeyID
+		u.secretAccessKey = secretAccessKey
+		u.region = region
+	}
+}
+
+// Create an uploader for a path-style endpoint such as http://minio:9000.
+// Requests are unsigned unless WithS3Credentials is given.
+func NewS3Uploader(endpoint string, client *http.Client, opts ...S3Option) *S3Uploader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u := &S3Uploader{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   client,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Split an s3://bucket/key destination into its parts
+func ParseS3URL(dest string) (bucket, key string, err error) {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", "", fmt.Errorf("parse destination: %w", err)
+	}
+	if u.Scheme != "s3" {
+		return "", "", fmt.Errorf("unsupported destination scheme %q", u.Scheme)
+	}
+
+	bucket = u.Host
+	key = strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", "", fmt.Errorf("destination must be s3://bucket/key")
+	}
+	return bucket, key, nil
+}
+
+// Upload body to bucket/key without buffering it to disk
+func (u *S3Uploader) Upload(ctx context.Context, bucket, key string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.endpoint, body)
+	if err != nil {
+		return fmt.Errorf("build PutObject request: %w", err)
+	}
+	// Send the path with the same strict encoding the signature covers
+	req.URL.RawPath = req.URL.EscapedPath() + "/" + uriEncode(bucket, false) + "/" + uriEncode(key, true)
+	req.URL.Path += "/" + bucket + "/" + key
+	req.Header.Set("Content-Type", "application/gzip")
+	if u.accessKeyID != "" {
+		u.sign(req)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("PutObject %s/%s: %w", bucket, key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("PutObject %s/%s: unexpected status %d: %s", bucket, key, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Sign req with AWS Signature Version 4, leaving the payload unsigned so the
+// body can stream
+func (u *S3Uploader) sign(req *http.Request) {
+	now := u.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + unsignedPayload + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonical))
+
+	scope := date + "/" + u.region + "/" + sigV4Service + "/aws4_request"
+	stringToSign := sigV4Algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+u.secretAccessKey), date)
+	for _, part := range []string{u.region, sigV4Service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, u.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Percent-encode s the way SigV4 expects: every byte except unreserved
+// characters, and slashes too unless keepSlash is set
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
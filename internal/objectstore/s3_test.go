@@ -0,0 +1,75 @@
+package objectstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestUploadSignsRequest(t *testing.T) {
+	var got *http.Request
+	var body string
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		got, body = req, string(b)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})}
+
+	u := NewS3Uploader("http://s3.example.com/", client,
+		WithS3Credentials("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1"))
+	u.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	if err := u.Upload(context.Background(), "backups", "snapshots/kv store+1.gz", strings.NewReader("data")); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if got.Method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", got.Method)
+	}
+	if path := got.URL.EscapedPath(); path != "/backups/snapshots/kv%20store%2B1.gz" {
+		t.Errorf("path = %s", path)
+	}
+	if body != "data" {
+		t.Errorf("body = %q, want %q", body, "data")
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20260102/us-east-1/s3/aws4_request, " +
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=8e31dc981d1e0eb3a69d874cd471f5dd1ac9530eeba0feaeafdd9ab1710565fe"
+	if auth := got.Header.Get("Authorization"); auth != want {
+		t.Errorf("Authorization =\n  %s\nwant\n  %s", auth, want)
+	}
+	if date := got.Header.Get("X-Amz-Date"); date != "20260102T030405Z" {
+		t.Errorf("X-Amz-Date = %s", date)
+	}
+}
+
+func TestUploadUnsignedWithoutCredentials(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if auth := req.Header.Get("Authorization"); auth != "" {
+			t.Errorf("Authorization = %q, want none", auth)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})}
+	if err := NewS3Uploader("http://s3.example.com", client).Upload(context.Background(), "b", "k", http.NoBody); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+}
+
+func TestUploadReportsErrorStatus(t *testing.T) {
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusForbidden, Body: io.NopCloser(strings.NewReader("SignatureDoesNotMatch"))}, nil
+	})}
+	err := NewS3Uploader("http://s3.example.com", client).Upload(context.Background(), "b", "k", http.NoBody)
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("err = %v, want the response body", err)
+	}
+}
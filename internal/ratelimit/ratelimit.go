@@ -0,0 +1,231 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+)
+
+const (
+	// Metadata header carrying the caller's tenant namespace
+	NamespaceHeader = "x-namespace"
+
+	// Identity attribute naming the caller's namespace, which takes the place
+	// of the header when the credential carries it
+	NamespaceAttribute = "namespace"
+
+	// Set by the REST gateway to the address of the HTTP client
+	forwardedForHeader = "x-forwarded-for"
+
+	// Bucket key used when a request carries no usable identity
+	defaultKey = "default"
+
+	// Most token buckets kept before the least recently used is evicted
+	defaultMaxBuckets = 10000
+
+	// Separates the namespace from the caller in a bucket key. Metadata values
+	// are printable ASCII, so no namespace can contain it
+	namespaceSep = "\x00"
+)
+
+// Derive the rate limit bucket key for a request
+type KeyExtractor func(ctx context.Context, info *grpc.UnaryServerInfo) string
+
+// Bucket requests by the caller's authenticated identity, or by its IP
+// address when the request is not authenticated. The port is left out so
+// opening another connection does not start a fresh bucket
+func PeerKeyExtractor(ctx context.Context, _ *grpc.UnaryServerInfo) string {
+	if id, ok := auth.FromContext(ctx); ok && id.SubjectID != "" {
+		return "subject:" + id.SubjectID
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr := p.Addr.String()
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return addr
+		}
+		// The REST gateway calls in over loopback on behalf of its clients
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			if forwarded := forwardedFor(ctx); forwarded != "" {
+				return forwarded
+			}
+		}
+		return host
+	}
+	return defaultKey
+}
+
+// Address the REST gateway received a request from, the last entry of the
+// x-forwarded-for it sets. Earlier entries come from the client
+func forwardedFor(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(forwardedForHeader)
+	if len(values) == 0 {
+		return ""
+	}
+	entries := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(entries[len(entries)-1])
+}
+
+// Bucket requests by namespace within each caller as PeerKeyExtractor
+// identifies it. The namespace comes from the caller's namespace credential
+// attribute, e.g. a JWT claim, and only without one from the x-namespace
+// header, which the caller chooses. Naming a new namespace in the header
+// therefore only gives a caller a new bucket for its own requests
+func NamespaceKeyExtractor(ctx context.Context, info *grpc.UnaryServerInfo) string {
+	namespace := defaultKey
+	if id, ok := auth.FromContext(ctx); ok && id.Metadata[NamespaceAttribute] != "" {
+		namespace = id.Metadata[NamespaceAttribute]
+	} else if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(NamespaceHeader); len(values) > 0 && values[0] != "" {
+			namespace = values[0]
+		}
+	}
+	return namespace + namespaceSep + PeerKeyExtractor(ctx, info)
+}
+
+// Configure a Limiter
+type Option func(*Limiter)
+
+// Override the limit for specific bucket keys, e.g. premium tenants
+func WithPerNamespaceLimits(limits map[string]rate.Limit) Option {
+	return func(l *Limiter) {
+		for key, limit := range limits {
+			l.overrides[key] = limit
+		}
+	}
+}
+
+// Cap the number of buckets held in memory
+func WithMaxBuckets(n int) Option {
+	return func(l *Limiter) {
+		if n > 0 {
+			l.maxBuckets = n
+		}
+	}
+}
+
+type bucket struct {
+	key     string
+	limiter *rate.Limiter
+}
+
+// Token buckets keyed by request identity, evicted in LRU order
+type Limiter struct {
+	limit      rate.Limit
+	burst      int
+	overrides  map[string]rate.Limit
+	maxBuckets int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List
+}
+
+// Create a limiter allowing limit requests per second with the given burst per key
+func NewLimiter(limit rate.Limit, burst int, opts ...Option) *Limiter {
+	l := &Limiter{
+		limit:      limit,
+		burst:      burst,
+		overrides:  make(map[string]rate.Limit),
+		maxBuckets: defaultMaxBuckets,
+		buckets:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Report whether a request for key may proceed now
+func (l *Limiter) Allow(key string) bool {
+	return l.bucketFor(key).Allow()
+}
+
+// Number of buckets currently held
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
+
+// Retrieve or create the bucket for key, evicting the least recently used if full
+func (l *Limiter) bucketFor(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(elem)
+		return elem.Value.(*bucket).limiter
+	}
+
+	limit := l.limit
+	// Overrides name a namespace, whichever caller it is keyed under
+	namespace, _, _ := strings.Cut(key, namespaceSep)
+	if override, ok := l.overrides[namespace]; ok {
+		limit = override
+	}
+	b := &bucket{key: key, limiter: rate.NewLimiter(limit, l.burst)}
+	l.buckets[key] = l.lru.PushFront(b)
+
+	for l.lru.Len() > l.maxBuckets {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.buckets, oldest.Value.(*bucket).key)
+	}
+
+	return b.limiter
+}
+
+// Reject requests over the limit with codes.ResourceExhausted
+func NewInterceptor(limit rate.Limit, burst int, extract KeyExtractor, opts ...Option) grpc.UnaryServerInterceptor {
+	return NewLimiter(limit, burst, opts...).UnaryServerInterceptor(extract)
+}
+
+// Reject requests over the limit with codes.ResourceExhausted
+func (l *Limiter) UnaryServerInterceptor(extract KeyExtractor) grpc.UnaryServerInterceptor {
+	if extract == nil {
+		extract = PeerKeyExtractor
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := l.check(extract(ctx, info), info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Streaming variant of UnaryServerInterceptor, counting each stream opened
+// as one request from the same bucket
+func (l *Limiter) StreamServerInterceptor(extract KeyExtractor) grpc.StreamServerInterceptor {
+	if extract == nil {
+		extract = PeerKeyExtractor
+	}
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.check(extract(ss.Context(), &grpc.UnaryServerInfo{FullMethod: info.FullMethod}), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (l *Limiter) check(key, method string) error {
+	if !l.Allow(key) {
+		slog.Warn("rate limit exceeded", "key", strings.ReplaceAll(key, namespaceSep, "/"), "method", method)
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
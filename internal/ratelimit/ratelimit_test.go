@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+)
+
+func TestLimiterBoundsNamespaceBuckets(t *testing.T) {
+	const maxBuckets = 1000
+	l := NewLimiter(rate.Limit(10), 1, WithMaxBuckets(maxBuckets))
+
+	for i := range 10000 {
+		l.Allow(fmt.Sprintf("tenant-%d", i))
+		if n := l.Len(); n > maxBuckets {
+			t.Fatalf("after %d namespaces Len() = %d, want at most %d", i+1, n, maxBuckets)
+		}
+	}
+	if n := l.Len(); n != maxBuckets {
+		t.Errorf("Len() = %d, want %d", n, maxBuckets)
+	}
+
+	// The most recent namespace survived eviction with its token spent
+	if l.Allow("tenant-9999") {
+		t.Error("tenant-9999 was allowed again, its bucket was evicted")
+	}
+	// The oldest was evicted and starts over with a full bucket
+	if !l.Allow("tenant-0") {
+		t.Error("tenant-0 was rejected, its bucket was not evicted")
+	}
+}
+
+func TestPerNamespaceLimits(t *testing.T) {
+	l := NewLimiter(rate.Limit(1), 1, WithPerNamespaceLimits(map[string]rate.Limit{"premium": rate.Inf}))
+
+	key := "premium" + namespaceSep + "10.0.0.1"
+	for i := range 100 {
+		if !l.Allow(key) {
+			t.Fatalf("premium request %d rejected", i)
+		}
+	}
+	key = "trial" + namespaceSep + "10.0.0.1"
+	if !l.Allow(key) {
+		t.Fatal("first trial request rejected")
+	}
+	if l.Allow(key) {
+		t.Error("second trial request allowed beyond a burst of 1")
+	}
+}
+
+func peerContext(addr string) context.Context {
+	tcp, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		panic(err)
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: tcp})
+}
+
+func TestPeerKeyExtractorIgnoresPort(t *testing.T) {
+	a := PeerKeyExtractor(peerContext("10.0.0.1:40000"), nil)
+	b := PeerKeyExtractor(peerContext("10.0.0.1:40001"), nil)
+	if a != b {
+		t.Errorf("connections from one IP got keys %q and %q", a, b)
+	}
+	if c := PeerKeyExtractor(peerContext("10.0.0.2:40000"), nil); c == a {
+		t.Errorf("different IPs share key %q", a)
+	}
+}
+
+func TestNamespaceKeyExtractor(t *testing.T) {
+	withNamespace := func(ctx context.Context, namespace string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(NamespaceHeader, namespace))
+	}
+
+	// A caller naming another tenant's namespace still gets its own bucket
+	alice := NamespaceKeyExtractor(withNamespace(peerContext("10.0.0.1:1"), "premium"), nil)
+	bob := NamespaceKeyExtractor(withNamespace(peerContext("10.0.0.2:1"), "premium"), nil)
+	if alice == bob {
+		t.Errorf("callers on different IPs share bucket %q", alice)
+	}
+
+	// The credential's namespace wins over the header
+	ctx := withNamespace(peerContext("10.0.0.1:1"), "premium")
+	id := auth.Identity{SubjectID: "svc", Metadata: map[string]string{NamespaceAttribute: "trial"}}
+	key := NamespaceKeyExtractor(authenticated(t, ctx, id), nil)
+	if want := "trial" + namespaceSep + "subject:svc"; key != want {
+		t.Errorf("key = %q, want %q", key, want)
+	}
+}
+
+// Run ctx through the API key interceptor so it carries id
+func authenticated(t *testing.T, ctx context.Context, id auth.Identity) context.Context {
+	t.Helper()
+	provider := auth.NewStaticKeyProvider(map[string]auth.Identity{"key": id})
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = metadata.Join(md, metadata.Pairs(auth.AuthorizationHeader, "Bearer key"))
+	var out context.Context
+	_, err := auth.NewAPIKeyInterceptor(provider)(metadata.NewIncomingContext(ctx, md), nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, _ any) (any, error) {
+			out = ctx
+			return nil, nil
+		})
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package recovery
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+)
+
+// Convert handler panics into codes.Internal instead of crashing the server
+func NewInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = handlePanic(info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// Streaming variant of NewInterceptor
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = handlePanic(info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// Log and count a recovered panic, returning the error sent to the client
+func handlePanic(method string, r any) error {
+	slog.Error("panic in handler", "method", method, "panic", r, "stack", string(debug.Stack()))
+	metrics.HandlerPanics.WithLabelValues(method).Inc()
+	return status.Error(codes.Internal, "internal server error")
+}
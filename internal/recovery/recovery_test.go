@@ -0,0 +1,71 @@
+package recovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Memory backend that panics when asked to store the value "panic", which
+// happens while Set holds the key lock
+type panickingBackend struct {
+	*storage.Memory
+}
+
+func (p panickingBackend) Store(key, value string) {
+	if value == "panic" {
+		panic("injected panic storing " + key)
+	}
+	p.Memory.Store(key, value)
+}
+
+func TestPanicReturnsInternalAndServerKeepsServing(t *testing.T) {
+	svc := service.NewKVStoreService(service.WithConfig(config.Default()), service.WithStorage(panickingBackend{storage.NewMemory()}))
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnaryInterceptor(NewInterceptor()))
+	pb.RegisterKeyValueStoreServer(srv, svc)
+	go srv.Serve(lis)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+		svc.Close()
+	})
+	kv := pb.NewKeyValueStoreClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = kv.Set(ctx, &pb.SetRequest{Key: "user:1", Value: "panic"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("Set that panics: got %v, want Internal", err)
+	}
+
+	// The same key again, which hangs if the panic left its lock held
+	if _, err := kv.Set(ctx, &pb.SetRequest{Key: "user:1", Value: "ok"}); err != nil {
+		t.Fatalf("Set after panic: %v", err)
+	}
+	resp, err := kv.Get(ctx, &pb.GetRequest{Key: "user:1"})
+	if err != nil {
+		t.Fatalf("Get after panic: %v", err)
+	}
+	if resp.Value != "ok" {
+		t.Errorf("value = %q, want ok", resp.Value)
+	}
+}
@@ -0,0 +1,202 @@
+package ring
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+)
+
+// Virtual nodes per node when New is given 0 or less
+const DefaultVNodes = 128
+
+// Consistent hash ring assigning keys to nodes. Each node owns vnodes points
+// on a uint64 ring, and a key belongs to the first point at or after its
+// hash. Lookups are safe to run concurrently, Add and Remove are not
+type Ring struct {
+	vnodes int
+	// Virtual node positions, sorted, with the node owning each
+	points []point
+	nodes  map[string]struct{}
+}
+
+type point struct {
+	hash uint64
+	node string
+}
+
+// Hash range owned by a node: the hashes after Start up to and including End,
+// wrapping past zero when End is below Start. Start equal to End covers the
+// whole ring
+type Assignment struct {
+	Start uint64
+	End   uint64
+	Node  string
+}
+
+// Report whether h falls in the assignment's range
+func (a Assignment) Contains(h uint64) bool {
+	switch {
+	case a.Start < a.End:
+		return h > a.Start && h <= a.End
+	case a.Start > a.End:
+		return h > a.Start || h <= a.End
+	default:
+		return true
+	}
+}
+
+func (a Assignment) String() string {
+	return fmt.Sprintf("(%016x, %016x] %s", a.Start, a.End, a.Node)
+}
+
+// Create an empty ring giving each node vnodes points
+func New(vnodes int) *Ring {
+	if vnodes <= 0 {
+		vnodes = DefaultVNodes
+	}
+	return &Ring{vnodes: vnodes, nodes: make(map[string]struct{})}
+}
+
+// Position of key on the ring
+func Hash(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return mix(h.Sum64())
+}
+
+// Spread FNV output over the ring, whose raw values cluster for inputs that
+// differ only in their last bytes such as the virtual node suffix
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// Add nodes to the ring, ignoring any already present
+func (r *Ring) Add(nodes ...string) {
+	for _, node := range nodes {
+		if _, ok := r.nodes[node]; ok {
+			continue
+		}
+		r.nodes[node] = struct{}{}
+		for i := range r.vnodes {
+			r.points = append(r.points, point{hash: Hash(fmt.Sprintf("%s#%d", node, i)), node: node})
+		}
+	}
+	// Ties are broken by node name so every ring with the same nodes agrees
+	sort.Slice(r.points, func(i, j int) bool {
+		if r.points[i].hash != r.points[j].hash {
+			return r.points[i].hash < r.points[j].hash
+		}
+		return r.points[i].node < r.points[j].node
+	})
+}
+
+// Remove nodes from the ring, ignoring any not present
+func (r *Ring) Remove(nodes ...string) {
+	for _, node := range nodes {
+		delete(r.nodes, node)
+	}
+	r.points = slices.DeleteFunc(r.points, func(p point) bool {
+		_, ok := r.nodes[p.node]
+		return !ok
+	})
+}
+
+// Copy of the ring that can be changed without affecting r
+func (r *Ring) Clone() *Ring {
+	c := New(r.vnodes)
+	c.points = slices.Clone(r.points)
+	for node := range r.nodes {
+		c.nodes[node] = struct{}{}
+	}
+	return c
+}
+
+// Nodes on the ring in name order
+func (r *Ring) Nodes() []string {
+	nodes := make([]string, 0, len(r.nodes))
+	for node := range r.nodes {
+		nodes = append(nodes, node)
+	}
+	slices.Sort(nodes)
+	return nodes
+}
+
+// Node owning key, empty if the ring has no nodes
+func (r *Ring) NodeFor(key string) string {
+	return r.owner(Hash(key))
+}
+
+// Node owning hash h, empty if the ring has no nodes
+func (r *Ring) owner(h uint64) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].node
+}
+
+// Ranges of the ring in hash order with their owners, adjacent ranges of the
+// same node merged. The last range wraps past zero
+func (r *Ring) Assignments() []Assignment {
+	bounds := make([]uint64, len(r.points))
+	for i, p := range r.points {
+		bounds[i] = p.hash
+	}
+	bounds = slices.Compact(bounds)
+
+	var out []Assignment
+	for i, end := range bounds {
+		start := bounds[(i+len(bounds)-1)%len(bounds)]
+		out = appendRange(out, Assignment{Start: start, End: end, Node: r.owner(end)})
+	}
+	// The wrapping range continues the first one if both have the same owner
+	if n := len(out); n > 1 && out[0].Node == out[n-1].Node {
+		out[0].Start = out[n-1].Start
+		out = out[:n-1]
+	}
+	return out
+}
+
+// Ranges whose owner differs between old and new: added lists them with
+// their new owners, removed with their old ones, in hash order
+func Diff(old, new *Ring) (added, removed []Assignment) {
+	bounds := make([]uint64, 0, len(old.points)+len(new.points))
+	for _, p := range old.points {
+		bounds = append(bounds, p.hash)
+	}
+	for _, p := range new.points {
+		bounds = append(bounds, p.hash)
+	}
+	slices.Sort(bounds)
+	bounds = slices.Compact(bounds)
+
+	// Each range between bounds has a single owner in both rings
+	for i, end := range bounds {
+		start := bounds[(i+len(bounds)-1)%len(bounds)]
+		from, to := old.owner(end), new.owner(end)
+		if from == to {
+			continue
+		}
+		added = appendRange(added, Assignment{Start: start, End: end, Node: to})
+		removed = appendRange(removed, Assignment{Start: start, End: end, Node: from})
+	}
+	return added, removed
+}
+
+// Append a, extending the last assignment instead when a continues it
+func appendRange(out []Assignment, a Assignment) []Assignment {
+	if n := len(out); n > 0 && out[n-1].Node == a.Node && out[n-1].End == a.Start {
+		out[n-1].End = a.End
+		return out
+	}
+	return append(out, a)
+}
@@ -0,0 +1,175 @@
+package ring
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+)
+
+// Ring with the given nodes, each owning vnodes points
+func newRing(vnodes int, nodes ...string) *Ring {
+	r := New(vnodes)
+	r.Add(nodes...)
+	return r
+}
+
+// Assignment containing h, false if none or more than one does
+func owning(assignments []Assignment, h uint64) (Assignment, bool) {
+	var found Assignment
+	n := 0
+	for _, a := range assignments {
+		if a.Contains(h) {
+			found = a
+			n++
+		}
+	}
+	return found, n == 1
+}
+
+func TestAssignmentContains(t *testing.T) {
+	tests := []struct {
+		name string
+		a    Assignment
+		h    uint64
+		want bool
+	}{
+		{"inside", Assignment{Start: 10, End: 20}, 15, true},
+		{"at end", Assignment{Start: 10, End: 20}, 20, true},
+		{"at start", Assignment{Start: 10, End: 20}, 10, false},
+		{"after end", Assignment{Start: 10, End: 20}, 21, false},
+		{"wrapping, above start", Assignment{Start: 20, End: 10}, math.MaxUint64, true},
+		{"wrapping, below end", Assignment{Start: 20, End: 10}, 0, true},
+		{"wrapping, between", Assignment{Start: 20, End: 10}, 15, false},
+		{"whole ring", Assignment{Start: 7, End: 7}, 7, true},
+		{"whole ring, elsewhere", Assignment{Start: 7, End: 7}, 123, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.Contains(tt.h); got != tt.want {
+				t.Errorf("%v Contains(%d) = %v, want %v", tt.a, tt.h, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssignmentsAgreeWithNodeFor(t *testing.T) {
+	tests := []struct {
+		name string
+		ring *Ring
+	}{
+		{"single point", newRing(1, "a")},
+		{"single node", newRing(0, "a")},
+		{"two nodes, few points", newRing(4, "a", "b")},
+		{"three nodes", newRing(0, "a", "b", "c")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignments := tt.ring.Assignments()
+			if len(assignments) == 0 {
+				t.Fatal("no assignments")
+			}
+			// Ranges are contiguous and wrap back to the first
+			for i, a := range assignments {
+				next := assignments[(i+1)%len(assignments)]
+				if a.End != next.Start {
+					t.Errorf("%v is followed by %v", a, next)
+				}
+			}
+			for i := range 2000 {
+				key := fmt.Sprintf("key:%d", i)
+				a, ok := owning(assignments, Hash(key))
+				if !ok {
+					t.Fatalf("%s is not in exactly one assignment", key)
+				}
+				if want := tt.ring.NodeFor(key); a.Node != want {
+					t.Errorf("%s is in %v, NodeFor says %s", key, a, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSinglePointCoversRing(t *testing.T) {
+	r := newRing(1, "a")
+	assignments := r.Assignments()
+	if len(assignments) != 1 {
+		t.Fatalf("Assignments = %v, want one", assignments)
+	}
+	a := assignments[0]
+	if a.Start != a.End || a.Node != "a" {
+		t.Errorf("assignment %v, want the whole ring for a", a)
+	}
+	for _, h := range []uint64{0, a.End, a.End + 1, math.MaxUint64} {
+		if !a.Contains(h) {
+			t.Errorf("%v does not contain %d", a, h)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new *Ring
+		// Every added range goes to one of these, every removed range leaves one
+		addedTo   []string
+		removedOf []string
+	}{
+		{"add node", newRing(0, "a", "b"), newRing(0, "a", "b", "c"), []string{"c"}, []string{"a", "b"}},
+		{"remove node", newRing(0, "a", "b", "c"), newRing(0, "a", "b"), []string{"a", "b"}, []string{"c"}},
+		{"replace node", newRing(0, "a", "b"), newRing(0, "a", "c"), []string{"a", "c"}, []string{"a", "b"}},
+		{"empty old ring", newRing(0), newRing(0, "a", "b"), []string{"a", "b"}, []string{""}},
+		{"single point from empty", newRing(0), newRing(1, "a"), []string{"a"}, []string{""}},
+		{"unchanged", newRing(0, "a", "b"), newRing(0, "b", "a"), nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := Diff(tt.old, tt.new)
+			for _, a := range added {
+				if !slices.Contains(tt.addedTo, a.Node) {
+					t.Errorf("added %v, want a node in %q", a, tt.addedTo)
+				}
+			}
+			for _, a := range removed {
+				if !slices.Contains(tt.removedOf, a.Node) {
+					t.Errorf("removed %v, want a node in %q", a, tt.removedOf)
+				}
+			}
+
+			// Exactly the keys that change owner fall in the diff
+			moved := 0
+			for i := range 2000 {
+				key := fmt.Sprintf("key:%d", i)
+				from, to := tt.old.NodeFor(key), tt.new.NodeFor(key)
+				a, inAdded := owning(added, Hash(key))
+				r, inRemoved := owning(removed, Hash(key))
+				if from == to {
+					if inAdded || inRemoved {
+						t.Errorf("%s stays on %q but is in the diff", key, from)
+					}
+					continue
+				}
+				moved++
+				if !inAdded || a.Node != to {
+					t.Errorf("%s moves to %q, added range is %v", key, to, a)
+				}
+				if !inRemoved || r.Node != from {
+					t.Errorf("%s moves from %q, removed range is %v", key, from, r)
+				}
+			}
+			if len(added) > 0 && moved == 0 {
+				t.Error("diff has ranges but no key moved")
+			}
+		})
+	}
+}
+
+func TestDiffOfEmptyOldRingCoversRing(t *testing.T) {
+	added, removed := Diff(newRing(0), newRing(1, "a"))
+	if len(added) != 1 || added[0].Start != added[0].End || added[0].Node != "a" {
+		t.Errorf("added = %v, want the whole ring for a", added)
+	}
+	if len(removed) != 1 || removed[0].Node != "" {
+		t.Errorf("removed = %v, want the whole ring with no owner", removed)
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	channelzsvc "google.golang.org/grpc/channelz/service"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Captures the channelz implementation so it can be queried in-process
+type channelzRegistrar struct {
+	server channelzpb.ChannelzServer
+}
+
+func (r *channelzRegistrar) RegisterService(_ *grpc.ServiceDesc, impl any) {
+	r.server = impl.(channelzpb.ChannelzServer)
+}
+
+// In-process channelz API, no network round trip needed
+func newChannelzServer() channelzpb.ChannelzServer {
+	var r channelzRegistrar
+	channelzsvc.RegisterChannelzServiceToServer(&r)
+	return r.server
+}
+
+// Summary of server-side gRPC activity
+type grpcStats struct {
+	Servers     []grpcServerStats  `json:"servers"`
+	TopChannels []grpcChannelStats `json:"top_channels"`
+}
+
+type grpcServerStats struct {
+	ID             int64 `json:"id"`
+	CallsStarted   int64 `json:"calls_started"`
+	CallsSucceeded int64 `json:"calls_succeeded"`
+	CallsFailed    int64 `json:"calls_failed"`
+	// Client connections, busiest first
+	Sockets []grpcSocketStats `json:"sockets"`
+}
+
+type grpcSocketStats struct {
+	ID               int64  `json:"id"`
+	Remote           string `json:"remote"`
+	StreamsStarted   int64  `json:"streams_started"`
+	StreamsSucceeded int64  `json:"streams_succeeded"`
+	StreamsFailed    int64  `json:"streams_failed"`
+	MessagesSent     int64  `json:"messages_sent"`
+	MessagesReceived int64  `json:"messages_received"`
+}
+
+type grpcChannelStats struct {
+	ID             int64  `json:"id"`
+	Target         string `json:"target"`
+	State          string `json:"state"`
+	CallsStarted   int64  `json:"calls_started"`
+	CallsSucceeded int64  `json:"calls_succeeded"`
+	CallsFailed    int64  `json:"calls_failed"`
+}
+
+// Dump raw channelz servers and top-level channels as protobuf JSON
+func adminChannelzHandler(cz channelzpb.ChannelzServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		servers, err := cz.GetServers(r.Context(), &channelzpb.GetServersRequest{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		channels, err := cz.GetTopChannels(r.Context(), &channelzpb.GetTopChannelsRequest{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp := make(map[string]json.RawMessage, 2)
+		if resp["servers"], err = protojson.Marshal(servers); err == nil {
+			resp["top_channels"], err = protojson.Marshal(channels)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.Error("failed to encode channelz response", "error", err)
+		}
+	}
+}
+
+// Report per-server and per-connection call counts, e.g. to find the busiest clients
+func adminGRPCStatsHandler(cz channelzpb.ChannelzServer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		stats, err := collectGRPCStats(r.Context(), cz)
+		if err != nil {
+			slog.Error("failed to collect gRPC stats", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			slog.Error("failed to encode gRPC stats response", "error", err)
+		}
+	}
+}
+
+// Walk channelz servers, their sockets and the top-level channels
+func collectGRPCStats(ctx context.Context, cz channelzpb.ChannelzServer) (*grpcStats, error) {
+	stats := &grpcStats{
+		Servers:     []grpcServerStats{},
+		TopChannels: []grpcChannelStats{},
+	}
+
+	servers, err := cz.GetServers(ctx, &channelzpb.GetServersRequest{})
+	if err != nil {
+		return nil, err
+	}
+	for _, server := range servers.GetServer() {
+		data := server.GetData()
+		s := grpcServerStats{
+			ID:             server.GetRef().GetServerId(),
+			CallsStarted:   data.GetCallsStarted(),
+			CallsSucceeded: data.GetCallsSucceeded(),
+			CallsFailed:    data.GetCallsFailed(),
+			Sockets:        []grpcSocketStats{},
+		}
+		if s.Sockets, err = collectSocketStats(ctx, cz, s.ID); err != nil {
+			return nil, err
+		}
+		stats.Servers = append(stats.Servers, s)
+	}
+
+	channels, err := cz.GetTopChannels(ctx, &channelzpb.GetTopChannelsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels.GetChannel() {
+		data := channel.GetData()
+		stats.TopChannels = append(stats.TopChannels, grpcChannelStats{
+			ID:             channel.GetRef().GetChannelId(),
+			Target:         data.GetTarget(),
+			State:          data.GetState().GetState().String(),
+			CallsStarted:   data.GetCallsStarted(),
+			CallsSucceeded: data.GetCallsSucceeded(),
+			CallsFailed:    data.GetCallsFailed(),
+		})
+	}
+
+	return stats, nil
+}
+
+// Stats for every connection accepted by a server, most messages received first
+func collectSocketStats(ctx context.Context, cz channelzpb.ChannelzServer, serverID int64) ([]grpcSocketStats, error) {
+	sockets := []grpcSocketStats{}
+	start := int64(0)
+	for {
+		page, err := cz.GetServerSockets(ctx, &channelzpb.GetServerSocketsRequest{ServerId: serverID, StartSocketId: start})
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range page.GetSocketRef() {
+			start = ref.GetSocketId() + 1
+			resp, err := cz.GetSocket(ctx, &channelzpb.GetSocketRequest{SocketId: ref.GetSocketId()})
+			if err != nil {
+				// The connection closed between listing and lookup
+				continue
+			}
+			socket := resp.GetSocket()
+			data := socket.GetData()
+			sockets = append(sockets, grpcSocketStats{
+				ID:               ref.GetSocketId(),
+				Remote:           formatAddress(socket.GetRemote()),
+				StreamsStarted:   data.GetStreamsStarted(),
+				StreamsSucceeded: data.GetStreamsSucceeded(),
+				StreamsFailed:    data.GetStreamsFailed(),
+				MessagesSent:     data.GetMessagesSent(),
+				MessagesReceived: data.GetMessagesReceived(),
+			})
+		}
+		if page.GetEnd() || len(page.GetSocketRef()) == 0 {
+			break
+		}
+	}
+
+	sort.Slice(sockets, func(i, j int) bool { return sockets[i].MessagesReceived > sockets[j].MessagesReceived })
+	return sockets, nil
+}
+
+func formatAddress(addr *channelzpb.Address) string {
+	if tcp := addr.GetTcpipAddress(); tcp != nil {
+		return net.JoinHostPort(net.IP(tcp.GetIpAddress()).String(), strconv.Itoa(int(tcp.GetPort())))
+	}
+	if uds := addr.GetUdsAddress(); uds != nil {
+		return uds.GetFilename()
+	}
+	return addr.GetOtherAddress().GetName()
+}
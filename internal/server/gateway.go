@@ -0,0 +1,312 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/amillerrr/distributed-kv-store/client"
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+	"github.com/amillerrr/distributed-kv-store/internal/ratelimit"
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+	"github.com/amillerrr/distributed-kv-store/signing"
+)
+
+// Key and its current value as sent in the initial watch event
+type keyValue struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Found   bool   `json:"found"`
+	Version int64  `json:"version,omitempty"`
+}
+
+// Change to a watched key as sent in an SSE data line
+type keyEvent struct {
+	Type      string `json:"type"`
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`
+	Version   int64  `json:"version,omitempty"`
+	Sequence  int64  `json:"sequence,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// REST access to keys on the HTTP port. Routes are generated from the
+// google.api.http options in proto/store.proto, described by the OpenAPI
+// spec in proto/store.swagger.json, for example:
+//
+//	GET    /v1/keys/{key}        current value as JSON, 404 if it does not exist,
+//	                             304 if If-None-Match or If-Modified-Since still hold
+//	PUT    /v1/keys/{key}        store {"value": ...}
+//	DELETE /v1/keys/{key}        remove the key
+//
+// Watching is not generated, as changes are sent as server-sent events:
+//
+//	GET /v1/keys/{key}/watch  changes as server-sent events
+//
+// Generated routes call the gRPC server at grpcAddr, so REST requests pass
+// the same recovery, auth, signing, rate limit, breaker and validation
+// interceptors as gRPC ones. The connection closes once ctx is done
+func (s *Server) gatewayHandler(ctx context.Context, grpcAddr string) http.Handler {
+	gateway := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithForwardResponseOption(forwardKeyResponse),
+		runtime.WithIncomingHeaderMatcher(gatewayHeaderMatcher),
+		// Split the path on / before unescaping, so a key may contain %2F
+		runtime.WithUnescapingMode(runtime.UnescapingModeAllExceptReserved),
+	)
+	if err := pb.RegisterKeyValueStoreHandlerFromEndpoint(ctx, gateway, grpcAddr, s.gatewayDialOptions()); err != nil {
+		slog.Error("failed to register REST gateway", "error", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", gateway)
+	mux.Handle("GET /v1/keys/{key}", conditionalGet(gateway))
+	mux.HandleFunc("GET /v1/keys/{key}/watch", watchKeyHandler(s.kvStore))
+	if s.authProvider != nil {
+		return auth.HTTPMiddleware(s.authProvider)(mux)
+	}
+	return mux
+}
+
+// Headers REST callers may set that change how the gRPC server handles a
+// request. Authorization is always forwarded by the gateway
+var gatewayHeaders = map[string]bool{
+	signing.SignatureHeader:   true,
+	signing.TimestampHeader:   true,
+	signing.KeyIDHeader:       true,
+	ratelimit.NamespaceHeader: true,
+	client.RequestIDHeader:    true,
+}
+
+// Forward the headers in gatewayHeaders as metadata of the same name, and
+// the rest as the gateway does by default
+func gatewayHeaderMatcher(header string) (string, bool) {
+	if key := strings.ToLower(header); gatewayHeaders[key] {
+		return key, true
+	}
+	return runtime.DefaultHeaderMatcher(header)
+}
+
+// Dial the gRPC server over loopback. With TLS the certificate names the
+// public host rather than 127.0.0.1, and the connection never leaves this
+// host, so it is not verified
+func (s *Server) gatewayDialOptions() []grpc.DialOption {
+	creds := insecure.NewCredentials()
+	if s.cfg.TLSEnabled() {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: true})
+	}
+	return []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+}
+
+// Address reaching a listener bound to addr from this host
+func loopbackAddr(addr net.Addr) string {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return net.JoinHostPort("127.0.0.1", strconv.Itoa(tcp.Port))
+	}
+	return addr.String()
+}
+
+// Keep responses out of caches, except values found by Get, which caches may
+// keep if they revalidate them with the ETag and Last-Modified sent along.
+// A Get of a missing key is answered with 404, an unchanged one with 304
+func forwardKeyResponse(ctx context.Context, w http.ResponseWriter, resp proto.Message) error {
+	get, ok := resp.(*pb.GetResponse)
+	if !ok || !get.Found {
+		w.Header().Set("Cache-Control", "no-store")
+		if ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return nil
+	}
+
+	header := w.Header()
+	header.Set("Cache-Control", "no-cache")
+	header.Set("ETag", `"`+get.Etag+`"`)
+	if get.LastModifiedMs > 0 {
+		header.Set("Last-Modified", time.UnixMilli(get.LastModifiedMs).UTC().Format(http.TimeFormat))
+		header.Set("X-KV-Modified-At", strconv.FormatInt(get.LastModifiedMs, 10))
+	}
+	if get.NotModified {
+		// The gateway still writes the body, which net/http drops for a 304
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return nil
+}
+
+// Pass the If-None-Match or If-Modified-Since header of a Get on as its
+// if_none_match or if_modified_since_ms. As HTTP requires, If-Modified-Since
+// is ignored alongside If-None-Match, and so is a list of several ETags
+func conditionalGet(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if match := r.Header.Get("If-None-Match"); match != "" {
+			if etag, ok := parseETag(match); ok {
+				query.Set("if_none_match", etag)
+			}
+		} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil {
+			// The header has whole seconds and Last-Modified was rounded down to
+			// them, so writes within the same second count as no later
+			query.Set("if_modified_since_ms", strconv.FormatInt(since.UnixMilli()+999, 10))
+		}
+		r.URL.RawQuery = query.Encode()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Opaque part of a single, possibly weak, entity tag such as "abc" or W/"abc"
+func parseETag(header string) (string, bool) {
+	tag := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' || strings.Contains(tag, ",") {
+		return "", false
+	}
+	return tag[1 : len(tag)-1], true
+}
+
+// Stream changes to a key as server-sent events. The current value comes
+// first: pushed as GET /v1/keys/{key} over HTTP/2 when the request carries
+// "Prefer: push", otherwise as an "initial" event
+func watchKeyHandler(kvStore *service.KVStoreService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		key := r.PathValue("key")
+
+		// Watch before reading the current value so no change falls between
+		events, err := kvStore.Watch(r.Context(), key)
+		if err != nil {
+			writeStatusError(w, err)
+			return
+		}
+
+		pushed := prefersPush(r) && pushValue(w, r, key)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		if !pushed {
+			kv, err := currentValue(r, kvStore, key)
+			if err != nil {
+				slog.Warn("watch could not read initial value", "key", key, "error", err)
+				return
+			}
+			if err := writeSSE(w, "initial", "", kv); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		for event := range events {
+			id := ""
+			if event.Sequence > 0 {
+				id = fmt.Sprint(event.Sequence)
+			}
+			if err := writeSSE(w, strings.ToLower(event.ChangeType.String()), id, keyEvent{
+				Type:      event.ChangeType.String(),
+				Key:       event.Key,
+				Value:     event.Value,
+				Version:   event.Version,
+				Sequence:  event.Sequence,
+				Timestamp: event.Timestamp,
+			}); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Report whether the request asked for the current value to be pushed
+func prefersPush(r *http.Request) bool {
+	for _, prefer := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(prefer, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "push") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Push GET /v1/keys/{key}, reporting whether the client accepted it. Fails on
+// HTTP/1.1 and for HTTP/2 clients with push disabled
+func pushValue(w http.ResponseWriter, r *http.Request, key string) bool {
+	pusher, ok := w.(http.Pusher)
+	if !ok {
+		return false
+	}
+	header := http.Header{"Cache-Control": {"no-store"}}
+	if authz := r.Header.Get("Authorization"); authz != "" {
+		header.Set("Authorization", authz)
+	}
+	err := pusher.Push("/v1/keys/"+url.PathEscape(key), &http.PushOptions{Method: http.MethodGet, Header: header})
+	if err != nil {
+		slog.Debug("push of current value declined, sending it as an event", "key", key, "error", err)
+		return false
+	}
+	return true
+}
+
+func currentValue(r *http.Request, kvStore *service.KVStoreService, key string) (keyValue, error) {
+	resp, err := kvStore.Get(r.Context(), &pb.GetRequest{Key: key})
+	if err != nil {
+		return keyValue{}, err
+	}
+	return keyValue{Key: key, Value: resp.Value, Found: resp.Found, Version: resp.Version}, nil
+}
+
+// Write one server-sent event with v as its JSON data
+func writeSSE(w http.ResponseWriter, event, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// Answer with the HTTP status closest to a gRPC error
+func writeStatusError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	code := http.StatusInternalServerError
+	switch st.Code() {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.FailedPrecondition:
+		code = http.StatusConflict
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(w, st.Message(), code)
+}
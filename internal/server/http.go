@@ -0,0 +1,339 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+	"github.com/amillerrr/distributed-kv-store/internal/circuitbreaker"
+	"github.com/amillerrr/distributed-kv-store/internal/middleware/cors"
+	"github.com/amillerrr/distributed-kv-store/internal/objectstore"
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	"github.com/amillerrr/distributed-kv-store/internal/version"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Health, metrics and admin endpoints served on the HTTP port, with the REST
+// gateway calling the gRPC server at grpcAddr until ctx is done
+func (s *Server) httpHandler(ctx context.Context, grpcAddr string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health/live", livenessHandler)
+	breaker, _ := storage.As[*circuitbreaker.Backend](s.store)
+	mux.HandleFunc("/health/ready", readinessHandler(s.kvStore, &s.serving, breaker))
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/admin/", s.adminHandler())
+	mux.Handle("/v1/", s.gatewayHandler(ctx, grpcAddr))
+
+	if len(s.corsOrigins) > 0 {
+		return cors.Middleware(s.corsOrigins)(mux)
+	}
+	return mux
+}
+
+// Serve the /admin/ endpoints. They share the port with the REST gateway, so
+// they require the same credentials when an auth provider is configured
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/stats", adminStatsHandler(s.kvStore))
+	mux.HandleFunc("/admin/prefix-stats", adminPrefixStatsHandler(s.kvStore))
+
+	channelz := newChannelzServer()
+	mux.HandleFunc("/admin/channelz", adminChannelzHandler(channelz))
+	mux.HandleFunc("/admin/grpc-stats", adminGRPCStatsHandler(channelz))
+
+	var uploader *objectstore.S3Uploader
+	if s.cfg.S3Endpoint != "" {
+		var opts []objectstore.S3Option
+		if s.cfg.S3AccessKeyID != "" {
+			opts = append(opts, objectstore.WithS3Credentials(s.cfg.S3AccessKeyID, s.cfg.S3SecretAccessKey, s.cfg.S3Region))
+		}
+		uploader = objectstore.NewS3Uploader(s.cfg.S3Endpoint, nil, opts...)
+	}
+	mux.HandleFunc("/admin/snapshot", adminSnapshotHandler(s.kvStore, uploader))
+	mux.HandleFunc("/admin/compact", adminCompactHandler(s.kvStore))
+	mux.HandleFunc("/admin/scan-sessions", adminScanSessionsHandler(s.kvStore))
+	mux.HandleFunc("/admin/mark-ready", adminMarkReadyHandler(s.kvStore))
+
+	if s.authProvider != nil {
+		return auth.HTTPMiddleware(s.authProvider)(mux)
+	}
+	return mux
+}
+
+// Indicate whether the service is running
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, "alive", "")
+}
+
+// Indicate if the service is ready. The storage circuit breaker state is
+// reported when breaker is not nil
+func readinessHandler(kvStore *service.KVStoreService, serving *atomic.Bool, breaker *circuitbreaker.Backend) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// In production, might check db connections, dependant service availability, or resource availability
+		resp := healthResponse{Info: version.Get()}
+		if breaker != nil {
+			resp.CircuitBreaker = breaker.State().String()
+		}
+		write := func(code int, status, reason string) {
+			resp.Status, resp.Reason = status, reason
+			writeHealthResponse(w, code, resp)
+		}
+
+		// HTTP comes up first so probes get an answer while the store is seeded
+		if !serving.Load() {
+			write(http.StatusServiceUnavailable, "starting", "gRPC server not serving yet")
+			return
+		}
+
+		// SEED_FILE is loaded, or SEED_EXTERNAL is waiting for /admin/mark-ready
+		if !kvStore.Ready() {
+			write(http.StatusServiceUnavailable, "seeding", "store not seeded yet")
+			return
+		}
+
+		// Stop routing traffic here while a subscriber is about to drop events
+		if kvStore.Degraded() {
+			write(http.StatusServiceUnavailable, "degraded", "subscriber channel above 90% capacity")
+			return
+		}
+
+		// Traffic returns once the open timeout passes so a request can probe
+		if resp.CircuitBreaker == circuitbreaker.Open.String() {
+			write(http.StatusServiceUnavailable, "degraded", "storage circuit breaker is open")
+			return
+		}
+
+		write(http.StatusOK, "ready", "")
+	}
+}
+
+// Health body identifying which build is responding; probes only check the status code
+type healthResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// State of the storage circuit breaker, omitted when it is disabled
+	CircuitBreaker string `json:"circuit_breaker,omitempty"`
+	version.Info
+}
+
+// Write a health response with build info
+func writeHealth(w http.ResponseWriter, code int, status, reason string) {
+	writeHealthResponse(w, code, healthResponse{
+		Status: status,
+		Reason: reason,
+		Info:   version.Get(),
+	})
+}
+
+func writeHealthResponse(w http.ResponseWriter, code int, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Report store stats, tagged with partition stats when ?partition= is provided
+func adminStatsHandler(kvStore *service.KVStoreService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp := struct {
+			service.Stats
+			Partition *service.PartitionStats `json:"partition,omitempty"`
+		}{Stats: kvStore.Stats()}
+
+		if partition := r.URL.Query().Get("partition"); partition != "" {
+			stats := kvStore.PartitionStats(partition)
+			resp.Partition = &stats
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			slog.Error("failed to encode stats response", "error", err)
+		}
+	}
+}
+
+// Usage of the keys under one prefix, as served by /admin/prefix-stats
+type prefixStatJSON struct {
+	KeyCount            int64 `json:"key_count"`
+	TotalValueSizeBytes int64 `json:"total_value_size_bytes"`
+	TotalKeySizeBytes   int64 `json:"total_key_size_bytes"`
+	OldestModifiedMs    int64 `json:"oldest_modified_ms"`
+	NewestModifiedMs    int64 `json:"newest_modified_ms"`
+}
+
+// Report key counts and sizes by prefix, e.g.
+// GET /admin/prefix-stats?prefix=user:&prefix=session:. Without a prefix,
+// keys are grouped by their top-level namespace
+func adminPrefixStatsHandler(kvStore *service.KVStoreService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		resp, err := kvStore.PrefixStats(r.Context(), &pb.PrefixStatsRequest{Prefixes: r.URL.Query()["prefix"]})
+		if err != nil {
+			st := status.Convert(err)
+			code := http.StatusInternalServerError
+			if st.Code() == codes.InvalidArgument {
+				code = http.StatusBadRequest
+			}
+			http.Error(w, st.Message(), code)
+			return
+		}
+
+		stats := make(map[string]prefixStatJSON, len(resp.Stats))
+		for prefix, stat := range resp.Stats {
+			stats[prefix] = prefixStatJSON{
+				KeyCount:            stat.KeyCount,
+				TotalValueSizeBytes: stat.TotalValueSizeBytes,
+				TotalKeySizeBytes:   stat.TotalKeySizeBytes,
+				OldestModifiedMs:    stat.OldestModifiedMs,
+				NewestModifiedMs:    stat.NewestModifiedMs,
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]any{
+			"stats":          stats,
+			"computed_at_ms": resp.ComputedAtMs,
+		}); err != nil {
+			slog.Error("failed to encode prefix stats response", "error", err)
+		}
+	}
+}
+
+// Stream a snapshot to object storage, e.g. POST /admin/snapshot?dest=s3://bucket/key
+func adminSnapshotHandler(kvStore *service.KVStoreService, uploader *objectstore.S3Uploader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if uploader == nil {
+			http.Error(w, "S3_ENDPOINT is not configured", http.StatusServiceUnavailable)
+			return
+		}
+
+		bucket, key, err := objectstore.ParseS3URL(r.URL.Query().Get("dest"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Pipe the snapshot straight into the upload body
+		pr, pw := io.Pipe()
+		written := make(chan int64, 1)
+		go func() {
+			n, err := kvStore.WriteTo(pw)
+			written <- n
+			pw.CloseWithError(err)
+		}()
+
+		if err := uploader.Upload(r.Context(), bucket, key, pr); err != nil {
+			pr.CloseWithError(err)
+			slog.Error("snapshot upload failed", "bucket", bucket, "key", key, "error", err)
+			http.Error(w, "snapshot upload failed", http.StatusBadGateway)
+			return
+		}
+		n := <-written
+
+		slog.Info("snapshot uploaded", "bucket", bucket, "key", key, "bytes", n)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": "uploaded",
+			"dest":   fmt.Sprintf("s3://%s/%s", bucket, key),
+			"bytes":  n,
+		})
+	}
+}
+
+// Drop every open ConsistentScan session, e.g. DELETE /admin/scan-sessions
+func adminScanSessionsHandler(kvStore *service.KVStoreService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]int{"dropped": kvStore.DropScanSessions()})
+	}
+}
+
+// Report the store ready once an external process has seeded it
+func adminMarkReadyHandler(kvStore *service.KVStoreService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"changed": kvStore.MarkReady()})
+	}
+}
+
+// Compact the event history, e.g. POST /admin/compact?key=user:1&retain_last=10.
+// retain_since is Unix ms, omitting key compacts every key
+func adminCompactHandler(kvStore *service.KVStoreService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		req := &pb.CompactHistoryRequest{Key: query.Get("key")}
+		if v := query.Get("retain_last"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil {
+				http.Error(w, "retain_last must be an integer", http.StatusBadRequest)
+				return
+			}
+			req.RetainLast = int32(n)
+		}
+		if v := query.Get("retain_since"); v != "" {
+			ms, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "retain_since must be Unix milliseconds", http.StatusBadRequest)
+				return
+			}
+			req.RetainSince = ms
+		}
+
+		resp, err := kvStore.CompactHistory(r.Context(), req)
+		if err != nil {
+			st := status.Convert(err)
+			code := http.StatusInternalServerError
+			switch st.Code() {
+			case codes.InvalidArgument:
+				code = http.StatusBadRequest
+			case codes.FailedPrecondition:
+				code = http.StatusConflict
+			}
+			http.Error(w, st.Message(), code)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"status":        "compacted",
+			"removed_count": resp.RemovedCount,
+		})
+	}
+}
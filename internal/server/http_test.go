@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Create a server with cfg and close it when the test ends
+func newTestServer(t *testing.T, cfg *config.ServerConfig, opts ...ServerOption) *Server {
+	t.Helper()
+	s := New(append([]ServerOption{WithConfig(cfg)}, opts...)...)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestAdminSnapshotUploadsToS3(t *testing.T) {
+	var uploaded bytes.Buffer
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/backups/nightly/kv.gz" {
+			http.Error(w, "unexpected "+r.Method+" "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			http.Error(w, "missing signature", http.StatusForbidden)
+			return
+		}
+		io.Copy(&uploaded, r.Body)
+	}))
+	defer s3.Close()
+
+	cfg := config.Default()
+	cfg.S3Endpoint = s3.URL
+	cfg.S3AccessKeyID, cfg.S3SecretAccessKey = "AKID", "secret"
+	s := newTestServer(t, cfg)
+
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := s.kvStore.Set(ctx, &pb.SetRequest{Key: key, Value: "value-" + key}); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.adminHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/snapshot?dest=s3://backups/nightly/kv.gz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	// The uploaded object restores into an empty store
+	restored := service.NewKVStoreService(service.WithConfig(config.Default()))
+	defer restored.Close()
+	if _, err := restored.ReadFrom(&uploaded); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		resp, err := restored.Get(ctx, &pb.GetRequest{Key: key})
+		if err != nil {
+			t.Fatalf("Get %s: %v", key, err)
+		}
+		if resp.Value != "value-"+key {
+			t.Errorf("%s = %q, want %q", key, resp.Value, "value-"+key)
+		}
+	}
+}
+
+func TestAdminEndpointsRequireAuth(t *testing.T) {
+	provider := auth.NewStaticKeyProvider(map[string]auth.Identity{"admin-key": {SubjectID: "admin"}})
+	s := newTestServer(t, config.Default(), WithAuthProvider(provider))
+	handler := s.httpHandler(context.Background(), "127.0.0.1:0")
+
+	for _, path := range []string{"/admin/stats", "/admin/compact", "/admin/scan-sessions", "/admin/mark-ready", "/admin/snapshot"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s without a token: status = %d, want 401", path, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.Header.Set(auth.AuthorizationHeader, "Bearer admin-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("/admin/stats with a token: status = %d, want 200", rec.Code)
+	}
+
+	// Probes stay open
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("/health/live: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestReadinessWaitsForSeeding(t *testing.T) {
+	cfg := config.Default()
+	cfg.SeedExternal = true
+	s := newTestServer(t, cfg)
+	s.serving.Store(true)
+	handler := s.httpHandler(context.Background(), "127.0.0.1:0")
+
+	ready := func() (int, string) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	// Seed from a pipe so the load is still running when the probe arrives
+	r, w := io.Pipe()
+	seeded := make(chan error, 1)
+	go func() {
+		_, err := s.kvStore.LoadSeed(context.Background(), r)
+		seeded <- err
+	}()
+	io.WriteString(w, `{"key": "a", "value": "1"}`+"\n")
+
+	if code, body := ready(); code != http.StatusServiceUnavailable || !strings.Contains(body, `"seeding"`) {
+		t.Errorf("ready while seeding = %d %s, want 503 seeding", code, body)
+	}
+
+	w.Close()
+	if err := <-seeded; err != nil {
+		t.Fatalf("LoadSeed: %v", err)
+	}
+	// Still gated until the external seeder says it is done
+	if code, _ := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("ready before mark-ready = %d, want 503", code)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/mark-ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("mark-ready: status = %d: %s", rec.Code, rec.Body)
+	}
+	if code, body := ready(); code != http.StatusOK {
+		t.Errorf("ready after mark-ready = %d %s, want 200", code, body)
+	}
+}
+
+// Subscribe stream whose client never reads, so its channel fills up
+type stalledStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stalledStream) Context() context.Context { return s.ctx }
+
+func (s *stalledStream) Send(*pb.ChangeEvent) error {
+	<-s.ctx.Done()
+	return s.ctx.Err()
+}
+
+func TestReadinessFailsWhileSubscriberIsFull(t *testing.T) {
+	s := newTestServer(t, config.Default())
+	s.serving.Store(true)
+	handler := s.httpHandler(context.Background(), "127.0.0.1:0")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.kvStore.Subscribe(&pb.SubscribeRequest{KeyPattern: "slow:"}, &stalledStream{ctx: ctx})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Write until the stalled subscriber's channel is nearly full
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.kvStore.Degraded() {
+		if time.Now().After(deadline) {
+			t.Fatal("subscriber channel never filled")
+		}
+		if _, err := s.kvStore.Set(ctx, &pb.SetRequest{Key: "slow:key", Value: "v"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"degraded"`) {
+		t.Errorf("ready with a full subscriber = %d %s, want 503 degraded", rec.Code, rec.Body)
+	}
+}
+
+func TestHealthReportsVersion(t *testing.T) {
+	s := newTestServer(t, config.Default())
+	s.serving.Store(true)
+	handler := s.httpHandler(context.Background(), "127.0.0.1:0")
+
+	for path, want := range map[string]string{"/health/live": "alive", "/health/ready": "ready"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", path, rec.Code)
+		}
+
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode %s: %v", path, rec.Body, err)
+		}
+		if body["status"] != want {
+			t.Errorf("%s: status = %q, want %q", path, body["status"], want)
+		}
+		for _, field := range []string{"version", "commit", "go"} {
+			if body[field] == "" {
+				t.Errorf("%s: %s missing from %s", path, field, rec.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/amillerrr/distributed-kv-store/client"
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/ratelimit"
+	"github.com/amillerrr/distributed-kv-store/signing"
+)
+
+// Log incoming gRPC requests
+func loggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	logger := slog.Default()
+	// Tag every line with the caller's request ID so it can be traced
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(client.RequestIDHeader); len(ids) > 0 {
+			logger = logger.With("request_id", ids[0])
+		}
+	}
+	logger.Info("gRPC request", "method", info.FullMethod)
+
+	resp, err := handler(ctx, req)
+
+	if err != nil {
+		logger.Error("gRPC request failed", "method", info.FullMethod, "error", err)
+	} else {
+		logger.Info("gRPC request completed", "method", info.FullMethod)
+	}
+
+	return resp, err
+}
+
+// Limit requests and streams per caller or per x-namespace header within
+// each caller, sharing one set of buckets
+func newRateLimitInterceptors(cfg *config.ServerConfig) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	extract := ratelimit.PeerKeyExtractor
+	if cfg.RateLimitKey == config.RateLimitByNamespace {
+		extract = ratelimit.NamespaceKeyExtractor
+	}
+
+	overrides := make(map[string]rate.Limit, len(cfg.RateLimitNamespaceRPS))
+	for namespace, rps := range cfg.RateLimitNamespaceRPS {
+		overrides[namespace] = rate.Limit(rps)
+	}
+
+	slog.Info("rate limiting enabled", "rps", cfg.RateLimitRPS, "burst", cfg.RateLimitBurst, "key", cfg.RateLimitKey)
+	limiter := ratelimit.NewLimiter(rate.Limit(cfg.RateLimitRPS), cfg.RateLimitBurst, ratelimit.WithPerNamespaceLimits(overrides))
+	return limiter.UnaryServerInterceptor(extract), limiter.StreamServerInterceptor(extract)
+}
+
+// Build the provider selected by AUTH_PROVIDER, nil if auth is disabled
+func newAuthProvider(cfg *config.ServerConfig) (auth.Provider, error) {
+	switch cfg.AuthProvider {
+	case config.AuthStatic:
+		p, err := auth.LoadStaticKeyFile(cfg.AuthKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("API key authentication required", "key_file", cfg.AuthKeyFile)
+		return p, nil
+	case config.AuthJWT:
+		var opts []auth.JWTOption
+		if cfg.AuthJWTAudience != "" {
+			opts = append(opts, auth.WithAudience(cfg.AuthJWTAudience))
+		}
+		if cfg.AuthJWTIssuer != "" {
+			opts = append(opts, auth.WithIssuer(cfg.AuthJWTIssuer))
+		}
+		p, err := auth.NewJWTProvider(cfg.AuthJWKSURL, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("create JWT provider: %w", err)
+		}
+		slog.Info("JWT authentication required", "jwks_url", cfg.AuthJWKSURL, "audience", cfg.AuthJWTAudience, "issuer", cfg.AuthJWTIssuer)
+		return p, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Verify request and stream signatures made with any of the configured keys
+func newSigningInterceptors(cfg *config.ServerConfig) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	keys := make(map[string][]byte, len(cfg.RequestSigningKeys))
+	for id, secret := range cfg.RequestSigningKeys {
+		keys[id] = []byte(secret)
+	}
+
+	slog.Info("request signing required", "key_count", len(keys), "max_age", cfg.RequestSigningMaxAge)
+	return signing.NewVerificationInterceptor(keys, cfg.RequestSigningMaxAge),
+		signing.NewStreamVerificationInterceptor(keys, cfg.RequestSigningMaxAge)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestMetricsSanity(t *testing.T) {
+	s := newTestServer(t, config.Default())
+	handler := s.httpHandler(context.Background(), "127.0.0.1:0")
+
+	// Give the vectors label values to check
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c"} {
+		if _, err := s.kvStore.Set(ctx, &pb.SetRequest{Key: key, Value: "v"}); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+		if _, err := s.kvStore.Get(ctx, &pb.GetRequest{Key: key}); err != nil {
+			t.Fatalf("Get %s: %v", key, err)
+		}
+	}
+	if _, err := s.kvStore.Delete(ctx, &pb.DeleteRequest{Key: "a"}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/metrics: status = %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "kvstore_") {
+		t.Error("/metrics does not export any kvstore_ family")
+	}
+
+	if err := metrics.SelfTest(prometheus.DefaultGatherer); err != nil {
+		t.Errorf("metrics self test:\n%v", err)
+	}
+}
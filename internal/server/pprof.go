@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// Serve pprof on DEBUG_HTTP_PORT when enabled, overriding
+// DEBUG_PROFILING_ENABLED and the debug build tag
+func WithProfiling(enabled bool) ServerOption {
+	return func(s *Server) {
+		s.profiling = &enabled
+	}
+}
+
+// Report whether the pprof endpoints are served
+func (s *Server) profilingEnabled() bool {
+	if s.profiling != nil {
+		return *s.profiling
+	}
+	return profilingBuiltIn || s.cfg.DebugProfilingEnabled
+}
+
+// pprof endpoints, kept off the main HTTP port so they are never exposed
+// alongside health checks and the gateway
+func debugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
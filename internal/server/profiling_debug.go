@@ -0,0 +1,6 @@
+//go:build debug
+
+package server
+
+// Builds tagged debug serve pprof unless WithProfiling(false) is given
+const profilingBuiltIn = true
@@ -0,0 +1,6 @@
+//go:build !debug
+
+package server
+
+// pprof is served only when enabled through the config or WithProfiling
+const profilingBuiltIn = false
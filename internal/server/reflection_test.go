@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+)
+
+// List services through reflection on a server registered by s
+func listServices(t *testing.T, s *Server) error {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	s.Register(srv)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := stream.Send(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	}); err != nil {
+		return err
+	}
+	_, err = stream.Recv()
+	return err
+}
+
+func TestReflectionFollowsConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.ReflectionEnabled = false
+	if err := listServices(t, newTestServer(t, cfg)); status.Code(err) != codes.Unimplemented {
+		t.Errorf("reflection disabled: %v, want Unimplemented", err)
+	}
+
+	cfg = config.Default()
+	cfg.ReflectionEnabled = true
+	if err := listServices(t, newTestServer(t, cfg)); err != nil {
+		t.Errorf("reflection enabled: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/amillerrr/distributed-kv-store/discovery"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Registrations expire unless refreshed, so a crashed instance drops out
+	registrationTTL     = 30 * time.Second
+	registrationRefresh = 10 * time.Second
+)
+
+// Bind gRPC and HTTP to ports picked by the OS instead of the configured ones.
+// The gRPC address is logged, returned by Addr and registered if enabled
+func WithDynamicPort() ServerOption {
+	return func(s *Server) {
+		s.dynamicPort = true
+	}
+}
+
+// Register this instance's gRPC address in its own store under
+// <serviceName>/<node ID> while serving, for discovery.Resolver. The address
+// uses advertiseHost, the machine's hostname if empty
+func WithServiceRegistration(serviceName, advertiseHost string) ServerOption {
+	return func(s *Server) {
+		s.serviceName = serviceName
+		s.advertiseHost = advertiseHost
+	}
+}
+
+// Address clients should use to reach the gRPC listener
+func (s *Server) advertiseAddr(listenAddr net.Addr) string {
+	host := s.advertiseHost
+	if host == "" {
+		var err error
+		if host, err = os.Hostname(); err != nil {
+			slog.Warn("failed to read hostname, advertising localhost", "error", err)
+			host = "localhost"
+		}
+	}
+	_, port, _ := net.SplitHostPort(listenAddr.String())
+	return net.JoinHostPort(host, port)
+}
+
+// Keep this instance registered until stop is closed, then remove it and
+// close done
+func (s *Server) runRegistration(addr string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	key := discovery.InstanceKey(s.serviceName, s.kvStore.NodeID())
+	register := func() {
+		_, err := s.kvStore.Set(context.Background(), &pb.SetRequest{
+			Key:   key,
+			Value: addr,
+			TtlMs: proto.Int64(registrationTTL.Milliseconds()),
+		})
+		if err != nil {
+			slog.Warn("failed to refresh service registration", "key", key, "error", err)
+		}
+	}
+
+	register()
+	slog.Info("service registered", "key", key, "address", addr)
+
+	ticker := time.NewTicker(registrationRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			register()
+		case <-stop:
+			if _, err := s.kvStore.Delete(context.Background(), &pb.DeleteRequest{Key: key}); err != nil {
+				slog.Warn("failed to remove service registration", "key", key, "error", err)
+				return
+			}
+			slog.Info("service deregistered", "key", key)
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/amillerrr/distributed-kv-store/discovery"
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestServiceRegistration(t *testing.T) {
+	cfg := config.Default()
+	cfg.ShutdownDrainSignalLeadTime = 0
+	s := New(WithConfig(cfg), WithDynamicPort(), WithServiceRegistration("kv", "127.0.0.1"))
+
+	ctx, stop := context.WithCancel(context.Background())
+	var startErr error
+	done := make(chan struct{})
+	go func() {
+		startErr = s.Start(ctx)
+		close(done)
+	}()
+	defer func() {
+		stop()
+		<-done
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Addr() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("server never bound its listener")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	want := s.advertiseAddr(s.Addr())
+
+	registry, err := grpc.NewClient(want, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial registry: %v", err)
+	}
+	defer registry.Close()
+	kv := pb.NewKeyValueStoreClient(registry)
+
+	// Watch the registration so its removal on shutdown is seen
+	watchCtx, cancelWatch := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelWatch()
+	stream, err := kv.Subscribe(watchCtx, &pb.SubscribeRequest{KeyPattern: discovery.Prefix("kv"), ReplayExisting: true})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv: %v", err)
+	}
+	key := discovery.InstanceKey("kv", s.kvStore.NodeID())
+	if event.ChangeType != pb.ChangeEvent_SET || event.Key != key || event.Value != want {
+		t.Fatalf("registration = %v %s=%s, want SET %s=%s", event.ChangeType, event.Key, event.Value, key, want)
+	}
+
+	// A channel resolving through the registry reaches this instance
+	conn, err := grpc.NewClient("kv:///kv",
+		grpc.WithResolvers(discovery.Resolver(kv, "")),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial through resolver: %v", err)
+	}
+	defer conn.Close()
+	callCtx, cancelCall := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelCall()
+	resp, err := pb.NewKeyValueStoreClient(conn).Get(callCtx, &pb.GetRequest{Key: key})
+	if err != nil || resp.Value != want {
+		t.Fatalf("Get through resolver = %v, %v, want %s", resp, err, want)
+	}
+
+	stop()
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("stream ended before the registration was removed: %v", err)
+		}
+		if event.ChangeType == pb.ChangeEvent_DELETE && event.Key == key {
+			break
+		}
+	}
+
+	// Open streams would hold up the graceful stop
+	cancelWatch()
+	conn.Close()
+	<-done
+	if startErr != nil {
+		t.Errorf("Start: %v", startErr)
+	}
+}
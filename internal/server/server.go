@@ -0,0 +1,468 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	channelzsvc "google.golang.org/grpc/channelz/service"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/amillerrr/distributed-kv-store/client"
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+	"github.com/amillerrr/distributed-kv-store/internal/circuitbreaker"
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/recovery"
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	kvtls "github.com/amillerrr/distributed-kv-store/internal/tls"
+	"github.com/amillerrr/distributed-kv-store/internal/validation"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+	"github.com/amillerrr/distributed-kv-store/signing"
+)
+
+// Longest Start waits for in-flight requests once its context is done
+const shutdownTimeout = 10 * time.Second
+
+// KV store service together with the gRPC and HTTP servers that expose it
+type Server struct {
+	cfg     *config.ServerConfig
+	store   storage.Backend
+	kvStore *service.KVStoreService
+
+	// Bind to OS-assigned ports instead of the configured ones
+	dynamicPort bool
+	// Register under this name for discovery, disabled if empty
+	serviceName   string
+	advertiseHost string
+
+	// Authenticates requests and streams, built from the config when not given
+	authProvider auth.Provider
+
+	// Browser origins allowed to call the HTTP endpoints, CORS is off if empty
+	corsOrigins []string
+
+	// gRPC listener address, set once Start has bound it
+	addrMu sync.Mutex
+	addr   net.Addr
+
+	// Set once gRPC is serving, readiness fails until then
+	serving atomic.Bool
+
+	// Serve pprof, from the config and build tags when nil
+	profiling *bool
+
+	// Connection to UPSTREAM_ADDR, and the error creating it, which Start returns
+	upstreamConn *grpc.ClientConn
+	upstreamErr  error
+}
+
+// Configure optional Server behavior
+type ServerOption func(*Server)
+
+// Use cfg for ports, TLS, interceptors and the service, config.Default() otherwise
+func WithConfig(cfg *config.ServerConfig) ServerOption {
+	return func(s *Server) {
+		s.cfg = cfg
+	}
+}
+
+// Keep data in backend instead of memory, the server closes it on Close
+func WithStorage(backend storage.Backend) ServerOption {
+	return func(s *Server) {
+		s.store = backend
+	}
+}
+
+// Allow browsers on origins to call the HTTP endpoints. ["*"] allows every
+// origin and is meant for development only
+func WithCORSOrigins(origins []string) ServerOption {
+	return func(s *Server) {
+		s.corsOrigins = origins
+	}
+}
+
+// Authenticate requests and streams with provider instead of the one AUTH_PROVIDER
+// selects. The caller owns provider and closes it if needed
+func WithAuthProvider(provider auth.Provider) ServerOption {
+	return func(s *Server) {
+		s.authProvider = provider
+	}
+}
+
+// Create the KV store service. Nothing listens until Start is called
+func New(opts ...ServerOption) *Server {
+	s := &Server{cfg: config.Default()}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	serviceOpts := []service.Option{service.WithConfig(s.cfg)}
+	if s.store != nil {
+		serviceOpts = append(serviceOpts, service.WithStorage(s.store))
+	}
+	// The connection is lazy, so nothing is dialed until the first miss
+	if s.cfg.UpstreamAddr != "" {
+		s.upstreamConn, s.upstreamErr = newPeerConn(s.cfg, s.cfg.UpstreamAddr)
+		if s.upstreamErr == nil {
+			serviceOpts = append(serviceOpts, service.WithUpstreamStore(pb.NewKeyValueStoreClient(s.upstreamConn)))
+			slog.Info("falling back to upstream store on misses", "upstream", s.cfg.UpstreamAddr, "fill_ttl", s.cfg.UpstreamFillTTL)
+		}
+	}
+	s.kvStore = service.NewKVStoreService(serviceOpts...)
+	return s
+}
+
+// Register the KV store, admin, channelz and, if enabled, reflection services on
+// grpcServer. Start does this itself, call it directly to serve on a server
+// created elsewhere such as in tests
+func (s *Server) Register(grpcServer grpc.ServiceRegistrar) {
+	pb.RegisterKeyValueStoreServer(grpcServer, s.kvStore)
+	pb.RegisterAdminServiceServer(grpcServer, s.kvStore)
+
+	// Reflection exposes the full schema, so it is opt-in for production
+	if s.cfg.ReflectionEnabled {
+		if rs, ok := grpcServer.(reflection.GRPCServer); ok {
+			reflection.Register(rs)
+			slog.Info("gRPC reflection enabled")
+		}
+	}
+
+	// Expose channelz over gRPC for tools like grpcdebug
+	channelzsvc.RegisterChannelzServiceToServer(grpcServer)
+}
+
+// Serve gRPC and HTTP on the configured ports until ctx is done or either
+// server fails, then shut down gracefully and close the service. Returns the
+// error that stopped serving, nil after a clean shutdown
+func (s *Server) Start(ctx context.Context) error {
+	if s.upstreamErr != nil {
+		s.Close()
+		return fmt.Errorf("create upstream store client for %s: %w", s.cfg.UpstreamAddr, s.upstreamErr)
+	}
+	if err := s.kvStore.StartupErr(); err != nil {
+		s.Close()
+		return err
+	}
+	if s.authProvider == nil {
+		provider, err := newAuthProvider(s.cfg)
+		if err != nil {
+			s.Close()
+			return err
+		}
+		if closer, ok := provider.(io.Closer); ok {
+			defer closer.Close()
+		}
+		s.authProvider = provider
+	}
+
+	grpcPort, httpPort, debugPort := s.cfg.GRPCPort, s.cfg.HTTPPort, s.cfg.DebugHTTPPort
+	if s.dynamicPort {
+		grpcPort, httpPort, debugPort = "0", "0", "0"
+	}
+
+	// Create TCP listeners for gRPC and HTTP
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", grpcPort))
+	if err != nil {
+		s.Close()
+		return fmt.Errorf("listen on port %s: %w", grpcPort, err)
+	}
+	httpLis, err := net.Listen("tcp", fmt.Sprintf(":%s", httpPort))
+	if err != nil {
+		lis.Close()
+		s.Close()
+		return fmt.Errorf("listen on port %s: %w", httpPort, err)
+	}
+	var debugLis net.Listener
+	if s.profilingEnabled() {
+		// Loopback by default, the endpoints have no authentication
+		debugLis, err = net.Listen("tcp", net.JoinHostPort(s.cfg.DebugHTTPHost, debugPort))
+		if err != nil {
+			lis.Close()
+			httpLis.Close()
+			s.Close()
+			return fmt.Errorf("listen on debug port %s: %w", debugPort, err)
+		}
+	}
+	s.addrMu.Lock()
+	s.addr = lis.Addr()
+	s.addrMu.Unlock()
+
+	grpcServer, certWatcher, err := s.newGRPCServer()
+	if err != nil {
+		lis.Close()
+		httpLis.Close()
+		if debugLis != nil {
+			debugLis.Close()
+		}
+		s.Close()
+		return err
+	}
+	s.Register(grpcServer)
+
+	// Accept HTTP/2 without TLS so gateway clients with prior knowledge can
+	// receive pushes. Watch streams never end on their own, so they are
+	// cancelled through the base context when shutdown starts
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	httpCtx, cancelHTTP := context.WithCancel(context.Background())
+	defer cancelHTTP()
+	// REST calls go through the gRPC port so they pass its interceptors. The
+	// connection outlives the HTTP drain and closes once Start returns
+	gatewayCtx, closeGateway := context.WithCancel(context.Background())
+	defer closeGateway()
+	httpServer := &http.Server{
+		Handler:     s.httpHandler(gatewayCtx, loopbackAddr(lis.Addr())),
+		Protocols:   &protocols,
+		BaseContext: func(net.Listener) context.Context { return httpCtx },
+	}
+	httpServer.RegisterOnShutdown(cancelHTTP)
+
+	// Buffered for every server so none blocks once shutdown starts
+	serverErrors := make(chan error, 3)
+
+	// Profiling is a debugging aid, so it is not drained on shutdown
+	if debugLis != nil {
+		debugServer := &http.Server{Handler: debugHandler()}
+		defer debugServer.Close()
+		go func() {
+			slog.Warn("pprof profiling endpoints enabled", "address", debugLis.Addr().String())
+			if err := debugServer.Serve(debugLis); err != nil && err != http.ErrServerClosed {
+				serverErrors <- err
+			}
+		}()
+	}
+
+	go func() {
+		slog.Info("HTTP health server listening", "address", httpLis.Addr().String())
+		if err := httpServer.Serve(httpLis); err != nil && err != http.ErrServerClosed {
+			serverErrors <- err
+		}
+	}()
+
+	// Warm the store before any client can reach it
+	if s.cfg.SeedFile != "" {
+		if _, err := s.kvStore.LoadSeedFile(ctx, s.cfg.SeedFile); err != nil {
+			httpServer.Close()
+			lis.Close()
+			if certWatcher != nil {
+				certWatcher.Close()
+			}
+			s.Close()
+			// Stopped while seeding, not a failure
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		// An external seeder adds to the file and marks the store ready itself
+		if !s.cfg.SeedExternal {
+			s.kvStore.MarkReady()
+		}
+	}
+
+	// Stream changes to and from a peer instance until shutdown
+	var syncConn *grpc.ClientConn
+	if s.cfg.SyncPeerAddr != "" {
+		syncConn, err = newPeerConn(s.cfg, s.cfg.SyncPeerAddr)
+		if err != nil {
+			lis.Close()
+			httpLis.Close()
+			s.Close()
+			return fmt.Errorf("create sync peer client for %s: %w", s.cfg.SyncPeerAddr, err)
+		}
+		slog.Info("syncing with peer", "peer", s.cfg.SyncPeerAddr, "node_id", s.kvStore.NodeID())
+		go s.kvStore.SyncWith(context.Background(), pb.NewKeyValueStoreClient(syncConn))
+	}
+
+	go func() {
+		slog.Info("gRPC server listening", "address", lis.Addr().String())
+		serverErrors <- grpcServer.Serve(lis)
+	}()
+	s.serving.Store(true)
+
+	// Advertise this instance for discovery while serving
+	stopRegistration := make(chan struct{})
+	registrationDone := make(chan struct{})
+	if s.serviceName != "" {
+		go s.runRegistration(s.advertiseAddr(lis.Addr()), stopRegistration, registrationDone)
+	} else {
+		close(registrationDone)
+	}
+
+	// Block until the caller stops us or a server fails
+	var serveErr error
+	select {
+	case serveErr = <-serverErrors:
+		slog.Error("server error", "error", serveErr)
+	case <-ctx.Done():
+	}
+
+	slog.Info("initiating graceful shutdown")
+
+	// Deregister first so clients stop picking this instance
+	close(stopRegistration)
+	<-registrationDone
+
+	// Warn subscribers and give them time to move to another instance before
+	// their streams are cut. Readiness fails meanwhile so no new traffic arrives
+	s.serving.Store(false)
+	if lead := s.cfg.ShutdownDrainSignalLeadTime; lead > 0 {
+		if notified := s.kvStore.AnnounceShutdown(); notified > 0 {
+			slog.Info("waiting for subscribers to reconnect elsewhere", "lead_time", lead)
+			time.Sleep(lead)
+		}
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server shutdown error", "error", err)
+	} else {
+		slog.Info("HTTP server stopped gracefully")
+	}
+
+	// Long-lived streams such as Subscribe and Sync never finish on their own,
+	// so force them closed once the shutdown deadline passes
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		slog.Info("gRPC server stopped gracefully")
+	case <-shutdownCtx.Done():
+		grpcServer.Stop()
+		slog.Warn("gRPC server stopped forcefully, streams still open at deadline")
+	}
+
+	if err := s.Close(); err != nil {
+		slog.Error("failed to close KV store service", "error", err)
+	}
+	if syncConn != nil {
+		syncConn.Close()
+	}
+	if certWatcher != nil {
+		certWatcher.Close()
+	}
+	slog.Info("shutdown complete")
+	return serveErr
+}
+
+// Address the gRPC server listens on, nil until Start has bound it
+func (s *Server) Addr() net.Addr {
+	s.addrMu.Lock()
+	defer s.addrMu.Unlock()
+	return s.addr
+}
+
+// Close the KV store service and its storage. Start calls this on return,
+// so it is only needed when the server was used through Register
+func (s *Server) Close() error {
+	if s.upstreamConn != nil {
+		s.upstreamConn.Close()
+	}
+	return s.kvStore.Close()
+}
+
+// Create the gRPC server with the configured interceptors and credentials.
+// The cert watcher is nil unless TLS is enabled
+func (s *Server) newGRPCServer() (*grpc.Server, *kvtls.CertWatcher, error) {
+	cfg := s.cfg
+
+	// Recovery wraps everything so a panic anywhere becomes codes.Internal,
+	// sampling runs next so later interceptors see the decision
+	interceptors := []grpc.UnaryServerInterceptor{recovery.NewInterceptor()}
+	streamInterceptors := []grpc.StreamServerInterceptor{recovery.StreamServerInterceptor()}
+	// Reject unsigned and replayed requests before doing any other work
+	if len(cfg.RequestSigningKeys) > 0 {
+		unary, stream := newSigningInterceptors(cfg)
+		interceptors = append(interceptors, unary)
+		streamInterceptors = append(streamInterceptors, stream)
+	}
+	// Authenticate before anything is logged or counted against the caller.
+	// Streams such as Subscribe and SetStream read and write keys too
+	if s.authProvider != nil {
+		interceptors = append(interceptors, auth.NewAPIKeyInterceptor(s.authProvider))
+		streamInterceptors = append(streamInterceptors, auth.NewStreamAPIKeyInterceptor(s.authProvider))
+	}
+	interceptors = append(interceptors, s.kvStore.SamplingInterceptor(), s.kvStore.LatencyInterceptor(), loggingInterceptor)
+	if cfg.RateLimitRPS > 0 {
+		unary, stream := newRateLimitInterceptors(cfg)
+		interceptors = append(interceptors, unary)
+		streamInterceptors = append(streamInterceptors, stream)
+	}
+	// Fail fast while storage is unhealthy, after logging so rejections show up
+	if breaker, ok := storage.As[*circuitbreaker.Backend](s.store); ok {
+		interceptors = append(interceptors, breaker.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, breaker.StreamServerInterceptor())
+	}
+	// Validation runs last so rejected requests still count against rate limits
+	validators := validation.Default(cfg.MaxValueSizeBytes())
+	interceptors = append(interceptors, validation.NewInterceptor(validators))
+	streamInterceptors = append(streamInterceptors, validation.StreamServerInterceptor(validators))
+	serverOpts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	}
+
+	// Serve certificates through the watcher so renewals apply without a restart
+	var certWatcher *kvtls.CertWatcher
+	if cfg.TLSEnabled() {
+		var err error
+		certWatcher, err = kvtls.NewCertWatcher(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load TLS credentials: %w", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(certWatcher.TLSConfig())))
+	}
+
+	return grpc.NewServer(serverOpts...), certWatcher, nil
+}
+
+// Connect to another instance such as the sync peer or upstream store, over
+// TLS when this server serves TLS, sending PEER_TOKEN_FILE's token if set and
+// signing requests when this server requires signatures
+func newPeerConn(cfg *config.ServerConfig, addr string) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if cfg.TLSEnabled() {
+		creds = credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if cfg.PeerTokenFile != "" {
+		token, err := os.ReadFile(cfg.PeerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("read peer token: %w", err)
+		}
+		bearer := strings.TrimSpace(string(token))
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(client.BearerTokenInterceptor(bearer)),
+			grpc.WithChainStreamInterceptor(client.BearerTokenStreamInterceptor(bearer)))
+	}
+	// Peers are expected to share the signing keys, the first by ID is used
+	if len(cfg.RequestSigningKeys) > 0 {
+		keyID := slices.Min(slices.Collect(maps.Keys(cfg.RequestSigningKeys)))
+		key := []byte(cfg.RequestSigningKeys[keyID])
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(signing.NewRequestSigner(key, signing.WithKeyID(keyID))),
+			grpc.WithChainStreamInterceptor(signing.NewStreamRequestSigner(key, signing.WithKeyID(keyID))))
+	}
+	return grpc.NewClient(addr, opts...)
+}
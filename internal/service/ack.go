@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Response header carrying the ID an ack_mode subscriber acknowledges with
+	SubscriptionIDHeader = "x-kvstore-subscription-id"
+
+	// Longest Set waits for acknowledgments when the request sets no timeout
+	defaultAckTimeout = 5 * time.Second
+)
+
+// Acknowledgment progress of an ack_mode subscriber
+type ackState struct {
+	// Subject that subscribed, empty without auth
+	owner string
+
+	mu sync.Mutex
+	// Highest sequence acknowledged, acks are cumulative
+	acked int64
+	// Closed and replaced on every ack to wake waiting Set calls
+	wake chan struct{}
+	// Closed once the subscriber is gone and can no longer acknowledge
+	gone chan struct{}
+}
+
+func newAckState() *ackState {
+	return &ackState{wake: make(chan struct{}), gone: make(chan struct{})}
+}
+
+// Record an acknowledgment of every event up to seq
+func (a *ackState) ack(seq int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if seq <= a.acked {
+		return
+	}
+	a.acked = seq
+	close(a.wake)
+	a.wake = make(chan struct{})
+}
+
+// Whether seq is acknowledged, and a channel closed at the next ack if not
+func (a *ackState) covers(seq int64) (bool, <-chan struct{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.acked >= seq, a.wake
+}
+
+// Assign a random ID to an ack_mode subscriber and make it known to
+// Acknowledge. The ID cannot be guessed, and with auth on only the caller
+// that subscribed may acknowledge with it
+func (s *KVStoreService) registerAcker(sub *subscriber, stream pb.KeyValueStore_SubscribeServer) error {
+	sub.ack = newAckState()
+	if id, ok := auth.FromContext(stream.Context()); ok {
+		sub.ack.owner = id.SubjectID
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	sub.id = hex.EncodeToString(b)
+	s.mu.Lock()
+	s.ackers[sub.id] = sub
+	s.mu.Unlock()
+
+	return stream.SetHeader(metadata.Pairs(SubscriptionIDHeader, sub.id))
+}
+
+// Forget an ack_mode subscriber, failing any Set still waiting on it
+func (s *KVStoreService) unregisterAcker(sub *subscriber) {
+	s.mu.Lock()
+	delete(s.ackers, sub.id)
+	s.mu.Unlock()
+	close(sub.ack.gone)
+}
+
+// Record that a subscriber received every event up to a sequence
+func (s *KVStoreService) Acknowledge(ctx context.Context, req *pb.AcknowledgeRequest) (*pb.AcknowledgeResponse, error) {
+	if req.SubscriptionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "subscription_id cannot be empty")
+	}
+	if req.Sequence <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "sequence must be positive")
+	}
+
+	s.mu.RLock()
+	sub, ok := s.ackers[req.SubscriptionId]
+	s.mu.RUnlock()
+	// Answered alike so other callers cannot tell the ID exists
+	if ok && sub.ack.owner != "" {
+		id, _ := auth.FromContext(ctx)
+		ok = id.SubjectID == sub.ack.owner
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no ack_mode subscription %q", req.SubscriptionId)
+	}
+
+	sub.ack.ack(req.Sequence)
+	slog.Debug("event acknowledged", "subscription_id", req.SubscriptionId, "sequence", req.Sequence)
+	return &pb.AcknowledgeResponse{}, nil
+}
+
+// Wait until every subscriber has acknowledged seq, up to timeout
+func (s *KVStoreService) awaitAcks(ctx context.Context, seq int64, subs []*subscriber, timeout time.Duration) error {
+	if len(subs) == 0 {
+		return nil
+	}
+	if timeout <= 0 {
+		timeout = defaultAckTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for i, sub := range subs {
+		for {
+			done, wake := sub.ack.covers(seq)
+			if done {
+				break
+			}
+			select {
+			case <-wake:
+			case <-sub.ack.gone:
+				return status.Errorf(codes.Unavailable, "subscription %s closed before acknowledging sequence %d", sub.id, seq)
+			case <-timer.C:
+				pending := 0
+				for _, rest := range subs[i:] {
+					if acked, _ := rest.ack.covers(seq); !acked {
+						pending++
+					}
+				}
+				slog.Warn("acknowledgment timed out", "sequence", seq, "pending", pending, "timeout", timeout)
+				return status.Errorf(codes.DeadlineExceeded, "%d of %d subscribers did not acknowledge sequence %d within %s", pending, len(subs), seq, timeout)
+			case <-ctx.Done():
+				return status.FromContextError(ctx.Err()).Err()
+			case <-s.done:
+				return status.Error(codes.Unavailable, "service is closing")
+			}
+		}
+	}
+	return nil
+}
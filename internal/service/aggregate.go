@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Most unparseable keys listed in an Aggregate error
+const maxAggregateFailedKeys = 100
+
+// Running totals of the values an Aggregate has parsed. Integer sums are
+// kept exactly so overflow can be reported
+type aggregator struct {
+	count    int64
+	intSum   int64
+	floatSum float64
+	min, max float64
+	overflow bool
+}
+
+func (a *aggregator) addInt(n int64) {
+	sum := a.intSum + n
+	if (n > 0 && sum < a.intSum) || (n < 0 && sum > a.intSum) {
+		a.overflow = true
+	}
+	a.intSum = sum
+	a.addFloat(float64(n))
+}
+
+func (a *aggregator) addFloat(f float64) {
+	if a.count == 0 {
+		a.min, a.max = f, f
+	}
+	a.min, a.max = min(a.min, f), max(a.max, f)
+	a.floatSum += f
+	a.count++
+}
+
+// Compute an aggregate over the numeric values of the keys matching a pattern
+func (s *KVStoreService) Aggregate(ctx context.Context, req *pb.AggregateRequest) (*pb.AggregateResponse, error) {
+	if req.Operation == pb.AggregateOp_AGGREGATE_UNSPECIFIED {
+		return nil, status.Error(codes.InvalidArgument, "operation must be set")
+	}
+	if _, ok := pb.AggregateOp_name[int32(req.Operation)]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown operation %d", req.Operation)
+	}
+	if req.ValueType != pb.ValueType_VALUE_TYPE_INT64 && req.ValueType != pb.ValueType_VALUE_TYPE_FLOAT64 {
+		return nil, status.Error(codes.InvalidArgument, "value_type must be VALUE_TYPE_INT64 or VALUE_TYPE_FLOAT64")
+	}
+	match, err := s.keyMatcher(&pb.GetManyRequest{Pattern: req.Pattern, MatchMode: req.MatchMode})
+	if err != nil {
+		return nil, err
+	}
+
+	var agg aggregator
+	var failed []string
+	s.ForEach(func(key, value string) bool {
+		if !match(key) {
+			return true
+		}
+		s.recordGet(key)
+		if req.ValueType == pb.ValueType_VALUE_TYPE_INT64 {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				failed = append(failed, key)
+				return true
+			}
+			agg.addInt(n)
+			return true
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			failed = append(failed, key)
+			return true
+		}
+		agg.addFloat(f)
+		return true
+	})
+
+	if len(failed) > 0 {
+		slog.Warn("aggregate over unparseable values", "pattern", req.Pattern, "value_type", req.ValueType, "failed_count", len(failed))
+		return nil, aggregateTypeError(failed, req.ValueType)
+	}
+	if agg.overflow && (req.Operation == pb.AggregateOp_AGGREGATE_SUM || req.Operation == pb.AggregateOp_AGGREGATE_AVG) {
+		return nil, status.Error(codes.OutOfRange, "sum of the matched values overflows int64")
+	}
+
+	resp := &pb.AggregateResponse{MatchedCount: agg.count}
+	if agg.count > 0 {
+		switch req.Operation {
+		case pb.AggregateOp_AGGREGATE_SUM:
+			resp.Result = agg.floatSum
+			if req.ValueType == pb.ValueType_VALUE_TYPE_INT64 {
+				resp.Result = float64(agg.intSum)
+			}
+		case pb.AggregateOp_AGGREGATE_COUNT:
+			resp.Result = float64(agg.count)
+		case pb.AggregateOp_AGGREGATE_MIN:
+			resp.Result = agg.min
+		case pb.AggregateOp_AGGREGATE_MAX:
+			resp.Result = agg.max
+		case pb.AggregateOp_AGGREGATE_AVG:
+			resp.Result = agg.floatSum / float64(agg.count)
+			if req.ValueType == pb.ValueType_VALUE_TYPE_INT64 {
+				resp.Result = float64(agg.intSum) / float64(agg.count)
+			}
+		}
+	}
+
+	slog.Info("aggregate request", "pattern", req.Pattern, "operation", req.Operation, "value_type", req.ValueType, "matched_count", agg.count)
+	return resp, nil
+}
+
+// FAILED_PRECONDITION naming the keys whose values are not of valueType, the
+// first maxAggregateFailedKeys of them in key order
+func aggregateTypeError(keys []string, valueType pb.ValueType) error {
+	slices.Sort(keys)
+	st := status.Newf(codes.FailedPrecondition, "%d matched values are not valid %s, first %q", len(keys), valueType, keys[0])
+	var violations []*errdetails.PreconditionFailure_Violation
+	for _, key := range keys[:min(len(keys), maxAggregateFailedKeys)] {
+		violations = append(violations, &errdetails.PreconditionFailure_Violation{
+			Type:        valueType.String(),
+			Subject:     key,
+			Description: "value is not a valid " + valueType.String(),
+		})
+	}
+	if detailed, err := st.WithDetails(&errdetails.PreconditionFailure{Violations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
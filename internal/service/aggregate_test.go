@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func setValues(t *testing.T, s *KVStoreService, pairs map[string]string) {
+	t.Helper()
+	for key, value := range pairs {
+		if _, err := s.Set(context.Background(), &pb.SetRequest{Key: key, Value: value}); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+}
+
+func TestAggregateOperations(t *testing.T) {
+	s := newTestService(t)
+	setValues(t, s, map[string]string{
+		"count:a": "4", "count:b": "-1", "count:c": "12",
+		"price:a": "1.5", "price:b": "2.5",
+		"other:a": "not a number",
+	})
+
+	tests := []struct {
+		name      string
+		pattern   string
+		op        pb.AggregateOp
+		valueType pb.ValueType
+		want      float64
+		wantCount int64
+	}{
+		{"sum", "count:", pb.AggregateOp_AGGREGATE_SUM, pb.ValueType_VALUE_TYPE_INT64, 15, 3},
+		{"count", "count:", pb.AggregateOp_AGGREGATE_COUNT, pb.ValueType_VALUE_TYPE_INT64, 3, 3},
+		{"min", "count:", pb.AggregateOp_AGGREGATE_MIN, pb.ValueType_VALUE_TYPE_INT64, -1, 3},
+		{"max", "count:", pb.AggregateOp_AGGREGATE_MAX, pb.ValueType_VALUE_TYPE_INT64, 12, 3},
+		{"avg", "count:", pb.AggregateOp_AGGREGATE_AVG, pb.ValueType_VALUE_TYPE_INT64, 5, 3},
+		{"float sum", "price:", pb.AggregateOp_AGGREGATE_SUM, pb.ValueType_VALUE_TYPE_FLOAT64, 4, 2},
+		{"integers as floats", "count:", pb.AggregateOp_AGGREGATE_AVG, pb.ValueType_VALUE_TYPE_FLOAT64, 5, 3},
+		{"no matches", "none:", pb.AggregateOp_AGGREGATE_SUM, pb.ValueType_VALUE_TYPE_INT64, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.Aggregate(context.Background(), &pb.AggregateRequest{Pattern: tt.pattern, Operation: tt.op, ValueType: tt.valueType})
+			if err != nil {
+				t.Fatalf("Aggregate: %v", err)
+			}
+			if resp.Result != tt.want || resp.MatchedCount != tt.wantCount {
+				t.Errorf("result, matched = %v, %d, want %v, %d", resp.Result, resp.MatchedCount, tt.want, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestAggregateListsUnparseableKeys(t *testing.T) {
+	s := newTestService(t)
+	setValues(t, s, map[string]string{
+		"count:a": "4", "count:b": "four", "count:c": "1.5", "count:d": "NaN",
+	})
+
+	tests := []struct {
+		name      string
+		valueType pb.ValueType
+		want      []string
+	}{
+		{"int64", pb.ValueType_VALUE_TYPE_INT64, []string{"count:b", "count:c", "count:d"}},
+		{"float64", pb.ValueType_VALUE_TYPE_FLOAT64, []string{"count:b", "count:d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Aggregate(context.Background(), &pb.AggregateRequest{Pattern: "count:", Operation: pb.AggregateOp_AGGREGATE_SUM, ValueType: tt.valueType})
+			st := status.Convert(err)
+			if st.Code() != codes.FailedPrecondition {
+				t.Fatalf("err = %v, want FailedPrecondition", err)
+			}
+			var failed []string
+			for _, detail := range st.Details() {
+				if pf, ok := detail.(*errdetails.PreconditionFailure); ok {
+					for _, v := range pf.Violations {
+						failed = append(failed, v.Subject)
+					}
+				}
+			}
+			if !slices.Equal(failed, tt.want) {
+				t.Errorf("failed keys = %q, want %q", failed, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregateCapsFailedKeys(t *testing.T) {
+	s := newTestService(t)
+	for i := range maxAggregateFailedKeys + 10 {
+		setValues(t, s, map[string]string{fmt.Sprintf("bad:%03d", i): "x"})
+	}
+
+	_, err := s.Aggregate(context.Background(), &pb.AggregateRequest{Pattern: "bad:", Operation: pb.AggregateOp_AGGREGATE_COUNT, ValueType: pb.ValueType_VALUE_TYPE_INT64})
+	listed := 0
+	for _, detail := range status.Convert(err).Details() {
+		if pf, ok := detail.(*errdetails.PreconditionFailure); ok {
+			listed = len(pf.Violations)
+		}
+	}
+	if listed != maxAggregateFailedKeys {
+		t.Errorf("%d keys listed, want %d", listed, maxAggregateFailedKeys)
+	}
+}
+
+func TestAggregateReportsOverflow(t *testing.T) {
+	s := newTestService(t)
+	setValues(t, s, map[string]string{"big:a": fmt.Sprint(int64(math.MaxInt64)), "big:b": "1"})
+
+	_, err := s.Aggregate(context.Background(), &pb.AggregateRequest{Pattern: "big:", Operation: pb.AggregateOp_AGGREGATE_SUM, ValueType: pb.ValueType_VALUE_TYPE_INT64})
+	if status.Code(err) != codes.OutOfRange {
+		t.Errorf("err = %v, want OutOfRange", err)
+	}
+	// The maximum does not overflow
+	resp, err := s.Aggregate(context.Background(), &pb.AggregateRequest{Pattern: "big:", Operation: pb.AggregateOp_AGGREGATE_MAX, ValueType: pb.ValueType_VALUE_TYPE_INT64})
+	if err != nil || resp.Result != math.MaxInt64 {
+		t.Errorf("MAX = %v, %v, want MaxInt64", resp, err)
+	}
+}
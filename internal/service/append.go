@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Append separator and value to a key's current value, storing value alone if
+// the key does not exist. Any TTL on the key is kept
+func (s *KVStoreService) Append(ctx context.Context, req *pb.AppendRequest) (*pb.AppendResponse, error) {
+	if req.Key == "" {
+		slog.Warn("append request with empty key")
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	req.Key = key
+	if err := s.shedWrite("Append", req.Key); err != nil {
+		return nil, err
+	}
+
+	slog.Info("append request", "key", req.Key)
+	s.logSample(ctx, "Append", req.Key, req.Value)
+
+	// Read and write under the key lock so concurrent appends are not lost
+	newValue := req.Value
+	var found bool
+	var version, expiresAt, timestamp int64
+	err = s.withKeyLock(req.Key, func() error {
+		var current string
+		current, found = s.store.Load(req.Key)
+		if found && s.isExpired(req.Key) {
+			s.clearTTL(req.Key)
+			found = false
+		}
+		if found {
+			newValue = current + req.Separator + req.Value
+		}
+		if s.maxValueSize > 0 && len(newValue) > s.maxValueSize {
+			slog.Warn("append would exceed maximum value size", "key", req.Key, "value_length", len(newValue), "max", s.maxValueSize)
+			return status.Errorf(codes.InvalidArgument, "value would exceed maximum size of %d bytes", s.maxValueSize)
+		}
+		s.store.Store(req.Key, newValue)
+		version = s.bumpVersion(req.Key)
+		expiresAt, _ = s.expiresAt(req.Key)
+		timestamp = s.stampWrite(req.Key, false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordSet(req.Key)
+
+	s.notifySubscribers(ctx, &pb.ChangeEvent{
+		ChangeType:  pb.ChangeEvent_APPEND,
+		Key:         req.Key,
+		Value:       newValue,
+		Timestamp:   timestamp,
+		Version:     version,
+		ExpiresAtMs: expiresAt,
+	})
+
+	slog.Info("value appended", "key", req.Key, "created", !found, "value_length", len(newValue))
+	return &pb.AppendResponse{
+		NewLength: int64(len(newValue)),
+		Version:   version,
+	}, nil
+}
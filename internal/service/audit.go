@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/audit"
+)
+
+// Audit forwarders requested through options
+type auditConfig struct {
+	webhookURL    string
+	batchSize     int
+	flushInterval time.Duration
+	headers       [][2]string
+
+	syslog        bool
+	syslogNetwork string
+	syslogAddr    string
+
+	bufferSize int
+}
+
+// POST every mutation to url as JSON arrays of up to batchSize entries, at
+// least every flushInterval. Failed deliveries are retried with backoff on
+// network errors and 5xx responses
+func WithAuditWebhook(url string, batchSize int, flushInterval time.Duration) Option {
+	return func(s *KVStoreService) {
+		s.audit.webhookURL = url
+		s.audit.batchSize = batchSize
+		s.audit.flushInterval = flushInterval
+	}
+}
+
+// Send a header such as Authorization with every audit webhook request
+func WithAuditWebhookAuthHeader(key, value string) Option {
+	return func(s *KVStoreService) {
+		s.audit.headers = append(s.audit.headers, [2]string{key, value})
+	}
+}
+
+// Write every mutation to syslog, e.g. network udp and addr siem.internal:514.
+// Empty network and addr use the local daemon
+func WithAuditSyslog(network, addr string) Option {
+	return func(s *KVStoreService) {
+		s.audit.syslog = true
+		s.audit.syslogNetwork = network
+		s.audit.syslogAddr = addr
+	}
+}
+
+// Entries each audit forwarder holds before dropping new ones, 0 for the default
+func WithAuditBufferSize(n int) Option {
+	return func(s *KVStoreService) {
+		s.audit.bufferSize = n
+	}
+}
+
+// Start the audit forwarders requested by options
+func (s *KVStoreService) startAuditForwarders() {
+	cfg := s.audit
+	if cfg.webhookURL != "" {
+		var opts []audit.WebhookOption
+		for _, h := range cfg.headers {
+			opts = append(opts, audit.WithHeader(h[0], h[1]))
+		}
+		s.eventSinks = append(s.eventSinks, audit.NewWebhook(cfg.webhookURL, cfg.batchSize, cfg.flushInterval, cfg.bufferSize, opts...))
+	}
+	if cfg.syslog {
+		w, err := audit.NewSyslog(cfg.syslogNetwork, cfg.syslogAddr, cfg.bufferSize)
+		if err != nil {
+			// Serving without the requested audit trail would lose it unnoticed
+			s.startErr = fmt.Errorf("start audit syslog: %w", err)
+			return
+		}
+		s.eventSinks = append(s.eventSinks, w)
+	}
+}
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"net"
+	"testing"
+)
+
+func TestUnreachableAuditSyslogFailsStartup(t *testing.T) {
+	// A port nothing listens on
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	lis.Close()
+
+	s := newTestService(t, WithAuditSyslog("tcp", addr))
+	if s.StartupErr() == nil {
+		t.Error("StartupErr() = nil with an unreachable syslog daemon")
+	}
+}
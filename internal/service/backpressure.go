@@ -0,0 +1,110 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+)
+
+// Fill ratios that trigger a slow subscriber warning, in ascending order
+var fillWarnThresholds = []float64{0.50, 0.75, 0.90}
+
+const (
+	// Minimum time between repeated warnings at the same threshold
+	fillWarnCooldown = 30 * time.Second
+
+	// Fill ratio at which the service reports itself degraded
+	degradedFillRatio = 0.90
+
+	// How often subscriber channels are sampled for the fill ratio gauge
+	fillSampleInterval = time.Second
+)
+
+// Report the fraction of a subscriber's channel currently in use
+func fillRatio(sub *subscriber) float64 {
+	events := sub.buffer()
+	if cap(events) == 0 {
+		return 0
+	}
+	return float64(len(events)) / float64(cap(events))
+}
+
+// Warn when a subscriber falls behind, called as its loop takes an event
+func (s *KVStoreService) observeFill(sub *subscriber) {
+	ratio := fillRatio(sub)
+
+	level := 0.0
+	for _, threshold := range fillWarnThresholds {
+		if ratio >= threshold {
+			level = threshold
+		}
+	}
+
+	if level == 0 {
+		sub.warnedFill = 0
+		return
+	}
+
+	// Warn on escalation immediately, otherwise only once the cooldown has passed
+	now := time.Now()
+	if level <= sub.warnedFill && now.Sub(sub.warnedAt) < fillWarnCooldown {
+		return
+	}
+
+	sub.warnedFill = level
+	sub.warnedAt = now
+	slog.Warn("subscriber falling behind",
+		"pattern", sub.pattern,
+		"fill_ratio", ratio,
+		"threshold", level,
+		"buffered", len(sub.buffer()),
+		"capacity", cap(sub.buffer()),
+	)
+}
+
+// Sample subscriber channel fill every fillSampleInterval until the service
+// is closed. Sampled here rather than as events are taken off a channel, since
+// a subscriber whose client stopped reading stops taking them
+func (s *KVStoreService) runFillSampler() {
+	ticker := time.NewTicker(fillSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sampleFill()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Set the fill ratio gauge of each pattern to that of its fullest subscriber
+func (s *KVStoreService) sampleFill() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for pattern, subs := range s.subscribers {
+		fullest := 0.0
+		for _, sub := range subs {
+			fullest = max(fullest, fillRatio(sub))
+		}
+		metrics.SubscriberFillRatio.WithLabelValues(pattern).Set(fullest)
+	}
+}
+
+// Report whether any subscriber channel is close to overflowing
+func (s *KVStoreService) Degraded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, subs := range s.subscribers {
+		for _, sub := range subs {
+			if fillRatio(sub) >= degradedFillRatio {
+				return true
+			}
+		}
+	}
+	return false
+}
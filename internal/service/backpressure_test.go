@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestFillRatioReportsFullestSubscriber(t *testing.T) {
+	s := newTestService(t)
+
+	// Neither reads, as with a client stuck in Send; the second sees no events
+	stuck := &subscriber{pattern: "fill:"}
+	idle := &subscriber{pattern: "fill:", allowedTypes: []pb.ChangeEvent_ChangeType{pb.ChangeEvent_DELETE}}
+	for _, sub := range []*subscriber{stuck, idle} {
+		s.initEvents(sub)
+		s.addSubscriber(sub)
+		defer s.dropSubscriber(sub)
+	}
+
+	for range cap(stuck.events) {
+		if _, err := s.Set(context.Background(), &pb.SetRequest{Key: "fill:k", Value: "v"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	s.sampleFill()
+
+	if got := testutil.ToFloat64(metrics.SubscriberFillRatio.WithLabelValues("fill:")); got != 1 {
+		t.Errorf("fill ratio = %v, want 1 from the stuck subscriber", got)
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Namespace for barrier counters in the store
+const barrierPrefix = "barrier:"
+
+// Arrivals and target count for a barrier
+type barrierState struct {
+	count    int64
+	expected int64
+}
+
+func (b barrierState) reached() bool {
+	return b.count >= b.expected
+}
+
+// Barrier values are stored as "count/expected" so Get shows progress
+func (b barrierState) String() string {
+	return fmt.Sprintf("%d/%d", b.count, b.expected)
+}
+
+func parseBarrier(value string) (barrierState, error) {
+	var b barrierState
+	if _, err := fmt.Sscanf(value, "%d/%d", &b.count, &b.expected); err != nil {
+		return barrierState{}, fmt.Errorf("malformed barrier value %q", value)
+	}
+	return b, nil
+}
+
+// Atomically register an arrival at a barrier
+func (s *KVStoreService) SetBarrier(ctx context.Context, req *pb.SetBarrierRequest) (*pb.SetBarrierResponse, error) {
+	if req.Name == "" {
+		slog.Warn("set barrier request with empty name")
+		return nil, status.Error(codes.InvalidArgument, "name cannot be empty")
+	}
+	if req.ExpectedCount <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_count must be positive")
+	}
+	if req.TtlMs < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl_ms cannot be negative")
+	}
+
+	key := barrierPrefix + req.Name
+	if err := s.shedWrite("SetBarrier", key); err != nil {
+		return nil, err
+	}
+
+	state := barrierState{expected: req.ExpectedCount}
+	var exists bool
+	var value string
+	var version, expiresAt, timestamp int64
+	err := s.withKeyLock(key, func() error {
+		var current string
+		current, exists = s.store.Load(key)
+		if exists && !s.isExpired(key) {
+			existing, err := parseBarrier(current)
+			if err == nil && existing.expected != req.ExpectedCount {
+				err = fmt.Errorf("barrier expects %d arrivals, request expects %d", existing.expected, req.ExpectedCount)
+			}
+			if err != nil {
+				return status.Error(codes.FailedPrecondition, err.Error())
+			}
+			state = existing
+		} else {
+			// First arrival creates the barrier and starts its TTL
+			exists = false
+			s.clearTTL(key)
+			if req.TtlMs > 0 {
+				s.setTTL(key, time.Duration(req.TtlMs)*time.Millisecond)
+			}
+		}
+
+		state.count++
+		value = state.String()
+		s.store.Store(key, value)
+		version = s.bumpVersion(key)
+		expiresAt, _ = s.expiresAt(key)
+		timestamp = s.stampWrite(key, false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordSet(key)
+
+	s.notifySubscribers(ctx, &pb.ChangeEvent{
+		ChangeType:  pb.ChangeEvent_SET,
+		Key:         key,
+		Value:       value,
+		Timestamp:   timestamp,
+		Version:     version,
+		ExpiresAtMs: expiresAt,
+	})
+
+	slog.Info("barrier arrival", "name", req.Name, "count", state.count, "expected_count", state.expected, "created", !exists)
+	return &pb.SetBarrierResponse{
+		Reached:       state.reached(),
+		Count:         state.count,
+		ExpectedCount: state.expected,
+	}, nil
+}
+
+// Stream barrier progress until it is reached, expires, or the client goes away
+func (s *KVStoreService) WaitBarrier(req *pb.WaitBarrierRequest, stream pb.KeyValueStore_WaitBarrierServer) error {
+	if req.Name == "" {
+		slog.Warn("wait barrier request with empty name")
+		return status.Error(codes.InvalidArgument, "name cannot be empty")
+	}
+
+	key := barrierPrefix + req.Name
+	slog.Info("waiting on barrier", "name", req.Name)
+
+	untrack, err := s.trackStream()
+	if err != nil {
+		return err
+	}
+	defer untrack()
+
+	// Subscribe before reading the current state so no arrival is missed
+	sub := &subscriber{
+		pattern: key,
+		events:  make(chan *pb.ChangeEvent, 100),
+	}
+	s.addSubscriber(sub)
+	defer s.dropSubscriber(sub)
+
+	// send reports progress and whether the wait is over
+	send := func(value string) (bool, error) {
+		state, err := parseBarrier(value)
+		if err != nil {
+			return false, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		if err := stream.Send(&pb.WaitBarrierResponse{
+			Reached:       state.reached(),
+			Count:         state.count,
+			ExpectedCount: state.expected,
+		}); err != nil {
+			return false, err
+		}
+		return state.reached(), nil
+	}
+
+	if value, ok := s.store.Load(key); ok && !s.isExpired(key) {
+		if done, err := send(value); done || err != nil {
+			return err
+		}
+	}
+
+	for {
+		// Re-arm the expiry timer each pass since the barrier may be created after we start waiting
+		var expired <-chan time.Time
+		if expiresAt, ok := s.expiresAt(key); ok {
+			expired = time.After(time.Until(time.UnixMilli(expiresAt)))
+		}
+
+		select {
+		case event := <-sub.events:
+			if event.ChangeType == pb.ChangeEvent_DELETE_RANGE && keyInRange(key, event.StartKey, event.EndKey) {
+				return status.Error(codes.Aborted, "barrier was deleted before it was reached")
+			}
+			// The loop re-arms the expiry timer, which covers a changed TTL, and a
+			// sorted set of the same name is not the barrier
+			if event.Key != key || event.ChangeType == pb.ChangeEvent_EXPIRY_UPDATED ||
+				event.ChangeType == pb.ChangeEvent_ZADD || event.ChangeType == pb.ChangeEvent_ZREM {
+				continue
+			}
+			if event.ChangeType == pb.ChangeEvent_DELETE {
+				return status.Error(codes.DeadlineExceeded, "barrier expired before it was reached")
+			}
+			if done, err := send(event.Value); done || err != nil {
+				if done {
+					slog.Info("barrier reached", "name", req.Name)
+				}
+				return err
+			}
+		case <-expired:
+			slog.Warn("barrier expired before it was reached", "name", req.Name)
+			return status.Error(codes.DeadlineExceeded, "barrier expired before it was reached")
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "service is closing")
+		}
+	}
+}
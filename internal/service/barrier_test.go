@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestBarrierReleasesWaiter(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	wait, err := kv.WaitBarrier(ctx, &pb.WaitBarrierRequest{Name: "deploy"})
+	if err != nil {
+		t.Fatalf("WaitBarrier: %v", err)
+	}
+
+	const writers = 5
+	var wg sync.WaitGroup
+	reached := make(chan bool, writers)
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := kv.SetBarrier(ctx, &pb.SetBarrierRequest{Name: "deploy", ExpectedCount: writers, TtlMs: 60_000})
+			if err != nil {
+				t.Errorf("SetBarrier: %v", err)
+				return
+			}
+			reached <- resp.Reached
+		}()
+	}
+	wg.Wait()
+	close(reached)
+
+	released := 0
+	for r := range reached {
+		if r {
+			released++
+		}
+	}
+	if released != 1 {
+		t.Errorf("%d arrivals reported the barrier reached, want 1", released)
+	}
+
+	for {
+		resp, err := wait.Recv()
+		if err != nil {
+			t.Fatalf("WaitBarrier ended before the barrier was reached: %v", err)
+		}
+		if resp.Reached {
+			if resp.Count != writers {
+				t.Errorf("reached at count %d, want %d", resp.Count, writers)
+			}
+			return
+		}
+	}
+}
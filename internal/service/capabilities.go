@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+
+	"github.com/amillerrr/distributed-kv-store/internal/capabilities"
+	"github.com/amillerrr/distributed-kv-store/internal/version"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Register the features this service supports. Runs once options are
+// applied, so features they disabled are left out
+func (s *KVStoreService) registerCapabilities() {
+	for _, name := range []string{
+		capabilities.TTL,
+		capabilities.FieldMask,
+		capabilities.ETag,
+		capabilities.Append,
+		capabilities.MergePatch,
+		capabilities.Metadata,
+		capabilities.InspectKey,
+		capabilities.Range,
+		capabilities.RandomKeys,
+		capabilities.Migrate,
+		capabilities.Aggregate,
+		capabilities.ValueStream,
+		capabilities.DeleteRange,
+		capabilities.SortedSets,
+		capabilities.MultiWatch,
+		capabilities.Barriers,
+	} {
+		s.capabilities.Register(name)
+	}
+	// Resuming and acknowledgments both replay from the event history
+	if s.history != nil {
+		s.capabilities.Register(capabilities.EventHistory)
+		s.capabilities.Register(capabilities.AckMode)
+	}
+	if s.keyEventRate > 0 {
+		s.capabilities.Register(capabilities.KeyEventRateLimit)
+	}
+	if s.idleAfter > 0 {
+		s.capabilities.Register(capabilities.IdleKeyEvents)
+	}
+}
+
+// Report the server version, the features registered for this service and
+// the value size limit
+func (s *KVStoreService) ServerCapabilities(ctx context.Context, req *pb.CapabilitiesRequest) (*pb.CapabilitiesResponse, error) {
+	return &pb.CapabilitiesResponse{
+		Version:           version.Get().Version,
+		SupportedFeatures: s.capabilities.List(),
+		MaxValueSizeMb:    int32(s.maxValueSize / (1 << 20)),
+	}, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestCloseEndsStreamsAndFlushesEventLog(t *testing.T) {
+	dir := t.TempDir()
+	s := NewKVStoreService(WithEventLog(filepath.Join(dir, "events.log")))
+
+	stream := &recordingStream{ctx: context.Background(), events: make(chan *pb.ChangeEvent, 100)}
+	ended := make(chan error, 1)
+	go func() { ended <- s.Subscribe(&pb.SubscribeRequest{KeyPattern: "k"}, stream) }()
+
+	if _, err := s.Set(context.Background(), &pb.SetRequest{Key: "k", Value: "logged"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The stream's own context never ends, only Close can stop it
+	select {
+	case err := <-ended:
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("Subscribe ended with %v, want Unavailable", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe still running after Close")
+	}
+	if err := s.Subscribe(&pb.SubscribeRequest{KeyPattern: "k"}, stream); status.Code(err) != codes.Unavailable {
+		t.Errorf("Subscribe after Close = %v, want Unavailable", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	var logged strings.Builder
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		logged.Write(data)
+	}
+	if !strings.Contains(logged.String(), `"logged"`) {
+		t.Errorf("event log after Close = %q, want the Set flushed", logged.String())
+	}
+}
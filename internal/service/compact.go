@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Compact the event history every interval, keeping the last retainLast
+// events of each key. Has no effect when the event history is disabled
+func WithHistoryAutoCompact(retainLast int, interval time.Duration) Option {
+	return func(s *KVStoreService) {
+		s.compactRetainLast = retainLast
+		s.compactInterval = interval
+	}
+}
+
+// Remove older events of a key, or of every key, from the event history
+func (s *KVStoreService) CompactHistory(ctx context.Context, req *pb.CompactHistoryRequest) (*pb.CompactHistoryResponse, error) {
+	if req.RetainLast < 0 || req.RetainSince < 0 {
+		return nil, status.Error(codes.InvalidArgument, "retain_last and retain_since cannot be negative")
+	}
+	if req.RetainLast == 0 && req.RetainSince == 0 {
+		return nil, status.Error(codes.InvalidArgument, "retain_last or retain_since is required")
+	}
+	if s.history == nil {
+		return nil, status.Error(codes.FailedPrecondition, "event history is disabled on this server")
+	}
+	key := req.Key
+	if key != "" {
+		normalized, err := s.normalizeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		key = normalized
+	}
+
+	removed := s.history.compact(key, int(req.RetainLast), req.RetainSince)
+	slog.Info("event history compacted", "key", key, "retain_last", req.RetainLast, "retain_since", req.RetainSince, "removed_count", removed)
+	return &pb.CompactHistoryResponse{RemovedCount: int64(removed)}, nil
+}
+
+// Compact every key's history on the configured interval until the service is closed
+func (s *KVStoreService) runHistoryCompactor() {
+	ticker := time.NewTicker(s.compactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if removed := s.history.compact("", s.compactRetainLast, 0); removed > 0 {
+				slog.Info("event history compacted", "retain_last", s.compactRetainLast, "removed_count", removed)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Drop events of key, or of every key when empty, that are neither among the
+// retainLast most recent for their key nor at or after retainSince Unix ms.
+// Each key's latest event always stays. Events without a key, such as
+// DELETE_RANGE and BATCH, are left alone. Returns how many were dropped
+func (h *eventHistory) compact(key string, retainLast int, retainSince int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// Oldest first, as since returns them
+	held := make([]*pb.ChangeEvent, 0, len(h.events))
+	for i := range h.events {
+		if event := h.events[(h.next+i)%len(h.events)]; event != nil {
+			held = append(held, event)
+		}
+	}
+
+	// Walk newest first so each key's count starts at its latest event
+	keep := make([]bool, len(held))
+	seen := make(map[string]int)
+	removed := 0
+	for i := len(held) - 1; i >= 0; i-- {
+		event := held[i]
+		if event.Key == "" || (key != "" && event.Key != key) {
+			keep[i] = true
+			continue
+		}
+		seen[event.Key]++
+		n := seen[event.Key]
+		if n == 1 || n <= retainLast || (retainSince > 0 && event.Timestamp/int64(time.Millisecond) >= retainSince) {
+			keep[i] = true
+			continue
+		}
+		removed++
+	}
+	if removed == 0 {
+		return 0
+	}
+
+	// Pack the survivors at the start so the freed slots are written next and
+	// the oldest survivor is still the first overwritten once they fill up
+	clear(h.events)
+	n := 0
+	for i, event := range held {
+		if keep[i] {
+			h.events[n] = event
+			n++
+		}
+	}
+	h.next = n % len(h.events)
+	return removed
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Most keys accepted by a single GetComposite
+	maxCompositeKeys = 1000
+
+	// Limits on rendering a GetComposite template
+	maxCompositeRendered   = 4 << 20
+	compositeRenderTimeout = time.Second
+)
+
+var (
+	errRenderTooLarge = status.Errorf(codes.ResourceExhausted, "rendered template exceeds %d bytes", maxCompositeRendered)
+	errRenderTimeout  = status.Errorf(codes.DeadlineExceeded, "template did not render within %s", compositeRenderTimeout)
+)
+
+// Fetch several keys in one call, rendering them through a template if given
+func (s *KVStoreService) GetComposite(ctx context.Context, req *pb.GetCompositeRequest) (*pb.GetCompositeResponse, error) {
+	if len(req.Keys) == 0 {
+		slog.Warn("get composite request with no keys")
+		return nil, status.Error(codes.InvalidArgument, "keys cannot be empty")
+	}
+	if len(req.Keys) > maxCompositeKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d keys may be fetched at once", maxCompositeKeys)
+	}
+
+	var tmpl *template.Template
+	if req.Template != "" {
+		var err error
+		tmpl, err = template.New("composite").Option("missingkey=zero").Parse(req.Template)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid template: %v", err)
+		}
+	}
+
+	resp := &pb.GetCompositeResponse{}
+	values := make(map[string]string, len(req.Keys))
+	missing := 0
+	for _, requested := range req.Keys {
+		if requested == "" {
+			return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+		}
+		key, err := s.normalizeKey(requested)
+		if err != nil {
+			return nil, err
+		}
+
+		value, found := s.load(key)
+		if !found {
+			value = req.MissingDefault
+			missing++
+		} else {
+			s.recordGet(key)
+		}
+		values[requested] = value
+		resp.Pairs = append(resp.Pairs, &pb.KeyValuePair{Key: requested, Value: value})
+	}
+
+	if tmpl != nil {
+		rendered, err := renderTemplate(ctx, tmpl, values)
+		if err != nil {
+			return nil, err
+		}
+		resp.Pairs = nil
+		resp.Rendered = rendered
+	}
+
+	slog.Info("get composite request", "key_count", len(req.Keys), "missing_count", missing, "templated", tmpl != nil)
+	return resp, nil
+}
+
+// Read the live value of a normalized key, treating expired keys as absent
+func (s *KVStoreService) load(key string) (string, bool) {
+	var value string
+	var found bool
+	s.withStoreRead(func() {
+		value, found = s.store.Load(key)
+	})
+
+	if found && s.isExpired(key) {
+		s.expireKey(key)
+		return "", false
+	}
+	return value, found
+}
+
+// Output of a template render, failing writes past the size limit or once
+// the render is abandoned
+type renderBuffer struct {
+	buf  strings.Builder
+	stop atomic.Bool
+}
+
+func (b *renderBuffer) Write(p []byte) (int, error) {
+	if b.stop.Load() {
+		return 0, errRenderTimeout
+	}
+	if b.buf.Len()+len(p) > maxCompositeRendered {
+		return 0, errRenderTooLarge
+	}
+	return b.buf.Write(p)
+}
+
+// Execute tmpl with a size limit and deadline. text/template cannot be
+// interrupted, so an abandoned render keeps running in the background until
+// its next write fails or it finishes
+func renderTemplate(ctx context.Context, tmpl *template.Template, data any) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, compositeRenderTimeout)
+	defer cancel()
+
+	out := &renderBuffer{}
+	done := make(chan error, 1)
+	go func() {
+		done <- tmpl.Execute(out, data)
+	}()
+
+	select {
+	case err := <-done:
+		switch {
+		case errors.Is(err, errRenderTooLarge):
+			return "", errRenderTooLarge
+		case err != nil:
+			return "", status.Errorf(codes.InvalidArgument, "template failed: %v", err)
+		}
+		return out.buf.String(), nil
+	case <-ctx.Done():
+		out.stop.Store(true)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return "", status.FromContextError(ctx.Err()).Err()
+		}
+		slog.Warn("composite template render abandoned", "timeout", compositeRenderTimeout, "error", ctx.Err())
+		return "", errRenderTimeout
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestGetCompositeTemplateLimits(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "0123456789"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	// Large enough that a few hundred copies reach the output cap quickly
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "big", Value: strings.Repeat("x", 64<<10)}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     codes.Code
+	}{
+		{"rendered", `value={{index . "k"}}`, codes.OK},
+		{"too large", `{{range 10000000}}{{index $ "big"}}{{end}}`, codes.ResourceExhausted},
+		{"too slow", `{{range 100000000}}{{end}}`, codes.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			resp, err := s.GetComposite(ctx, &pb.GetCompositeRequest{Keys: []string{"k", "big"}, Template: tt.template})
+			if code := status.Code(err); code != tt.want {
+				t.Fatalf("err = %v, want %s", err, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > compositeRenderTimeout+time.Second {
+				t.Errorf("returned after %s", elapsed)
+			}
+			if tt.want == codes.OK && resp.Rendered != "value=0123456789" {
+				t.Errorf("Rendered = %q", resp.Rendered)
+			}
+		})
+	}
+}
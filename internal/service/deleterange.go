@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Most keys DeleteRange removes in one call
+const maxDeleteRangeKeys = 10000
+
+// Delete all keys in [start_key, end_key). Subscribers receive one
+// DELETE_RANGE event instead of a DELETE per key. Fails with
+// ResourceExhausted, deleting nothing, if the range holds too many keys
+func (s *KVStoreService) DeleteRange(ctx context.Context, req *pb.DeleteRangeRequest) (*pb.DeleteRangeResponse, error) {
+	start, end, err := s.normalizeRange(req.StartKey, req.EndKey)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("delete range request", "start_key", start, "end_key", end, "dry_run", req.DryRun)
+
+	// Exclusive lock so no single-key write interleaves with the deletion
+	var keys, sets []string
+	now := time.Now().UnixNano()
+	deleted := false
+	s.withStoreWrite(func() {
+		s.rangeKeys(start, end, func(key string) bool {
+			keys = append(keys, key)
+			return true
+		})
+		sets = s.zsets.keys(func(key string) bool { return keyInRange(key, start, end) })
+		if req.DryRun || len(keys)+len(sets) > maxDeleteRangeKeys {
+			return
+		}
+		for _, key := range sets {
+			s.zsets.drop(key)
+		}
+		stamp := writeStamp{timestamp: now, origin: s.nodeID}
+		for _, key := range keys {
+			s.store.Delete(key)
+			s.clearTTL(key)
+			s.forgetVersion(key)
+			s.forgetStats(key)
+			s.meta.remove(key)
+			// Tombstone each key so an older synced write cannot bring it back
+			s.stampKey(key, stamp)
+			s.tombstones.add(key, stamp)
+		}
+		deleted = true
+	})
+	// Sorted sets count as keys too
+	count := len(keys) + len(sets)
+	if req.DryRun {
+		return &pb.DeleteRangeResponse{DeletedCount: int64(count)}, nil
+	}
+	if !deleted {
+		slog.Warn("delete range too large", "start_key", start, "end_key", end, "key_count", count)
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"range holds %d keys, %d more than the %d that may be deleted at once; narrow the range",
+			count, count-maxDeleteRangeKeys, maxDeleteRangeKeys)
+	}
+
+	if count > 0 {
+		s.notifySubscribers(ctx, &pb.ChangeEvent{
+			ChangeType: pb.ChangeEvent_DELETE_RANGE,
+			StartKey:   start,
+			EndKey:     end,
+			Timestamp:  now,
+		})
+	}
+
+	slog.Info("range deleted", "start_key", start, "end_key", end, "deleted_count", count)
+	return &pb.DeleteRangeResponse{DeletedCount: int64(count)}, nil
+}
+
+// Apply a peer's DELETE_RANGE to local keys last written before it. Keys with
+// a later write survive, and keys created here afterwards are not affected
+func (s *KVStoreService) applyRemoteRange(ctx context.Context, event *pb.ChangeEvent) {
+	incoming := writeStamp{timestamp: event.Timestamp, origin: event.OriginNodeId}
+
+	deleted := 0
+	s.withStoreWrite(func() {
+		var keys []string
+		s.rangeKeys(event.StartKey, event.EndKey, func(key string) bool {
+			keys = append(keys, key)
+			return true
+		})
+		for _, key := range keys {
+			if current, ok := s.stamps.Load(key); ok && !incoming.after(current.(writeStamp)) {
+				continue
+			}
+			s.store.Delete(key)
+			s.clearTTL(key)
+			s.forgetVersion(key)
+			s.forgetStats(key)
+			s.meta.remove(key)
+			s.stamps.Store(key, incoming)
+			s.tombstones.add(key, incoming)
+			deleted++
+		}
+	})
+
+	if deleted == 0 {
+		return
+	}
+	slog.Debug("applied synced range delete", "start_key", event.StartKey, "end_key", event.EndKey, "deleted_count", deleted, "origin", event.OriginNodeId)
+	s.notifySubscribers(ctx, &pb.ChangeEvent{
+		ChangeType:   pb.ChangeEvent_DELETE_RANGE,
+		StartKey:     event.StartKey,
+		EndKey:       event.EndKey,
+		Timestamp:    event.Timestamp,
+		OriginNodeId: event.OriginNodeId,
+	})
+}
+
+// Report whether an event concerns keys a subscription to pattern covers
+func eventMatches(event *pb.ChangeEvent, pattern string) bool {
+	if event.ChangeType == pb.ChangeEvent_DELETE_RANGE {
+		return rangeOverlapsPrefix(event.StartKey, event.EndKey, pattern)
+	}
+	return strings.HasPrefix(event.Key, pattern)
+}
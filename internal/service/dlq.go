@@ -0,0 +1,83 @@
+package service
+
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Largest dead letter queue a subscriber may request
+const maxDLQSize = 10000
+
+// Bounded overflow for events that did not fit in a subscriber's channel
+type deadLetterQueue struct {
+	mu     sync.Mutex
+	events []*pb.ChangeEvent
+	max    int
+	// Signalled on push so an idle Subscribe loop wakes up
+	ready chan struct{}
+}
+
+func newDeadLetterQueue(max int) *deadLetterQueue {
+	return &deadLetterQueue{
+		max:   max,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// Queue an event, returning false if the queue is full
+func (q *deadLetterQueue) push(event *pb.ChangeEvent) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.events) >= q.max {
+		return false
+	}
+	q.events = append(q.events, event)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Remove the oldest queued event
+func (q *deadLetterQueue) pop() (*pb.ChangeEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.events) == 0 {
+		return nil, false
+	}
+	event := q.events[0]
+	q.events[0] = nil
+	q.events = q.events[1:]
+	return event, true
+}
+
+func (q *deadLetterQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.events)
+}
+
+// Wake-up channel, nil when the subscriber has no queue so selects skip it
+func (q *deadLetterQueue) readyChan() <-chan struct{} {
+	if q == nil {
+		return nil
+	}
+	return q.ready
+}
+
+// Divert an event to the subscriber's dead letter queue, false if it was dropped
+func (s *KVStoreService) deadLetter(sub *subscriber, event *pb.ChangeEvent) bool {
+	if !sub.dlq.push(event) {
+		slog.Warn("subscriber channel and dead letter queue full, skipping event", "pattern", sub.pattern, "key", event.Key)
+		return false
+	}
+	metrics.DLQEvents.WithLabelValues(sub.pattern).Inc()
+	return true
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestFullChannelOverflowsToDLQ(t *testing.T) {
+	s := newTestService(t)
+	sub := &subscriber{
+		pattern: "k",
+		events:  make(chan *pb.ChangeEvent, 1),
+		dlq:     newDeadLetterQueue(2),
+	}
+
+	for i := range 4 {
+		s.enqueue(sub, &pb.ChangeEvent{Key: "k", Value: strconv.Itoa(i)})
+	}
+	if event := <-sub.events; event.Value != "0" {
+		t.Errorf("channel holds %q, want 0", event.Value)
+	}
+	// The channel has room again, but the queue keeps its events in order
+	for _, want := range []string{"1", "2"} {
+		event, ok := sub.dlq.pop()
+		if !ok || event.Value != want {
+			t.Fatalf("dead letter queue gave %v, want %s", event, want)
+		}
+	}
+	if n := sub.dlq.len(); n != 0 {
+		t.Errorf("dead letter queue holds %d events, want the overflow of a full queue dropped", n)
+	}
+}
+
+func TestSubscriberWithDLQMissesNothing(t *testing.T) {
+	s := newTestService(t)
+	// Three times what the channel holds, written without reading
+	const writes = 3 * subscriberBuffer
+	events := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "k", MaxDlqSize: writes})
+
+	ctx := context.Background()
+	for i := range writes {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: strconv.Itoa(i)}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	for i := range writes {
+		if event := nextEvent(t, events); event.Value != strconv.Itoa(i) {
+			t.Fatalf("event %d has value %q", i, event.Value)
+		}
+	}
+}
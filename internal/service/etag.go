@@ -0,0 +1,13 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Content-based ETag of a value, so equal values share one whatever their
+// key or version
+func valueETag(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
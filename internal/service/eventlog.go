@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc/peer"
+
+	"github.com/amillerrr/distributed-kv-store/internal/eventlog"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Record every mutation to an append-only log rotated daily
+func WithEventLog(path string, opts ...eventlog.Option) Option {
+	return func(s *KVStoreService) {
+		w, err := eventlog.Open(path, opts...)
+		if err != nil {
+			slog.Error("failed to open event log, mutations will not be logged", "path", path, "error", err)
+			return
+		}
+		s.eventSinks = append(s.eventSinks, w)
+	}
+}
+
+// Destination for mutation records. Append must not block
+type eventSink interface {
+	Append(entry eventlog.Entry) bool
+	Close() error
+}
+
+// Queue a change event for the event log and audit forwarders, if enabled
+func (s *KVStoreService) logEvent(ctx context.Context, event *pb.ChangeEvent) {
+	if len(s.eventSinks) == 0 {
+		return
+	}
+	entry := eventlog.Entry{
+		Timestamp: event.Timestamp,
+		Op:        event.ChangeType.String(),
+		Key:       event.Key,
+		Value:     event.Value,
+		StartKey:  event.StartKey,
+		EndKey:    event.EndKey,
+		Caller:    callerFromContext(ctx),
+	}
+	for _, sink := range s.eventSinks {
+		sink.Append(entry)
+	}
+}
+
+// Identify the client behind a request, empty for internal mutations
+func callerFromContext(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
@@ -0,0 +1,30 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Watch keys of a service embedded in the same process, without gRPC
+func ExampleKVStoreService_WatchChan() {
+	svc := service.NewKVStoreService()
+	defer svc.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := svc.WatchChan(ctx, "user:", 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := svc.Set(ctx, &pb.SetRequest{Key: "user:1", Value: "alice"}); err != nil {
+		log.Fatal(err)
+	}
+	event := <-events
+	fmt.Println(event.ChangeType, event.Key, event.Value)
+	// Output: SET user:1 alice
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Most keys accepted by a single ExistsMany
+const maxExistsKeys = 10000
+
+// Report whether a key exists without sending its value
+func (s *KVStoreService) Exists(ctx context.Context, req *pb.ExistsRequest) (*pb.ExistsResponse, error) {
+	if req.Key == "" {
+		slog.Warn("exists request with empty key")
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := s.exists(key)
+	slog.Info("exists request", "key", key, "exists", exists)
+	return &pb.ExistsResponse{Exists: exists}, nil
+}
+
+// Report which of the requested keys exist
+func (s *KVStoreService) ExistsMany(ctx context.Context, req *pb.ExistsManyRequest) (*pb.ExistsManyResponse, error) {
+	if len(req.Keys) > maxExistsKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d keys may be checked at once", maxExistsKeys)
+	}
+
+	results := make(map[string]bool, len(req.Keys))
+	for _, requested := range req.Keys {
+		if requested == "" {
+			return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+		}
+		key, err := s.normalizeKey(requested)
+		if err != nil {
+			return nil, err
+		}
+		results[requested] = s.exists(key)
+	}
+
+	slog.Info("exists many request", "key_count", len(req.Keys))
+	return &pb.ExistsManyResponse{Results: results}, nil
+}
+
+// Check presence of a normalized key, treating expired keys as absent
+func (s *KVStoreService) exists(key string) bool {
+	var found bool
+	s.withStoreRead(func() {
+		// Backends that can check presence avoid reading the value
+		if checker, ok := storage.As[interface{ Has(key string) bool }](s.store); ok {
+			found = checker.Has(key)
+		} else {
+			_, found = s.store.Load(key)
+		}
+	})
+
+	if found && s.isExpired(key) {
+		s.expireKey(key)
+		return false
+	}
+	return found
+}
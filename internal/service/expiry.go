@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Give an existing key a new TTL, or none when ttl_ms is 0, leaving its value
+// and version alone. Subscribers get an EXPIRY_UPDATED event instead of a SET
+func (s *KVStoreService) SetExpiry(ctx context.Context, req *pb.SetExpiryRequest) (*pb.SetExpiryResponse, error) {
+	if req.Key == "" {
+		slog.Warn("set expiry request with empty key")
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	if req.TtlMs < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl_ms cannot be negative")
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.shedWrite("SetExpiry", key); err != nil {
+		return nil, err
+	}
+
+	slog.Info("set expiry request", "key", key, "ttl_ms", req.TtlMs)
+
+	// The key lock keeps a concurrent Set from resetting the TTL in between
+	var expired, updated bool
+	var version, expiresAt int64
+	err = s.withKeyLock(key, func() error {
+		_, found := s.store.Load(key)
+		if !found || s.isExpired(key) {
+			expired = found
+			return status.Errorf(codes.NotFound, "key %q not found", key)
+		}
+		_, hadTTL := s.expiresAt(key)
+		if req.TtlMs == 0 && !hadTTL {
+			return nil
+		}
+		s.clearTTL(key)
+		if req.TtlMs > 0 {
+			expiresAt = s.setTTL(key, time.Duration(req.TtlMs)*time.Millisecond)
+		}
+		version = s.version(key)
+		updated = true
+		return nil
+	})
+	if expired {
+		s.expireKey(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return &pb.SetExpiryResponse{}, nil
+	}
+	s.recordSet(key)
+
+	s.notifySubscribers(ctx, &pb.ChangeEvent{
+		ChangeType:  pb.ChangeEvent_EXPIRY_UPDATED,
+		Key:         key,
+		Timestamp:   time.Now().UnixNano(),
+		Version:     version,
+		ExpiresAtMs: expiresAt,
+	})
+
+	slog.Info("key expiry updated", "key", key, "expires_at_ms", expiresAt)
+	return &pb.SetExpiryResponse{Updated: true, ExpiresAtMs: expiresAt}, nil
+}
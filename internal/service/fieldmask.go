@@ -0,0 +1,36 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// A field mask segment is an object key or array index without path syntax
+var fieldMaskSegment = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// Check that a field mask is a plain dot-separated path
+func validateFieldMask(mask string) error {
+	for _, segment := range strings.Split(mask, ".") {
+		if !fieldMaskSegment.MatchString(segment) {
+			return status.Errorf(codes.InvalidArgument, "invalid field_mask %q: segments must be non-empty and contain only letters, digits, '_' or '-'", mask)
+		}
+	}
+	return nil
+}
+
+// Extract the JSON sub-value at mask from a stored JSON value
+func applyFieldMask(value, mask string) (string, error) {
+	if !gjson.Valid(value) {
+		return "", status.Error(codes.FailedPrecondition, "field_mask requires a JSON value")
+	}
+
+	result := gjson.Get(value, mask)
+	if !result.Exists() {
+		return "", status.Errorf(codes.NotFound, "field %q not found in value", mask)
+	}
+	return result.Raw, nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// How often Flush retries queueing a marker for a subscriber that is full
+const flushRetryInterval = time.Millisecond
+
+// Enable Flush. Meant for tests: it adds a marker lookup to every event sent
+// to a subscriber
+func WithFlushSupport() Option {
+	return func(s *KVStoreService) {
+		s.flushSupport = true
+	}
+}
+
+// Block until every Subscribe and MultiWatch stream has sent all events
+// queued for it before the call, for tests that assert on what subscribers
+// received. A marker is queued behind each subscriber's pending events, waiting for room
+// if it is full, and each subscriber's loop discards its marker when it gets
+// there. Subscribers that disconnect meanwhile are not waited for. Internal
+// watchers such as WaitBarrier and sync are not covered
+func (s *KVStoreService) Flush(ctx context.Context) error {
+	if !s.flushSupport {
+		return status.Error(codes.FailedPrecondition, "flush support is not enabled")
+	}
+
+	var waits []*flushWait
+	s.mu.RLock()
+	for _, subs := range s.subscribers {
+		for _, sub := range subs {
+			if sub.hasClient() {
+				waits = append(waits, &flushWait{sub: sub, marker: &pb.ChangeEvent{}, done: make(chan struct{})})
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	// Markers stay registered until their subscriber takes them, so one
+	// still queued when Flush gives up is discarded rather than sent
+	for _, w := range waits {
+		s.flushMarkers.Store(w.marker, w.done)
+	}
+
+	// Queue the markers, retrying subscribers without room until they drain
+	pending := waits
+	for len(pending) > 0 {
+		s.mu.RLock()
+		var full []*flushWait
+		for _, w := range pending {
+			if s.isSubscribed(w.sub) && !s.queueMarker(w.sub, w.marker) {
+				full = append(full, w)
+			}
+		}
+		s.mu.RUnlock()
+		pending = full
+		if len(pending) == 0 {
+			break
+		}
+		select {
+		case <-time.After(flushRetryInterval):
+		case <-ctx.Done():
+			for _, w := range pending {
+				s.flushMarkers.Delete(w.marker)
+			}
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+
+	for _, w := range waits {
+		select {
+		case <-w.done:
+		case <-w.sub.gone:
+			s.flushMarkers.Delete(w.marker)
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.done:
+			return status.Error(codes.Unavailable, "service is closing")
+		}
+	}
+	return nil
+}
+
+// Marker Flush is waiting on for one subscriber
+type flushWait struct {
+	sub    *subscriber
+	marker *pb.ChangeEvent
+	done   chan struct{}
+}
+
+// Queue marker behind everything already pending for sub, in its dead letter
+// queue if that holds events. Reports false if there is no room. Caller must
+// hold mu for reading
+func (s *KVStoreService) queueMarker(sub *subscriber, marker *pb.ChangeEvent) bool {
+	if sub.dlq != nil && sub.dlq.len() > 0 {
+		return sub.dlq.push(marker)
+	}
+	s.ensureEvents(sub)
+	select {
+	case sub.events <- marker:
+		return true
+	default:
+		return sub.dlq != nil && sub.dlq.push(marker)
+	}
+}
+
+// Report whether sub is still registered. Caller must hold mu for reading
+func (s *KVStoreService) isSubscribed(sub *subscriber) bool {
+	for _, existing := range s.subscribers[sub.pattern] {
+		if existing == sub {
+			return true
+		}
+	}
+	return false
+}
+
+// Report whether event is a Flush marker, releasing the Flush waiting on it
+func (s *KVStoreService) takeFlushMarker(event *pb.ChangeEvent) bool {
+	if !s.flushSupport {
+		return false
+	}
+	done, ok := s.flushMarkers.LoadAndDelete(event)
+	if ok {
+		close(done.(chan struct{}))
+	}
+	return ok
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestFlushWaitsForDelivery(t *testing.T) {
+	s := newTestService(t, WithFlushSupport())
+	users := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "user:"})
+	ones := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "user:1"})
+
+	ctx := context.Background()
+	for i := range 50 {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: "user:" + strconv.Itoa(i), Value: "v"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	// Everything was sent before Flush returned, so nothing needs waiting for
+	if n := len(users); n != 50 {
+		t.Errorf("user: subscriber received %d events, want 50", n)
+	}
+	// user:1 and user:10 through user:19
+	if n := len(ones); n != 11 {
+		t.Errorf("user:1 subscriber received %d events, want 11", n)
+	}
+}
+
+func TestFlushRequiresSupport(t *testing.T) {
+	s := newTestService(t)
+	if err := s.Flush(context.Background()); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Flush without support = %v, want FailedPrecondition", err)
+	}
+}
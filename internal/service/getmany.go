@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Pairs returned by GetMany when the request sets no limit
+	defaultGetManyResults = 1000
+
+	// Largest max_results accepted, larger result sets should use GetManyStream
+	maxGetManyResults = 10000
+
+	// Pairs GetManyStream loads under one hold of the store lock
+	getManyStreamBatch = 256
+)
+
+// Retrieve all pairs whose keys match the pattern in a single pass
+func (s *KVStoreService) GetMany(ctx context.Context, req *pb.GetManyRequest) (*pb.GetManyResponse, error) {
+	if req.MaxResults < 0 || req.MaxResults > maxGetManyResults {
+		return nil, status.Errorf(codes.InvalidArgument, "max_results must be between 0 and %d", maxGetManyResults)
+	}
+	match, err := s.keyMatcher(req)
+	if err != nil {
+		return nil, err
+	}
+	limit := int(req.MaxResults)
+	if limit == 0 {
+		limit = defaultGetManyResults
+	}
+
+	resp := &pb.GetManyResponse{}
+	s.ForEach(func(key, value string) bool {
+		if !match(key) {
+			return true
+		}
+		if len(resp.Pairs) == limit {
+			resp.Truncated = true
+			return false
+		}
+		resp.Pairs = append(resp.Pairs, &pb.KeyValuePair{Key: key, Value: value})
+		s.recordGet(key)
+		return true
+	})
+	sort.Slice(resp.Pairs, func(i, j int) bool { return resp.Pairs[i].Key < resp.Pairs[j].Key })
+
+	slog.Info("get many request", "pattern", req.Pattern, "match_mode", req.MatchMode, "key_count", len(resp.Pairs), "truncated", resp.Truncated)
+	return resp, nil
+}
+
+// Stream all pairs whose keys match the pattern, in no particular order
+func (s *KVStoreService) GetManyStream(req *pb.GetManyRequest, stream pb.KeyValueStore_GetManyStreamServer) error {
+	if req.MaxResults < 0 {
+		return status.Error(codes.InvalidArgument, "max_results cannot be negative")
+	}
+	match, err := s.keyMatcher(req)
+	if err != nil {
+		return err
+	}
+
+	// Collect matching keys first, then load and send them a batch at a time
+	// so the store lock is never held while waiting on the client
+	var keys []string
+	s.ForEach(func(key, _ string) bool {
+		if match(key) {
+			keys = append(keys, key)
+		}
+		return true
+	})
+
+	sent := 0
+	for batch := range slices.Chunk(keys, getManyStreamBatch) {
+		pairs := s.loadPairs(batch)
+		for _, pair := range pairs {
+			if req.MaxResults > 0 && sent == int(req.MaxResults) {
+				break
+			}
+			if err := stream.Send(pair); err != nil {
+				slog.Error("failed to stream pairs", "pattern", req.Pattern, "error", err)
+				return err
+			}
+			s.recordGet(pair.Key)
+			sent++
+		}
+		if req.MaxResults > 0 && sent == int(req.MaxResults) {
+			break
+		}
+	}
+
+	slog.Info("get many stream completed", "pattern", req.Pattern, "match_mode", req.MatchMode, "key_count", sent)
+	return nil
+}
+
+// Current values of keys, skipping those deleted or expired since they were
+// listed
+func (s *KVStoreService) loadPairs(keys []string) []*pb.KeyValuePair {
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+
+	pairs := make([]*pb.KeyValuePair, 0, len(keys))
+	for _, key := range keys {
+		if value, ok := s.store.Load(key); ok && !s.isExpired(key) {
+			pairs = append(pairs, &pb.KeyValuePair{Key: key, Value: value})
+		}
+	}
+	return pairs
+}
+
+// Build the key predicate for a GetMany request
+func (s *KVStoreService) keyMatcher(req *pb.GetManyRequest) (func(string) bool, error) {
+	if req.Pattern == "" {
+		slog.Warn("get many request with empty pattern")
+		return nil, status.Error(codes.InvalidArgument, "pattern cannot be empty")
+	}
+
+	switch req.MatchMode {
+	case pb.MatchMode_MATCH_PREFIX:
+		prefix, err := s.normalizeKey(req.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return func(key string) bool { return strings.HasPrefix(key, prefix) }, nil
+	case pb.MatchMode_MATCH_GLOB:
+		if _, err := path.Match(req.Pattern, ""); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid glob %q: %v", req.Pattern, err)
+		}
+		return func(key string) bool {
+			matched, _ := path.Match(req.Pattern, key)
+			return matched
+		}, nil
+	case pb.MatchMode_MATCH_REGEX:
+		re, err := regexp.Compile(req.Pattern)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid regex %q: %v", req.Pattern, err)
+		}
+		return re.MatchString, nil
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown match mode %d", req.MatchMode)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// GetManyStream server stream whose Send blocks until release is closed
+type blockingPairStream struct {
+	grpc.ServerStream
+	sending chan struct{}
+	release chan struct{}
+	pairs   []*pb.KeyValuePair
+}
+
+func (b *blockingPairStream) Context() context.Context { return context.Background() }
+
+func (b *blockingPairStream) Send(pair *pb.KeyValuePair) error {
+	if len(b.pairs) == 0 {
+		close(b.sending)
+	}
+	<-b.release
+	b.pairs = append(b.pairs, pair)
+	return nil
+}
+
+func TestGetManyStreamSendsWithoutStoreLock(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	for i := range 10 {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: fmt.Sprintf("user:%d", i), Value: "v"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	stream := &blockingPairStream{sending: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.GetManyStream(&pb.GetManyRequest{Pattern: "user:", MatchMode: pb.MatchMode_MATCH_PREFIX}, stream)
+	}()
+	<-stream.sending
+
+	// A slow reader must not hold up writers
+	written := make(chan error, 1)
+	go func() {
+		_, err := s.DeleteRange(ctx, &pb.DeleteRangeRequest{StartKey: "other:", EndKey: "other;"})
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatalf("DeleteRange: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("DeleteRange blocked while GetManyStream was sending")
+	}
+
+	close(stream.release)
+	if err := <-done; err != nil {
+		t.Fatalf("GetManyStream: %v", err)
+	}
+	if len(stream.pairs) != 10 {
+		t.Errorf("streamed %d pairs, want 10", len(stream.pairs))
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Most keys accepted by a single GetSnapshot
+const maxSnapshotKeys = 10000
+
+// Retrieve several keys at one instant. Writes are held off while the keys are
+// read, so the results never mix values from before and after a write.
+// Unlike MGet, an invalid key fails the whole call
+func (s *KVStoreService) GetSnapshot(ctx context.Context, req *pb.GetSnapshotRequest) (*pb.GetSnapshotResponse, error) {
+	if len(req.Keys) > maxSnapshotKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d keys may be read in one snapshot", maxSnapshotKeys)
+	}
+	keys := make([]string, len(req.Keys))
+	for i, requested := range req.Keys {
+		if requested == "" {
+			return nil, status.Errorf(codes.InvalidArgument, "keys[%d] cannot be empty", i)
+		}
+		key, err := s.normalizeKey(requested)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+
+	resp := &pb.GetSnapshotResponse{Results: make([]*pb.GetResult, 0, len(keys))}
+	var expired []string
+	found := 0
+
+	// Single-key writes hold storeMu for reading, so holding it for writing
+	// excludes every write while the keys are read
+	now := time.Now()
+	s.withStoreWrite(func() {
+		for i, key := range keys {
+			result := &pb.GetResult{Key: req.Keys[i]}
+			resp.Results = append(resp.Results, result)
+
+			value, ok := s.store.Load(key)
+			if ok {
+				if expiresAt, hasTTL := s.expiresAt(key); hasTTL && now.UnixMilli() >= expiresAt {
+					expired = append(expired, key)
+					continue
+				}
+				result.Value = value
+				result.Found = true
+				result.Version = s.version(key)
+				found++
+			}
+		}
+	})
+	resp.SnapshotTimestamp = now.UnixNano()
+
+	for _, key := range expired {
+		s.expireKey(key)
+	}
+	for i, result := range resp.Results {
+		if result.Found {
+			s.recordGet(keys[i])
+		}
+	}
+
+	slog.Info("get snapshot request", "key_count", len(keys), "found_count", found)
+	return resp, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+)
+
+// A key and its access count over the last scan interval
+type HotKey struct {
+	Key         string `json:"key"`
+	AccessCount int64  `json:"access_count"`
+}
+
+// Periodically report the most accessed keys
+func WithHotKeyTracking(topN int, interval time.Duration) Option {
+	return func(s *KVStoreService) {
+		s.statsEnabled = true
+		s.hotKeyTopN = topN
+		s.hotKeyInterval = interval
+	}
+}
+
+// Report the top-N keys every interval until the service is closed
+func (s *KVStoreService) runHotKeyScanner() {
+	ticker := time.NewTicker(s.hotKeyInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			hotKeys := s.scanHotKeys(s.hotKeyTopN)
+			if len(hotKeys) > 0 {
+				slog.Info("hot keys", "keys", hotKeys)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Collect the top-N keys by recent gets and reset the window counters
+func (s *KVStoreService) scanHotKeys(topN int) []HotKey {
+	var candidates []HotKey
+	s.keyStats.Range(func(k, v any) bool {
+		if count := v.(*keyStats).windowGets.Swap(0); count > 0 {
+			candidates = append(candidates, HotKey{Key: k.(string), AccessCount: count})
+		}
+		return true
+	})
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].AccessCount != candidates[j].AccessCount {
+			return candidates[i].AccessCount > candidates[j].AccessCount
+		}
+		return candidates[i].Key < candidates[j].Key
+	})
+	if len(candidates) > topN {
+		candidates = candidates[:topN]
+	}
+
+	// Reset so the gauge only ever carries the current top-N labels
+	metrics.HotKeyAccessCount.Reset()
+	for _, hk := range candidates {
+		metrics.HotKeyAccessCount.WithLabelValues(hk.Key).Set(float64(hk.AccessCount))
+	}
+
+	return candidates
+}
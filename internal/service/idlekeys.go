@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Announce keys that have not been read or written for idleAfter, scanning
+// every idleAfter. Enables per-key stats, which the last use is read from.
+// Every RPC reading or writing values counts as use, as do synced changes,
+// upstream fills, seeding and snapshot restores
+func WithIdleKeyTracking(idleAfter time.Duration) Option {
+	return func(s *KVStoreService) {
+		s.statsEnabled = true
+		s.idleAfter = idleAfter
+	}
+}
+
+// Delete keys once they are announced as idle, only applies with
+// WithIdleKeyTracking
+func WithDeleteIdleKeys() Option {
+	return func(s *KVStoreService) {
+		s.deleteIdleKeys = true
+	}
+}
+
+// Last read or write of a key as Unix ms
+func (k *keyStats) lastUsedMs() int64 {
+	return max(k.lastAccessedMs.Load(), k.lastModifiedMs.Load(), k.createdAtMs.Load())
+}
+
+// Look for idle keys every idleAfter until the service is closed
+func (s *KVStoreService) runIdleKeyScanner() {
+	ticker := time.NewTicker(s.idleAfter)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.scanIdleKeys()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Announce the keys that became idle since the last scan, deleting them if
+// configured, and export how many keys are idle
+func (s *KVStoreService) scanIdleKeys() {
+	type idleKey struct {
+		key        string
+		lastUsedMs int64
+	}
+
+	cutoff := time.Now().Add(-s.idleAfter).UnixMilli()
+	idle := 0
+	var newlyIdle []idleKey
+	s.keyStats.Range(func(k, v any) bool {
+		key, stats := k.(string), v.(*keyStats)
+		lastUsed := stats.lastUsedMs()
+		if lastUsed > cutoff {
+			return true
+		}
+		if _, ok := s.store.Load(key); !ok || s.isExpired(key) {
+			return true
+		}
+		idle++
+		// Keys already announced stay quiet until they are used again
+		if stats.idle.CompareAndSwap(false, true) {
+			newlyIdle = append(newlyIdle, idleKey{key: key, lastUsedMs: lastUsed})
+		}
+		return true
+	})
+	metrics.IdleKeys.Set(float64(idle))
+
+	for _, k := range newlyIdle {
+		s.announceIdle(k.key, k.lastUsedMs)
+		if s.deleteIdleKeys {
+			s.deleteIdleKey(k.key)
+		}
+	}
+	if len(newlyIdle) > 0 {
+		slog.Info("idle keys found", "new", len(newlyIdle), "idle", idle, "idle_after", s.idleAfter)
+	}
+}
+
+// Send a KEY_IDLE to each matching subscriber that asked for the type by name
+func (s *KVStoreService) announceIdle(key string, lastUsedMs int64) {
+	event := &pb.ChangeEvent{
+		ChangeType:     pb.ChangeEvent_KEY_IDLE,
+		Key:            key,
+		Timestamp:      time.Now().UnixNano(),
+		Meta:           s.meta.get(key),
+		LastAccessedMs: lastUsedMs,
+	}
+
+	subscribers, done := s.subscribersForPublish()
+	defer done()
+
+	notified := 0
+	for pattern, subs := range subscribers {
+		if !strings.HasPrefix(key, pattern) {
+			continue
+		}
+		for _, sub := range subs {
+			if slices.Contains(sub.allowedTypes, pb.ChangeEvent_KEY_IDLE) && sub.accepts(event) && s.deliver(sub, event) {
+				notified++
+			}
+		}
+	}
+	slog.Info("key idle", "key", key, "last_accessed_ms", lastUsedMs, "subscriber_count", notified)
+}
+
+// Delete a key announced as idle, unless it was used in the meantime
+func (s *KVStoreService) deleteIdleKey(key string) {
+	var found bool
+	var meta map[string]string
+	var timestamp int64
+	s.withKeyLock(key, func() error {
+		if v, ok := s.keyStats.Load(key); !ok || !v.(*keyStats).idle.Load() {
+			return nil
+		}
+		_, found = s.store.LoadAndDelete(key)
+		s.clearTTL(key)
+		s.forgetVersion(key)
+		s.forgetStats(key)
+		meta = s.meta.remove(key)
+		if found {
+			timestamp = s.stampWrite(key, true)
+		}
+		return nil
+	})
+
+	if !found {
+		return
+	}
+	slog.Info("idle key deleted", "key", key)
+	s.notifySubscribers(context.Background(), &pb.ChangeEvent{
+		ChangeType: pb.ChangeEvent_DELETE,
+		Key:        key,
+		Timestamp:  timestamp,
+		Meta:       meta,
+	})
+}
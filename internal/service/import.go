@@ -0,0 +1,146 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Pairs applied together, whichever of size or interval is reached first
+	importBatchSize     = 100
+	importFlushInterval = 10 * time.Millisecond
+
+	// Most failure messages returned in an ImportComplete
+	maxImportErrors = 100
+)
+
+// Store a stream of k/v pairs, applying them in small batches so readers see
+// data while a large import is still running. An ImportConfig sent first asks
+// for progress every report_every pairs, the reply stream always ends with
+// one ImportComplete
+func (s *KVStoreService) Import(stream pb.KeyValueStore_ImportServer) error {
+	ctx := stream.Context()
+	if err := s.shedWrite("Import", ""); err != nil {
+		return err
+	}
+
+	// The config, if any, must be known before the first pair is applied
+	first, err := stream.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	var reportEvery int64
+	var leading *pb.KeyValuePair
+	if first != nil {
+		switch req := first.Request.(type) {
+		case *pb.ImportRequest_Config:
+			if req.Config.ReportEvery < 0 {
+				return status.Error(codes.InvalidArgument, "report_every cannot be negative")
+			}
+			reportEvery = int64(req.Config.ReportEvery)
+		case *pb.ImportRequest_Pair:
+			leading = req.Pair
+		default:
+			return status.Error(codes.InvalidArgument, "import message carries neither a config nor a pair")
+		}
+	}
+	slog.Info("import started", "report_every", reportEvery)
+
+	// Receive on a separate goroutine so a partial batch can be flushed on a timer
+	pairs := make(chan *pb.KeyValuePair, importBatchSize)
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(pairs)
+		if first == nil {
+			recvErr <- io.EOF
+			return
+		}
+		if leading != nil {
+			pairs <- leading
+		}
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			pair := req.GetPair()
+			if pair == nil {
+				recvErr <- status.Error(codes.InvalidArgument, "only the first import message may be a config")
+				return
+			}
+			select {
+			case pairs <- pair:
+			case <-ctx.Done():
+				recvErr <- ctx.Err()
+				return
+			}
+		}
+	}()
+
+	resp := &pb.ImportComplete{}
+	batch := make([]*pb.KeyValuePair, 0, importBatchSize)
+	flush := func() error {
+		defer func() { batch = batch[:0] }()
+		for _, pair := range batch {
+			_, err := s.Set(ctx, &pb.SetRequest{Key: pair.Key, Value: pair.Value})
+			if err == nil {
+				resp.ImportedCount++
+			} else {
+				resp.FailedCount++
+				if len(resp.Errors) < maxImportErrors {
+					resp.Errors = append(resp.Errors, fmt.Sprintf("%q: %s", pair.Key, status.Convert(err).Message()))
+				}
+			}
+
+			processed := resp.ImportedCount + resp.FailedCount
+			if reportEvery > 0 && processed%reportEvery == 0 {
+				if err := stream.Send(&pb.ImportReply{Reply: &pb.ImportReply_Progress{Progress: &pb.ImportProgress{
+					ProcessedCount: processed,
+					FailedCount:    resp.FailedCount,
+					CurrentKey:     pair.Key,
+				}}}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(importFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case pair, ok := <-pairs:
+			if !ok {
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := <-recvErr; err != io.EOF {
+					slog.Error("import aborted", "imported_count", resp.ImportedCount, "error", err)
+					return err
+				}
+				slog.Info("import completed", "imported_count", resp.ImportedCount, "failed_count", resp.FailedCount)
+				return stream.Send(&pb.ImportReply{Reply: &pb.ImportReply_Complete{Complete: resp}})
+			}
+			batch = append(batch, pair)
+			if len(batch) >= importBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Describe a key for debugging, like Redis OBJECT and its IDLETIME. The key's
+// stats are read but not updated, so inspecting does not count as an access
+func (s *KVStoreService) InspectKey(ctx context.Context, req *pb.InspectKeyRequest) (*pb.InspectKeyResponse, error) {
+	if req.Key == "" {
+		slog.Warn("inspect request with empty key")
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.InspectKeyResponse{
+		TtlRemainingMs:       -1,
+		SubscriberMatchCount: int32(s.countMatchingSubscribers(key)),
+		StatsEnabled:         s.statsEnabled,
+	}
+
+	s.withStoreRead(func() {
+		value, found := s.store.Load(key)
+		if found && !s.isExpired(key) {
+			resp.Exists = true
+			resp.ValueSizeBytes = int64(len(value))
+			resp.Version = s.version(key)
+			if expiresAt, ok := s.expiresAt(key); ok {
+				resp.TtlRemainingMs = max(expiresAt-time.Now().UnixMilli(), 0)
+			}
+		}
+	})
+
+	if resp.Exists {
+		if stats, ok := s.KeyStats(key); ok {
+			resp.CreatedAtMs = stats.CreatedAtMs
+			resp.LastModifiedMs = stats.LastModifiedMs
+			resp.LastAccessedMs = stats.LastAccessedMs
+			resp.GetCount = stats.GetCount
+			resp.SetCount = stats.SetCount
+		}
+	}
+
+	slog.Info("inspect request", "key", key, "exists", resp.Exists)
+	return resp, nil
+}
+
+// Number of subscribers whose pattern key falls under
+func (s *KVStoreService) countMatchingSubscribers(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for pattern, subs := range s.subscribers {
+		if strings.HasPrefix(key, pattern) {
+			count += len(subs)
+		}
+	}
+	return count
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestInspectKeyReportsTTL(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "session:1", Value: "abc"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "session:2", Value: "abc", TtlMs: proto.Int64(60_000)}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	tests := []struct {
+		key             string
+		wantExists      bool
+		minTTL, maxTTL  int64
+		wantSize        int64
+		wantSubscribers int32
+	}{
+		{"session:1", true, -1, -1, 3, 1},
+		{"session:2", true, 1, 60_000, 3, 1},
+		{"session:3", false, -1, -1, 0, 1},
+		{"other:1", false, -1, -1, 0, 0},
+	}
+	subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "session:"})
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			resp, err := s.InspectKey(ctx, &pb.InspectKeyRequest{Key: tt.key})
+			if err != nil {
+				t.Fatalf("InspectKey: %v", err)
+			}
+			if resp.Exists != tt.wantExists || resp.ValueSizeBytes != tt.wantSize {
+				t.Errorf("exists, size = %v, %d, want %v, %d", resp.Exists, resp.ValueSizeBytes, tt.wantExists, tt.wantSize)
+			}
+			if resp.TtlRemainingMs < tt.minTTL || resp.TtlRemainingMs > tt.maxTTL {
+				t.Errorf("ttl_remaining_ms = %d, want %d to %d", resp.TtlRemainingMs, tt.minTTL, tt.maxTTL)
+			}
+			if resp.SubscriberMatchCount != tt.wantSubscribers {
+				t.Errorf("subscriber_match_count = %d, want %d", resp.SubscriberMatchCount, tt.wantSubscribers)
+			}
+			if resp.StatsEnabled {
+				t.Error("stats_enabled without key stats")
+			}
+		})
+	}
+}
+
+func TestInspectKeyReportsAccessCounts(t *testing.T) {
+	s := newTestService(t, WithKeyStats())
+	ctx := context.Background()
+	for range 2 {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: "user:1", Value: "v"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	for range 3 {
+		if _, err := s.Get(ctx, &pb.GetRequest{Key: "user:1"}); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+	}
+
+	resp, err := s.InspectKey(ctx, &pb.InspectKeyRequest{Key: "user:1"})
+	if err != nil {
+		t.Fatalf("InspectKey: %v", err)
+	}
+	if !resp.StatsEnabled || resp.SetCount != 2 || resp.GetCount != 3 {
+		t.Errorf("stats_enabled, set_count, get_count = %v, %d, %d, want true, 2, 3", resp.StatsEnabled, resp.SetCount, resp.GetCount)
+	}
+	if resp.CreatedAtMs == 0 || resp.LastModifiedMs < resp.CreatedAtMs || resp.LastAccessedMs < resp.LastModifiedMs {
+		t.Errorf("created, modified, accessed = %d, %d, %d, want them set and in order", resp.CreatedAtMs, resp.LastModifiedMs, resp.LastAccessedMs)
+	}
+
+	// Inspecting is not an access
+	again, _ := s.InspectKey(ctx, &pb.InspectKeyRequest{Key: "user:1"})
+	if again.GetCount != 3 {
+		t.Errorf("get_count after InspectKey = %d, want 3", again.GetCount)
+	}
+}
@@ -0,0 +1,75 @@
+//go:build debug
+
+package service
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Builds tagged debug check the service's invariants as it runs and panic on
+// the first violation, at the cost of a walk over every subscriber per Set
+
+// Panic unless key holds value. Caller must hold the key lock and storeMu
+func (s *KVStoreService) assertStored(key, value string) {
+	stored, ok := s.store.Load(key)
+	if !ok {
+		panic(fmt.Sprintf("invariant violated: key %q missing right after it was stored", key))
+	}
+	if stored != value {
+		panic(fmt.Sprintf("invariant violated: key %q holds %d bytes right after %d bytes were stored", key, len(stored), len(value)))
+	}
+}
+
+// Panic if the subscriber map holds an empty pattern list, a subscriber
+// filed under another pattern or registered twice, or one already dropped,
+// whose channel is closed or pooled. In lock-free mode the copy publishers
+// read must also hold as many subscribers as the map
+func (s *KVStoreService) assertSubscribers() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	registered := make(map[*subscriber]bool)
+	for pattern, subs := range s.subscribers {
+		if len(subs) == 0 {
+			panic(fmt.Sprintf("invariant violated: empty subscriber list left for pattern %q", pattern))
+		}
+		for _, sub := range subs {
+			if sub.pattern != pattern {
+				panic(fmt.Sprintf("invariant violated: subscriber for pattern %q registered under %q", sub.pattern, pattern))
+			}
+			if registered[sub] {
+				panic(fmt.Sprintf("invariant violated: subscriber for pattern %q registered twice", pattern))
+			}
+			registered[sub] = true
+			// Only closed once the subscriber is unregistered
+			if sub.gone != nil {
+				select {
+				case <-sub.gone:
+					panic(fmt.Sprintf("invariant violated: dropped subscriber for pattern %q is still registered", pattern))
+				default:
+				}
+			}
+		}
+	}
+
+	if !s.lockFree {
+		return
+	}
+	published := 0
+	if snapshot := s.subscriberSnapshot.Load(); snapshot != nil {
+		for _, subs := range *snapshot {
+			published += len(subs)
+		}
+	}
+	if published != len(registered) {
+		panic(fmt.Sprintf("invariant violated: %d subscribers registered but %d published for lock-free delivery", len(registered), published))
+	}
+}
+
+// Panic unless sub is registered under pattern. Caller must hold mu
+func (s *KVStoreService) assertRegistered(pattern string, sub *subscriber) {
+	if !slices.Contains(s.subscribers[pattern], sub) {
+		panic(fmt.Sprintf("invariant violated: removing subscriber for pattern %q that is not registered", pattern))
+	}
+}
@@ -0,0 +1,49 @@
+//go:build debug
+
+package service
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Run fn and return the invariant violation it panicked with, "" if none
+func violation(fn func()) (msg string) {
+	defer func() {
+		if r := recover(); r != nil {
+			msg, _ = r.(string)
+		}
+	}()
+	fn()
+	return ""
+}
+
+func TestInvariantChecksCatchViolations(t *testing.T) {
+	s := newTestService(t)
+	s.store.Store("k", "stored")
+
+	if msg := violation(func() { s.assertStored("k", "stored") }); msg != "" {
+		t.Errorf("assertStored of a matching value panicked: %s", msg)
+	}
+	if msg := violation(func() { s.assertStored("k", "other") }); !strings.HasPrefix(msg, "invariant violated") {
+		t.Errorf("assertStored of a different value = %q, want a violation", msg)
+	}
+
+	sub := &subscriber{pattern: "k", events: make(chan *pb.ChangeEvent, 1)}
+	if msg := violation(func() { s.removeSubscriber("k", sub) }); !strings.HasPrefix(msg, "invariant violated") {
+		t.Errorf("removing an unregistered subscriber = %q, want a violation", msg)
+	}
+
+	// A subscriber filed under the wrong pattern
+	s.mu.Lock()
+	s.subscribers["other"] = []*subscriber{sub}
+	s.mu.Unlock()
+	if msg := violation(s.assertSubscribers); !strings.HasPrefix(msg, "invariant violated") {
+		t.Errorf("assertSubscribers = %q, want a violation", msg)
+	}
+	s.mu.Lock()
+	delete(s.subscribers, "other")
+	s.mu.Unlock()
+}
@@ -0,0 +1,11 @@
+//go:build !debug
+
+package service
+
+// Invariant checks compile to nothing unless built with -tags debug
+
+func (s *KVStoreService) assertStored(key, value string) {}
+
+func (s *KVStoreService) assertSubscribers() {}
+
+func (s *KVStoreService) assertRegistered(pattern string, sub *subscriber) {}
@@ -0,0 +1,25 @@
+package service
+
+// Call fn for every k/v pair until it returns false, returning the number of keys visited
+func (s *KVStoreService) ForEach(fn func(key, value string) bool) int {
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+
+	visited := 0
+	s.store.Range(func(key, value string) bool {
+		if s.isExpired(key) {
+			return true
+		}
+		visited++
+		return fn(key, value)
+	})
+	return visited
+}
+
+// Like ForEach but includes per-key stats, zero-valued when stats tracking is disabled
+func (s *KVStoreService) ForEachWithStats(fn func(key, value string, stats KeyStats) bool) int {
+	return s.ForEach(func(key, value string) bool {
+		stats, _ := s.KeyStats(key)
+		return fn(key, value, stats)
+	})
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// How often limiters of keys that have gone quiet are discarded
+const keyEventLimiterSweepInterval = time.Minute
+
+// Send subscribers at most perSec events for any one key, allowing bursts of
+// burst. Events over the limit are coalesced rather than all dropped: the
+// latest is held back and delivered once the key has a token again, so
+// subscribers always end up with a key's final value. Set calls waiting for
+// acknowledgments do not wait for a held back event
+func WithKeyEventRateLimit(perSec float64, burst int) Option {
+	return func(s *KVStoreService) {
+		s.keyEventRate = rate.Limit(perSec)
+		s.keyEventBurst = max(burst, 1)
+	}
+}
+
+// Token bucket of one key, with the event held back until it refills.
+// Held back events are numbered, and sent is the number of the last one
+// delivered; while they differ newer events queue behind the held one
+type keyEventLimiter struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	pending *pb.ChangeEvent
+	held    uint64
+	sent    uint64
+}
+
+// Report whether event may be sent to subscribers now. If not, it is held
+// back in place of any earlier event of its key still waiting for a token
+func (s *KVStoreService) allowKeyEvent(event *pb.ChangeEvent) bool {
+	if s.keyEventRate <= 0 || event.Key == "" {
+		return true
+	}
+	v, ok := s.keyEventLimiters.Load(event.Key)
+	if !ok {
+		v, _ = s.keyEventLimiters.LoadOrStore(event.Key, &keyEventLimiter{limiter: rate.NewLimiter(s.keyEventRate, s.keyEventBurst)})
+	}
+	l := v.(*keyEventLimiter)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// Until every held back event is delivered later ones queue behind it,
+	// keeping order
+	if l.sent == l.held && l.limiter.Allow() {
+		return true
+	}
+	if l.pending != nil {
+		metrics.EventsRateLimited.Inc()
+	} else if l.sent == l.held {
+		// Otherwise one is being delivered and sendHeldEvent schedules the next
+		s.scheduleHeldEvent(l)
+	}
+	l.held++
+	l.pending = event
+	return false
+}
+
+// Send the held back event once the key has a token. Called with l.mu held
+func (s *KVStoreService) scheduleHeldEvent(l *keyEventLimiter) {
+	delay := l.limiter.Reserve().Delay()
+	time.AfterFunc(delay, func() { s.sendHeldEvent(l) })
+}
+
+// Deliver the event a limiter held back. Delivery may block on a full
+// subscriber, so it runs unlocked; publishes of the key meanwhile queue
+// behind it as sent has not caught up with held
+func (s *KVStoreService) sendHeldEvent(l *keyEventLimiter) {
+	l.mu.Lock()
+	event, n := l.pending, l.held
+	l.pending = nil
+	l.mu.Unlock()
+
+	select {
+	case <-s.done:
+	default:
+		s.deliverEvent(event)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sent = n
+	if l.pending != nil {
+		s.scheduleHeldEvent(l)
+	}
+}
+
+// Discard limiters whose bucket has refilled and hold nothing back, until
+// the service is closed
+func (s *KVStoreService) runKeyEventLimiterSweeper() {
+	ticker := time.NewTicker(keyEventLimiterSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.keyEventLimiters.Range(func(k, v any) bool {
+				l := v.(*keyEventLimiter)
+				l.mu.Lock()
+				if l.sent == l.held && l.limiter.TokensAt(now) >= float64(s.keyEventBurst) {
+					s.keyEventLimiters.Delete(k)
+				}
+				l.mu.Unlock()
+				return true
+			})
+		case <-s.done:
+			return
+		}
+	}
+}
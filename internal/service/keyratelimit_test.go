@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestKeyEventRateLimitKeepsOrder(t *testing.T) {
+	s := newTestService(t, WithKeyEventRateLimit(200, 1))
+	events := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "counter"})
+
+	const writes = 50
+	for i := range writes {
+		if _, err := s.Set(context.Background(), &pb.SetRequest{Key: "counter", Value: strconv.Itoa(i)}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Held back events may be coalesced but never arrive out of order
+	last := -1
+	for last != writes-1 {
+		event := nextEvent(t, events)
+		n, err := strconv.Atoi(event.Value)
+		if err != nil {
+			t.Fatalf("unexpected event value %q", event.Value)
+		}
+		if n <= last {
+			t.Fatalf("got %d after %d", n, last)
+		}
+		last = n
+	}
+}
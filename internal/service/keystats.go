@@ -0,0 +1,108 @@
+package service
+
+import (
+	"sync/atomic"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Point-in-time access figures for a single key
+type KeyStats struct {
+	GetCount       int64 `json:"get_count"`
+	SetCount       int64 `json:"set_count"`
+	CreatedAtMs    int64 `json:"created_at_ms"`
+	LastModifiedMs int64 `json:"last_modified_ms"`
+	LastAccessedMs int64 `json:"last_accessed_ms"`
+}
+
+// Live counters for a single key, updated without holding store locks
+type keyStats struct {
+	getCount       atomic.Int64
+	setCount       atomic.Int64
+	createdAtMs    atomic.Int64
+	lastModifiedMs atomic.Int64
+	lastAccessedMs atomic.Int64
+	// Gets since the last hot key scan
+	windowGets atomic.Int64
+	// Set once the key is announced as idle, cleared by its next read or write
+	idle atomic.Bool
+}
+
+func (k *keyStats) snapshot() KeyStats {
+	return KeyStats{
+		GetCount:       k.getCount.Load(),
+		SetCount:       k.setCount.Load(),
+		CreatedAtMs:    k.createdAtMs.Load(),
+		LastModifiedMs: k.lastModifiedMs.Load(),
+		LastAccessedMs: k.lastAccessedMs.Load(),
+	}
+}
+
+// Enable per-key access statistics
+func WithKeyStats() Option {
+	return func(s *KVStoreService) {
+		s.statsEnabled = true
+	}
+}
+
+// Retrieve or create the live counters for a key
+func (s *KVStoreService) statsFor(key string) *keyStats {
+	if v, ok := s.keyStats.Load(key); ok {
+		return v.(*keyStats)
+	}
+	stats := &keyStats{}
+	stats.createdAtMs.Store(time.Now().UnixMilli())
+	v, _ := s.keyStats.LoadOrStore(key, stats)
+	return v.(*keyStats)
+}
+
+// Count a read of an existing key
+func (s *KVStoreService) recordGet(key string) {
+	if !s.statsEnabled {
+		return
+	}
+	stats := s.statsFor(key)
+	stats.getCount.Add(1)
+	stats.windowGets.Add(1)
+	stats.lastAccessedMs.Store(time.Now().UnixMilli())
+	stats.idle.Store(false)
+	s.countMonitorGet(key)
+}
+
+// Count a read of each pair returned
+func (s *KVStoreService) recordGets(pairs []*pb.KeyValuePair) {
+	for _, pair := range pairs {
+		s.recordGet(pair.Key)
+	}
+}
+
+// Count a write to a key
+func (s *KVStoreService) recordSet(key string) {
+	if !s.statsEnabled {
+		return
+	}
+	stats := s.statsFor(key)
+	stats.setCount.Add(1)
+	stats.lastModifiedMs.Store(time.Now().UnixMilli())
+	stats.idle.Store(false)
+}
+
+// Drop the counters for a removed key
+func (s *KVStoreService) forgetStats(key string) {
+	if s.statsEnabled {
+		s.keyStats.Delete(key)
+	}
+}
+
+// Retrieve stats for a key, false if tracking is disabled or the key is unknown
+func (s *KVStoreService) KeyStats(key string) (KeyStats, bool) {
+	if !s.statsEnabled {
+		return KeyStats{}, false
+	}
+	v, ok := s.keyStats.Load(key)
+	if !ok {
+		return KeyStats{}, false
+	}
+	return v.(*keyStats).snapshot(), true
+}
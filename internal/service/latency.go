@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// Unary request durations kept for percentile estimates
+const latencyWindowSize = 1024
+
+// Durations of the most recent requests, written without locks. Readers may
+// see a slot mid-update, which only skews an estimate by one sample
+type latencyWindow struct {
+	samples [latencyWindowSize]atomic.Int64
+	// Requests recorded since start, the next slot is count % size
+	count atomic.Int64
+}
+
+func (l *latencyWindow) record(d time.Duration) {
+	i := l.count.Add(1) - 1
+	l.samples[i%latencyWindowSize].Store(int64(d))
+}
+
+// 99th percentile of the recorded durations, 0 before any request
+func (l *latencyWindow) p99() time.Duration {
+	n := min(l.count.Load(), latencyWindowSize)
+	if n == 0 {
+		return 0
+	}
+	durations := make([]int64, n)
+	for i := range durations {
+		durations[i] = l.samples[i].Load()
+	}
+	slices.Sort(durations)
+	return time.Duration(durations[(n*99-1)/100])
+}
+
+// Record how long each unary request takes, reported by MonitorStream
+func (s *KVStoreService) LatencyInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		s.latency.record(time.Since(start))
+		return resp, err
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/loadshed"
+)
+
+// Reject writes while heap usage is above threshold, a fraction of GOMEMLIMIT.
+// Reads and subscriptions are never shed. Has no effect if GOMEMLIMIT is unset
+func WithLoadShedding(threshold float64) Option {
+	return func(s *KVStoreService) {
+		s.loadShed = loadshed.NewMonitor(threshold)
+	}
+}
+
+// Fail a write with ResourceExhausted while load is being shed
+func (s *KVStoreService) shedWrite(method, key string) error {
+	if !s.loadShed.Shedding() {
+		return nil
+	}
+	slog.Warn("write rejected under memory pressure", "method", method, "key", key)
+	return status.Error(codes.ResourceExhausted, "server is under memory pressure, retry later")
+}
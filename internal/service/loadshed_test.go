@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"runtime/debug"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestWritesShedUnderMemoryPressure(t *testing.T) {
+	// A 1 GiB limit with a threshold of a few KiB is crossed by any live heap
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(1 << 30))
+	s := newTestService(t, WithLoadShedding(1e-6))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for !s.loadShed.Shedding() {
+		if ctx.Err() != nil {
+			t.Fatal("monitor never started shedding")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	kv := newTestClient(t, s)
+	writes := map[string]func() error{
+		"Set": func() error {
+			_, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "v"})
+			return err
+		},
+		"SetWithVersion": func() error {
+			_, err := s.SetWithVersion(ctx, &pb.SetWithVersionRequest{Key: "k", Value: "v"})
+			return err
+		},
+		"SetBarrier": func() error {
+			_, err := s.SetBarrier(ctx, &pb.SetBarrierRequest{Name: "b", ExpectedCount: 2})
+			return err
+		},
+		"SetMeta": func() error {
+			_, err := s.SetMeta(ctx, &pb.SetMetaRequest{Key: "k", Meta: map[string]string{"a": "b"}})
+			return err
+		},
+		"SetExpiry": func() error {
+			_, err := s.SetExpiry(ctx, &pb.SetExpiryRequest{Key: "k", TtlMs: 1000})
+			return err
+		},
+		"SetStream": func() error {
+			stream, err := kv.SetStream(ctx)
+			if err != nil {
+				return err
+			}
+			_, err = stream.CloseAndRecv()
+			return err
+		},
+		"Import": func() error {
+			stream, err := kv.Import(ctx)
+			if err != nil {
+				return err
+			}
+			stream.CloseSend()
+			_, err = stream.Recv()
+			return err
+		},
+	}
+	for method, write := range writes {
+		if err := write(); status.Code(err) != codes.ResourceExhausted {
+			t.Errorf("%s = %v, want ResourceExhausted", method, err)
+		}
+	}
+
+	s.applyRemote(ctx, &pb.ChangeEvent{
+		ChangeType:   pb.ChangeEvent_SET,
+		Key:          "synced",
+		Value:        "v",
+		Timestamp:    time.Now().UnixNano(),
+		OriginNodeId: "peer",
+	})
+	if _, found := s.store.Load("synced"); found {
+		t.Error("synced write was applied while shedding")
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"slices"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Publish events without taking mu. Every subscribe and unsubscribe copies
+// the subscriber map and swaps in the copy, and publishing iterates the copy
+// it loaded, so a burst of subscription churn no longer stalls writes behind
+// mu. The cost is a map copy per registration, and since a publish may still
+// hold a copy with a removed subscriber, its channel is never closed or
+// returned to the channel pool but left to the garbage collector. WatchChan
+// forwards through a channel of its own so it can still close it
+func WithLockFreeSubscribers() Option {
+	return func(s *KVStoreService) {
+		s.lockFree = true
+	}
+}
+
+// Copy the subscriber map for lock-free publishing. Caller must hold mu
+func (s *KVStoreService) publishSubscribers() {
+	if !s.lockFree {
+		return
+	}
+	snapshot := make(map[string][]*subscriber, len(s.subscribers))
+	for pattern, subs := range s.subscribers {
+		// removeSubscriber shifts elements within the slice, so copy it too
+		snapshot[pattern] = slices.Clone(subs)
+	}
+	s.subscriberSnapshot.Store(&snapshot)
+}
+
+// Subscribers to publish to and the function to call once done with them.
+// In lock-free mode that is the latest copy, otherwise the map under mu
+func (s *KVStoreService) subscribersForPublish() (map[string][]*subscriber, func()) {
+	if s.lockFree {
+		if snapshot := s.subscriberSnapshot.Load(); snapshot != nil {
+			return *snapshot, func() {}
+		}
+		return nil, func() {}
+	}
+	s.mu.RLock()
+	return s.subscribers, s.mu.RUnlock
+}
+
+// Forward events of a WatchChan subscriber to a channel that is closed once
+// it is dropped, as in lock-free mode its own channel is never closed
+func (s *KVStoreService) forwardWatch(sub *subscriber) <-chan *pb.ChangeEvent {
+	out := make(chan *pb.ChangeEvent)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case event := <-sub.events:
+				select {
+				case out <- event:
+				case <-sub.gone:
+					return
+				}
+			case <-sub.gone:
+				return
+			}
+		}
+	}()
+	return out
+}
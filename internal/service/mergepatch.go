@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Apply an RFC 7396 JSON Merge Patch to a key's JSON value. The result is
+// re-encoded, so object keys come out sorted and insignificant whitespace is
+// dropped. Any TTL on the key is kept
+func (s *KVStoreService) MergePatch(ctx context.Context, req *pb.MergePatchRequest) (*pb.MergePatchResponse, error) {
+	if req.Key == "" {
+		slog.Warn("merge patch request with empty key")
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	req.Key = key
+	patch, err := decodeJSON(req.Patch)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "patch is not valid JSON: %v", err)
+	}
+	if err := s.shedWrite("MergePatch", req.Key); err != nil {
+		return nil, err
+	}
+
+	slog.Info("merge patch request", "key", req.Key)
+	s.logSample(ctx, "MergePatch", req.Key, req.Patch)
+
+	// Read and write under the key lock so concurrent patches are not lost
+	var newValue string
+	var expired bool
+	var version, expiresAt, timestamp int64
+	err = s.withKeyLock(req.Key, func() error {
+		current, found := s.store.Load(req.Key)
+		if found && s.isExpired(req.Key) {
+			expired = true
+			return status.Errorf(codes.NotFound, "key %q not found", req.Key)
+		}
+		if !found {
+			return status.Errorf(codes.NotFound, "key %q not found", req.Key)
+		}
+		doc, err := decodeJSON(current)
+		if err != nil {
+			return status.Error(codes.FailedPrecondition, "current value is not valid JSON")
+		}
+		if newValue, err = encodeJSON(mergePatch(doc, patch)); err != nil {
+			return status.Errorf(codes.Internal, "encode patched value: %v", err)
+		}
+		if s.maxValueSize > 0 && len(newValue) > s.maxValueSize {
+			slog.Warn("merge patch would exceed maximum value size", "key", req.Key, "value_length", len(newValue), "max", s.maxValueSize)
+			return status.Errorf(codes.InvalidArgument, "value would exceed maximum size of %d bytes", s.maxValueSize)
+		}
+		s.store.Store(req.Key, newValue)
+		version = s.bumpVersion(req.Key)
+		expiresAt, _ = s.expiresAt(req.Key)
+		timestamp = s.stampWrite(req.Key, false)
+		return nil
+	})
+	if expired {
+		// Nothing replaces the value, so delete it as the reaper would
+		s.expireKey(req.Key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.recordSet(req.Key)
+
+	s.notifySubscribers(ctx, &pb.ChangeEvent{
+		ChangeType:  pb.ChangeEvent_SET,
+		Key:         req.Key,
+		Value:       newValue,
+		Timestamp:   timestamp,
+		Version:     version,
+		ExpiresAtMs: expiresAt,
+	})
+
+	slog.Info("value patched", "key", req.Key, "value_length", len(newValue))
+	return &pb.MergePatchResponse{
+		NewValue: newValue,
+		Version:  version,
+	}, nil
+}
+
+// Decode a single JSON document, keeping numbers exact
+func decodeJSON(s string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// Encode v compactly, leaving characters such as < and & unescaped
+func encodeJSON(v any) (string, error) {
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// Merge patch into target as RFC 7396 defines it: an object patch merges into
+// the target field by field, removing fields patched to null, anything else
+// replaces the target
+func mergePatch(target, patch any) any {
+	fields, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	doc, ok := target.(map[string]any)
+	if !ok {
+		doc = map[string]any{}
+	}
+	for name, value := range fields {
+		if value == nil {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = mergePatch(doc[name], value)
+	}
+	return doc
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Most labels a single key may carry
+const maxMetaLabels = 64
+
+// Labels per key with a reverse index from each label to the keys carrying it
+type metaIndex struct {
+	mu     sync.RWMutex
+	labels map[string]map[string]string
+	// Keys by label, indexed as name and value joined by a NUL
+	keys map[string]map[string]struct{}
+}
+
+func newMetaIndex() *metaIndex {
+	return &metaIndex{
+		labels: make(map[string]map[string]string),
+		keys:   make(map[string]map[string]struct{}),
+	}
+}
+
+func labelID(name, value string) string {
+	return name + "\x00" + value
+}
+
+// Replace the labels of key, clearing them when labels is empty
+func (m *metaIndex) set(key string, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeLocked(key)
+	if len(labels) == 0 {
+		return
+	}
+	m.labels[key] = maps.Clone(labels)
+	for name, value := range labels {
+		id := labelID(name, value)
+		if m.keys[id] == nil {
+			m.keys[id] = make(map[string]struct{})
+		}
+		m.keys[id][key] = struct{}{}
+	}
+}
+
+// Labels of key, nil if it has none. The map must not be modified
+func (m *metaIndex) get(key string) map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.labels[key]
+}
+
+// Drop the labels of key, returning those it had
+func (m *metaIndex) remove(key string) map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.removeLocked(key)
+}
+
+func (m *metaIndex) removeLocked(key string) map[string]string {
+	old, ok := m.labels[key]
+	if !ok {
+		return nil
+	}
+	delete(m.labels, key)
+	for name, value := range old {
+		id := labelID(name, value)
+		delete(m.keys[id], key)
+		if len(m.keys[id]) == 0 {
+			delete(m.keys, id)
+		}
+	}
+	return old
+}
+
+// Keys carrying every one of labels, in key order
+func (m *metaIndex) search(labels map[string]string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Scan the smallest key set and check the other labels against it
+	var smallest map[string]struct{}
+	for name, value := range labels {
+		set := m.keys[labelID(name, value)]
+		if len(set) == 0 {
+			return nil
+		}
+		if smallest == nil || len(set) < len(smallest) {
+			smallest = set
+		}
+	}
+
+	var matched []string
+	for key := range smallest {
+		if hasLabels(m.labels[key], labels) {
+			matched = append(matched, key)
+		}
+	}
+	slices.Sort(matched)
+	return matched
+}
+
+// Report whether have includes every label in want
+func hasLabels(have, want map[string]string) bool {
+	for name, value := range want {
+		if v, ok := have[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Attach the current labels of an event's key, and of each key in a batch
+func (s *KVStoreService) attachMeta(event *pb.ChangeEvent) {
+	if event.Key != "" && event.Meta == nil {
+		event.Meta = s.meta.get(event.Key)
+	}
+	for _, inner := range event.GetBatch().GetEvents() {
+		s.attachMeta(inner)
+	}
+}
+
+// Replace the labels of an existing key
+func (s *KVStoreService) SetMeta(ctx context.Context, req *pb.SetMetaRequest) (*pb.SetMetaResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Meta) > maxMetaLabels {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d labels may be set on a key", maxMetaLabels)
+	}
+	for name := range req.Meta {
+		if name == "" {
+			return nil, status.Error(codes.InvalidArgument, "label names cannot be empty")
+		}
+	}
+	if err := s.shedWrite("SetMeta", key); err != nil {
+		return nil, err
+	}
+
+	// Under the key lock so a concurrent delete cannot leave labels behind
+	err = s.withKeyLock(key, func() error {
+		if _, found := s.store.Load(key); !found || s.isExpired(key) {
+			return status.Errorf(codes.NotFound, "key %q not found", key)
+		}
+		s.meta.set(key, req.Meta)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordSet(key)
+
+	slog.Info("key labels set", "key", key, "label_count", len(req.Meta))
+	return &pb.SetMetaResponse{}, nil
+}
+
+// Retrieve the labels of an existing key
+func (s *KVStoreService) GetMeta(ctx context.Context, req *pb.GetMetaRequest) (*pb.GetMetaResponse, error) {
+	if req.Key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	found, labels := s.loadMeta(key)
+	if !found || s.isExpired(key) {
+		return nil, status.Errorf(codes.NotFound, "key %q not found", key)
+	}
+	return &pb.GetMetaResponse{Meta: maps.Clone(labels)}, nil
+}
+
+// Find the keys carrying every requested label
+func (s *KVStoreService) SearchByMeta(ctx context.Context, req *pb.SearchMetaRequest) (*pb.SearchMetaResponse, error) {
+	if len(req.Labels) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "labels cannot be empty")
+	}
+
+	keys := slices.DeleteFunc(s.meta.search(req.Labels), s.isExpired)
+	slog.Info("search by labels", "label_count", len(req.Labels), "key_count", len(keys))
+	return &pb.SearchMetaResponse{Keys: keys}, nil
+}
+
+// Whether key is stored, and its labels
+func (s *KVStoreService) loadMeta(key string) (bool, map[string]string) {
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+	_, found := s.store.Load(key)
+	return found, s.meta.get(key)
+}
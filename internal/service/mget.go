@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Most keys accepted by a single MGet
+const maxMGetKeys = 1000
+
+// Retrieve several keys at once. A key that cannot be read gets an error in
+// its own result instead of failing the whole call
+func (s *KVStoreService) MGet(ctx context.Context, req *pb.MGetRequest) (*pb.MGetResponse, error) {
+	if len(req.Keys) > maxMGetKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d keys may be fetched at once", maxMGetKeys)
+	}
+
+	resp := &pb.MGetResponse{Results: make([]*pb.GetResult, 0, len(req.Keys))}
+	found := 0
+	for _, requested := range req.Keys {
+		result := &pb.GetResult{Key: requested}
+		resp.Results = append(resp.Results, result)
+
+		if requested == "" {
+			result.Error = "key cannot be empty"
+			continue
+		}
+		key, err := s.normalizeKey(requested)
+		if err != nil {
+			result.Error = status.Convert(err).Message()
+			continue
+		}
+
+		value, ok, version := s.loadVersioned(key)
+		if ok && s.isExpired(key) {
+			s.expireKey(key)
+			ok = false
+		}
+		if !ok {
+			continue
+		}
+
+		s.recordGet(key)
+		result.Value = value
+		result.Found = true
+		result.Version = version
+		found++
+	}
+
+	slog.Info("mget request", "key_count", len(req.Keys), "found_count", found)
+	return resp, nil
+}
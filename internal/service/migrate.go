@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Keys copied between progress messages of a migration
+	migrateReportEvery = 100
+
+	// Most failure messages returned in a MigrateComplete
+	maxMigrateErrors = 100
+)
+
+// Copy every key under from_prefix to the same key under to_prefix, with its
+// TTL and labels, deleting the source when delete_source is set. The keys to
+// copy are listed when the migration starts, then each is copied on its own,
+// so writes to other keys carry on and keys added meanwhile are left behind
+func (s *KVStoreService) Migrate(req *pb.MigrateRequest, stream pb.KeyValueStore_MigrateServer) error {
+	if req.FromPrefix == "" || req.ToPrefix == "" {
+		return status.Error(codes.InvalidArgument, "from_prefix and to_prefix cannot be empty")
+	}
+	from, err := s.normalizeKey(req.FromPrefix)
+	if err != nil {
+		return err
+	}
+	to, err := s.normalizeKey(req.ToPrefix)
+	if err != nil {
+		return err
+	}
+	// Copies would otherwise land among the keys still to be copied
+	if strings.HasPrefix(from, to) || strings.HasPrefix(to, from) {
+		return status.Errorf(codes.InvalidArgument, "prefixes %q and %q overlap", from, to)
+	}
+
+	var keys []string
+	s.withStoreRead(func() {
+		s.rangeKeys(from, prefixEnd(from), func(key string) bool {
+			if !s.isExpired(key) {
+				keys = append(keys, key)
+			}
+			return true
+		})
+	})
+
+	slog.Info("migrate started", "from_prefix", from, "to_prefix", to, "delete_source", req.DeleteSource, "key_count", len(keys))
+
+	ctx := stream.Context()
+	resp := &pb.MigrateComplete{}
+	for i, key := range keys {
+		if err := ctx.Err(); err != nil {
+			slog.Warn("migrate aborted", "migrated_count", resp.MigratedCount, "error", err)
+			return status.FromContextError(err).Err()
+		}
+
+		dst := to + strings.TrimPrefix(key, from)
+		copied, err := s.copyKey(ctx, key, dst, req.DeleteSource)
+		switch {
+		case err != nil:
+			resp.FailedCount++
+			if len(resp.Errors) < maxMigrateErrors {
+				resp.Errors = append(resp.Errors, fmt.Sprintf("%q: %s", key, status.Convert(err).Message()))
+			}
+		case copied:
+			resp.MigratedCount++
+		}
+
+		if (i+1)%migrateReportEvery == 0 {
+			if err := stream.Send(&pb.MigrateReply{Reply: &pb.MigrateReply_Progress{Progress: &pb.MigrateProgress{
+				MigratedCount: resp.MigratedCount,
+				FailedCount:   resp.FailedCount,
+				TotalEstimate: int64(len(keys)),
+				CurrentKey:    key,
+			}}}); err != nil {
+				return err
+			}
+		}
+	}
+
+	slog.Info("migrate completed", "from_prefix", from, "to_prefix", to, "migrated_count", resp.MigratedCount, "failed_count", resp.FailedCount)
+	return stream.Send(&pb.MigrateReply{Reply: &pb.MigrateReply_Complete{Complete: resp}})
+}
+
+// Store the value, remaining TTL and labels of src under dst, replacing dst,
+// and delete src when move is set. Both keys change under the exclusive
+// store lock, so no reader sees one without the other. Reports false if src
+// no longer exists
+func (s *KVStoreService) copyKey(ctx context.Context, src, dst string, move bool) (bool, error) {
+	if err := s.shedWrite("Migrate", dst); err != nil {
+		return false, err
+	}
+
+	var value string
+	var found, expired bool
+	var expiresAt, version, dstTimestamp, srcTimestamp int64
+	var srcMeta map[string]string
+	s.withStoreWrite(func() {
+		value, found = s.store.Load(src)
+		if !found || s.isExpired(src) {
+			expired = found
+			found = false
+			return
+		}
+		var hasTTL bool
+		expiresAt, hasTTL = s.expiresAt(src)
+		meta := s.meta.get(src)
+
+		s.store.Store(dst, value)
+		s.clearTTL(dst)
+		if hasTTL {
+			s.expiries.Store(dst, expiresAt)
+		}
+		s.meta.set(dst, meta)
+		version = s.bumpVersion(dst)
+		dstTimestamp = s.stampWrite(dst, false)
+
+		if move {
+			s.store.Delete(src)
+			s.clearTTL(src)
+			s.forgetVersion(src)
+			srcMeta = s.meta.remove(src)
+			srcTimestamp = s.stampWrite(src, true)
+		}
+	})
+	if expired {
+		s.expireKey(src)
+	}
+	if !found {
+		return false, nil
+	}
+	s.recordSet(dst)
+	if move {
+		s.forgetStats(src)
+	}
+
+	s.notifySubscribers(ctx, &pb.ChangeEvent{
+		ChangeType:  pb.ChangeEvent_SET,
+		Key:         dst,
+		Value:       value,
+		Timestamp:   dstTimestamp,
+		Version:     version,
+		ExpiresAtMs: expiresAt,
+	})
+	if move {
+		s.notifySubscribers(ctx, &pb.ChangeEvent{
+			ChangeType: pb.ChangeEvent_DELETE,
+			Key:        src,
+			Timestamp:  srcTimestamp,
+			Meta:       srcMeta,
+		})
+	}
+	return true, nil
+}
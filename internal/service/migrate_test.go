@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Run a migration, returning its progress messages and final result
+func migrate(t *testing.T, kv pb.KeyValueStoreClient, req *pb.MigrateRequest) ([]*pb.MigrateProgress, *pb.MigrateComplete) {
+	t.Helper()
+	stream, err := kv.Migrate(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	var progress []*pb.MigrateProgress
+	for {
+		reply, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if p := reply.GetProgress(); p != nil {
+			progress = append(progress, p)
+			continue
+		}
+		return progress, reply.GetComplete()
+	}
+}
+
+func TestMigrateReportsProgress(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+	ctx := context.Background()
+
+	const total = 250
+	for i := range total {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: fmt.Sprintf("old:%03d", i), Value: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	progress, complete := migrate(t, kv, &pb.MigrateRequest{FromPrefix: "old:", ToPrefix: "new:"})
+	if len(progress) != total/migrateReportEvery {
+		t.Fatalf("%d progress messages, want %d", len(progress), total/migrateReportEvery)
+	}
+	for i, p := range progress {
+		done := int64((i + 1) * migrateReportEvery)
+		if p.MigratedCount != done || p.TotalEstimate != total || p.CurrentKey != fmt.Sprintf("old:%03d", done-1) {
+			t.Errorf("progress %d = %+v, want %d of %d migrated", i, p, done, total)
+		}
+	}
+	if complete.MigratedCount != total || complete.FailedCount != 0 {
+		t.Errorf("complete = %+v, want %d migrated", complete, total)
+	}
+
+	for _, i := range []int{0, 123, total - 1} {
+		resp, err := s.Get(ctx, &pb.GetRequest{Key: fmt.Sprintf("new:%03d", i)})
+		if err != nil || resp.Value != fmt.Sprint(i) {
+			t.Errorf("new:%03d = %v, %v, want %d", i, resp, err, i)
+		}
+		// Without delete_source the originals stay
+		if resp, _ := s.Get(ctx, &pb.GetRequest{Key: fmt.Sprintf("old:%03d", i)}); !resp.Found {
+			t.Errorf("old:%03d was removed", i)
+		}
+	}
+}
+
+func TestMigrateDeleteSource(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+	ctx := context.Background()
+
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "old:1", Value: "a", TtlMs: proto.Int64(3_600_000)}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.SetMeta(ctx, &pb.SetMetaRequest{Key: "old:1", Meta: map[string]string{"team": "search"}}); err != nil {
+		t.Fatalf("SetMeta: %v", err)
+	}
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "old:2", Value: "b"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	events := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "old:"})
+
+	_, complete := migrate(t, kv, &pb.MigrateRequest{FromPrefix: "old:", ToPrefix: "new:", DeleteSource: true})
+	if complete.MigratedCount != 2 {
+		t.Fatalf("complete = %+v, want 2 migrated", complete)
+	}
+
+	for _, key := range []string{"old:1", "old:2"} {
+		if resp, _ := s.Get(ctx, &pb.GetRequest{Key: key}); resp.Found {
+			t.Errorf("%s still exists", key)
+		}
+		if event := nextEvent(t, events); event.ChangeType != pb.ChangeEvent_DELETE || event.Key != key {
+			t.Errorf("event = %v %s, want DELETE %s", event.ChangeType, event.Key, key)
+		}
+	}
+
+	// The TTL and labels move with the value
+	inspect, err := s.InspectKey(ctx, &pb.InspectKeyRequest{Key: "new:1"})
+	if err != nil || !inspect.Exists || inspect.TtlRemainingMs <= 0 {
+		t.Errorf("InspectKey new:1 = %+v, %v, want a remaining TTL", inspect, err)
+	}
+	meta, err := s.GetMeta(ctx, &pb.GetMetaRequest{Key: "new:1"})
+	if err != nil || meta.Meta["team"] != "search" {
+		t.Errorf("GetMeta new:1 = %v, %v, want team=search", meta, err)
+	}
+	if meta, _ := s.GetMeta(ctx, &pb.GetMetaRequest{Key: "old:1"}); len(meta.GetMeta()) != 0 {
+		t.Errorf("old:1 kept labels %v", meta.Meta)
+	}
+}
+
+func TestMigrateRejectsOverlappingPrefixes(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+
+	stream, err := kv.Migrate(context.Background(), &pb.MigrateRequest{FromPrefix: "user:", ToPrefix: "user:v2:"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument", err)
+	}
+}
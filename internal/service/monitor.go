@@ -0,0 +1,273 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Update interval until a command sets one, and the shortest allowed
+	defaultMonitorInterval = time.Second
+	minMonitorInterval     = 100 * time.Millisecond
+
+	// Hot keys included in each update, and keys counted per stream to find them
+	monitorHotKeys     = 10
+	monitorHotKeySlots = 10 * monitorHotKeys
+)
+
+// Stats one monitor stream sends, changed by the client's commands
+type monitorSession struct {
+	interval time.Duration
+	metrics  []pb.MonitorMetric
+
+	// Reads since the previous update, nil when stats tracking is disabled
+	gets *hotKeyCounter
+	// Unary requests at the previous update, for deltas
+	lastRequests int64
+}
+
+// Apply a command, leaving unset fields as they are
+func (m *monitorSession) apply(cmd *pb.MonitorCommand) error {
+	if cmd.TickIntervalMs != 0 {
+		interval := time.Duration(cmd.TickIntervalMs) * time.Millisecond
+		if interval < minMonitorInterval {
+			return status.Errorf(codes.InvalidArgument, "tick_interval_ms must be at least %d", minMonitorInterval.Milliseconds())
+		}
+		m.interval = interval
+	}
+	if len(cmd.Metrics) > 0 {
+		m.metrics = cmd.Metrics
+	}
+	return nil
+}
+
+func (m *monitorSession) wants(metric pb.MonitorMetric) bool {
+	return len(m.metrics) == 0 || slices.Contains(m.metrics, metric)
+}
+
+// Push stats on the interval the client chooses until it closes the stream
+func (s *KVStoreService) MonitorStream(stream pb.AdminService_MonitorStreamServer) error {
+	untrack, err := s.trackStream()
+	if err != nil {
+		return err
+	}
+	defer untrack()
+
+	session := &monitorSession{interval: defaultMonitorInterval}
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := session.apply(first); err != nil {
+		return err
+	}
+	slog.Info("monitor stream opened", "interval", session.interval, "metrics", session.metrics)
+	if s.statsEnabled {
+		session.gets = s.watchGets()
+		defer s.unwatchGets(session.gets)
+	}
+
+	// Receive commands separately so updates keep flowing between them
+	commands := make(chan *pb.MonitorCommand)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			cmd, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case commands <- cmd:
+			case <-stream.Context().Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(session.interval)
+	defer ticker.Stop()
+	for {
+		if err := stream.Send(s.monitorUpdate(session)); err != nil {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case cmd := <-commands:
+			if err := session.apply(cmd); err != nil {
+				return err
+			}
+			ticker.Reset(session.interval)
+			slog.Info("monitor stream updated", "interval", session.interval, "metrics", session.metrics)
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "service is closing")
+		}
+	}
+}
+
+// Collect the stats the session asked for
+func (s *KVStoreService) monitorUpdate(session *monitorSession) *pb.MonitorUpdate {
+	update := &pb.MonitorUpdate{
+		TimestampMs:    time.Now().UnixMilli(),
+		TickIntervalMs: session.interval.Milliseconds(),
+		Metrics:        session.metrics,
+	}
+
+	if session.wants(pb.MonitorMetric_METRIC_KEY_COUNT) {
+		update.KeyCount = s.monitorKeyCount()
+	}
+	if session.wants(pb.MonitorMetric_METRIC_SUBSCRIBER_COUNT) {
+		s.mu.RLock()
+		for _, subs := range s.subscribers {
+			update.SubscriberCount += int32(len(subs))
+		}
+		s.mu.RUnlock()
+	}
+	if session.wants(pb.MonitorMetric_METRIC_HOT_KEYS) {
+		update.HotKeys = session.gets.take(monitorHotKeys)
+	}
+	if session.wants(pb.MonitorMetric_METRIC_MEMORY) {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		update.HeapAllocBytes = ms.HeapAlloc
+		update.HeapSysBytes = ms.HeapSys
+	}
+	if session.wants(pb.MonitorMetric_METRIC_LATENCY) {
+		update.P99LatencyMs = float64(s.latency.p99()) / float64(time.Millisecond)
+		requests := s.latency.count.Load()
+		update.RequestCount = requests - session.lastRequests
+		session.lastRequests = requests
+	}
+	return update
+}
+
+// Number of keys for a monitor update. Backends that keep a count report it
+// directly, including expired keys not yet reaped. Otherwise the store is
+// counted at most once per default interval for all streams together
+func (s *KVStoreService) monitorKeyCount() int64 {
+	if counter, ok := storage.As[interface{ Len() int }](s.store); ok {
+		return int64(counter.Len())
+	}
+	return s.monitorKeys.get(defaultMonitorInterval, func() int64 {
+		return int64(s.ForEach(func(_, _ string) bool { return true }))
+	})
+}
+
+// Key count reused until it is older than the caller allows
+type cachedKeyCount struct {
+	mu    sync.Mutex
+	count int64
+	at    time.Time
+}
+
+func (c *cachedKeyCount) get(maxAge time.Duration, count func() int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.at.IsZero() || time.Since(c.at) >= maxAge {
+		c.count = count()
+		c.at = time.Now()
+	}
+	return c.count
+}
+
+// Start counting reads for a monitor stream
+func (s *KVStoreService) watchGets() *hotKeyCounter {
+	h := &hotKeyCounter{counts: make(map[string]int64, monitorHotKeySlots), slots: monitorHotKeySlots}
+	s.monitorMu.Lock()
+	defer s.monitorMu.Unlock()
+	if s.monitorCounters == nil {
+		s.monitorCounters = make(map[*hotKeyCounter]struct{})
+	}
+	s.monitorCounters[h] = struct{}{}
+	return h
+}
+
+func (s *KVStoreService) unwatchGets(h *hotKeyCounter) {
+	s.monitorMu.Lock()
+	defer s.monitorMu.Unlock()
+	delete(s.monitorCounters, h)
+}
+
+// Count a read for every open monitor stream
+func (s *KVStoreService) countMonitorGet(key string) {
+	s.monitorMu.RLock()
+	defer s.monitorMu.RUnlock()
+	for h := range s.monitorCounters {
+		h.add(key)
+	}
+}
+
+// Approximate read counts for the most read keys in bounded memory, using
+// the Space-Saving algorithm: once every slot is taken, a new key replaces
+// the least read one and inherits its count, so a key read more often than
+// 1/slots of all reads is never missed
+type hotKeyCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	slots  int
+}
+
+func (h *hotKeyCounter) add(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.counts[key]; ok || len(h.counts) < h.slots {
+		h.counts[key]++
+		return
+	}
+	minKey, minCount := "", int64(0)
+	for k, n := range h.counts {
+		if minKey == "" || n < minCount {
+			minKey, minCount = k, n
+		}
+	}
+	delete(h.counts, minKey)
+	h.counts[key] = minCount + 1
+}
+
+// Most read n keys since the previous take, which starts a new count
+func (h *hotKeyCounter) take(n int) []*pb.HotKeyCount {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	hot := make([]*pb.HotKeyCount, 0, len(h.counts))
+	for key, count := range h.counts {
+		hot = append(hot, &pb.HotKeyCount{Key: key, AccessCount: count})
+	}
+	clear(h.counts)
+	h.mu.Unlock()
+
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].AccessCount != hot[j].AccessCount {
+			return hot[i].AccessCount > hot[j].AccessCount
+		}
+		return hot[i].Key < hot[j].Key
+	})
+	if len(hot) > n {
+		hot = hot[:n]
+	}
+	return hot
+}
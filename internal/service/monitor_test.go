@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHotKeyCounterFindsHotKeysInBoundedMemory(t *testing.T) {
+	h := &hotKeyCounter{counts: make(map[string]int64), slots: 20}
+	for i := range 10000 {
+		h.add(fmt.Sprintf("cold:%d", i))
+		if i%4 == 0 {
+			h.add("hot:a")
+		}
+		if i%5 == 0 {
+			h.add("hot:b")
+		}
+		if n := len(h.counts); n > h.slots {
+			t.Fatalf("counting %d keys, want at most %d", n, h.slots)
+		}
+	}
+
+	hot := h.take(2)
+	if len(hot) != 2 || hot[0].Key != "hot:a" || hot[1].Key != "hot:b" {
+		t.Fatalf("hot keys = %v, want hot:a then hot:b", hot)
+	}
+	if hot[0].AccessCount < 2500 {
+		t.Errorf("hot:a counted %d reads, want at least 2500", hot[0].AccessCount)
+	}
+	if rest := h.take(2); len(rest) != 0 {
+		t.Errorf("take after take = %v, want nothing until more reads", rest)
+	}
+}
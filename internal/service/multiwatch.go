@@ -0,0 +1,199 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Most patterns one MultiWatch stream may watch
+const maxMultiWatchPatterns = 100
+
+// Select cases of a MultiWatch loop before the one per pattern
+const (
+	multiWatchStreamDone = iota
+	multiWatchServiceDone
+	multiWatchRequest
+	multiWatchRecvErr
+	multiWatchFirstPattern
+)
+
+// Stream changes for every pattern the client asks for over one stream. Each
+// pattern is registered as its own subscriber, and a single loop forwards
+// events from all of them, tagged with the pattern they matched. Events of
+// one pattern arrive in order, those of different patterns may interleave
+// out of sequence order. Requests after the first replace the set of patterns
+func (s *KVStoreService) MultiWatch(stream pb.KeyValueStore_MultiWatchServer) error {
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(first.Patterns) == 0 {
+		return status.Error(codes.InvalidArgument, "patterns cannot be empty")
+	}
+
+	s.mu.RLock()
+	draining := s.draining
+	s.mu.RUnlock()
+	if draining {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	untrack, err := s.trackStream()
+	if err != nil {
+		return err
+	}
+	defer untrack()
+
+	subs := make(map[string]*subscriber)
+	defer func() {
+		for _, sub := range subs {
+			s.dropSubscriber(sub)
+		}
+	}()
+	if err := s.setMultiWatchPatterns(subs, first.Patterns); err != nil {
+		return err
+	}
+
+	// Receive requests separately so events keep flowing between them
+	ctx := stream.Context()
+	requests := make(chan *pb.MultiWatchRequest)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// The number of patterns changes, so the cases are built with reflect
+	// and rebuilt after every request
+	var cases []reflect.SelectCase
+	var watched []*subscriber
+	rebuild := func() {
+		cases = append(cases[:0],
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.done)},
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(requests)},
+			reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(recvErr)},
+		)
+		watched = watched[:0]
+		for _, sub := range subs {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(sub.events)})
+			watched = append(watched, sub)
+		}
+	}
+	rebuild()
+
+	// A shutdown notice reaches every pattern's subscriber but is sent once
+	announced := false
+	for {
+		chosen, value, _ := reflect.Select(cases)
+		switch chosen {
+		case multiWatchStreamDone:
+			slog.Info("multi watch stream closed by client", "pattern_count", len(subs))
+			return nil
+		case multiWatchServiceDone:
+			slog.Info("multi watch ended, service closing", "pattern_count", len(subs))
+			return status.Error(codes.Unavailable, "service is closing")
+		case multiWatchRequest:
+			if err := s.setMultiWatchPatterns(subs, value.Interface().(*pb.MultiWatchRequest).Patterns); err != nil {
+				return err
+			}
+			rebuild()
+			continue
+		case multiWatchRecvErr:
+			if err := value.Interface().(error); !errors.Is(err, io.EOF) {
+				return err
+			}
+			// The client is done changing patterns but still reading
+			cases[multiWatchRecvErr].Chan = reflect.ValueOf((chan error)(nil))
+			continue
+		}
+
+		sub := watched[chosen-multiWatchFirstPattern]
+		event := value.Interface().(*pb.ChangeEvent)
+		if s.takeFlushMarker(event) {
+			continue
+		}
+		if event.ChangeType == pb.ChangeEvent_SERVER_SHUTDOWN {
+			if announced {
+				continue
+			}
+			announced = true
+		} else {
+			// Events are shared with other subscribers, so tag a copy
+			event = proto.Clone(event).(*pb.ChangeEvent)
+			event.MatchedPattern = sub.pattern
+		}
+		if err := stream.Send(event); err != nil {
+			slog.Error("failed to send event to multi watch", "pattern", sub.pattern, "error", err)
+			return err
+		}
+	}
+}
+
+// Register a subscriber for each of patterns missing from subs and drop
+// those of patterns no longer wanted. Nothing changes if a pattern is invalid
+func (s *KVStoreService) setMultiWatchPatterns(subs map[string]*subscriber, patterns []string) error {
+	if len(patterns) > maxMultiWatchPatterns {
+		return status.Errorf(codes.InvalidArgument, "patterns cannot have more than %d entries", maxMultiWatchPatterns)
+	}
+	wanted := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" {
+			return status.Error(codes.InvalidArgument, "patterns cannot contain an empty pattern")
+		}
+		pattern, err := s.normalizeKey(pattern)
+		if err != nil {
+			return err
+		}
+		wanted[pattern] = true
+	}
+
+	var added, removed []string
+	for pattern, sub := range subs {
+		if !wanted[pattern] {
+			s.dropSubscriber(sub)
+			delete(subs, pattern)
+			removed = append(removed, pattern)
+		}
+	}
+	for pattern := range wanted {
+		if subs[pattern] != nil {
+			continue
+		}
+		sub := &subscriber{
+			pattern:    pattern,
+			events:     make(chan *pb.ChangeEvent, subscriberBuffer),
+			multiWatch: true,
+		}
+		s.addSubscriber(sub)
+		subs[pattern] = sub
+		added = append(added, pattern)
+	}
+	slog.Info("multi watch patterns updated", "added", added, "removed", removed, "pattern_count", len(subs))
+	return nil
+}
+
+// Report whether events go to a client stream rather than an internal watcher
+func (sub *subscriber) hasClient() bool {
+	return sub.stream != nil || sub.multiWatch
+}
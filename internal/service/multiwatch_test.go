@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Serve s over an in-process connection, closed when the test ends
+func newTestClient(t *testing.T, s *KVStoreService) pb.KeyValueStoreClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterKeyValueStoreServer(srv, s)
+	go srv.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+	})
+	return pb.NewKeyValueStoreClient(conn)
+}
+
+func TestMultiWatchDeliversEveryPattern(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	patterns := []string{"users:", "orders:", "carts:", "sessions:", "config:"}
+	stream, err := kv.MultiWatch(ctx)
+	if err != nil {
+		t.Fatalf("MultiWatch: %v", err)
+	}
+	if err := stream.Send(&pb.MultiWatchRequest{Patterns: patterns}); err != nil {
+		t.Fatalf("send patterns: %v", err)
+	}
+
+	// Writes made before every pattern is registered would not be seen
+	for {
+		s.mu.RLock()
+		registered := 0
+		for _, pattern := range patterns {
+			registered += len(s.subscribers[pattern])
+		}
+		s.mu.RUnlock()
+		if registered == len(patterns) {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("only %d of %d patterns registered", registered, len(patterns))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for i, pattern := range patterns {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: fmt.Sprintf("%s%d", pattern, i), Value: "v"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	seen := make(map[string]string)
+	for range patterns {
+		event, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv after %d events: %v", len(seen), err)
+		}
+		seen[event.MatchedPattern] = event.Key
+	}
+	for i, pattern := range patterns {
+		if want := fmt.Sprintf("%s%d", pattern, i); seen[pattern] != want {
+			t.Errorf("pattern %s delivered %q, want %q", pattern, seen[pattern], want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Pre-built key normalizers
+var (
+	KeyNormalizerLowercase = strings.ToLower
+	KeyNormalizerTrimSpace = strings.TrimSpace
+)
+
+// Combine normalizers, applied left to right
+func KeyNormalizerChain(fns ...func(string) string) func(string) string {
+	return func(key string) string {
+		for _, fn := range fns {
+			key = fn(key)
+		}
+		return key
+	}
+}
+
+// Rewrite every key and subscription pattern before any other processing
+func WithKeyNormalizer(fn func(string) string) Option {
+	return func(s *KVStoreService) {
+		s.keyNormalizer = fn
+	}
+}
+
+// Apply the configured normalizer to a non-empty key
+func (s *KVStoreService) normalizeKey(key string) (string, error) {
+	if s.keyNormalizer == nil {
+		return key, nil
+	}
+	normalized := s.keyNormalizer(key)
+	if normalized == "" {
+		return "", status.Errorf(codes.InvalidArgument, "key %q is empty after normalization", key)
+	}
+	return normalized, nil
+}
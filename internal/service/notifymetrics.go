@@ -0,0 +1,51 @@
+package service
+
+import (
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+)
+
+// Record how long each pattern's subscribers take to be notified and how
+// many events they were sent or dropped. Off by default since it adds clock
+// reads and counter updates under the subscriber lock on every event
+func WithNotifyMetrics() Option {
+	return func(s *KVStoreService) {
+		s.notifyMetrics = true
+	}
+}
+
+// Start of a pattern's notify loop, zero when notify metrics are off
+func (s *KVStoreService) notifyLoopStart() time.Time {
+	if !s.notifyMetrics {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// Record a pattern's notify loop that began at start
+func (s *KVStoreService) observeNotifyLoop(pattern string, start time.Time) {
+	if start.IsZero() {
+		return
+	}
+	metrics.NotifyLoopDuration.WithLabelValues(pattern).Observe(time.Since(start).Seconds())
+}
+
+// Count an attempt to queue an event for a subscriber of pattern
+func (s *KVStoreService) countEnqueue(pattern string, queued bool) {
+	if !s.notifyMetrics {
+		return
+	}
+	result := "dropped"
+	if queued {
+		result = "success"
+	}
+	metrics.EventEnqueueAttempts.WithLabelValues(pattern, result).Inc()
+}
+
+// Drop the notify metrics of a pattern nobody subscribes to anymore
+func forgetNotifyMetrics(pattern string) {
+	metrics.NotifyLoopDuration.DeleteLabelValues(pattern)
+	metrics.EventEnqueueAttempts.DeleteLabelValues(pattern, "success")
+	metrics.EventEnqueueAttempts.DeleteLabelValues(pattern, "dropped")
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	"github.com/amillerrr/distributed-kv-store/internal/eventlog"
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+)
+
+// Configure optional KVStoreService behavior
+type Option func(*KVStoreService)
+
+// Log a sample of requests at debug level, rate is clamped to [0, 1]
+func WithDebugSampling(rate float64) Option {
+	return func(s *KVStoreService) {
+		s.sampleRate = min(max(rate, 0), 1)
+	}
+}
+
+// Include values in sampled request logs
+func WithLogValues(enabled bool) Option {
+	return func(s *KVStoreService) {
+		s.logValues = enabled
+	}
+}
+
+// Reject values larger than n bytes, 0 disables the limit
+func WithMaxValueSize(n int) Option {
+	return func(s *KVStoreService) {
+		s.maxValueSize = n
+	}
+}
+
+// Keep data in backend instead of memory, the service closes it on Close
+func WithStorage(backend storage.Backend) Option {
+	return func(s *KVStoreService) {
+		s.store = backend
+	}
+}
+
+// Apply every service setting from a server configuration
+func WithConfig(cfg *config.ServerConfig) Option {
+	return func(s *KVStoreService) {
+		WithMaxValueSize(cfg.MaxValueSizeBytes())(s)
+		WithMaxZSetSize(cfg.ZSetMaxSize)(s)
+		WithMaxZSets(cfg.ZSetMaxSets)(s)
+		WithDebugSampling(cfg.DebugSampleRate)(s)
+		WithLogValues(cfg.DebugLogValues)(s)
+		WithEventHistory(cfg.EventHistorySize)(s)
+		if cfg.HistoryCompactRetainLast > 0 {
+			WithHistoryAutoCompact(cfg.HistoryCompactRetainLast, cfg.HistoryCompactInterval)(s)
+		}
+		WithNodeID(cfg.NodeID)(s)
+		WithUpstreamFillTTL(cfg.UpstreamFillTTL)(s)
+		WithScanSessionTTL(cfg.ScanSessionTTL)(s)
+		WithPrefixStatsCacheTTL(cfg.PrefixStatsCacheTTL)(s)
+		if cfg.SeedFile != "" || cfg.SeedExternal {
+			WithStartupGate()(s)
+		}
+		if cfg.SeedOverwrite {
+			WithSeedOverwrite()(s)
+		}
+		if cfg.NotifyMetricsEnabled {
+			WithNotifyMetrics()(s)
+		}
+		if cfg.SubscriberLazyChannels {
+			WithLazyChannelInit()(s)
+		}
+		if cfg.SubscriberChannelPoolSize > 0 {
+			WithSubscriberChannelPooling(cfg.SubscriberChannelPoolSize)(s)
+		}
+		if cfg.SubscriberLockFree {
+			WithLockFreeSubscribers()(s)
+		}
+		if cfg.KeyEventRateLimit > 0 {
+			WithKeyEventRateLimit(cfg.KeyEventRateLimit, cfg.KeyEventRateBurst)(s)
+		}
+		if cfg.LoadShedThreshold > 0 {
+			WithLoadShedding(cfg.LoadShedThreshold)(s)
+		}
+		if cfg.StoreMetricsEnabled {
+			WithSyncMapMetrics(prometheus.DefaultRegisterer)(s)
+		}
+		if cfg.SequenceFile != "" {
+			WithSequenceFile(cfg.SequenceFile, cfg.SequencePersistInterval)(s)
+		}
+		if cfg.HotKeyTopN > 0 {
+			WithHotKeyTracking(cfg.HotKeyTopN, cfg.HotKeyInterval)(s)
+		}
+		if cfg.IdleKeyAfter > 0 {
+			WithIdleKeyTracking(cfg.IdleKeyAfter)(s)
+			if cfg.IdleKeyDelete {
+				WithDeleteIdleKeys()(s)
+			}
+		}
+		if cfg.EventLogPath != "" {
+			format := eventlog.FormatJSON
+			if cfg.EventLogFormat == config.EventLogProto {
+				format = eventlog.FormatProto
+			}
+			WithEventLog(cfg.EventLogPath, eventlog.WithFormat(format))(s)
+		}
+		WithAuditBufferSize(cfg.AuditBufferSize)(s)
+		if cfg.AuditWebhookURL != "" {
+			WithAuditWebhook(cfg.AuditWebhookURL, cfg.AuditWebhookBatchSize, cfg.AuditWebhookFlushInterval)(s)
+			if name, value, ok := cfg.AuditWebhookHeader(); ok {
+				WithAuditWebhookAuthHeader(name, value)(s)
+			}
+		}
+		if cfg.AuditSyslogAddr != "" {
+			WithAuditSyslog(cfg.AuditSyslogNetwork, cfg.AuditSyslogAddr)(s)
+		}
+		if len(cfg.KeyNormalizers) > 0 {
+			var fns []func(string) string
+			for _, name := range cfg.KeyNormalizers {
+				switch name {
+				case config.NormalizeLowercase:
+					fns = append(fns, KeyNormalizerLowercase)
+				case config.NormalizeTrimSpace:
+					fns = append(fns, KeyNormalizerTrimSpace)
+				}
+			}
+			WithKeyNormalizer(KeyNormalizerChain(fns...))(s)
+		}
+	}
+}
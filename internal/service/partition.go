@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Aggregate figures for a single partition
+type PartitionStats struct {
+	Partition  string `json:"partition"`
+	KeyCount   int64  `json:"key_count"`
+	ValueBytes int64  `json:"value_bytes"`
+}
+
+// Retrieve all k/v pairs sharing the partition prefix
+func (s *KVStoreService) Partition(ctx context.Context, req *pb.PartitionRequest) (*pb.PartitionResponse, error) {
+	partition := normalizePartition(req.Prefix)
+	if partition == "" {
+		slog.Warn("partition request with empty prefix")
+		return nil, status.Error(codes.InvalidArgument, "prefix cannot be empty")
+	}
+	partition, err := s.normalizeKey(partition)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("partition request", "partition", partition)
+
+	var pairs []*pb.KeyValuePair
+	s.ForEach(func(key, value string) bool {
+		if inPartition(key, partition) {
+			pairs = append(pairs, &pb.KeyValuePair{Key: key, Value: value})
+		}
+		return true
+	})
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	s.recordGets(pairs)
+
+	slog.Info("partition retrieved", "partition", partition, "key_count", len(pairs))
+	return &pb.PartitionResponse{
+		Partition: partition,
+		Pairs:     pairs,
+	}, nil
+}
+
+// Delete every key in the partition atomically
+func (s *KVStoreService) DeletePartition(ctx context.Context, req *pb.DeletePartitionRequest) (*pb.DeletePartitionResponse, error) {
+	partition := normalizePartition(req.Prefix)
+	if partition == "" {
+		slog.Warn("delete partition request with empty prefix")
+		return nil, status.Error(codes.InvalidArgument, "prefix cannot be empty")
+	}
+	partition, err := s.normalizeKey(partition)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("delete partition request", "partition", partition)
+
+	// Exclusive lock so no single-key write interleaves with the deletion
+	var deleted []string
+	metas := make(map[string]map[string]string)
+	timestamps := make(map[string]int64)
+	s.withStoreWrite(func() {
+		s.store.Range(func(key, _ string) bool {
+			if inPartition(key, partition) {
+				deleted = append(deleted, key)
+			}
+			return true
+		})
+		for _, key := range deleted {
+			s.store.Delete(key)
+			s.clearTTL(key)
+			s.forgetVersion(key)
+			s.forgetStats(key)
+			if meta := s.meta.remove(key); meta != nil {
+				metas[key] = meta
+			}
+			timestamps[key] = s.stampWrite(key, true)
+		}
+		now := time.Now().UnixNano()
+		for _, key := range s.zsets.keys(func(key string) bool { return inPartition(key, partition) }) {
+			s.zsets.drop(key)
+			// A key holding both a value and a set is announced once
+			if _, ok := timestamps[key]; !ok {
+				deleted = append(deleted, key)
+				timestamps[key] = now
+			}
+		}
+	})
+
+	for _, key := range deleted {
+		s.notifySubscribers(ctx, &pb.ChangeEvent{
+			ChangeType: pb.ChangeEvent_DELETE,
+			Key:        key,
+			Timestamp:  timestamps[key],
+			Meta:       metas[key],
+		})
+	}
+
+	slog.Info("partition deleted", "partition", partition, "deleted_count", len(deleted))
+	return &pb.DeletePartitionResponse{
+		DeletedCount: int64(len(deleted)),
+	}, nil
+}
+
+// Compute key count and value size for a partition
+func (s *KVStoreService) PartitionStats(prefix string) PartitionStats {
+	stats := PartitionStats{Partition: normalizePartition(prefix)}
+	if stats.Partition == "" {
+		return stats
+	}
+	// Normalized like Partition so both report the same keys
+	partition, err := s.normalizeKey(stats.Partition)
+	if err != nil {
+		return PartitionStats{}
+	}
+	stats.Partition = partition
+
+	s.ForEach(func(key, value string) bool {
+		if inPartition(key, stats.Partition) {
+			stats.KeyCount++
+			stats.ValueBytes += int64(len(value))
+		}
+		return true
+	})
+
+	return stats
+}
+
+// Strip surrounding whitespace and a trailing delimiter
+func normalizePartition(prefix string) string {
+	return strings.TrimSuffix(strings.TrimSpace(prefix), ":")
+}
+
+// Report whether key belongs to the colon-delimited partition
+func inPartition(key, partition string) bool {
+	return key == partition || strings.HasPrefix(key, partition+":")
+}
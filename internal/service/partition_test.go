@@ -0,0 +1,41 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestPartitionStatsNormalizesPrefix(t *testing.T) {
+	s := newTestService(t, WithKeyNormalizer(strings.ToLower))
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "User:1", Value: "abc"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	resp, err := s.Partition(ctx, &pb.PartitionRequest{Prefix: "User"})
+	if err != nil {
+		t.Fatalf("Partition: %v", err)
+	}
+	stats := s.PartitionStats("User")
+	if stats.Partition != "user" || stats.KeyCount != int64(len(resp.Pairs)) || stats.KeyCount != 1 || stats.ValueBytes != 3 {
+		t.Errorf("PartitionStats = %+v, Partition returned %d keys", stats, len(resp.Pairs))
+	}
+}
+
+func TestPartitionCountsReads(t *testing.T) {
+	s := newTestService(t, WithKeyStats())
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "user:1", Value: "v"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if _, err := s.Partition(ctx, &pb.PartitionRequest{Prefix: "user"}); err != nil {
+		t.Fatalf("Partition: %v", err)
+	}
+	if n := s.statsFor("user:1").getCount.Load(); n != 1 {
+		t.Errorf("get count after Partition = %d, want 1", n)
+	}
+}
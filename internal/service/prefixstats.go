@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Most prefixes one PrefixStats request may name
+	maxStatsPrefixes = 100
+
+	defaultPrefixStatsCacheTTL = 5 * time.Second
+)
+
+// Reuse PrefixStats results for d before walking the keys again, 0 disables
+// the cache
+func WithPrefixStatsCacheTTL(d time.Duration) Option {
+	return func(s *KVStoreService) {
+		s.prefixStats.ttl = d
+	}
+}
+
+// Recent PrefixStats results by the prefixes they were computed for
+type prefixStatsCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*pb.PrefixStatsResponse
+}
+
+func newPrefixStatsCache() *prefixStatsCache {
+	return &prefixStatsCache{
+		ttl:     defaultPrefixStatsCacheTTL,
+		entries: make(map[string]*pb.PrefixStatsResponse),
+	}
+}
+
+// Cached result for id, nil if there is none or it has expired
+func (c *prefixStatsCache) get(id string, now time.Time) *pb.PrefixStatsResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	resp := c.entries[id]
+	if resp == nil || now.Sub(time.UnixMilli(resp.ComputedAtMs)) >= c.ttl {
+		return nil
+	}
+	return resp
+}
+
+// Remember resp for id, dropping expired results so the cache stays small
+func (c *prefixStatsCache) put(id string, resp *pb.PrefixStatsResponse, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for other, cached := range c.entries {
+		if now.Sub(time.UnixMilli(cached.ComputedAtMs)) >= c.ttl {
+			delete(c.entries, other)
+		}
+	}
+	c.entries[id] = resp
+}
+
+// Report key count, sizes and write times for each prefix, or for each
+// top-level namespace when no prefixes are given
+func (s *KVStoreService) PrefixStats(ctx context.Context, req *pb.PrefixStatsRequest) (*pb.PrefixStatsResponse, error) {
+	if len(req.Prefixes) > maxStatsPrefixes {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d prefixes may be requested at once", maxStatsPrefixes)
+	}
+	prefixes := make([]string, 0, len(req.Prefixes))
+	for _, prefix := range req.Prefixes {
+		if prefix == "" {
+			return nil, status.Error(codes.InvalidArgument, "prefixes cannot contain an empty prefix")
+		}
+		normalized, err := s.normalizeKey(prefix)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, normalized)
+	}
+	slices.Sort(prefixes)
+	prefixes = slices.Compact(prefixes)
+
+	// Quoted so the list is unambiguous whatever the prefixes contain
+	id := fmt.Sprintf("%q", prefixes)
+	now := time.Now()
+	cache := s.prefixStats.ttl > 0
+	if cache {
+		if resp := s.prefixStats.get(id, now); resp != nil {
+			return resp, nil
+		}
+	}
+
+	resp := &pb.PrefixStatsResponse{
+		Stats:        make(map[string]*pb.PrefixStat),
+		ComputedAtMs: now.UnixMilli(),
+	}
+	for _, prefix := range prefixes {
+		resp.Stats[prefix] = &pb.PrefixStat{}
+	}
+	visited := s.ForEach(func(key, value string) bool {
+		if len(prefixes) == 0 {
+			// Keys without a ':' are grouped under the empty prefix
+			namespace, _, found := strings.Cut(key, ":")
+			if found {
+				namespace += ":"
+			} else {
+				namespace = ""
+			}
+			stat := resp.Stats[namespace]
+			if stat == nil {
+				stat = &pb.PrefixStat{}
+				resp.Stats[namespace] = stat
+			}
+			s.addPrefixStat(stat, key, value)
+			return true
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				s.addPrefixStat(resp.Stats[prefix], key, value)
+			}
+		}
+		return true
+	})
+
+	if cache {
+		s.prefixStats.put(id, resp, now)
+	}
+	slog.Info("prefix stats computed", "prefix_count", len(resp.Stats), "keys_visited", visited, "duration", time.Since(now))
+	return resp, nil
+}
+
+// Count one key towards a prefix
+func (s *KVStoreService) addPrefixStat(stat *pb.PrefixStat, key, value string) {
+	stat.KeyCount++
+	stat.TotalKeySizeBytes += int64(len(key))
+	stat.TotalValueSizeBytes += int64(len(value))
+
+	stats, ok := s.KeyStats(key)
+	if !ok || stats.LastModifiedMs == 0 {
+		return
+	}
+	if stat.OldestModifiedMs == 0 || stats.LastModifiedMs < stat.OldestModifiedMs {
+		stat.OldestModifiedMs = stats.LastModifiedMs
+	}
+	stat.NewestModifiedMs = max(stat.NewestModifiedMs, stats.LastModifiedMs)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestPrefixStatsDetectsNamespaces(t *testing.T) {
+	s := newTestService(t, WithPrefixStatsCacheTTL(0))
+	setValues(t, s, map[string]string{
+		"user:1": "ab", "user:2": "cde", "order:1": "f", "plain": "gh",
+	})
+
+	resp, err := s.PrefixStats(context.Background(), &pb.PrefixStatsRequest{})
+	if err != nil {
+		t.Fatalf("PrefixStats: %v", err)
+	}
+	want := map[string]*pb.PrefixStat{
+		"user:":  {KeyCount: 2, TotalKeySizeBytes: 12, TotalValueSizeBytes: 5},
+		"order:": {KeyCount: 1, TotalKeySizeBytes: 7, TotalValueSizeBytes: 1},
+		"":       {KeyCount: 1, TotalKeySizeBytes: 5, TotalValueSizeBytes: 2},
+	}
+	if len(resp.Stats) != len(want) {
+		t.Errorf("namespaces = %v, want %d", resp.Stats, len(want))
+	}
+	for prefix, w := range want {
+		got := resp.Stats[prefix]
+		if got.GetKeyCount() != w.KeyCount || got.GetTotalKeySizeBytes() != w.TotalKeySizeBytes || got.GetTotalValueSizeBytes() != w.TotalValueSizeBytes {
+			t.Errorf("%q = %v, want %v", prefix, got, w)
+		}
+	}
+}
+
+func TestPrefixStatsForRequestedPrefixes(t *testing.T) {
+	s := newTestService(t, WithPrefixStatsCacheTTL(0), WithKeyStats())
+	setValues(t, s, map[string]string{"user:1": "a", "user:10": "b", "user:2": "c"})
+
+	resp, err := s.PrefixStats(context.Background(), &pb.PrefixStatsRequest{Prefixes: []string{"user:1", "user:", "user:1", "cart:"}})
+	if err != nil {
+		t.Fatalf("PrefixStats: %v", err)
+	}
+	got := make(map[string]int64)
+	for prefix, stat := range resp.Stats {
+		got[prefix] = stat.KeyCount
+	}
+	// Overlapping prefixes each count the key, and unmatched ones are reported empty
+	want := map[string]int64{"user:": 3, "user:1": 2, "cart:": 0}
+	if len(got) != len(want) {
+		t.Errorf("stats for %v, want %v", got, want)
+	}
+	for prefix, n := range want {
+		if got[prefix] != n {
+			t.Errorf("%q has %d keys, want %d", prefix, got[prefix], n)
+		}
+	}
+
+	user := resp.Stats["user:"]
+	if user.OldestModifiedMs == 0 || user.NewestModifiedMs < user.OldestModifiedMs {
+		t.Errorf("modified range = %d to %d, want both set with key stats on", user.OldestModifiedMs, user.NewestModifiedMs)
+	}
+}
+
+func TestPrefixStatsCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wait      time.Duration
+		wantCount int64
+	}{
+		{"cached", time.Hour, 0, 1},
+		{"expired", 20 * time.Millisecond, 40 * time.Millisecond, 2},
+		{"disabled", 0, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, WithPrefixStatsCacheTTL(tt.ttl))
+			ctx := context.Background()
+			req := &pb.PrefixStatsRequest{Prefixes: []string{"user:"}}
+			setValues(t, s, map[string]string{"user:1": "a"})
+
+			if _, err := s.PrefixStats(ctx, req); err != nil {
+				t.Fatalf("PrefixStats: %v", err)
+			}
+			setValues(t, s, map[string]string{"user:2": "b"})
+			time.Sleep(tt.wait)
+
+			resp, err := s.PrefixStats(ctx, req)
+			if err != nil {
+				t.Fatalf("PrefixStats: %v", err)
+			}
+			if n := resp.Stats["user:"].KeyCount; n != tt.wantCount {
+				t.Errorf("key count = %d, want %d", n, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestPrefixStatsCacheKeyedByPrefixes(t *testing.T) {
+	s := newTestService(t, WithPrefixStatsCacheTTL(time.Hour))
+	ctx := context.Background()
+	setValues(t, s, map[string]string{"user:1": "a", "order:1": "b"})
+
+	first, err := s.PrefixStats(ctx, &pb.PrefixStatsRequest{Prefixes: []string{"user:", "order:"}})
+	if err != nil {
+		t.Fatalf("PrefixStats: %v", err)
+	}
+	// The same set in another order shares the entry, a different set does not
+	same, _ := s.PrefixStats(ctx, &pb.PrefixStatsRequest{Prefixes: []string{"order:", "user:"}})
+	other, _ := s.PrefixStats(ctx, &pb.PrefixStatsRequest{Prefixes: []string{"user:"}})
+	if same != first {
+		t.Error("reordered prefixes were computed again")
+	}
+	if other == first || !slices.Equal(slices.Sorted(maps.Keys(other.Stats)), []string{"user:"}) {
+		t.Errorf("different prefixes returned %v", slices.Sorted(maps.Keys(other.Stats)))
+	}
+}
+
+func TestPrefixStatsRejectsBadPrefixes(t *testing.T) {
+	s := newTestService(t)
+	tests := []struct {
+		name     string
+		prefixes []string
+	}{
+		{"empty prefix", []string{"user:", ""}},
+		{"too many", slices.Repeat([]string{"p:"}, maxStatsPrefixes+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.PrefixStats(context.Background(), &pb.PrefixStatsRequest{Prefixes: tt.prefixes})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("err = %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
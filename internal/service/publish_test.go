@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Offered load of BenchmarkPublishPaced
+const pacedSetsPerSec = 100_000
+
+// Publish modes compared by the benchmarks
+var publishModes = []struct {
+	name string
+	opts []Option
+}{
+	{"mutex", nil},
+	{"lockfree", []Option{WithLockFreeSubscribers()}},
+}
+
+func TestLockFreePublishing(t *testing.T) {
+	s := newTestService(t, WithLockFreeSubscribers())
+	events := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "user:"})
+
+	if _, err := s.Set(context.Background(), &pb.SetRequest{Key: "user:1", Value: "v"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if event := nextEvent(t, events); event.Key != "user:1" {
+		t.Errorf("got event for %q, want user:1", event.Key)
+	}
+}
+
+// Sets fanned out to 1000 draining subscribers as fast as they go, with and
+// without another goroutine subscribing and unsubscribing throughout. Reader
+// contention on mu needs several Ps, run with e.g. -cpu 4,8
+func BenchmarkPublish(b *testing.B) {
+	// Registrations log, which would dominate the churn case
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for _, mode := range publishModes {
+		b.Run(mode.name+"/steady", func(b *testing.B) { benchmarkPublish(b, mode.opts, false, 0) })
+		b.Run(mode.name+"/churn", func(b *testing.B) { benchmarkPublish(b, mode.opts, true, 0) })
+	}
+}
+
+// As BenchmarkPublish, but Sets are issued on a schedule of 100k per second
+// and their latency is measured from when they were due, so a stalled
+// publisher shows up in p99-us rather than as a lower rate
+func BenchmarkPublishPaced(b *testing.B) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for _, mode := range publishModes {
+		b.Run(mode.name+"/steady", func(b *testing.B) { benchmarkPublish(b, mode.opts, false, pacedSetsPerSec) })
+		b.Run(mode.name+"/churn", func(b *testing.B) { benchmarkPublish(b, mode.opts, true, pacedSetsPerSec) })
+	}
+}
+
+// Run b.N Sets against 1000 subscribers, paced at rate per second if set
+func benchmarkPublish(b *testing.B, opts []Option, churn bool, rate int) {
+	const subscribers = 1000
+	s := NewKVStoreService(opts...)
+	defer s.Close()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	drain := func(sub *subscriber) {
+		defer wg.Done()
+		for {
+			select {
+			case <-sub.events:
+			case <-stop:
+				return
+			}
+		}
+	}
+	for i := range subscribers {
+		sub := &subscriber{pattern: "bench:" + strconv.Itoa(i%10)}
+		s.initEvents(sub)
+		s.addSubscriber(sub)
+		wg.Add(1)
+		go drain(sub)
+	}
+	if churn {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				sub := &subscriber{pattern: "churn:"}
+				s.initEvents(sub)
+				s.addSubscriber(sub)
+				s.dropSubscriber(sub)
+			}
+		}()
+	}
+	defer func() {
+		close(stop)
+		wg.Wait()
+	}()
+
+	ctx := context.Background()
+	if rate == 0 {
+		b.ResetTimer()
+		b.RunParallel(func(p *testing.PB) {
+			req := &pb.SetRequest{Key: "bench:1", Value: "v"}
+			for p.Next() {
+				s.Set(ctx, req)
+			}
+		})
+		b.StopTimer()
+		return
+	}
+
+	// Enough workers that a few slow Sets do not hold the schedule back
+	const workers = 64
+	interval := time.Second / time.Duration(rate)
+	latencies := make([][]time.Duration, workers)
+	b.ResetTimer()
+	start := time.Now()
+	var sets sync.WaitGroup
+	for w := range workers {
+		sets.Add(1)
+		go func() {
+			defer sets.Done()
+			req := &pb.SetRequest{Key: "bench:1", Value: "v"}
+			for i := w; i < b.N; i += workers {
+				due := start.Add(time.Duration(i) * interval)
+				time.Sleep(time.Until(due))
+				s.Set(ctx, req)
+				latencies[w] = append(latencies[w], time.Since(due))
+			}
+		}()
+	}
+	sets.Wait()
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	all := slices.Concat(latencies...)
+	slices.Sort(all)
+	b.ReportMetric(float64(all[len(all)*99/100].Microseconds()), "p99-us")
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "sets/s")
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Largest count accepted by RandomKeys
+const maxRandomKeys = 10000
+
+// Sample count keys starting with prefix. Every matching key is visited while
+// writes continue, and at most count keys are held at a time
+func (s *KVStoreService) RandomKeys(ctx context.Context, req *pb.RandomKeysRequest) (*pb.RandomKeysResponse, error) {
+	if req.Count <= 0 || req.Count > maxRandomKeys {
+		return nil, status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxRandomKeys)
+	}
+	prefix := req.Prefix
+	if prefix != "" {
+		var err error
+		if prefix, err = s.normalizeKey(prefix); err != nil {
+			return nil, err
+		}
+	}
+	count := int(req.Count)
+
+	var keys []string
+	s.withStoreRead(func() {
+		if req.WithReplacement {
+			keys = s.sampleKeysWithReplacement(prefix, count)
+		} else {
+			keys = s.sampleKeys(prefix, count)
+		}
+	})
+
+	// Keys are collected in storage order, which may be sorted
+	rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+	slog.Info("random keys request", "prefix", prefix, "count", count, "with_replacement", req.WithReplacement, "key_count", len(keys))
+	return &pb.RandomKeysResponse{Keys: keys}, nil
+}
+
+// Choose count distinct keys with reservoir sampling (Knuth's Algorithm R),
+// or every key if there are fewer. Caller must hold storeMu
+func (s *KVStoreService) sampleKeys(prefix string, count int) []string {
+	reservoir := make([]string, 0, min(count, 64))
+	seen := 0
+	s.forEachKeyWithPrefix(prefix, func(key string) bool {
+		seen++
+		if len(reservoir) < count {
+			reservoir = append(reservoir, key)
+		} else if i := rand.IntN(seen); i < count {
+			reservoir[i] = key
+		}
+		return true
+	})
+	return reservoir
+}
+
+// Draw count keys independently. The keys are counted first, then the drawn
+// positions are picked up on a second pass, so only the positions are held.
+// The passes need not visit keys in the same order, as uniform positions
+// pick uniform keys in any order. Caller must hold storeMu
+func (s *KVStoreService) sampleKeysWithReplacement(prefix string, count int) []string {
+	total := 0
+	s.forEachKeyWithPrefix(prefix, func(string) bool {
+		total++
+		return true
+	})
+	if total == 0 {
+		return nil
+	}
+
+	positions := make([]int, count)
+	for i := range positions {
+		positions[i] = rand.IntN(total)
+	}
+	slices.Sort(positions)
+
+	keys := make([]string, 0, count)
+	pos := 0
+	s.forEachKeyWithPrefix(prefix, func(key string) bool {
+		// A key may have been drawn several times
+		for len(keys) < count && positions[len(keys)] == pos {
+			keys = append(keys, key)
+		}
+		pos++
+		return len(keys) < count
+	})
+	return keys
+}
+
+// Call fn for each unexpired key starting with prefix until it returns false.
+// A prefix is looked up in the backend's index when it has one, but the whole
+// keyspace is walked without it, as holding the index for millions of keys
+// would stall writes. Caller must hold storeMu
+func (s *KVStoreService) forEachKeyWithPrefix(prefix string, fn func(key string) bool) {
+	visit := func(key string) bool {
+		if s.isExpired(key) {
+			return true
+		}
+		return fn(key)
+	}
+	if prefix == "" {
+		s.store.Range(func(key, _ string) bool {
+			return visit(key)
+		})
+		return
+	}
+	s.rangeKeys(prefix, prefixEnd(prefix), visit)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestRandomKeysDistinct(t *testing.T) {
+	s := newTestService(t)
+	for i := range 100 {
+		s.store.Store(fmt.Sprintf("key:%03d", i), "v")
+	}
+	s.store.Store("other", "v")
+
+	resp, err := s.RandomKeys(context.Background(), &pb.RandomKeysRequest{Count: 10, Prefix: "key:"})
+	if err != nil {
+		t.Fatalf("RandomKeys: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, key := range resp.Keys {
+		if seen[key] || key == "other" {
+			t.Errorf("sample %v repeats a key or leaves the prefix", resp.Keys)
+			break
+		}
+		seen[key] = true
+	}
+	if len(resp.Keys) != 10 {
+		t.Errorf("got %d keys, want 10", len(resp.Keys))
+	}
+}
+
+// Sampling 10 keys from the whole keyspace, by the full scan RandomKeys makes
+// without a prefix and by walking the memory backend's B-tree index, as it
+// does for a prefix. Every key here starts with the prefix
+func BenchmarkRandomKeys(b *testing.B) {
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for _, n := range []int{10_000, 100_000, 1_000_000} {
+		s := NewKVStoreService()
+		for i := range n {
+			s.store.Store(fmt.Sprintf("key:%07d", i), "v")
+		}
+		for _, bc := range []struct{ name, prefix string }{{"scan", ""}, {"index", "key:"}} {
+			b.Run(fmt.Sprintf("%s/%d", bc.name, n), func(b *testing.B) {
+				req := &pb.RandomKeysRequest{Count: 10, Prefix: bc.prefix}
+				for b.Loop() {
+					if _, err := s.RandomKeys(context.Background(), req); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+		s.Close()
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Pairs returned by Range when the request sets no limit
+	defaultRangeLimit = 1000
+
+	// Largest limit accepted by Range
+	maxRangeLimit = 10000
+)
+
+// Retrieve pairs with keys in [start_key, end_key) in lexicographic order
+func (s *KVStoreService) Range(ctx context.Context, req *pb.RangeRequest) (*pb.RangeResponse, error) {
+	if req.Limit < 0 || req.Limit > maxRangeLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 0 and %d", maxRangeLimit)
+	}
+	start, end, err := s.normalizeRange(req.StartKey, req.EndKey)
+	if err != nil {
+		return nil, err
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultRangeLimit
+	}
+
+	resp := &pb.RangeResponse{}
+	collect := func(key, value string) bool {
+		if s.isExpired(key) {
+			return true
+		}
+		if len(resp.Pairs) == limit {
+			resp.Truncated = true
+			return false
+		}
+		resp.Pairs = append(resp.Pairs, &pb.KeyValuePair{Key: key, Value: value})
+		s.recordGet(key)
+		return true
+	}
+
+	// Backends with a sorted index serve the range directly
+	if ordered, ok := storage.As[interface {
+		RangeOrdered(start, end string, reverse bool, fn func(key, value string) bool)
+	}](s.store); ok {
+		s.withStoreRead(func() {
+			ordered.RangeOrdered(start, end, req.Reverse, collect)
+		})
+	} else {
+		var pairs []*pb.KeyValuePair
+		s.withStoreRead(func() {
+			s.store.Range(func(key, value string) bool {
+				if keyInRange(key, start, end) {
+					pairs = append(pairs, &pb.KeyValuePair{Key: key, Value: value})
+				}
+				return true
+			})
+		})
+
+		sort.Slice(pairs, func(i, j int) bool {
+			if req.Reverse {
+				return pairs[i].Key > pairs[j].Key
+			}
+			return pairs[i].Key < pairs[j].Key
+		})
+		for _, pair := range pairs {
+			if !collect(pair.Key, pair.Value) {
+				break
+			}
+		}
+	}
+
+	slog.Info("range request", "start_key", start, "end_key", end, "reverse", req.Reverse, "key_count", len(resp.Pairs), "truncated", resp.Truncated)
+	return resp, nil
+}
+
+// Normalize the bounds of a [start, end) range, either may be empty
+func (s *KVStoreService) normalizeRange(start, end string) (string, string, error) {
+	var err error
+	if start != "" {
+		if start, err = s.normalizeKey(start); err != nil {
+			return "", "", err
+		}
+	}
+	if end != "" {
+		if end, err = s.normalizeKey(end); err != nil {
+			return "", "", err
+		}
+		if start > end {
+			return "", "", status.Error(codes.InvalidArgument, "start_key must not be after end_key")
+		}
+	}
+	return start, end, nil
+}
+
+// Call fn for each key in [start, end), in key order only if the backend keeps
+// an index. Caller must hold storeMu
+func (s *KVStoreService) rangeKeys(start, end string, fn func(key string) bool) {
+	// Walking the index alone skips a map lookup per key
+	if index, ok := storage.As[interface {
+		KeysOrdered(start, end string, fn func(key string) bool)
+	}](s.store); ok {
+		index.KeysOrdered(start, end, fn)
+		return
+	}
+	if ordered, ok := storage.As[interface {
+		RangeOrdered(start, end string, reverse bool, fn func(key, value string) bool)
+	}](s.store); ok {
+		ordered.RangeOrdered(start, end, false, func(key, _ string) bool {
+			return fn(key)
+		})
+		return
+	}
+	s.store.Range(func(key, _ string) bool {
+		if keyInRange(key, start, end) {
+			return fn(key)
+		}
+		return true
+	})
+}
+
+// Report whether key falls in [start, end), end empty for no upper bound
+func keyInRange(key, start, end string) bool {
+	return key >= start && (end == "" || key < end)
+}
+
+// Report whether a subscription to keys starting with prefix can see any key
+// in [start, end)
+func rangeOverlapsPrefix(start, end, prefix string) bool {
+	// Keys with the prefix sort from prefix itself up to just below the next
+	// prefix of the same length, so they are all below start only if prefix
+	// sorts first and start does not extend it
+	if prefix < start && !strings.HasPrefix(start, prefix) {
+		return false
+	}
+	return end == "" || prefix < end
+}
+
+// Smallest key above every key starting with prefix, empty when there is none
+// or prefix is empty, so [prefix, prefixEnd(prefix)) holds exactly those keys
+func prefixEnd(prefix string) string {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			return prefix[:i] + string(prefix[i]+1)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+package service
+
+import "log/slog"
+
+// Report not ready until MarkReady is called, for a store that must be
+// seeded before it takes traffic. Requests are still served meanwhile so the
+// seed can be written through the API
+func WithStartupGate() Option {
+	return func(s *KVStoreService) {
+		s.startupGate = true
+	}
+}
+
+// Report whether the store has been seeded, always true without a startup gate
+func (s *KVStoreService) Ready() bool {
+	return s.ready.Load()
+}
+
+// Open the startup gate, reporting whether it was closed
+func (s *KVStoreService) MarkReady() bool {
+	if s.ready.Swap(true) {
+		return false
+	}
+	slog.Info("store marked ready")
+	return true
+}
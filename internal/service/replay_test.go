@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestReplayExistingHonorsAllowedTypes(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "user:1", Value: "v"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	sets := subscribe(t, s, &pb.SubscribeRequest{
+		KeyPattern:     "user:",
+		ReplayExisting: true,
+		AllowedTypes:   []pb.ChangeEvent_ChangeType{pb.ChangeEvent_SET},
+	})
+	// A distinct pattern, as subscribe waits for the pattern to have a subscriber
+	deletes := subscribe(t, s, &pb.SubscribeRequest{
+		KeyPattern:     "user",
+		ReplayExisting: true,
+		AllowedTypes:   []pb.ChangeEvent_ChangeType{pb.ChangeEvent_DELETE},
+	})
+
+	if event := nextEvent(t, sets); event.ChangeType != pb.ChangeEvent_SET || event.Key != "user:1" {
+		t.Errorf("SET subscriber replayed %v, want SET of user:1", event)
+	}
+	if _, err := s.Delete(ctx, &pb.DeleteRequest{Key: "user:1"}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if event := nextEvent(t, deletes); event.ChangeType != pb.ChangeEvent_DELETE {
+		t.Errorf("DELETE subscriber got %v first, want the DELETE", event)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+
+	"google.golang.org/grpc"
+)
+
+type sampledKey struct{}
+
+// Report whether the request carried by ctx was selected for debug tracing
+func Sampled(ctx context.Context) bool {
+	sampled, _ := ctx.Value(sampledKey{}).(bool)
+	return sampled
+}
+
+// Make the sampling decision once per request and store it in the context
+func (s *KVStoreService) SamplingInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if s.sampleRate > 0 && rand.Float64() < s.sampleRate {
+			ctx = context.WithValue(ctx, sampledKey{}, true)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Log the request arguments if it was sampled
+func (s *KVStoreService) logSample(ctx context.Context, method, key, value string) {
+	if !Sampled(ctx) {
+		return
+	}
+
+	attrs := []any{"method", method, "key", key}
+	if s.logValues && value != "" {
+		attrs = append(attrs, "value", value)
+	}
+	slog.Debug("sampled request", attrs...)
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/auth"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// How long an unused scan session is kept by default
+	defaultScanSessionTTL = time.Minute
+	// Most scan sessions open at once
+	maxScanSessions = 1000
+	// Most scan sessions one caller may have open at once
+	maxScanSessionsPerCaller = 16
+	// Most key bytes held by all open scan sessions together
+	maxScanCapturedBytes = 64 << 20
+)
+
+// Forget scan sessions after d without a page being read
+func WithScanSessionTTL(d time.Duration) Option {
+	return func(s *KVStoreService) {
+		s.scanSessionTTL = d
+	}
+}
+
+// Keys captured when a scan started, whose values are read page by page
+type scanSession struct {
+	keys    []string
+	caller  string
+	bytes   int
+	expires time.Time
+}
+
+// Return the keys starting with prefix in pages. The first call captures
+// the matching keys and later pages are served from that capture, so a key
+// written meanwhile is neither skipped nor repeated. Values are read as each
+// page is served, and keys deleted or expired since the scan started are left
+// out. Cursors stay valid until the session has gone unused for the scan
+// session TTL or its last page has been returned
+func (s *KVStoreService) ConsistentScan(ctx context.Context, req *pb.ConsistentScanRequest) (*pb.ConsistentScanResponse, error) {
+	if req.Limit < 0 || req.Limit > maxRangeLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 0 and %d", maxRangeLimit)
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultRangeLimit
+	}
+
+	if req.Cursor != "" {
+		id, offset, err := parseScanCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		s.scanMu.Lock()
+		defer s.scanMu.Unlock()
+		session, ok := s.scanSessions[id]
+		if !ok || time.Now().After(session.expires) {
+			delete(s.scanSessions, id)
+			return nil, status.Error(codes.NotFound, "scan cursor expired or unknown, start a new scan")
+		}
+		if offset > len(session.keys) {
+			return nil, status.Error(codes.InvalidArgument, "cursor is past the end of the scan")
+		}
+		return s.scanPage(id, session, offset, limit), nil
+	}
+
+	prefix := req.Prefix
+	if prefix != "" {
+		var err error
+		if prefix, err = s.normalizeKey(prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	var keys []string
+	size := 0
+	s.withStoreRead(func() {
+		s.forEachKeyWithPrefix(prefix, func(key string) bool {
+			keys = append(keys, key)
+			size += len(key)
+			return true
+		})
+	})
+	slices.Sort(keys)
+
+	slog.Info("consistent scan started", "prefix", prefix, "key_count", len(keys))
+	if len(keys) <= limit {
+		pairs := s.loadPairs(keys)
+		s.recordGets(pairs)
+		return &pb.ConsistentScanResponse{Pairs: pairs}, nil
+	}
+
+	caller := scanCaller(ctx)
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	if s.scanSessions == nil {
+		s.scanSessions = make(map[string]*scanSession)
+	}
+	if len(s.scanSessions) >= maxScanSessions {
+		return nil, status.Errorf(codes.ResourceExhausted, "%d scans are already open, finish or abandon some first", maxScanSessions)
+	}
+	open, held := 0, 0
+	for _, session := range s.scanSessions {
+		held += session.bytes
+		if session.caller == caller {
+			open++
+		}
+	}
+	if open >= maxScanSessionsPerCaller {
+		return nil, status.Errorf(codes.ResourceExhausted, "you already have %d scans open, finish or abandon some first", open)
+	}
+	if held+size > maxScanCapturedBytes {
+		return nil, status.Error(codes.ResourceExhausted, "open scans hold too many keys, narrow the prefix or retry later")
+	}
+	id := newScanID()
+	session := &scanSession{keys: keys, caller: caller, bytes: size}
+	s.scanSessions[id] = session
+	return s.scanPage(id, session, 0, limit), nil
+}
+
+// Serve the page at offset, ending the session after the last page. Caller
+// must hold scanMu
+func (s *KVStoreService) scanPage(id string, session *scanSession, offset, limit int) *pb.ConsistentScanResponse {
+	end := min(offset+limit, len(session.keys))
+	resp := &pb.ConsistentScanResponse{Pairs: s.loadPairs(session.keys[offset:end])}
+	s.recordGets(resp.Pairs)
+	if end == len(session.keys) {
+		delete(s.scanSessions, id)
+		return resp
+	}
+	session.expires = time.Now().Add(s.scanTTL())
+	resp.NextCursor = id + ":" + strconv.Itoa(end)
+	return resp
+}
+
+func (s *KVStoreService) scanTTL() time.Duration {
+	if s.scanSessionTTL > 0 {
+		return s.scanSessionTTL
+	}
+	return defaultScanSessionTTL
+}
+
+// Split a cursor into its session ID and the offset of the next page
+func parseScanCursor(cursor string) (string, int, error) {
+	id, offset, ok := strings.Cut(cursor, ":")
+	n, err := strconv.Atoi(offset)
+	if !ok || err != nil || n < 0 {
+		return "", 0, status.Error(codes.InvalidArgument, "malformed cursor")
+	}
+	return id, n, nil
+}
+
+// Identify who opened a scan by their credential, or their address when
+// unauthenticated, ignoring the port so new connections share the limit
+func scanCaller(ctx context.Context) string {
+	if id, ok := auth.FromContext(ctx); ok {
+		return "subject:" + id.SubjectID
+	}
+	addr := callerFromContext(ctx)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func newScanID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Drop scan sessions unused for longer than the scan session TTL
+func (s *KVStoreService) reapScanSessions() {
+	now := time.Now()
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	for id, session := range s.scanSessions {
+		if now.After(session.expires) {
+			delete(s.scanSessions, id)
+		}
+	}
+}
+
+// Drop every open scan session, e.g. ones abandoned by their clients.
+// Returns how many were dropped
+func (s *KVStoreService) DropScanSessions() int {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	n := len(s.scanSessions)
+	clear(s.scanSessions)
+	slog.Info("scan sessions dropped", "session_count", n)
+	return n
+}
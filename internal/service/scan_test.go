@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestConsistentScanReadsValuesPerPage(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	for i := range 6 {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: fmt.Sprintf("user:%d", i), Value: "old"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+
+	page, err := s.ConsistentScan(ctx, &pb.ConsistentScanRequest{Prefix: "user:", Limit: 2})
+	if err != nil {
+		t.Fatalf("ConsistentScan: %v", err)
+	}
+	var got []*pb.KeyValuePair
+	got = append(got, page.Pairs...)
+
+	// Changes made after the scan started: a new key is not picked up, a
+	// deleted one is left out and an updated one shows its new value
+	s.Set(ctx, &pb.SetRequest{Key: "user:9", Value: "new"})
+	s.Delete(ctx, &pb.DeleteRequest{Key: "user:3"})
+	s.Set(ctx, &pb.SetRequest{Key: "user:4", Value: "new"})
+
+	for page.NextCursor != "" {
+		if page, err = s.ConsistentScan(ctx, &pb.ConsistentScanRequest{Cursor: page.NextCursor, Limit: 2}); err != nil {
+			t.Fatalf("ConsistentScan: %v", err)
+		}
+		got = append(got, page.Pairs...)
+	}
+
+	want := []string{"user:0=old", "user:1=old", "user:2=old", "user:4=new", "user:5=old"}
+	if len(got) != len(want) {
+		t.Fatalf("scanned %d pairs, want %d: %v", len(got), len(want), got)
+	}
+	for i, pair := range got {
+		if kv := pair.Key + "=" + pair.Value; kv != want[i] {
+			t.Errorf("pair %d = %s, want %s", i, kv, want[i])
+		}
+	}
+}
+
+func TestConsistentScanLimitsSessionsPerCaller(t *testing.T) {
+	s := newTestService(t)
+	for i := range 3 {
+		s.Set(context.Background(), &pb.SetRequest{Key: fmt.Sprintf("k%d", i), Value: "v"})
+	}
+	from := func(addr string) context.Context {
+		tcp, err := net.ResolveTCPAddr("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: tcp})
+	}
+
+	// Each connection uses a new port, which must not get around the limit
+	for i := range maxScanSessionsPerCaller {
+		ctx := from(fmt.Sprintf("10.0.0.1:%d", 40000+i))
+		if _, err := s.ConsistentScan(ctx, &pb.ConsistentScanRequest{Limit: 1}); err != nil {
+			t.Fatalf("scan %d: %v", i, err)
+		}
+	}
+	_, err := s.ConsistentScan(from("10.0.0.1:50000"), &pb.ConsistentScanRequest{Limit: 1})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("scan over the limit: got %v, want ResourceExhausted", err)
+	}
+	if _, err := s.ConsistentScan(from("10.0.0.2:40000"), &pb.ConsistentScanRequest{Limit: 1}); err != nil {
+		t.Errorf("scan from another caller: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// Entries applied between progress logs while seeding
+const seedProgressInterval = 10000
+
+// Single line of a seed file
+type SeedEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Expire the key this long after seeding, 0 for no expiry
+	TTLMs int64 `json:"ttl_ms,omitempty"`
+}
+
+// Load newline-delimited SeedEntry JSON from path, see LoadSeed
+func (s *KVStoreService) LoadSeedFile(ctx context.Context, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open seed file: %w", err)
+	}
+	defer f.Close()
+
+	start := time.Now()
+	slog.Info("seeding store", "path", path)
+	n, err := s.LoadSeed(ctx, f)
+	if err != nil {
+		return n, fmt.Errorf("seed from %s: %w", path, err)
+	}
+	slog.Info("store seeded", "path", path, "key_count", n, "duration", time.Since(start))
+	return n, nil
+}
+
+// Replace keys that already hold a value when seeding, instead of keeping them
+func WithSeedOverwrite() Option {
+	return func(s *KVStoreService) {
+		s.seedOverwrite = true
+	}
+}
+
+// Store each SeedEntry read from r without notifying subscribers, for warming
+// the store before it serves. Keys that already hold a value are kept unless
+// WithSeedOverwrite is set. Stops at the first invalid entry, keeping those
+// already applied, and returns how many were applied
+func (s *KVStoreService) LoadSeed(ctx context.Context, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	applied, skipped, line := 0, 0, 0
+	defer func() {
+		if skipped > 0 {
+			slog.Info("seed skipped existing keys", "key_count", skipped)
+		}
+	}()
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return applied, err
+		}
+
+		var entry SeedEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return applied, fmt.Errorf("line %d: %w", line, err)
+		}
+		stored, err := s.applySeed(entry)
+		if err != nil {
+			return applied, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !stored {
+			skipped++
+			continue
+		}
+
+		applied++
+		if applied%seedProgressInterval == 0 {
+			slog.Info("seeding progress", "key_count", applied)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return applied, fmt.Errorf("read seed entries: %w", err)
+	}
+	return applied, nil
+}
+
+// Store one seed entry the way Set would, minus events. Reports false when
+// the key already holds a value and overwriting is off
+func (s *KVStoreService) applySeed(entry SeedEntry) (bool, error) {
+	if entry.Key == "" {
+		return false, errors.New("key cannot be empty")
+	}
+	key, err := s.normalizeKey(entry.Key)
+	if err != nil {
+		return false, errors.New(status.Convert(err).Message())
+	}
+	if s.maxValueSize > 0 && len(entry.Value) > s.maxValueSize {
+		return false, fmt.Errorf("key %q: value exceeds maximum size of %d bytes", key, s.maxValueSize)
+	}
+	if entry.TTLMs < 0 {
+		return false, fmt.Errorf("key %q: ttl_ms cannot be negative", key)
+	}
+
+	stored := false
+	s.withKeyLock(key, func() error {
+		if !s.seedOverwrite {
+			if _, ok := s.store.Load(key); ok && !s.isExpired(key) {
+				return nil
+			}
+		}
+		s.store.Store(key, entry.Value)
+		s.clearTTL(key)
+		if entry.TTLMs > 0 {
+			s.setTTL(key, time.Duration(entry.TTLMs)*time.Millisecond)
+		}
+		s.bumpVersion(key)
+		// Stamped like any local write so sync peers do not roll it back
+		s.stampWrite(key, false)
+		stored = true
+		return nil
+	})
+	if stored {
+		s.recordSet(key)
+	}
+	return stored, nil
+}
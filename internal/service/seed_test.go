@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const seedLines = `{"key": "a", "value": "seeded"}
+{"key": "b", "value": "seeded"}
+`
+
+func TestLoadSeedKeepsExistingKeys(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "a", Value: "live"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	n, err := s.LoadSeed(ctx, strings.NewReader(seedLines))
+	if err != nil {
+		t.Fatalf("LoadSeed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("applied %d entries, want 1", n)
+	}
+	if v, _ := s.store.Load("a"); v != "live" {
+		t.Errorf("a = %q after seeding, want the live value kept", v)
+	}
+	if v, _ := s.store.Load("b"); v != "seeded" {
+		t.Errorf("b = %q, want seeded", v)
+	}
+	if _, ok := s.stamps.Load("b"); !ok {
+		t.Error("seeded key b has no sync stamp")
+	}
+}
+
+func TestLoadSeedOverwrite(t *testing.T) {
+	s := newTestService(t, WithSeedOverwrite())
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "a", Value: "live"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	before, _ := s.stamps.Load("a")
+
+	n, err := s.LoadSeed(ctx, strings.NewReader(seedLines))
+	if err != nil {
+		t.Fatalf("LoadSeed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("applied %d entries, want 2", n)
+	}
+	if v, _ := s.store.Load("a"); v != "seeded" {
+		t.Errorf("a = %q, want seeded", v)
+	}
+	if after, _ := s.stamps.Load("a"); !after.(writeStamp).after(before.(writeStamp)) {
+		t.Error("overwritten key a kept its old sync stamp")
+	}
+}
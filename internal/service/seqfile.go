@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Keeps sequence numbers increasing across restarts by writing a reservation
+// ahead of the counter. After a crash numbering resumes past the reservation,
+// so up to interval numbers are skipped but none are reused
+type sequenceFile struct {
+	path     string
+	interval int64
+	// Held while writing the file, taken before eventHistory.mu so sequence
+	// numbers keep being assigned up to the reservation meanwhile
+	mu sync.Mutex
+	// Highest sequence that may be assigned before the next write, guarded
+	// by eventHistory.mu
+	reserved int64
+}
+
+// Continue sequence numbers from path across restarts, writing every interval
+// events and on Close. Requires event history to be enabled. StartupErr
+// reports a file that cannot be read or an interval below 1
+func WithSequenceFile(path string, interval int) Option {
+	return func(s *KVStoreService) {
+		s.sequencePath = path
+		s.sequenceInterval = interval
+	}
+}
+
+// Read the persisted counter, 0 if the file does not exist yet
+func readSequenceFile(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	seq, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return max(seq, 0), nil
+}
+
+// Replace the file contents with seq, so a crash mid-write leaves the old
+// value. The directory is synced too so the rename survives a crash
+func writeSequenceFile(path string, seq int64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(strconv.FormatInt(seq, 10) + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Restore the counter from path and persist from now on
+func (h *eventHistory) persistTo(path string, interval int) error {
+	seq, err := readSequenceFile(path)
+	if err != nil {
+		return fmt.Errorf("restore sequence numbering from %s: %w", path, err)
+	}
+	if interval < 1 {
+		return fmt.Errorf("sequence persist interval must be at least 1, got %d", interval)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq = seq
+	h.file = &sequenceFile{path: path, interval: int64(interval), reserved: seq}
+	slog.Info("sequence numbering restored", "path", path, "last_sequence", seq)
+	return nil
+}
+
+// Make sure seq is covered by a persisted reservation, writing the next block
+// if not. Must be called without h.mu. Returns false if the write failed
+func (h *eventHistory) reserve(f *sequenceFile, seq int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h.mu.Lock()
+	covered := seq <= f.reserved
+	h.mu.Unlock()
+	if covered {
+		return true
+	}
+
+	next := seq + f.interval
+	if err := writeSequenceFile(f.path, next); err != nil {
+		slog.Error("failed to persist sequence reservation", "path", f.path, "error", err)
+		return false
+	}
+	h.mu.Lock()
+	f.reserved = next
+	h.mu.Unlock()
+	return true
+}
+
+// Write the exact counter so a clean restart continues without a gap
+func (h *eventHistory) flushSequence() error {
+	f := h.file
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Later events wait for a new reservation until the write is done
+	h.mu.Lock()
+	seq, reserved := h.seq, f.reserved
+	f.reserved = seq
+	h.mu.Unlock()
+
+	if err := writeSequenceFile(f.path, seq); err != nil {
+		h.mu.Lock()
+		f.reserved = reserved
+		h.mu.Unlock()
+		return fmt.Errorf("persist sequence: %w", err)
+	}
+	return nil
+}
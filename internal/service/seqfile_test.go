@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Set key and return the sequence number its event was given
+func setSequence(t *testing.T, s *KVStoreService, key string) int64 {
+	t.Helper()
+	if _, err := s.Set(context.Background(), &pb.SetRequest{Key: key, Value: "v"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	_, _, latest := s.history.since(0)
+	return latest
+}
+
+func TestSequenceFileSurvivesCrash(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sequence")
+
+	s := newTestService(t, WithSequenceFile(path, 10))
+	if err := s.StartupErr(); err != nil {
+		t.Fatalf("StartupErr: %v", err)
+	}
+	var last int64
+	for range 25 {
+		last = setSequence(t, s, "k")
+	}
+
+	// Copy the file as it is now, as a crash would leave it before Close
+	// records the exact counter
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashed := filepath.Join(dir, "crashed")
+	if err := os.WriteFile(crashed, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newTestService(t, WithSequenceFile(crashed, 10))
+	if err := restarted.StartupErr(); err != nil {
+		t.Fatalf("StartupErr after crash: %v", err)
+	}
+	if seq := setSequence(t, restarted, "k"); seq <= last || seq > last+10+1 {
+		t.Errorf("first sequence after crash = %d, want in (%d, %d]", seq, last, last+11)
+	}
+
+	// A clean shutdown continues without a gap
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	reopened := newTestService(t, WithSequenceFile(path, 10))
+	if seq := setSequence(t, reopened, "k"); seq != last+1 {
+		t.Errorf("first sequence after clean restart = %d, want %d", seq, last+1)
+	}
+}
+
+func TestSequenceFileRestoreFailureStopsStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sequence")
+	if err := os.WriteFile(path, []byte("not a number\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestService(t, WithSequenceFile(path, 10))
+	if s.StartupErr() == nil {
+		t.Fatal("StartupErr = nil for an unreadable sequence file")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "not a number\n" {
+		t.Errorf("Close rewrote the sequence file to %q", data)
+	}
+}
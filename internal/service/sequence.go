@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Recent events kept for subscribers resuming by sequence number
+	defaultEventHistorySize = 1000
+
+	// Response headers sent when resuming, carrying the oldest sequence still
+	// held (the next to be assigned if none are) and the most recent assigned
+	OldestSequenceHeader = "x-kvstore-oldest-sequence"
+	LatestSequenceHeader = "x-kvstore-latest-sequence"
+
+	// Response header on Set and Get carrying the sequence number of the
+	// key's last SET or APPEND, sent only while the event history is on
+	SequenceHeader = "x-kvstore-sequence"
+)
+
+// Fixed-size ring of the most recent events, numbered in the order recorded
+type eventHistory struct {
+	mu     sync.Mutex
+	events []*pb.ChangeEvent
+	// Index of the next slot to write
+	next int
+	// Sequence number of the most recent event, 0 before any
+	seq int64
+	// Persists seq across restarts, nil when disabled
+	file *sequenceFile
+}
+
+func newEventHistory(size int) *eventHistory {
+	return &eventHistory{events: make([]*pb.ChangeEvent, size)}
+}
+
+// Keep the most recent n events for resuming subscribers, 0 disables resume
+func WithEventHistory(n int) Option {
+	return func(s *KVStoreService) {
+		if n <= 0 {
+			s.history = nil
+			return
+		}
+		s.history = newEventHistory(n)
+	}
+}
+
+// Assign the next sequence number to event and remember it
+func (h *eventHistory) record(event *pb.ChangeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The reservation is written without h.mu so other events are numbered
+	// meanwhile, up to the old reservation. On a failed write numbering goes
+	// on, and a crash before the next successful write may reuse numbers
+	for f := h.file; f != nil && h.seq >= f.reserved; {
+		next := h.seq + 1
+		h.mu.Unlock()
+		ok := h.reserve(f, next)
+		h.mu.Lock()
+		if !ok {
+			break
+		}
+	}
+	h.seq++
+	event.Sequence = h.seq
+	h.events[h.next] = event
+	h.next = (h.next + 1) % len(h.events)
+}
+
+// Remember the sequence number of a change to a key's value
+func (s *KVStoreService) rememberSequence(event *pb.ChangeEvent) {
+	switch event.ChangeType {
+	case pb.ChangeEvent_SET, pb.ChangeEvent_APPEND:
+		if event.Key != "" && event.Sequence != 0 {
+			s.sequences.Store(event.Key, event.Sequence)
+		}
+	}
+}
+
+// Sequence number of the key's last SET or APPEND, 0 if none is known
+func (s *KVStoreService) keySequence(key string) int64 {
+	if seq, ok := s.sequences.Load(key); ok {
+		return seq.(int64)
+	}
+	return 0
+}
+
+// Report a sequence number in the SequenceHeader, nothing is sent for 0
+func (s *KVStoreService) setSequenceHeader(ctx context.Context, seq int64) {
+	if seq == 0 {
+		return
+	}
+	// Fails only outside a gRPC call, such as a direct call in tests
+	_ = grpc.SetHeader(ctx, metadata.Pairs(SequenceHeader, strconv.FormatInt(seq, 10)))
+}
+
+// Events after seq in order, the oldest sequence still held (0 if none) and the latest assigned
+func (h *eventHistory) since(seq int64) ([]*pb.ChangeEvent, int64, int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var out []*pb.ChangeEvent
+	oldest := int64(0)
+	for i := range h.events {
+		event := h.events[(h.next+i)%len(h.events)]
+		if event == nil {
+			continue
+		}
+		if oldest == 0 {
+			oldest = event.Sequence
+		}
+		if event.Sequence > seq {
+			out = append(out, event)
+		}
+	}
+	return out, oldest, h.seq
+}
+
+// Send held events after the requested sequence, returning the last one sent
+func (s *KVStoreService) replayHistory(sub *subscriber, from int64, stream pb.KeyValueStore_SubscribeServer) (int64, error) {
+	events, oldest, latest := s.history.since(from)
+	// Nothing held, e.g. after a restart, so anything after latest is still to come
+	if oldest == 0 {
+		oldest = latest + 1
+	}
+
+	// Tell the client what history covers so it can detect missed events or a restart
+	header := metadata.Pairs(
+		OldestSequenceHeader, strconv.FormatInt(oldest, 10),
+		LatestSequenceHeader, strconv.FormatInt(latest, 10),
+	)
+	if err := stream.SendHeader(header); err != nil {
+		return 0, err
+	}
+
+	last := from
+	for _, event := range events {
+		last = event.Sequence
+		if !eventMatches(event, sub.pattern) || !sub.accepts(event) {
+			continue
+		}
+		if err := stream.Send(event); err != nil {
+			return 0, err
+		}
+	}
+	return last, nil
+}
@@ -3,35 +3,307 @@ package service
 import (
 	"context"
 	"log/slog"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/amillerrr/distributed-kv-store/internal/capabilities"
+	"github.com/amillerrr/distributed-kv-store/internal/loadshed"
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
 	pb "github.com/amillerrr/distributed-kv-store/proto"
 )
 
 type subscriber struct {
 	pattern string
-	stream pb.KeyValueStore_SubscribeServer
-	events chan *pb.ChangeEvent
+	stream  pb.KeyValueStore_SubscribeServer
+	events  chan *pb.ChangeEvent
+	// Set when the channel is allocated lazily, closed once it exists
+	eventsReady chan struct{}
+	eventsOnce  sync.Once
+	// Channel goes back to the pool instead of being closed
+	pooled bool
+	// Closed once unregistered, nil unless flush support or lock-free mode needs it
+	gone chan struct{}
+	// Change types to deliver, all when empty
+	allowedTypes []pb.ChangeEvent_ChangeType
+	// gjson path that must be truthy and substring that must appear in SET values, ignored when empty
+	valueFilter   string
+	valueContains string
+	// Labels the key of an event must carry, ignored when empty
+	metaFilter map[string]string
+	// Receive SetMulti changes as a single BATCH event
+	batchEvents bool
+	// Warn when a matching key has less than this long to live, 0 disables
+	ttlWarnThreshold time.Duration
+	// Overflow for a full channel, nil when not requested
+	dlq *deadLetterQueue
+	// Highest fill threshold warned about and when, owned by the Subscribe loop
+	warnedFill float64
+	warnedAt   time.Time
+	// ID and acknowledgment progress of an ack_mode subscriber, ack nil otherwise
+	id  string
+	ack *ackState
+	// Registered by a MultiWatch stream, which has a client like stream does
+	multiWatch bool
 }
 
 type KVStoreService struct {
 	pb.UnimplementedKeyValueStoreServer
-	store sync.Map
-	mu sync.RWMutex
+	pb.UnimplementedAdminServiceServer
+	store storage.Backend
+	// Held for reading by single-key writes, for writing by multi-key operations
+	storeMu sync.RWMutex
+	// Serialize read-check-write sequences on the same key
+	keyLocks keyLocks
+	versions sync.Map
+	// Last version handed out to any key, so a version is never reused even
+	// after its key is deleted and created again
+	versionClock atomic.Int64
+	// Sequence number of each live key's last SET or APPEND, kept only while
+	// the event history assigns sequence numbers
+	sequences sync.Map
+	// Expiry per key as Unix ms, absent for keys without a TTL
+	expiries sync.Map
+	// Labels attached to keys with SetMeta
+	meta *metaIndex
+	// Sorted sets by key and the most members each may hold, 0 if unlimited
+	zsets       *sortedSets
+	maxZSetSize int
+	maxZSets    int
+	// *ttlWarnings per key, cleared whenever its TTL changes
+	warnedKeys  sync.Map
+	mu          sync.RWMutex
 	subscribers map[string][]*subscriber
-	subID int
+	// ack_mode subscribers by subscription ID
+	ackers map[string]*subscriber
+
+	// Largest accepted value in bytes, 0 if unlimited
+	maxValueSize int
+	// Applied to keys and patterns on the way in, nil leaves them unchanged
+	keyNormalizer func(string) string
+
+	// Debug sampling settings
+	sampleRate float64
+	logValues  bool
+
+	// Recent unary request durations, for MonitorStream
+	latency latencyWindow
+
+	// Per-key stats, populated only when statsEnabled
+	statsEnabled   bool
+	keyStats       sync.Map
+	hotKeyTopN     int
+	hotKeyInterval time.Duration
+	// Keys unused this long are announced as idle, disabled when 0
+	idleAfter      time.Duration
+	deleteIdleKeys bool
+
+	// Destinations for mutation records: the event log and audit forwarders
+	eventSinks []eventSink
+	// Audit forwarders to start once all options are applied
+	audit auditConfig
+	// Recent events for resuming subscribers, nil when disabled
+	history *eventHistory
+	// Events kept per key by periodic history compaction, disabled when 0
+	compactRetainLast int
+	compactInterval   time.Duration
+	// Registry for storage call counts and heap samples, nil when disabled
+	storeMetricsReg prometheus.Registerer
+	// File persisting the sequence counter, disabled when empty
+	sequencePath     string
+	sequenceInterval int
+
+	// Allocate subscriber channels on first delivery, and reuse them when pooled
+	lazyChannels bool
+	chanPool     *eventChanPool
+	// Publish to a copy of subscribers swapped on every change instead of under mu
+	lockFree           bool
+	subscriberSnapshot atomic.Pointer[map[string][]*subscriber]
+	// Time notify loops and count enqueue attempts per pattern
+	notifyMetrics bool
+	// Consulted by Get on a local miss, nil when disabled
+	upstream        pb.KeyValueStoreClient
+	upstreamFillTTL time.Duration
+	// *upstreamFetch per key being looked up upstream
+	upstreamFetches sync.Map
+	// Flush markers queued for subscribers, mapped to the channel closed once taken
+	flushSupport bool
+	flushMarkers sync.Map
+	// Events per second and burst per key, disabled when the rate is 0
+	keyEventRate  rate.Limit
+	keyEventBurst int
+	// *keyEventLimiter per key that has sent events recently
+	keyEventLimiters sync.Map
+	// Open ConsistentScan sessions by ID
+	scanMu         sync.Mutex
+	scanSessions   map[string]*scanSession
+	scanSessionTTL time.Duration
+	// Read counters of the open monitor streams that want hot keys
+	monitorMu       sync.RWMutex
+	monitorCounters map[*hotKeyCounter]struct{}
+	// Key count shared by monitor streams when the backend keeps none
+	monitorKeys cachedKeyCount
+	// Recent PrefixStats results
+	prefixStats *prefixStatsCache
+	// Cleared until the store is seeded when the startup gate is on
+	ready       atomic.Bool
+	startupGate bool
+	// Seeding replaces live keys rather than skipping them
+	seedOverwrite bool
+
+	// Optional features reported by ServerCapabilities
+	capabilities *capabilities.Registry
+
+	// Rejects writes under memory pressure, nil when disabled
+	loadShed *loadshed.Monitor
+
+	// Tagged on local changes so sync peers can tell where a change came from
+	nodeID string
+	// Latest writeStamp per key, including recently deleted keys
+	stamps     sync.Map
+	tombstones tombstones
+
+	// Why the service cannot serve, reported by StartupErr
+	startErr error
+
+	// Closed to stop background goroutines and end active streams
+	done      chan struct{}
+	closeOnce sync.Once
+	// Active Subscribe and WaitBarrier streams, guarded by mu once closed is set
+	streams sync.WaitGroup
+	closed  bool
+	// Set by AnnounceShutdown, new subscriptions are refused, guarded by mu
+	draining bool
 }
 
-func NewKVStoreService() *KVStoreService {
+// Longest Close waits for active streams to end
+const closeTimeout = 5 * time.Second
+
+func NewKVStoreService(opts ...Option) *KVStoreService {
 	slog.Info("initializing KV store service")
-	return &KVStoreService{
-		subscribers: make(map[string][]*subscriber),
+	s := &KVStoreService{
+		store:        storage.NewMemory(),
+		subscribers:  make(map[string][]*subscriber),
+		ackers:       make(map[string]*subscriber),
+		meta:         newMetaIndex(),
+		zsets:        newSortedSets(),
+		prefixStats:  newPrefixStatsCache(),
+		history:      newEventHistory(defaultEventHistorySize),
+		done:         make(chan struct{}),
+		nodeID:       newNodeID(),
+		capabilities: capabilities.New(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.registerCapabilities()
+
+	// Applied after all options so it attaches to the final event history
+	if s.sequencePath != "" {
+		if s.history == nil {
+			slog.Warn("sequence file ignored, event history is disabled", "path", s.sequencePath)
+		} else if err := s.history.persistTo(s.sequencePath, s.sequenceInterval); err != nil {
+			// Numbering from 0 would reuse sequences subscribers already saw
+			s.startErr = err
+		}
+	}
+
+	// Started after all options so the buffer size and headers apply
+	s.startAuditForwarders()
+
+	// Wrapped last so the final backend is the one instrumented
+	if s.storeMetricsReg != nil {
+		m := newStoreMetrics(s.storeMetricsReg)
+		s.store = &countingStore{Backend: s.store, metrics: m}
+		go s.runMemStatsSampler(m)
+	}
+
+	if s.loadShed != nil {
+		go s.loadShed.Run(s.done)
+	}
+	if s.hotKeyTopN > 0 && s.hotKeyInterval > 0 {
+		go s.runHotKeyScanner()
+	}
+	if s.idleAfter > 0 {
+		go s.runIdleKeyScanner()
+	}
+	if s.history != nil && s.compactRetainLast > 0 && s.compactInterval > 0 {
+		go s.runHistoryCompactor()
 	}
+	if s.keyEventRate > 0 {
+		go s.runKeyEventLimiterSweeper()
+	}
+	go s.runTTLReaper()
+	go s.runFillSampler()
+	s.ready.Store(!s.startupGate)
+	return s
+}
+
+// Why options given to NewKVStoreService left the service unable to serve,
+// nil if they did not. The service must be closed either way
+func (s *KVStoreService) StartupErr() error {
+	return s.startErr
+}
+
+// Stop background goroutines, end active streams and flush the event log.
+// Returns context.DeadlineExceeded if streams do not finish within closeTimeout
+func (s *KVStoreService) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		slog.Info("closing KV store service")
+
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.done)
+
+		finished := make(chan struct{})
+		go func() {
+			s.streams.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-time.After(closeTimeout):
+			slog.Warn("timed out waiting for streams to end", "timeout", closeTimeout)
+			err = context.DeadlineExceeded
+		}
+
+		if s.history != nil {
+			if flushErr := s.history.flushSequence(); flushErr != nil && err == nil {
+				err = flushErr
+			}
+		}
+		for _, sink := range s.eventSinks {
+			if closeErr := sink.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+		if closeErr := s.store.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+// Register a long-lived stream so Close can wait for it, failing once closed
+func (s *KVStoreService) trackStream() (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, status.Error(codes.Unavailable, "service is closing")
+	}
+	s.streams.Add(1)
+	return s.streams.Done, nil
 }
 
 // Retrieve value by key
@@ -40,10 +312,37 @@ func (s *KVStoreService) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetRe
 		slog.Warn("get request with empty key")
 		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
 	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	req.Key = key
+	if req.FieldMask != "" {
+		if err := validateFieldMask(req.FieldMask); err != nil {
+			return nil, err
+		}
+	}
+	if req.IfModifiedSinceMs < 0 {
+		return nil, status.Error(codes.InvalidArgument, "if_modified_since_ms cannot be negative")
+	}
 
 	slog.Info("get request", "key", req.Key)
+	s.logSample(ctx, "Get", req.Key, "")
 
-	value, found := s.store.Load(req.Key)
+	value, found, version := s.loadVersioned(req.Key)
+	if found && s.isExpired(req.Key) {
+		s.expireKey(req.Key)
+		found = false
+	}
+	if found {
+		s.countUpstream("hit")
+	} else if s.upstream != nil {
+		s.countUpstream("miss")
+		value, version, found, err = s.fillFromUpstream(ctx, req.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if !found {
 		slog.Info("key not found", "key", req.Key)
 		return &pb.GetResponse{
@@ -52,16 +351,47 @@ func (s *KVStoreService) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetRe
 		}, nil
 	}
 
-	valueStr, ok := value.(string)
-	if !ok {
-		slog.Error("stored value is not a string", "key", req.Key)
-		return nil, status.Error(codes.Internal, "internal storage error")
+	s.recordGet(req.Key)
+	s.setSequenceHeader(ctx, s.keySequence(req.Key))
+
+	if req.FieldMask != "" {
+		masked, err := applyFieldMask(value, req.FieldMask)
+		if err != nil {
+			slog.Info("field mask not applied", "key", req.Key, "field_mask", req.FieldMask, "error", err)
+			return nil, err
+		}
+		value = masked
+	}
+
+	etag := valueETag(value)
+	var lastModified int64
+	if stats, ok := s.KeyStats(req.Key); ok {
+		lastModified = stats.LastModifiedMs
+	}
+	// As in HTTP, a matching ETag decides and the time is only a fallback
+	notModified := req.IfNoneMatch != "" && req.IfNoneMatch == etag
+	if req.IfNoneMatch == "" && req.IfModifiedSinceMs > 0 && lastModified > 0 {
+		notModified = lastModified <= req.IfModifiedSinceMs
+	}
+	if notModified {
+		metrics.ConditionalGetHits.Inc()
+		slog.Info("key not modified", "key", req.Key)
+		return &pb.GetResponse{
+			Found:          true,
+			Version:        version,
+			Etag:           etag,
+			NotModified:    true,
+			LastModifiedMs: lastModified,
+		}, nil
 	}
 
 	slog.Info("kkey retrieved successfully", "key", req.Key)
 	return &pb.GetResponse{
-		Value: valueStr,
-		Found: true,
+		Value:          value,
+		Found:          true,
+		Version:        version,
+		Etag:           etag,
+		LastModifiedMs: lastModified,
 	}, nil
 }
 
@@ -70,100 +400,533 @@ func (s *KVStoreService) Set(ctx context.Context, req *pb.SetRequest) (*pb.SetRe
 	if req.Key == "" {
 		slog.Warn("set request with empty key")
 		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
-	} 
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	req.Key = key
+	if s.maxValueSize > 0 && len(req.Value) > s.maxValueSize {
+		slog.Warn("set request value too large", "key", req.Key, "value_length", len(req.Value), "max", s.maxValueSize)
+		return nil, status.Errorf(codes.InvalidArgument, "value exceeds maximum size of %d bytes", s.maxValueSize)
+	}
+	if req.GetTtlMs() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ttl_ms cannot be negative")
+	}
+	if req.AckTimeoutMs < 0 {
+		return nil, status.Error(codes.InvalidArgument, "ack_timeout_ms cannot be negative")
+	}
+	// Acknowledgments refer to sequence numbers, which need the event history
+	if req.WaitForAck && s.history == nil {
+		return nil, status.Error(codes.FailedPrecondition, "event history is disabled on this server")
+	}
+	if err := s.shedWrite("Set", req.Key); err != nil {
+		return nil, err
+	}
 
 	slog.Info("set request", "key", req.Key)
+	s.logSample(ctx, "Set", req.Key, req.Value)
 
-	// Store the value
-	s.store.Store(req.Key, req.Value)
+	// Check the conflict policy and store the value under the key lock
+	var expiresAt, version, timestamp int64
+	err = s.withKeyLock(req.Key, func() error {
+		if err := s.checkConflict(req); err != nil {
+			return err
+		}
+		s.store.Store(req.Key, req.Value)
+		s.assertStored(req.Key, req.Value)
+		s.clearTTL(req.Key)
+		if req.TtlMs != nil && *req.TtlMs > 0 {
+			expiresAt = s.setTTL(req.Key, time.Duration(*req.TtlMs)*time.Millisecond)
+		}
+		version = s.bumpVersion(req.Key)
+		timestamp = s.stampWrite(req.Key, false)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.recordSet(req.Key)
+	s.assertSubscribers()
 
 	// Create change event
 	event := &pb.ChangeEvent{
-		ChangeType: pb.ChangeEvent_SET,
-		Key: req.Key,
-		Value: req.Value,
-		Timestamp: time.Now().UnixMilli(),
+		ChangeType:  pb.ChangeEvent_SET,
+		Key:         req.Key,
+		Value:       req.Value,
+		Timestamp:   timestamp,
+		Version:     version,
+		ExpiresAtMs: expiresAt,
 	}
 
 	// Notify subscribers
-	s.notifySubscribers(event)
+	notified, ackers := s.publish(ctx, event)
+	if Sampled(ctx) {
+		slog.Debug("sampled event dispatched", "key", req.Key, "subscriber_count", notified)
+	}
+	s.setSequenceHeader(ctx, event.Sequence)
+
+	if req.WaitForAck {
+		if err := s.awaitAcks(ctx, event.Sequence, ackers, time.Duration(req.AckTimeoutMs)*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
 
 	slog.Info("key stored successfully", "key", req.Key, "value_length", len(req.Value))
 
 	return &pb.SetResponse{
-		Success: true,
-		Message: "key stored successfully",
+		Success:     true,
+		Message:     "key stored successfully",
+		Version:     version,
+		ExpiresAtMs: expiresAt,
 	}, nil
 }
 
+// Remove a key
+func (s *KVStoreService) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	if req.Key == "" {
+		slog.Warn("delete request with empty key")
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	key, err := s.normalizeKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	req.Key = key
+
+	slog.Info("delete request", "key", req.Key)
+	s.logSample(ctx, "Delete", req.Key, "")
+
+	var found, deleted bool
+	var meta map[string]string
+	var timestamp int64
+	s.withKeyLock(req.Key, func() error {
+		_, found = s.store.LoadAndDelete(req.Key)
+		// An expired key is already gone as far as callers are concerned
+		deleted = found && !s.isExpired(req.Key)
+		s.clearTTL(req.Key)
+		s.forgetVersion(req.Key)
+		meta = s.meta.remove(req.Key)
+		if found {
+			timestamp = s.stampWrite(req.Key, true)
+		}
+		return nil
+	})
+	s.forgetStats(req.Key)
+	if s.zsets.drop(req.Key) {
+		found, deleted = true, true
+		if timestamp == 0 {
+			timestamp = time.Now().UnixNano()
+		}
+	}
+
+	if found {
+		s.notifySubscribers(ctx, &pb.ChangeEvent{
+			ChangeType: pb.ChangeEvent_DELETE,
+			Key:        req.Key,
+			Timestamp:  timestamp,
+			Meta:       meta,
+		})
+	}
+
+	slog.Info("delete completed", "key", req.Key, "deleted", deleted)
+	return &pb.DeleteResponse{Deleted: deleted}, nil
+}
+
+// Enforce the request's conflict policy, caller must hold the key lock
+func (s *KVStoreService) checkConflict(req *pb.SetRequest) error {
+	switch req.ConflictPolicy {
+	case pb.ConflictPolicy_POLICY_LWW:
+		return nil
+	case pb.ConflictPolicy_POLICY_FWW:
+		if _, exists := s.store.Load(req.Key); exists && !s.isExpired(req.Key) {
+			slog.Info("set rejected, key exists", "key", req.Key, "policy", req.ConflictPolicy)
+			return status.Error(codes.AlreadyExists, "key already exists")
+		}
+		return nil
+	case pb.ConflictPolicy_POLICY_CAS:
+		if current := s.version(req.Key); current != req.ExpectedVersion {
+			slog.Info("set rejected, version mismatch", "key", req.Key, "expected_version", req.ExpectedVersion, "current_version", current)
+			return status.Errorf(codes.Aborted, "version mismatch: expected %d, current %d", req.ExpectedVersion, current)
+		}
+		return nil
+	default:
+		return status.Errorf(codes.InvalidArgument, "unknown conflict policy %d", req.ConflictPolicy)
+	}
+}
+
 // Stream changes for matching keys
 func (s *KVStoreService) Subscribe(req *pb.SubscribeRequest, stream pb.KeyValueStore_SubscribeServer) error {
 	if req.KeyPattern == "" {
 		slog.Warn("subscribe request with empty pattern")
 		return status.Error(codes.InvalidArgument, "key_pattern cannot be empty")
 	}
+	pattern, err := s.normalizeKey(req.KeyPattern)
+	if err != nil {
+		return err
+	}
+	req.KeyPattern = pattern
+	if req.MaxDlqSize < 0 || req.MaxDlqSize > maxDLQSize {
+		return status.Errorf(codes.InvalidArgument, "max_dlq_size must be between 0 and %d", maxDLQSize)
+	}
+	if req.ValueFilter != "" {
+		if err := validateValueFilter(req.ValueFilter); err != nil {
+			return err
+		}
+	}
+	if req.TtlWarnThresholdMs < 0 {
+		return status.Error(codes.InvalidArgument, "ttl_warn_threshold_ms cannot be negative")
+	}
+	if req.StreamTimeoutMs < 0 {
+		return status.Error(codes.InvalidArgument, "stream_timeout_ms cannot be negative")
+	}
+	if req.ResumeFromSequence < 0 {
+		return status.Error(codes.InvalidArgument, "resume_from_sequence cannot be negative")
+	}
+	if (req.ResumeFromSequence > 0 || req.AckMode) && s.history == nil {
+		return status.Error(codes.FailedPrecondition, "event history is disabled on this server")
+	}
 
-	slog.Info("new subscriber", "pattern", req.KeyPattern)
+	slog.Info("new subscriber", "pattern", req.KeyPattern, "allowed_types", req.AllowedTypes, "max_dlq_size", req.MaxDlqSize)
 
 	// Create subscriber
 	sub := &subscriber{
-		pattern: req.KeyPattern,
-		stream: stream,
-		events: make(chan *pb.ChangeEvent, 100),
+		pattern:          req.KeyPattern,
+		stream:           stream,
+		allowedTypes:     req.AllowedTypes,
+		valueFilter:      req.ValueFilter,
+		valueContains:    req.ValueContains,
+		metaFilter:       req.MetaFilter,
+		batchEvents:      req.BatchEvents,
+		ttlWarnThreshold: time.Duration(req.TtlWarnThresholdMs) * time.Millisecond,
+	}
+	s.initEvents(sub)
+	if s.flushSupport {
+		sub.gone = make(chan struct{})
+	}
+	if req.MaxDlqSize > 0 {
+		sub.dlq = newDeadLetterQueue(int(req.MaxDlqSize))
 	}
 
-	// Register subscriber
-	s.mu.Lock()
-	s.subscribers[req.KeyPattern] = append(s.subscribers[req.KeyPattern], sub)
-	subscriberCount := len(s.subscribers[req.KeyPattern])
-	s.mu.Unlock()
+	s.mu.RLock()
+	draining := s.draining
+	s.mu.RUnlock()
+	if draining {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
 
-	slog.Info("subscriber reistered", "pattern", req.KeyPattern, "total_subscribers", subscriberCount)
+	untrack, err := s.trackStream()
+	if err != nil {
+		return err
+	}
+	defer untrack()
+
+	// Bound the stream's lifetime if the client asked for it, including sends
+	// blocked on a client that stopped reading
+	ctx := stream.Context()
+	if req.StreamTimeoutMs > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(req.StreamTimeoutMs)*time.Millisecond)
+		defer cancel()
+		stream = &timeoutStream{KeyValueStore_SubscribeServer: stream, ctx: ctx, timeoutMs: req.StreamTimeoutMs}
+	}
+
+	// Registered for acknowledgments before events so none goes unacknowledged
+	if req.AckMode {
+		if err := s.registerAcker(sub, stream); err != nil {
+			return err
+		}
+		defer s.unregisterAcker(sub)
+	}
 
-	// Clean up on exit
-	defer func() {
-		s.removeSubscriber(req.KeyPattern, sub)
-		close(sub.events)
-		slog.Info("subscriber unregistered", "pattern", req.KeyPattern)
-	}()
+	// Register subscriber and clean up on exit
+	s.addSubscriber(sub)
+	defer s.dropSubscriber(sub)
 
-	// Stream events to client
+	// Registered before replaying so no change between the two is lost. Resume
+	// runs first since it sends the response header, and live events it
+	// already covered are skipped
+	var resumedThrough int64
+	if req.ResumeFromSequence > 0 {
+		if resumedThrough, err = s.replayHistory(sub, req.ResumeFromSequence, stream); err != nil {
+			slog.Error("failed to resume subscriber", "pattern", req.KeyPattern, "error", err)
+			return err
+		}
+	} else if req.AckMode {
+		// Send the subscription ID now, a resume sends it with its own header
+		if err := stream.SendHeader(nil); err != nil {
+			return err
+		}
+	}
+
+	if req.ReplayExisting {
+		if err := s.replayExisting(req, sub, stream); err != nil {
+			return err
+		}
+	}
+
+	send := func(event *pb.ChangeEvent) error {
+		// Everything queued before a flush marker has been sent once it is reached
+		if s.takeFlushMarker(event) {
+			return nil
+		}
+		// Unsequenced events such as TTL warnings are never part of a replay
+		if event.Sequence != 0 && event.Sequence <= resumedThrough {
+			return nil
+		}
+		if err := stream.Send(event); err != nil {
+			slog.Error("failed to send event to subscriber", "pattern", req.KeyPattern, "error", err)
+			return err
+		}
+		slog.Debug("event sent to subscriber", "pattern", req.KeyPattern, "key", event.Key)
+		return nil
+	}
+
+	// Stream events to client. Until a lazy channel is allocated events is
+	// nil and never receives, ready fires once it exists
+	events := sub.buffer()
+	var ready chan struct{}
+	if events == nil {
+		ready = sub.eventsReady
+	}
 	for {
+		// Overflowed events are newer than anything in the channel, so they
+		// go out once it is drained
+		if sub.dlq != nil && len(events) == 0 {
+			if event, ok := sub.dlq.pop(); ok {
+				if err := send(event); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
 		select {
-		case event := <-sub.events:
-			if err := stream.Send(event); err != nil {
-				slog.Error("failed to send event to subscriber", "pattern", req.KeyPattern, "error", err)
+		case event := <-events:
+			s.observeFill(sub)
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-ready:
+			events, ready = sub.events, nil
+		case <-sub.dlq.readyChan():
+		case <-ctx.Done():
+			if err := streamTimeout(stream); err != nil {
+				slog.Warn("subscription stream timed out", "pattern", req.KeyPattern, "stream_timeout_ms", req.StreamTimeoutMs)
 				return err
 			}
-			slog.Debug("event sent to subscriber", "pattern", req.KeyPattern, "key", event.Key)
-		case <-stream.Context().Done():
 			slog.Info("subscription stream closed by client", "pattern", req.KeyPattern)
 			return nil
+		case <-s.done:
+			slog.Info("subscription ended, service closing", "pattern", req.KeyPattern)
+			return status.Error(codes.Unavailable, "service is closing")
 		}
 	}
 }
 
-// Send change events to matching subscribers
-func (s *KVStoreService) notifySubscribers(event *pb.ChangeEvent) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// Send the current value of every matching key to a new subscriber
+func (s *KVStoreService) replayExisting(req *pb.SubscribeRequest, sub *subscriber, stream pb.KeyValueStore_SubscribeServer) error {
+	var events []*pb.ChangeEvent
+	now := time.Now().UnixNano()
+	s.ForEach(func(key, value string) bool {
+		if !strings.HasPrefix(key, req.KeyPattern) {
+			return true
+		}
+		// Filtered like live events, so allowed_types without SET replays nothing
+		event := &pb.ChangeEvent{
+			ChangeType: pb.ChangeEvent_SET,
+			Key:        key,
+			Value:      value,
+			Timestamp:  now,
+			Version:    s.version(key),
+			Meta:       s.meta.get(key),
+		}
+		if sub.accepts(event) {
+			events = append(events, event)
+			s.recordGet(key)
+		}
+		return true
+	})
+
+	if len(events) == 0 && req.StrictReplay {
+		slog.Info("strict replay found no matching keys", "pattern", req.KeyPattern)
+		if err := stream.Send(&pb.ChangeEvent{
+			ChangeType: pb.ChangeEvent_NO_INITIAL_KEYS,
+			Timestamp:  now,
+		}); err != nil {
+			return err
+		}
+		return status.Errorf(codes.NotFound, "no keys match pattern %q", req.KeyPattern)
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Key < events[j].Key })
+	for _, event := range events {
+		if err := stream.Send(event); err != nil {
+			slog.Error("failed to replay event to subscriber", "pattern", req.KeyPattern, "error", err)
+			return err
+		}
+	}
+
+	slog.Info("replayed existing keys to subscriber", "pattern", req.KeyPattern, "key_count", len(events))
+	return nil
+}
+
+// Register a subscriber to start receiving events
+func (s *KVStoreService) addSubscriber(sub *subscriber) {
+	s.mu.Lock()
+	s.subscribers[sub.pattern] = append(s.subscribers[sub.pattern], sub)
+	subscriberCount := len(s.subscribers[sub.pattern])
+	s.publishSubscribers()
+	s.mu.Unlock()
+
+	slog.Info("subscriber reistered", "pattern", sub.pattern, "total_subscribers", subscriberCount)
+}
+
+// Unregister a subscriber and close its channel
+func (s *KVStoreService) dropSubscriber(sub *subscriber) {
+	s.removeSubscriber(sub.pattern, sub)
+	s.releaseEvents(sub)
+	if sub.gone != nil {
+		close(sub.gone)
+	}
+	slog.Info("subscriber unregistered", "pattern", sub.pattern)
+}
+
+// Send change events to matching subscribers, returning how many were notified
+func (s *KVStoreService) notifySubscribers(ctx context.Context, event *pb.ChangeEvent) int {
+	notified, _ := s.publish(ctx, event)
+	return notified
+}
+
+// Notify subscribers of event, returning how many were notified and which of
+// them acknowledge events
+func (s *KVStoreService) publish(ctx context.Context, event *pb.ChangeEvent) (int, []*subscriber) {
+	if !s.recordEvent(ctx, event) {
+		return 0, nil
+	}
+	return s.deliverEvent(event)
+}
+
+// Stamp an event and add it to the history and event log. False when the
+// key's event rate holds it back from subscribers
+func (s *KVStoreService) recordEvent(ctx context.Context, event *pb.ChangeEvent) bool {
+	s.stampEvent(event)
+	s.attachMeta(event)
+	if s.history != nil {
+		s.history.record(event)
+		s.rememberSequence(event)
+	}
+	s.logEvent(ctx, event)
+	return s.allowKeyEvent(event)
+}
+
+// Queue an already recorded event for matching subscribers, returning how
+// many were notified and which of them acknowledge events
+func (s *KVStoreService) deliverEvent(event *pb.ChangeEvent) (int, []*subscriber) {
+	return s.deliverEvents([]*pb.ChangeEvent{event}, false)
+}
+
+// Queue already recorded events for matching subscribers in order. With
+// batch set, subscribers that asked for batches get the events they match as
+// one BATCH event. Returns how many subscribers were notified and which of
+// them acknowledge events
+func (s *KVStoreService) deliverEvents(events []*pb.ChangeEvent, batch bool) (int, []*subscriber) {
+	subscribers, done := s.subscribersForPublish()
+	defer done()
 
 	notifiedCount := 0
-	for pattern, subs := range s.subscribers {
-		if strings.HasPrefix(event.Key, pattern) {
-			for _, sub := range subs {
-				select {
-				case sub.events <- event:
-					notifiedCount++
-				default:
-					slog.Warn("subscriber channel full, skipping event", "pattern", pattern, "key", event.Key)
+	var ackers []*subscriber
+	for pattern, subs := range subscribers {
+		matched := filterEvents(events, func(event *pb.ChangeEvent) bool { return eventMatches(event, pattern) })
+		if len(matched) == 0 {
+			continue
+		}
+		start := s.notifyLoopStart()
+		for _, sub := range subs {
+			if s.deliverMatched(sub, filterEvents(matched, sub.accepts), batch) {
+				notifiedCount++
+				if sub.ack != nil {
+					ackers = append(ackers, sub)
 				}
 			}
 		}
+		s.observeNotifyLoop(pattern, start)
 	}
 
 	if notifiedCount > 0 {
-		slog.Info("notified subscribers", "key", event.Key, "subscriber_count", notifiedCount)
+		if len(events) == 1 {
+			slog.Info("notified subscribers", "key", events[0].Key, "subscriber_count", notifiedCount)
+		} else {
+			slog.Info("notified subscribers of batch", "key_count", len(events), "subscriber_count", notifiedCount)
+		}
+	}
+
+	return notifiedCount, ackers
+}
+
+// Queue the events a subscriber accepts, true if any were queued
+func (s *KVStoreService) deliverMatched(sub *subscriber, events []*pb.ChangeEvent, batch bool) bool {
+	if len(events) == 0 {
+		return false
+	}
+	if batch && sub.batchEvents {
+		last := events[len(events)-1]
+		return s.deliver(sub, &pb.ChangeEvent{
+			ChangeType: pb.ChangeEvent_BATCH,
+			Timestamp:  last.Timestamp,
+			Sequence:   last.Sequence,
+			Batch:      &pb.BatchChangeEvent{Events: events},
+		})
+	}
+	delivered := false
+	for _, event := range events {
+		if s.deliver(sub, event) {
+			delivered = true
+		}
+	}
+	return delivered
+}
+
+// Events keep accepts, reusing events when it accepts them all
+func filterEvents(events []*pb.ChangeEvent, keep func(*pb.ChangeEvent) bool) []*pb.ChangeEvent {
+	for i, event := range events {
+		if keep(event) {
+			continue
+		}
+		kept := slices.Clone(events[:i])
+		for _, event := range events[i+1:] {
+			if keep(event) {
+				kept = append(kept, event)
+			}
+		}
+		return kept
+	}
+	return events
+}
+
+// Queue an event for one subscriber, falling back to its dead letter queue.
+// Caller must hold mu for reading unless in lock-free mode
+func (s *KVStoreService) deliver(sub *subscriber, event *pb.ChangeEvent) bool {
+	queued := s.enqueue(sub, event)
+	s.countEnqueue(sub.pattern, queued)
+	return queued
+}
+
+func (s *KVStoreService) enqueue(sub *subscriber, event *pb.ChangeEvent) bool {
+	// Keep order: once events are queued, later ones queue behind them
+	if sub.dlq != nil && sub.dlq.len() > 0 {
+		return s.deadLetter(sub, event)
+	}
+	s.ensureEvents(sub)
+	select {
+	case sub.events <- event:
+		return true
+	default:
+		if sub.dlq != nil {
+			return s.deadLetter(sub, event)
+		}
+		slog.Warn("subscriber channel full, skipping event", "pattern", sub.pattern, "key", event.Key)
+		return false
 	}
 }
 
@@ -171,17 +934,21 @@ func (s *KVStoreService) notifySubscribers(event *pb.ChangeEvent) {
 func (s *KVStoreService) removeSubscriber(pattern string, sub *subscriber) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.assertRegistered(pattern, sub)
 
 	subs := s.subscribers[pattern]
-	for i, existingSub :=  range subs {
+	for i, existingSub := range subs {
 		if existingSub == sub {
 			s.subscribers[pattern] = append(subs[:i], subs[i+1:]...)
 			break
-		} 
+		}
 	}
 
 	// Clean up empty pattern lists
 	if len(s.subscribers[pattern]) == 0 {
 		delete(s.subscribers, pattern)
-	} 
+		metrics.SubscriberFillRatio.DeleteLabelValues(pattern)
+		forgetNotifyMetrics(pattern)
+	}
+	s.publishSubscribers()
 }
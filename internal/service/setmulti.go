@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Most pairs accepted by a single SetMulti
+const maxSetMultiPairs = 1000
+
+// Store all pairs under one exclusive lock and announce them together, so
+// subscribers see a single batch rather than a burst of separate events
+func (s *KVStoreService) SetMulti(ctx context.Context, req *pb.SetMultiRequest) (*pb.SetMultiResponse, error) {
+	if len(req.Pairs) == 0 {
+		slog.Warn("set multi request with no pairs")
+		return nil, status.Error(codes.InvalidArgument, "pairs cannot be empty")
+	}
+	if len(req.Pairs) > maxSetMultiPairs {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d pairs may be set at once", maxSetMultiPairs)
+	}
+	if err := s.shedWrite("SetMulti", ""); err != nil {
+		return nil, err
+	}
+
+	// Coalesce repeated keys to their last value, keeping first-seen order
+	var keys []string
+	values := make(map[string]string, len(req.Pairs))
+	for _, pair := range req.Pairs {
+		if pair.Key == "" {
+			return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+		}
+		key, err := s.normalizeKey(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+		if s.maxValueSize > 0 && len(pair.Value) > s.maxValueSize {
+			return nil, status.Errorf(codes.InvalidArgument, "value for %q exceeds maximum size of %d bytes", key, s.maxValueSize)
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = pair.Value
+	}
+
+	slog.Info("set multi request", "pair_count", len(req.Pairs), "key_count", len(keys))
+
+	versions, notified := s.setTogether(ctx, keys, values)
+
+	slog.Info("keys stored successfully", "key_count", len(keys), "subscriber_count", notified)
+	return &pb.SetMultiResponse{Versions: versions}, nil
+}
+
+// Store all pairs like SetMulti, announcing them in request order and only
+// once every pair is readable. A subscriber handling
+// one of the events can Get any other key of the request and see this write
+// or a later one. All other writes wait while the pairs are stored, so keep
+// requests small
+func (s *KVStoreService) SetOrdered(ctx context.Context, req *pb.SetOrderedRequest) (*pb.SetOrderedResponse, error) {
+	if len(req.Pairs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "pairs cannot be empty")
+	}
+	if len(req.Pairs) > maxSetMultiPairs {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d pairs may be set at once", maxSetMultiPairs)
+	}
+	if err := s.shedWrite("SetOrdered", ""); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(req.Pairs))
+	values := make(map[string]string, len(req.Pairs))
+	for _, pair := range req.Pairs {
+		if pair.Key == "" {
+			return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+		}
+		key, err := s.normalizeKey(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+		if s.maxValueSize > 0 && len(pair.Value) > s.maxValueSize {
+			return nil, status.Errorf(codes.InvalidArgument, "value for %q exceeds maximum size of %d bytes", key, s.maxValueSize)
+		}
+		// A repeated key would leave its first event announcing a value
+		// that is no longer readable
+		if _, ok := values[key]; ok {
+			return nil, status.Errorf(codes.InvalidArgument, "key %q appears more than once", key)
+		}
+		keys = append(keys, key)
+		values[key] = pair.Value
+	}
+
+	slog.Info("set ordered request", "key_count", len(keys))
+
+	versions, notified := s.setTogether(ctx, keys, values)
+
+	resp := &pb.SetOrderedResponse{Versions: make([]int64, len(keys))}
+	for i, key := range keys {
+		resp.Versions[i] = versions[key]
+	}
+	slog.Info("keys stored successfully", "key_count", len(keys), "subscriber_count", notified)
+	return resp, nil
+}
+
+// Store keys with exclusive access to the store, then notify subscribers of
+// them in order once the lock is released. Returns each key's new version
+// and how many subscribers were notified
+func (s *KVStoreService) setTogether(ctx context.Context, keys []string, values map[string]string) (map[string]int64, int) {
+	events := make([]*pb.ChangeEvent, 0, len(keys))
+	versions := make(map[string]int64, len(keys))
+
+	s.withStoreWrite(func() {
+		for _, key := range keys {
+			s.store.Store(key, values[key])
+			s.clearTTL(key)
+			version := s.bumpVersion(key)
+			versions[key] = version
+			events = append(events, &pb.ChangeEvent{
+				ChangeType: pb.ChangeEvent_SET,
+				Key:        key,
+				Value:      values[key],
+				Timestamp:  s.stampWrite(key, false),
+				Version:    version,
+			})
+		}
+	})
+
+	for _, key := range keys {
+		s.recordSet(key)
+	}
+	return versions, s.notifyBatch(ctx, events)
+}
+
+// Send changes made together to matching subscribers, as one BATCH event to
+// those that asked for batches. Returns how many subscribers were notified
+func (s *KVStoreService) notifyBatch(ctx context.Context, events []*pb.ChangeEvent) int {
+	allowed := make([]*pb.ChangeEvent, 0, len(events))
+	for _, event := range events {
+		if s.recordEvent(ctx, event) {
+			allowed = append(allowed, event)
+		}
+	}
+	notified, _ := s.deliverEvents(allowed, true)
+	return notified
+}
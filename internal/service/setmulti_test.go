@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Subscribe stream that hands every event it is sent to the test
+type recordingStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events chan *pb.ChangeEvent
+}
+
+func (r *recordingStream) Context() context.Context { return r.ctx }
+
+func (r *recordingStream) Send(event *pb.ChangeEvent) error {
+	select {
+	case r.events <- event:
+		return nil
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+}
+
+// Subscribe with req until the test ends, returning the events sent once
+// the subscription is registered
+func subscribe(t *testing.T, s *KVStoreService, req *pb.SubscribeRequest) <-chan *pb.ChangeEvent {
+	t.Helper()
+	// Subscribe rewrites req, so the pattern is read before it starts
+	pattern := req.KeyPattern
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &recordingStream{ctx: ctx, events: make(chan *pb.ChangeEvent, 100)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Subscribe(req, stream)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.RLock()
+		n := len(s.subscribers[pattern])
+		s.mu.RUnlock()
+		if n > 0 {
+			return stream.events
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription to %q never registered", pattern)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Wait for the next event on events
+func nextEvent(t *testing.T, events <-chan *pb.ChangeEvent) *pb.ChangeEvent {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event within 5s")
+		return nil
+	}
+}
+
+func TestSetMultiNotifiesLikePublish(t *testing.T) {
+	s := newTestService(t)
+	plain := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "user:"})
+	batched := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "user:1", BatchEvents: true})
+
+	_, err := s.SetMulti(context.Background(), &pb.SetMultiRequest{Pairs: []*pb.KeyValuePair{
+		{Key: "user:1", Value: "a"},
+		{Key: "user:2", Value: "b"},
+		{Key: "order:1", Value: "c"},
+	}})
+	if err != nil {
+		t.Fatalf("SetMulti: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for range 2 {
+		event := nextEvent(t, plain)
+		if event.Sequence == 0 {
+			t.Errorf("event for %s has no sequence, it skipped the history", event.Key)
+		}
+		seen[event.Key] = true
+	}
+	if !seen["user:1"] || !seen["user:2"] {
+		t.Errorf("plain subscriber got %v, want user:1 and user:2", seen)
+	}
+
+	batch := nextEvent(t, batched)
+	if batch.ChangeType != pb.ChangeEvent_BATCH || len(batch.Batch.GetEvents()) != 1 || batch.Batch.Events[0].Key != "user:1" {
+		t.Errorf("batched subscriber got %v, want a BATCH of user:1", batch)
+	}
+}
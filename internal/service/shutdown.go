@@ -0,0 +1,34 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Tell every Subscribe and MultiWatch stream the server is about to shut
+// down so clients reconnect elsewhere before their streams end, and refuse
+// new subscriptions from now on. Returns how many subscribers were told
+func (s *KVStoreService) AnnounceShutdown() int {
+	event := &pb.ChangeEvent{
+		ChangeType: pb.ChangeEvent_SERVER_SHUTDOWN,
+		Timestamp:  time.Now().UnixNano(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = true
+
+	notified := 0
+	for _, subs := range s.subscribers {
+		for _, sub := range subs {
+			// Sync and barrier watchers are internal and have no client to warn
+			if sub.hasClient() && s.deliver(sub, event) {
+				notified++
+			}
+		}
+	}
+	slog.Info("announced shutdown to subscribers", "subscriber_count", notified)
+	return notified
+}
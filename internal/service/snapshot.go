@@ -0,0 +1,136 @@
+package service
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Single line in a snapshot stream
+type snapshotEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Count bytes passing through a writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Count bytes passing through a reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Serialize the store as gzip-compressed, newline-delimited JSON. The pairs
+// are copied under the store lock and encoded after it is released, so a
+// slow w such as an upload does not block writers
+func (s *KVStoreService) WriteTo(w io.Writer) (int64, error) {
+	var entries []snapshotEntry
+	s.ForEach(func(key, value string) bool {
+		entries = append(entries, snapshotEntry{Key: key, Value: value})
+		return true
+	})
+
+	cw := &countingWriter{w: w}
+	gz := gzip.NewWriter(cw)
+	enc := json.NewEncoder(gz)
+
+	var encodeErr error
+	count := 0
+	for _, entry := range entries {
+		if encodeErr = enc.Encode(entry); encodeErr != nil {
+			break
+		}
+		count++
+	}
+
+	if encodeErr != nil {
+		return cw.n, fmt.Errorf("encode snapshot entry: %w", encodeErr)
+	}
+	if err := gz.Close(); err != nil {
+		return cw.n, fmt.Errorf("close snapshot stream: %w", err)
+	}
+
+	slog.Info("snapshot written", "key_count", count, "bytes", cw.n)
+	return cw.n, nil
+}
+
+// Restore k/v pairs from a stream produced by WriteTo
+func (s *KVStoreService) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	gz, err := gzip.NewReader(cr)
+	if err != nil {
+		return cr.n, fmt.Errorf("open snapshot stream: %w", err)
+	}
+	defer gz.Close()
+
+	var entries []snapshotEntry
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var entry snapshotEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return cr.n, fmt.Errorf("decode snapshot entry %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return cr.n, fmt.Errorf("read snapshot stream: %w", err)
+	}
+
+	// Apply only once the whole stream decoded cleanly
+	s.withStoreWrite(func() {
+		for _, entry := range entries {
+			s.store.Store(entry.Key, entry.Value)
+		}
+	})
+	for _, entry := range entries {
+		s.recordSet(entry.Key)
+	}
+
+	slog.Info("snapshot restored", "key_count", len(entries), "bytes", cr.n)
+	return cr.n, nil
+}
+
+// Write a snapshot to a local file
+func (s *KVStoreService) Snapshot(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := s.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close snapshot file: %w", err)
+	}
+
+	// Rename so readers never observe a partial snapshot
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename snapshot file: %w", err)
+	}
+	return nil
+}
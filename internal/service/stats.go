@@ -0,0 +1,47 @@
+package service
+
+import "github.com/amillerrr/distributed-kv-store/internal/storage"
+
+// Point-in-time figures describing the store
+type Stats struct {
+	KeyCount        int64        `json:"key_count"`
+	SubscriberCount int          `json:"subscriber_count"`
+	Capabilities    Capabilities `json:"capabilities"`
+	// Present only for the tiered storage backend
+	Tiers *storage.TierStats `json:"tiers,omitempty"`
+}
+
+// Optional request features this server understands
+type Capabilities struct {
+	FieldMaskSupported bool `json:"field_mask_supported"`
+}
+
+// Collect store-wide stats
+func (s *KVStoreService) Stats() Stats {
+	stats := Stats{
+		Capabilities: Capabilities{FieldMaskSupported: true},
+	}
+
+	stats.KeyCount = int64(s.ForEach(func(_, _ string) bool { return true }))
+
+	s.mu.RLock()
+	for _, subs := range s.subscribers {
+		stats.SubscriberCount += len(subs)
+	}
+	s.mu.RUnlock()
+
+	if tiers, ok := s.TierStats(); ok {
+		stats.Tiers = &tiers
+	}
+
+	return stats
+}
+
+// Hot tier hit rate and per-tier key counts, false unless storage is tiered
+func (s *KVStoreService) TierStats() (storage.TierStats, bool) {
+	tiered, ok := storage.As[interface{ TierStats() storage.TierStats }](s.store)
+	if !ok {
+		return storage.TierStats{}, false
+	}
+	return tiered.TierStats(), true
+}
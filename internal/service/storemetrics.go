@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+)
+
+// How often heap and GC figures are sampled for WithSyncMapMetrics
+const memStatsInterval = time.Minute
+
+// Count storage calls and sample heap and GC figures into reg, to correlate
+// GC pauses with key count growth. Optional backend capabilities such as Has
+// and RangeOrdered are not counted
+func WithSyncMapMetrics(reg prometheus.Registerer) Option {
+	return func(s *KVStoreService) {
+		s.storeMetricsReg = reg
+	}
+}
+
+// Collectors registered by WithSyncMapMetrics
+type storeMetrics struct {
+	loads   prometheus.Counter
+	stores  prometheus.Counter
+	deletes prometheus.Counter
+	ranges  prometheus.Counter
+
+	heapAlloc   prometheus.Gauge
+	heapObjects prometheus.Gauge
+	gcPauseP99  prometheus.Gauge
+}
+
+func newStoreMetrics(reg prometheus.Registerer) *storeMetrics {
+	counter := func(name, help string) prometheus.Counter {
+		return register(reg, prometheus.NewCounter(prometheus.CounterOpts{Namespace: "kvstore", Name: name, Help: help}))
+	}
+	gauge := func(name, help string) prometheus.Gauge {
+		return register(reg, prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "kvstore", Name: name, Help: help}))
+	}
+	return &storeMetrics{
+		loads:       counter("store_load_calls_total", "Total storage Load calls."),
+		stores:      counter("store_store_calls_total", "Total storage Store calls."),
+		deletes:     counter("store_delete_calls_total", "Total storage Delete and LoadAndDelete calls."),
+		ranges:      counter("store_range_calls_total", "Total full storage scans."),
+		heapAlloc:   gauge("runtime_heap_alloc_bytes", "Bytes of allocated heap objects at the last sample."),
+		heapObjects: gauge("runtime_heap_objects", "Number of allocated heap objects at the last sample."),
+		gcPauseP99:  gauge("runtime_gc_pause_p99_seconds", "99th percentile of the most recent 256 GC pauses at the last sample."),
+	}
+}
+
+// Register c, reusing an identical collector already registered, e.g. by an
+// earlier service in the same process
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) {
+			if existing, ok := already.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		slog.Error("failed to register store metric", "error", err)
+	}
+	return c
+}
+
+// Backend wrapper counting calls by kind
+type countingStore struct {
+	storage.Backend
+	metrics *storeMetrics
+}
+
+func (c *countingStore) Unwrap() storage.Backend {
+	return c.Backend
+}
+
+func (c *countingStore) Load(key string) (string, bool) {
+	c.metrics.loads.Inc()
+	return c.Backend.Load(key)
+}
+
+func (c *countingStore) Store(key, value string) {
+	c.metrics.stores.Inc()
+	c.Backend.Store(key, value)
+}
+
+func (c *countingStore) Delete(key string) {
+	c.metrics.deletes.Inc()
+	c.Backend.Delete(key)
+}
+
+func (c *countingStore) LoadAndDelete(key string) (string, bool) {
+	c.metrics.deletes.Inc()
+	return c.Backend.LoadAndDelete(key)
+}
+
+func (c *countingStore) Range(fn func(key, value string) bool) {
+	c.metrics.ranges.Inc()
+	c.Backend.Range(fn)
+}
+
+// Sample heap and GC figures every memStatsInterval until the service is closed
+func (s *KVStoreService) runMemStatsSampler(m *storeMetrics) {
+	ticker := time.NewTicker(memStatsInterval)
+	defer ticker.Stop()
+
+	for {
+		m.sampleMemStats()
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (m *storeMetrics) sampleMemStats() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	m.heapAlloc.Set(float64(stats.HeapAlloc))
+	m.heapObjects.Set(float64(stats.HeapObjects))
+
+	// PauseNs is a ring of the most recent pauses, unused slots are zero
+	pauses := make([]uint64, 0, len(stats.PauseNs))
+	for _, ns := range stats.PauseNs {
+		if ns > 0 {
+			pauses = append(pauses, ns)
+		}
+	}
+	if len(pauses) == 0 {
+		m.gcPauseP99.Set(0)
+		return
+	}
+	slices.Sort(pauses)
+	p99 := pauses[(len(pauses)*99-1)/100]
+	m.gcPauseP99.Set(time.Duration(p99).Seconds())
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Subscribe stream that gives up on a send once ctx, the stream's timeout, is
+// done. The send itself keeps waiting in the background until the handler
+// returns and gRPC closes the stream under it
+type timeoutStream struct {
+	pb.KeyValueStore_SubscribeServer
+	ctx       context.Context
+	timeoutMs int64
+}
+
+func (t *timeoutStream) Send(event *pb.ChangeEvent) error {
+	if err := streamTimeout(t); err != nil {
+		return err
+	}
+	sent := make(chan error, 1)
+	go func() {
+		sent <- t.KeyValueStore_SubscribeServer.Send(event)
+	}()
+	select {
+	case err := <-sent:
+		return err
+	case <-t.ctx.Done():
+		if err := streamTimeout(t); err != nil {
+			return err
+		}
+		return status.FromContextError(t.ctx.Err()).Err()
+	}
+}
+
+// Error to end a stream with once its timeout elapsed, nil if it has not or
+// the stream has no timeout
+func streamTimeout(stream pb.KeyValueStore_SubscribeServer) error {
+	t, ok := stream.(*timeoutStream)
+	if !ok || t.ctx.Err() == nil || t.KeyValueStore_SubscribeServer.Context().Err() != nil {
+		return nil
+	}
+	return status.Errorf(codes.DeadlineExceeded, "stream timeout of %dms elapsed", t.timeoutMs)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Subscribe stream of a client that never reads, so every Send blocks
+type stalledStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stalledStream) Context() context.Context { return s.ctx }
+
+func (s *stalledStream) Send(*pb.ChangeEvent) error {
+	<-s.ctx.Done()
+	return s.ctx.Err()
+}
+
+func TestSubscribeTimeoutEndsBlockedSend(t *testing.T) {
+	s := newTestService(t)
+	if _, err := s.Set(context.Background(), &pb.SetRequest{Key: "user:1", Value: "v"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Cancelled only once the test is over, as gRPC would after the handler returns
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Subscribe(&pb.SubscribeRequest{KeyPattern: "user:", ReplayExisting: true, StreamTimeoutMs: 100}, &stalledStream{ctx: ctx})
+	}()
+
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("err = %v, want DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe kept waiting on a send past its stream timeout")
+	}
+}
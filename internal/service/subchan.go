@@ -0,0 +1,113 @@
+package service
+
+import (
+	"sync"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Events buffered per subscriber before it counts as full
+const subscriberBuffer = 100
+
+// Allocate a subscriber's channel when the first event is queued for it
+// instead of when it subscribes. Idle subscribers then cost about 900 bytes
+// less heap each, at the price of a once check on every delivery and an
+// allocation on the publish path for the first event
+func WithLazyChannelInit() Option {
+	return func(s *KVStoreService) {
+		s.lazyChannels = true
+	}
+}
+
+// Reuse subscriber channels through a pool, pre-allocating poolSize of them.
+// Channels go back to the pool when their subscriber disconnects, which saves
+// allocations under subscription churn. Like any sync.Pool the garbage
+// collector may empty it, so the pre-allocation mostly helps right after start
+func WithSubscriberChannelPooling(poolSize int) Option {
+	return func(s *KVStoreService) {
+		s.chanPool = newEventChanPool(poolSize)
+	}
+}
+
+// Pool of empty subscriber channels
+type eventChanPool struct {
+	pool sync.Pool
+}
+
+func newEventChanPool(size int) *eventChanPool {
+	p := &eventChanPool{}
+	p.pool.New = func() any {
+		return make(chan *pb.ChangeEvent, subscriberBuffer)
+	}
+	for range size {
+		p.pool.Put(make(chan *pb.ChangeEvent, subscriberBuffer))
+	}
+	return p
+}
+
+func (p *eventChanPool) get() chan *pb.ChangeEvent {
+	return p.pool.Get().(chan *pb.ChangeEvent)
+}
+
+// Drain ch and return it to the pool. No one may send on ch anymore
+func (p *eventChanPool) put(ch chan *pb.ChangeEvent) {
+	for len(ch) > 0 {
+		<-ch
+	}
+	p.pool.Put(ch)
+}
+
+// New subscriber channel, from the pool when pooling is on
+func (s *KVStoreService) newEventChan() chan *pb.ChangeEvent {
+	if s.chanPool != nil {
+		return s.chanPool.get()
+	}
+	return make(chan *pb.ChangeEvent, subscriberBuffer)
+}
+
+// Set up the channel of a Subscribe stream, now or on first delivery
+func (s *KVStoreService) initEvents(sub *subscriber) {
+	sub.pooled = s.chanPool != nil
+	if !s.lazyChannels {
+		sub.events = s.newEventChan()
+		return
+	}
+	sub.eventsReady = make(chan struct{})
+}
+
+// Allocate a lazy subscriber's channel if it has none yet. Caller must hold
+// mu for reading unless in lock-free mode
+func (s *KVStoreService) ensureEvents(sub *subscriber) {
+	if sub.eventsReady == nil {
+		return
+	}
+	sub.eventsOnce.Do(func() {
+		sub.events = s.newEventChan()
+		close(sub.eventsReady)
+	})
+}
+
+// The subscriber's channel, nil while a lazy one is not allocated yet
+func (sub *subscriber) buffer() chan *pb.ChangeEvent {
+	if sub.eventsReady != nil {
+		select {
+		case <-sub.eventsReady:
+		default:
+			return nil
+		}
+	}
+	return sub.events
+}
+
+// Release the channel of a subscriber that has been removed. In lock-free
+// mode a publish may still send on it, so it is left alone
+func (s *KVStoreService) releaseEvents(sub *subscriber) {
+	ch := sub.buffer()
+	switch {
+	case ch == nil, s.lockFree:
+	case sub.pooled:
+		s.chanPool.put(ch)
+	default:
+		close(ch)
+	}
+}
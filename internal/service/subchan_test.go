@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// Heap held per idle subscriber, registered the way Subscribe registers them
+func BenchmarkIdleSubscribers(b *testing.B) {
+	const subscribers = 10000
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"eager", nil},
+		{"lazy", []Option{WithLazyChannelInit()}},
+		{"pooled", []Option{WithSubscriberChannelPooling(subscribers)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var perSub float64
+			for b.Loop() {
+				s := NewKVStoreService(bc.opts...)
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				for i := range subscribers {
+					sub := &subscriber{pattern: fmt.Sprintf("idle:%d", i)}
+					s.initEvents(sub)
+					// Registered directly, addSubscriber would log each one
+					s.mu.Lock()
+					s.subscribers[sub.pattern] = append(s.subscribers[sub.pattern], sub)
+					s.mu.Unlock()
+				}
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				perSub = float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)) / subscribers
+				runtime.KeepAlive(s)
+				s.Close()
+			}
+			b.ReportMetric(perSub, "heap-B/subscriber")
+		})
+	}
+}
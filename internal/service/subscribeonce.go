@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Longest SubscribeOnce may wait
+const maxSubscribeOnceTimeout = 60 * time.Second
+
+// Wait for the next change to a key starting with key_pattern, for scripts
+// that need one event without holding a stream open. Only changes made after
+// the call arrives are seen. Timing out is not an error: the response reports
+// timed_out instead
+func (s *KVStoreService) SubscribeOnce(ctx context.Context, req *pb.SubscribeOnceRequest) (*pb.SubscribeOnceResponse, error) {
+	if req.KeyPattern == "" {
+		return nil, status.Error(codes.InvalidArgument, "key_pattern cannot be empty")
+	}
+	timeout := time.Duration(req.TimeoutMs) * time.Millisecond
+	if timeout <= 0 || timeout > maxSubscribeOnceTimeout {
+		return nil, status.Errorf(codes.InvalidArgument, "timeout_ms must be between 1 and %d", maxSubscribeOnceTimeout.Milliseconds())
+	}
+	pattern, err := s.normalizeKey(req.KeyPattern)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	draining := s.draining
+	s.mu.RUnlock()
+	if draining {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	untrack, err := s.trackStream()
+	if err != nil {
+		return nil, err
+	}
+	defer untrack()
+
+	sub := &subscriber{
+		pattern:       pattern,
+		valueContains: req.ValueContains,
+		events:        make(chan *pb.ChangeEvent, subscriberBuffer),
+	}
+	s.addSubscriber(sub)
+	defer s.dropSubscriber(sub)
+	slog.Info("waiting for change", "pattern", pattern, "value_contains", req.ValueContains, "timeout", timeout)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case event := <-sub.events:
+			// Value filters pass changes without a value, such as deletes
+			if req.ValueContains != "" && !strings.Contains(event.Value, req.ValueContains) {
+				continue
+			}
+			return &pb.SubscribeOnceResponse{Event: event}, nil
+		case <-timer.C:
+			return &pb.SubscribeOnceResponse{TimedOut: true}, nil
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-s.done:
+			return nil, status.Error(codes.Unavailable, "service is closing")
+		}
+	}
+}
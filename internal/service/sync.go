@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// Header carrying the sender's node ID on Sync streams, in both directions
+	NodeIDHeader = "x-kvstore-node-id"
+
+	// Delay bounds between attempts to reconnect to a sync peer
+	syncMinBackoff = time.Second
+	syncMaxBackoff = 30 * time.Second
+
+	// How long a deleted key's stamp is kept, so a peer's older write that
+	// arrives after the delete cannot bring the key back
+	syncTombstoneTTL = 5 * time.Minute
+)
+
+// Either end of a Sync stream
+type syncStream interface {
+	Context() context.Context
+	Send(*pb.ChangeEvent) error
+	Recv() (*pb.ChangeEvent, error)
+}
+
+// Last write applied to a key, orders concurrent writes from different nodes
+type writeStamp struct {
+	timestamp int64
+	origin    string
+}
+
+// Report whether w wins over other under last-writer-wins
+func (w writeStamp) after(other writeStamp) bool {
+	if w.timestamp != other.timestamp {
+		return w.timestamp > other.timestamp
+	}
+	return w.origin > other.origin
+}
+
+// Stamps of deleted keys in the order they were deleted
+type tombstones struct {
+	mu      sync.Mutex
+	entries []tombstone
+}
+
+type tombstone struct {
+	key       string
+	stamp     writeStamp
+	deletedAt time.Time
+}
+
+// Remember that key was deleted with stamp
+func (t *tombstones) add(key string, stamp writeStamp) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = append(t.entries, tombstone{key: key, stamp: stamp, deletedAt: time.Now()})
+}
+
+// Drop stamps of keys deleted more than syncTombstoneTTL before now, unless
+// the key was written again since
+func (t *tombstones) prune(stamps *sync.Map, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := now.Add(-syncTombstoneTTL)
+	n := 0
+	for n < len(t.entries) && t.entries[n].deletedAt.Before(cutoff) {
+		stamps.CompareAndDelete(t.entries[n].key, t.entries[n].stamp)
+		n++
+	}
+	t.entries = slices.Delete(t.entries, 0, n)
+}
+
+// Identify this instance to sync peers, a random ID is used when unset
+func WithNodeID(id string) Option {
+	return func(s *KVStoreService) {
+		if id != "" {
+			s.nodeID = id
+		}
+	}
+}
+
+// Node ID tagged on changes made on this instance
+func (s *KVStoreService) NodeID() string {
+	return s.nodeID
+}
+
+// First value of an incoming metadata header, empty if absent
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Random node ID for instances that were not given one
+func newNodeID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Exchange changes with the peer that opened the stream
+func (s *KVStoreService) Sync(stream pb.KeyValueStore_SyncServer) error {
+	peer := metadataValue(stream.Context(), NodeIDHeader)
+	if peer == "" {
+		return status.Errorf(codes.InvalidArgument, "%s header is required", NodeIDHeader)
+	}
+	if peer == s.nodeID {
+		return status.Error(codes.InvalidArgument, "cannot sync with itself")
+	}
+
+	untrack, err := s.trackStream()
+	if err != nil {
+		return err
+	}
+	defer untrack()
+
+	if err := stream.SendHeader(metadata.Pairs(NodeIDHeader, s.nodeID)); err != nil {
+		return err
+	}
+
+	slog.Info("sync peer connected", "peer", peer)
+	err = s.runSync(stream, peer)
+	slog.Info("sync peer disconnected", "peer", peer, "error", err)
+	return err
+}
+
+// Keep syncing with the peer behind kv until ctx is done or the service closes,
+// reconnecting with backoff whenever the stream fails
+func (s *KVStoreService) SyncWith(ctx context.Context, kv pb.KeyValueStoreClient) {
+	backoff := syncMinBackoff
+	for {
+		start := time.Now()
+		err := s.syncOnce(ctx, kv)
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		// A stream that stayed up for a while was healthy, start backoff over
+		if time.Since(start) > syncMaxBackoff {
+			backoff = syncMinBackoff
+		}
+		slog.Warn("sync with peer failed, retrying", "error", err, "backoff", backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		}
+		backoff = min(backoff*2, syncMaxBackoff)
+	}
+}
+
+// Run a single outgoing Sync stream until it fails
+func (s *KVStoreService) syncOnce(ctx context.Context, kv pb.KeyValueStoreClient) error {
+	untrack, err := s.trackStream()
+	if err != nil {
+		return err
+	}
+	defer untrack()
+
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(ctx, NodeIDHeader, s.nodeID))
+	defer cancel()
+
+	stream, err := kv.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	header, err := stream.Header()
+	if err != nil {
+		return err
+	}
+	peers := header.Get(NodeIDHeader)
+	if len(peers) == 0 || peers[0] == "" {
+		return status.Errorf(codes.Unknown, "peer did not send %s", NodeIDHeader)
+	}
+
+	slog.Info("syncing with peer", "peer", peers[0])
+	return s.runSync(stream, peers[0])
+}
+
+// Forward local changes to the peer while applying the peer's changes, until
+// either side fails or the service closes
+func (s *KVStoreService) runSync(stream syncStream, peer string) error {
+	sub := &subscriber{
+		events: make(chan *pb.ChangeEvent, 100),
+		// Sync is lossy under sustained overload, queue generously before that
+		dlq: newDeadLetterQueue(maxDLQSize),
+	}
+	s.addSubscriber(sub)
+	defer s.dropSubscriber(sub)
+
+	received := make(chan error, 1)
+	go func() {
+		received <- s.receiveSync(stream, peer)
+	}()
+
+	forward := func(event *pb.ChangeEvent) error {
+		// The peer already has its own changes
+		if event.OriginNodeId == peer {
+			return nil
+		}
+		return stream.Send(event)
+	}
+
+	for {
+		if len(sub.events) == 0 {
+			if event, ok := sub.dlq.pop(); ok {
+				if err := forward(event); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		select {
+		case event := <-sub.events:
+			if err := forward(event); err != nil {
+				return err
+			}
+		case <-sub.dlq.readyChan():
+		case err := <-received:
+			return err
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "service is closing")
+		}
+	}
+}
+
+// Apply changes from the peer until it closes its side of the stream
+func (s *KVStoreService) receiveSync(stream syncStream, peer string) error {
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if event.OriginNodeId == "" {
+			event.OriginNodeId = peer
+		}
+		s.applyRemote(stream.Context(), event)
+	}
+}
+
+// Apply a change made on another node if it is newer than the local state
+func (s *KVStoreService) applyRemote(ctx context.Context, event *pb.ChangeEvent) {
+	if event.OriginNodeId == s.nodeID {
+		return
+	}
+	switch event.ChangeType {
+	case pb.ChangeEvent_SET, pb.ChangeEvent_APPEND, pb.ChangeEvent_DELETE:
+		if event.Key == "" {
+			return
+		}
+	case pb.ChangeEvent_DELETE_RANGE:
+		s.applyRemoteRange(ctx, event)
+		return
+	default:
+		return
+	}
+	// Deletes free memory, so only new values are turned away
+	if event.ChangeType != pb.ChangeEvent_DELETE && s.shedWrite("sync", event.Key) != nil {
+		return
+	}
+	if s.maxValueSize > 0 && len(event.Value) > s.maxValueSize {
+		slog.Warn("synced value too large, skipping", "key", event.Key, "value_length", len(event.Value), "origin", event.OriginNodeId)
+		return
+	}
+
+	incoming := writeStamp{timestamp: event.Timestamp, origin: event.OriginNodeId}
+	applied := &pb.ChangeEvent{
+		ChangeType:   event.ChangeType,
+		Key:          event.Key,
+		Value:        event.Value,
+		Timestamp:    event.Timestamp,
+		OriginNodeId: event.OriginNodeId,
+	}
+
+	stale := false
+	found := true
+	s.withKeyLock(event.Key, func() error {
+		if current, ok := s.stamps.Load(event.Key); ok && !incoming.after(current.(writeStamp)) {
+			stale = true
+			return nil
+		}
+		// Appends carry the full new value, so they apply like a SET
+		if event.ChangeType != pb.ChangeEvent_DELETE {
+			s.store.Store(event.Key, event.Value)
+			s.restoreTTL(event.Key, event.ExpiresAtMs)
+			applied.Version = s.bumpVersion(event.Key)
+			applied.ExpiresAtMs = event.ExpiresAtMs
+		} else {
+			_, found = s.store.LoadAndDelete(event.Key)
+			s.clearTTL(event.Key)
+			s.forgetVersion(event.Key)
+			applied.Meta = s.meta.remove(event.Key)
+			s.tombstones.add(event.Key, incoming)
+		}
+		s.stamps.Store(event.Key, incoming)
+		return nil
+	})
+	if stale {
+		slog.Debug("synced change is stale, skipping", "key", event.Key, "origin", event.OriginNodeId)
+		return
+	}
+
+	if event.ChangeType == pb.ChangeEvent_DELETE {
+		s.forgetStats(event.Key)
+		// Nothing changed locally, but the tombstone still orders later writes
+		if !found {
+			return
+		}
+	} else {
+		// A key a peer keeps writing is in use, even if nobody reads it here
+		s.recordSet(event.Key)
+	}
+
+	slog.Debug("applied synced change", "key", event.Key, "change_type", event.ChangeType, "origin", event.OriginNodeId)
+	s.notifySubscribers(ctx, applied)
+}
+
+// Timestamp a local write to key and record it as the key's latest, ordered
+// after any write already applied to it. Caller must hold the key lock so
+// stamps follow the order writes are applied in. A delete's stamp is kept
+// for syncTombstoneTTL so an older synced write cannot resurrect the key
+func (s *KVStoreService) stampWrite(key string, deleted bool) int64 {
+	now := time.Now().UnixNano()
+	if current, ok := s.stamps.Load(key); ok {
+		now = max(now, current.(writeStamp).timestamp+1)
+	}
+	stamp := writeStamp{timestamp: now, origin: s.nodeID}
+	s.stamps.Store(key, stamp)
+	if deleted {
+		s.tombstones.add(key, stamp)
+	}
+	return now
+}
+
+// Tag a change with its origin and remember it as the key's latest write.
+// Writes that called stampWrite are already recorded
+func (s *KVStoreService) stampEvent(event *pb.ChangeEvent) {
+	if event.OriginNodeId == "" {
+		event.OriginNodeId = s.nodeID
+	}
+	if event.Key == "" {
+		return
+	}
+	s.stampKey(event.Key, writeStamp{timestamp: event.Timestamp, origin: event.OriginNodeId})
+}
+
+// Record stamp as the key's latest write unless a later one is known
+func (s *KVStoreService) stampKey(key string, stamp writeStamp) {
+	for {
+		current, loaded := s.stamps.LoadOrStore(key, stamp)
+		if !loaded || !stamp.after(current.(writeStamp)) {
+			return
+		}
+		if s.stamps.CompareAndSwap(key, current, stamp) {
+			return
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/amillerrr/distributed-kv-store/internal/config"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Create a service with the default config and close it when the test ends
+func newTestService(t *testing.T, opts ...Option) *KVStoreService {
+	t.Helper()
+	s := NewKVStoreService(append([]Option{WithConfig(config.Default())}, opts...)...)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestApplyRemoteKeepsTTL(t *testing.T) {
+	s := newTestService(t)
+	expiresAt := time.Now().Add(time.Hour).UnixMilli()
+	s.applyRemote(context.Background(), &pb.ChangeEvent{
+		ChangeType:   pb.ChangeEvent_SET,
+		Key:          "session:1",
+		Value:        "v",
+		Timestamp:    time.Now().UnixNano(),
+		OriginNodeId: "peer",
+		ExpiresAtMs:  expiresAt,
+	})
+	if got, ok := s.expiresAt("session:1"); !ok || got != expiresAt {
+		t.Errorf("expiresAt = %d, %v, want %d", got, ok, expiresAt)
+	}
+}
+
+func TestLocalWritesStampInOrder(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	// A peer's stamp from a clock ahead of ours
+	ahead := time.Now().Add(time.Minute).UnixNano()
+	s.applyRemote(ctx, &pb.ChangeEvent{ChangeType: pb.ChangeEvent_SET, Key: "k", Value: "remote", Timestamp: ahead, OriginNodeId: "peer"})
+
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "local"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	stamp, _ := s.stamps.Load("k")
+	if got := stamp.(writeStamp); got.timestamp <= ahead || got.origin != s.nodeID {
+		t.Errorf("stamp after local write = %+v, want later than %d from %s", got, ahead, s.nodeID)
+	}
+}
+
+func TestTombstonesArePruned(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "v"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Delete(ctx, &pb.DeleteRequest{Key: "k"}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := s.stamps.Load("k"); !ok {
+		t.Fatal("delete left no tombstone")
+	}
+
+	s.tombstones.prune(&s.stamps, time.Now())
+	if _, ok := s.stamps.Load("k"); !ok {
+		t.Error("tombstone pruned before syncTombstoneTTL")
+	}
+	s.tombstones.prune(&s.stamps, time.Now().Add(syncTombstoneTTL+time.Second))
+	if _, ok := s.stamps.Load("k"); ok {
+		t.Error("tombstone kept after syncTombstoneTTL")
+	}
+}
+
+func TestEventTimestampsIncrease(t *testing.T) {
+	s := newTestService(t)
+	events := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "k"})
+
+	ctx := context.Background()
+	for range 2 {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "v"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	first, second := nextEvent(t, events), nextEvent(t, events)
+	if second.Timestamp <= first.Timestamp {
+		t.Errorf("timestamps %d then %d, want strictly increasing", first.Timestamp, second.Timestamp)
+	}
+	if second.Sequence <= first.Sequence {
+		t.Errorf("sequences %d then %d, want strictly increasing", first.Sequence, second.Sequence)
+	}
+}
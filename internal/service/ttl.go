@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// How often expired keys are removed in the background
+const ttlReapInterval = time.Second
+
+// Expire key after ttl, returning the expiry as Unix ms
+func (s *KVStoreService) setTTL(key string, ttl time.Duration) int64 {
+	expiresAt := time.Now().Add(ttl).UnixMilli()
+	s.expiries.Store(key, expiresAt)
+	return expiresAt
+}
+
+// Expire key at expiresAt Unix ms as a peer did, removing its TTL if 0
+func (s *KVStoreService) restoreTTL(key string, expiresAt int64) {
+	s.clearTTL(key)
+	if expiresAt > 0 {
+		s.expiries.Store(key, expiresAt)
+	}
+}
+
+// Retrieve the expiry of a key as Unix ms, false if it has no TTL
+func (s *KVStoreService) expiresAt(key string) (int64, bool) {
+	if v, ok := s.expiries.Load(key); ok {
+		return v.(int64), true
+	}
+	return 0, false
+}
+
+// Remove the TTL from a key
+func (s *KVStoreService) clearTTL(key string) {
+	s.expiries.Delete(key)
+	s.warnedKeys.Delete(key)
+}
+
+// Report whether a key's TTL has passed
+func (s *KVStoreService) isExpired(key string) bool {
+	expiresAt, ok := s.expiresAt(key)
+	return ok && time.Now().UnixMilli() >= expiresAt
+}
+
+// Delete a key whose TTL has passed and notify subscribers
+func (s *KVStoreService) expireKey(key string) {
+	expired := false
+	var meta map[string]string
+	var timestamp int64
+	s.withKeyLock(key, func() error {
+		// Re-check under the key lock in case the key was rewritten
+		if !s.isExpired(key) {
+			return nil
+		}
+		s.store.Delete(key)
+		s.clearTTL(key)
+		s.forgetVersion(key)
+		s.forgetStats(key)
+		meta = s.meta.remove(key)
+		timestamp = s.stampWrite(key, true)
+		expired = true
+		return nil
+	})
+	if !expired {
+		return
+	}
+
+	slog.Info("key expired", "key", key)
+	s.notifySubscribers(context.Background(), &pb.ChangeEvent{
+		ChangeType: pb.ChangeEvent_DELETE,
+		Key:        key,
+		Timestamp:  timestamp,
+		Meta:       meta,
+	})
+}
+
+// Remove expired keys every interval until the service is closed, so they
+// are deleted and announced even if never read again. Abandoned scan
+// sessions and old tombstones are dropped on the same schedule
+func (s *KVStoreService) runTTLReaper() {
+	ticker := time.NewTicker(ttlReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.reapExpired()
+			s.warnExpiring()
+			s.reapScanSessions()
+			s.tombstones.prune(&s.stamps, time.Now())
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Expire every key whose TTL has passed
+func (s *KVStoreService) reapExpired() {
+	now := time.Now().UnixMilli()
+	var expired []string
+	s.expiries.Range(func(k, v any) bool {
+		if now >= v.(int64) {
+			expired = append(expired, k.(string))
+		}
+		return true
+	})
+
+	for _, key := range expired {
+		s.expireKey(key)
+	}
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Subscribers already warned about a key during the TTL ending at expiresAt.
+// Only the TTL reaper reads or writes subs
+type ttlWarnings struct {
+	expiresAt int64
+	subs      map[*subscriber]struct{}
+}
+
+// A key whose TTL ends within the longest warning threshold
+type expiringKey struct {
+	key       string
+	expiresAt int64
+	remaining time.Duration
+}
+
+// A TTL_WARNING waiting to be delivered
+type ttlWarning struct {
+	sub       *subscriber
+	event     *pb.ChangeEvent
+	remaining time.Duration
+}
+
+// Send a TTL_WARNING to each subscriber whose threshold a key has crossed,
+// once per subscriber for each TTL the key is given. Expiries are scanned
+// without holding mu, which is only taken to deliver the warnings
+func (s *KVStoreService) warnExpiring() {
+	watchers, longest := s.ttlWatchers()
+	if len(watchers) == 0 {
+		return
+	}
+
+	now := time.Now()
+	var due []expiringKey
+	s.expiries.Range(func(k, v any) bool {
+		key, expiresAt := k.(string), v.(int64)
+		if remaining := time.UnixMilli(expiresAt).Sub(now); remaining > 0 && remaining < longest {
+			due = append(due, expiringKey{key: key, expiresAt: expiresAt, remaining: remaining})
+		}
+		return true
+	})
+
+	var warnings []ttlWarning
+	for _, e := range due {
+		var warned *ttlWarnings
+		if w, ok := s.warnedKeys.Load(e.key); ok && w.(*ttlWarnings).expiresAt == e.expiresAt {
+			warned = w.(*ttlWarnings)
+			// Forget subscribers that have gone since they were warned
+			for sub := range warned.subs {
+				if !slices.Contains(watchers, sub) {
+					delete(warned.subs, sub)
+				}
+			}
+		}
+
+		for _, sub := range watchers {
+			if e.remaining >= sub.ttlWarnThreshold || !strings.HasPrefix(e.key, sub.pattern) {
+				continue
+			}
+			if warned == nil {
+				warned = &ttlWarnings{expiresAt: e.expiresAt, subs: make(map[*subscriber]struct{})}
+				s.warnedKeys.Store(e.key, warned)
+			}
+			if _, ok := warned.subs[sub]; ok {
+				continue
+			}
+			warned.subs[sub] = struct{}{}
+			warnings = append(warnings, ttlWarning{sub: sub, remaining: e.remaining, event: &pb.ChangeEvent{
+				ChangeType:  pb.ChangeEvent_TTL_WARNING,
+				Key:         e.key,
+				Timestamp:   now.UnixNano(),
+				ExpiresAtMs: e.expiresAt,
+				Meta:        s.meta.get(e.key),
+			}})
+		}
+	}
+	if len(warnings) == 0 {
+		return
+	}
+
+	subscribers, done := s.subscribersForPublish()
+	defer done()
+	for _, w := range warnings {
+		// The subscriber may have gone while mu was not held
+		if !slices.Contains(subscribers[w.sub.pattern], w.sub) {
+			continue
+		}
+		if w.sub.accepts(w.event) && s.deliver(w.sub, w.event) {
+			slog.Info("ttl warning sent", "key", w.event.Key, "pattern", w.sub.pattern, "remaining", w.remaining)
+		}
+	}
+}
+
+// Subscribers that asked for TTL warnings, and the longest threshold among them
+func (s *KVStoreService) ttlWatchers() ([]*subscriber, time.Duration) {
+	subscribers, done := s.subscribersForPublish()
+	defer done()
+
+	var watchers []*subscriber
+	var longest time.Duration
+	for _, subs := range subscribers {
+		for _, sub := range subs {
+			if sub.ttlWarnThreshold > 0 {
+				watchers = append(watchers, sub)
+				longest = max(longest, sub.ttlWarnThreshold)
+			}
+		}
+	}
+	return watchers, longest
+}
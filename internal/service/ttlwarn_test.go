@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestWarnExpiringWarnsOnceAndForgetsGoneSubscribers(t *testing.T) {
+	s := newTestService(t)
+	events := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "session:", TtlWarnThresholdMs: 60_000})
+
+	ttl := int64(30_000)
+	if _, err := s.Set(context.Background(), &pb.SetRequest{Key: "session:1", Value: "v", TtlMs: &ttl}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if event := nextEvent(t, events); event.ChangeType != pb.ChangeEvent_SET {
+		t.Fatalf("first event = %v, want the SET", event.ChangeType)
+	}
+
+	s.warnExpiring()
+	if event := nextEvent(t, events); event.ChangeType != pb.ChangeEvent_TTL_WARNING || event.Key != "session:1" {
+		t.Fatalf("event = %v for %q, want TTL_WARNING for session:1", event.ChangeType, event.Key)
+	}
+
+	// A subscriber that has since gone is dropped from the key's warnings
+	v, _ := s.warnedKeys.Load("session:1")
+	warned := v.(*ttlWarnings)
+	gone := &subscriber{pattern: "session:"}
+	warned.subs[gone] = struct{}{}
+
+	s.warnExpiring()
+	if _, ok := warned.subs[gone]; ok {
+		t.Error("gone subscriber is still held by the key's warnings")
+	}
+	if len(warned.subs) != 1 {
+		t.Errorf("key's warnings hold %d subscribers, want 1", len(warned.subs))
+	}
+	select {
+	case event := <-events:
+		t.Errorf("second pass sent %v, want no repeat warning", event.ChangeType)
+	default:
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/metrics"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Look up keys Get misses locally in upstream, storing any value found so
+// later Gets are served locally. Other reads do not fall back. Fills are not
+// announced to subscribers, and keys removed upstream stay cached until
+// deleted or until the fill TTL passes. upstream must not lead back to this
+// service, or every miss waits out its deadline
+func WithUpstreamStore(upstream pb.KeyValueStoreClient) Option {
+	return func(s *KVStoreService) {
+		s.upstream = upstream
+	}
+}
+
+// Expire values filled from the upstream store after d, 0 keeps them
+func WithUpstreamFillTTL(d time.Duration) Option {
+	return func(s *KVStoreService) {
+		s.upstreamFillTTL = d
+	}
+}
+
+// Longest an upstream lookup may take. Lookups outlive the Get that started
+// them, so this rather than any caller's deadline bounds them
+const upstreamLookupTimeout = 10 * time.Second
+
+// Upstream lookup shared by concurrent Gets of the same key
+type upstreamFetch struct {
+	done    chan struct{}
+	value   string
+	version int64
+	found   bool
+	err     error
+}
+
+// Count a Get against the upstream cache metrics
+func (s *KVStoreService) countUpstream(result string) {
+	if s.upstream != nil {
+		metrics.UpstreamCacheRequests.WithLabelValues(result).Inc()
+	}
+}
+
+// Fetch a locally missing key from upstream and store it. Concurrent callers
+// for the same key share one lookup, which runs on its own so a caller giving
+// up does not fail the others. Returns the value and its local version
+func (s *KVStoreService) fillFromUpstream(ctx context.Context, key string) (string, int64, bool, error) {
+	v, loaded := s.upstreamFetches.LoadOrStore(key, &upstreamFetch{done: make(chan struct{})})
+	f := v.(*upstreamFetch)
+	if !loaded {
+		go s.runUpstreamFetch(context.WithoutCancel(ctx), key, f)
+	}
+	select {
+	case <-f.done:
+		return f.value, f.version, f.found, f.err
+	case <-ctx.Done():
+		return "", 0, false, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// Look key up upstream and store what is found, publishing the result in f
+func (s *KVStoreService) runUpstreamFetch(ctx context.Context, key string, f *upstreamFetch) {
+	defer func() {
+		s.upstreamFetches.Delete(key)
+		close(f.done)
+	}()
+	ctx, cancel := context.WithTimeout(ctx, upstreamLookupTimeout)
+	defer cancel()
+
+	// Every local write and delete leaves a new sync stamp, so an unchanged
+	// stamp means nothing happened to the key during the lookup
+	before, _ := s.stamps.Load(key)
+
+	resp, err := s.upstream.Get(ctx, &pb.GetRequest{Key: key})
+	if err != nil {
+		s.countUpstream("error")
+		slog.Warn("upstream lookup failed", "key", key, "error", err)
+		f.err = status.Errorf(codes.Unavailable, "upstream lookup failed: %s", status.Convert(err).Message())
+		return
+	}
+	if !resp.Found {
+		return
+	}
+
+	// A local write or delete that landed during the lookup is newer, keep it
+	filled := false
+	s.withKeyLock(key, func() error {
+		value, found := s.store.Load(key)
+		live := found && !s.isExpired(key)
+		if after, _ := s.stamps.Load(key); live || after != before {
+			if live {
+				f.value, f.version, f.found = value, s.version(key), true
+			}
+			return nil
+		}
+		s.store.Store(key, resp.Value)
+		s.clearTTL(key)
+		if s.upstreamFillTTL > 0 {
+			s.setTTL(key, s.upstreamFillTTL)
+		}
+		f.value, f.version, f.found = resp.Value, s.bumpVersion(key), true
+		filled = true
+		return nil
+	})
+	if filled {
+		s.countUpstream("fill")
+		slog.Info("key filled from upstream", "key", key, "value_length", len(resp.Value))
+	}
+	if f.found {
+		s.recordSet(key)
+	}
+}
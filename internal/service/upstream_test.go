@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Upstream whose Get answers with value once release is closed
+type blockingUpstream struct {
+	pb.KeyValueStoreClient
+	started chan struct{}
+	release chan struct{}
+	value   string
+}
+
+func newBlockingUpstream(value string) *blockingUpstream {
+	return &blockingUpstream{started: make(chan struct{}, 1), release: make(chan struct{}), value: value}
+}
+
+func (u *blockingUpstream) Get(ctx context.Context, req *pb.GetRequest, _ ...grpc.CallOption) (*pb.GetResponse, error) {
+	u.started <- struct{}{}
+	select {
+	case <-u.release:
+		return &pb.GetResponse{Value: u.value, Found: true}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestUpstreamFillSurvivesFirstCallerCancel(t *testing.T) {
+	upstream := newBlockingUpstream("remote")
+	s := newTestService(t, WithUpstreamStore(upstream))
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := s.Get(first, &pb.GetRequest{Key: "k"})
+		firstErr <- err
+	}()
+	<-upstream.started
+
+	second := make(chan *pb.GetResponse, 1)
+	go func() {
+		resp, err := s.Get(context.Background(), &pb.GetRequest{Key: "k"})
+		if err != nil {
+			t.Errorf("Get sharing the lookup: %v", err)
+		}
+		second <- resp
+	}()
+
+	cancel()
+	if err := <-firstErr; err == nil {
+		t.Error("canceled Get succeeded")
+	}
+	close(upstream.release)
+	select {
+	case resp := <-second:
+		if resp == nil || resp.Value != "remote" {
+			t.Errorf("Get sharing the lookup = %v, want the upstream value", resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Get sharing the lookup never returned")
+	}
+}
+
+func TestUpstreamFillKeepsConcurrentDelete(t *testing.T) {
+	upstream := newBlockingUpstream("remote")
+	s := newTestService(t, WithUpstreamStore(upstream))
+	ctx := context.Background()
+
+	got := make(chan *pb.GetResponse, 1)
+	go func() {
+		resp, err := s.Get(ctx, &pb.GetRequest{Key: "k"})
+		if err != nil {
+			t.Errorf("Get: %v", err)
+		}
+		got <- resp
+	}()
+	<-upstream.started
+
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "local"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Delete(ctx, &pb.DeleteRequest{Key: "k"}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	close(upstream.release)
+
+	if resp := <-got; resp == nil || resp.Found {
+		t.Errorf("Get = %v, want not found after the concurrent delete", resp)
+	}
+	if _, found := s.store.Load("k"); found {
+		t.Error("fill undid the delete")
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Check that a value filter is a well-formed gjson path
+func validateValueFilter(filter string) error {
+	if strings.TrimSpace(filter) == "" {
+		return status.Error(codes.InvalidArgument, "value_filter cannot be blank")
+	}
+	if strings.HasPrefix(filter, ".") || strings.HasSuffix(filter, ".") {
+		return status.Errorf(codes.InvalidArgument, "invalid value_filter %q: path cannot start or end with '.'", filter)
+	}
+
+	// gjson accepts any string, so catch unbalanced queries and literals here
+	var open []rune
+	pairs := map[rune]rune{')': '(', ']': '[', '}': '{'}
+	inString := false
+	for i, r := range filter {
+		switch {
+		case r == '"' && (i == 0 || filter[i-1] != '\\'):
+			inString = !inString
+		case inString:
+		case r == '(' || r == '[' || r == '{':
+			open = append(open, r)
+		case pairs[r] != 0:
+			if len(open) == 0 || open[len(open)-1] != pairs[r] {
+				return status.Errorf(codes.InvalidArgument, "invalid value_filter %q: unbalanced %q", filter, r)
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if inString || len(open) > 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid value_filter %q: unterminated expression", filter)
+	}
+	return nil
+}
+
+// Report whether a JSON value matches the filter: it must exist and not be false, null, 0 or ""
+func matchesValueFilter(value, filter string) bool {
+	if !gjson.Valid(value) {
+		return false
+	}
+
+	result := gjson.Get(value, filter)
+	switch result.Type {
+	case gjson.False, gjson.Null:
+		return false
+	case gjson.Number:
+		return result.Num != 0
+	case gjson.String:
+		return result.Str != ""
+	default:
+		return result.Exists()
+	}
+}
+
+// Report whether the subscriber wants this event. Value filters only apply
+// to events that carry a value, so deletes of matching keys still arrive
+func (sub *subscriber) accepts(event *pb.ChangeEvent) bool {
+	if len(sub.allowedTypes) > 0 && !slices.Contains(sub.allowedTypes, event.ChangeType) {
+		return false
+	}
+	if event.Key != "" && !hasLabels(event.Meta, sub.metaFilter) {
+		return false
+	}
+	if event.ChangeType != pb.ChangeEvent_SET && event.ChangeType != pb.ChangeEvent_APPEND {
+		return true
+	}
+	return sub.acceptsValue(event.Value)
+}
+
+// Report whether a value passes the subscriber's value filters
+func (sub *subscriber) acceptsValue(value string) bool {
+	if sub.valueContains != "" && !strings.Contains(value, sub.valueContains) {
+		return false
+	}
+	return sub.valueFilter == "" || matchesValueFilter(value, sub.valueFilter)
+}
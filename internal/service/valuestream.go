@@ -0,0 +1,125 @@
+package service
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	defaultStreamChunkSize = 1 << 20
+	// Keeps a chunk and its framing under the default 4 MB gRPC message limit
+	maxStreamChunkSize = 3 << 20
+	// Most SetStream buffers when MAX_VALUE_SIZE_MB sets no limit, so a client
+	// cannot make the server hold an unbounded value in memory
+	maxStreamValueSize = 256 << 20
+)
+
+// Send the value of a key in chunks so values larger than the gRPC message
+// limit can be read. The value is read once, like a Get
+func (s *KVStoreService) GetStream(req *pb.GetStreamRequest, stream pb.KeyValueStore_GetStreamServer) error {
+	if req.ChunkSizeBytes < 0 || req.ChunkSizeBytes > maxStreamChunkSize {
+		return status.Errorf(codes.InvalidArgument, "chunk_size_bytes must be between 0 and %d", maxStreamChunkSize)
+	}
+	chunkSize := int(req.ChunkSizeBytes)
+	if chunkSize == 0 {
+		chunkSize = defaultStreamChunkSize
+	}
+
+	resp, err := s.Get(stream.Context(), &pb.GetRequest{Key: req.Key})
+	if err != nil {
+		return err
+	}
+	if !resp.Found {
+		return status.Errorf(codes.NotFound, "key %q not found", req.Key)
+	}
+
+	value := resp.Value
+	chunks := 0
+	for offset := 0; ; offset += chunkSize {
+		end := min(offset+chunkSize, len(value))
+		chunk := &pb.GetStreamChunk{
+			Data:   []byte(value[offset:end]),
+			Offset: int64(offset),
+			Eof:    end == len(value),
+		}
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		chunks++
+		if chunk.Eof {
+			break
+		}
+	}
+
+	slog.Info("get stream completed", "key", req.Key, "value_length", len(value), "chunks", chunks)
+	return nil
+}
+
+// Assemble a value sent in chunks and store it with a single Set once the
+// client closes the stream, so subscribers see one change. The stream passes
+// the same auth, signing and rate limit interceptors as Set on opening
+func (s *KVStoreService) SetStream(stream pb.KeyValueStore_SetStreamServer) error {
+	// Checked before any chunk is buffered
+	if err := s.shedWrite("SetStream", ""); err != nil {
+		return err
+	}
+	limit := s.maxValueSize
+	if limit <= 0 {
+		limit = maxStreamValueSize
+	}
+
+	var value strings.Builder
+	var key string
+	var ttlMs *int64
+	chunks := 0
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if chunks == 0 {
+			if chunk.Key == "" {
+				return status.Error(codes.InvalidArgument, "the first chunk must set key")
+			}
+			key, ttlMs = chunk.Key, chunk.TtlMs
+		} else if (chunk.Key != "" && chunk.Key != key) || chunk.TtlMs != nil {
+			return status.Error(codes.InvalidArgument, "only the first chunk may set key and ttl_ms")
+		}
+		if chunk.Offset != int64(value.Len()) {
+			return status.Errorf(codes.InvalidArgument, "chunk offset %d does not follow the %d bytes received", chunk.Offset, value.Len())
+		}
+		// Checked as chunks arrive so an oversized value is not buffered in full
+		if value.Len()+len(chunk.Data) > limit {
+			slog.Warn("set stream exceeds maximum value size", "key", key, "received", value.Len()+len(chunk.Data), "max", limit)
+			return status.Errorf(codes.InvalidArgument, "value exceeds maximum size of %d bytes", limit)
+		}
+		value.Write(chunk.Data)
+		chunks++
+	}
+
+	if chunks == 0 {
+		return status.Error(codes.InvalidArgument, "set stream sent no chunks")
+	}
+	// Values are proto strings, which must be UTF-8 to be read back
+	if !utf8.ValidString(value.String()) {
+		return status.Error(codes.InvalidArgument, "value is not valid UTF-8")
+	}
+
+	resp, err := s.Set(stream.Context(), &pb.SetRequest{Key: key, Value: value.String(), TtlMs: ttlMs})
+	if err != nil {
+		return err
+	}
+	slog.Info("set stream completed", "key", key, "value_length", value.Len(), "chunks", chunks)
+	return stream.SendAndClose(resp)
+}
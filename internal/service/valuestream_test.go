@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Send value in chunks of chunkSize with SetStream
+func setStream(t *testing.T, kv pb.KeyValueStoreClient, key, value string, chunkSize int) (*pb.SetResponse, error) {
+	t.Helper()
+	stream, err := kv.SetStream(context.Background())
+	if err != nil {
+		t.Fatalf("SetStream: %v", err)
+	}
+	for offset := 0; offset == 0 || offset < len(value); offset += chunkSize {
+		chunk := &pb.SetStreamChunk{Data: []byte(value[offset:min(offset+chunkSize, len(value))]), Offset: int64(offset)}
+		if offset == 0 {
+			chunk.Key = key
+		}
+		// The server stops reading once it rejects the stream
+		if err := stream.Send(chunk); err != nil {
+			break
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+// Read a value with GetStream, returning it and how many chunks carried it
+func getStream(t *testing.T, kv pb.KeyValueStoreClient, key string, chunkSize int32) (string, int, error) {
+	t.Helper()
+	stream, err := kv.GetStream(context.Background(), &pb.GetStreamRequest{Key: key, ChunkSizeBytes: chunkSize})
+	if err != nil {
+		t.Fatalf("GetStream: %v", err)
+	}
+	var value strings.Builder
+	chunks := 0
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			return "", chunks, err
+		}
+		if chunk.Offset != int64(value.Len()) {
+			t.Fatalf("chunk at offset %d after %d bytes", chunk.Offset, value.Len())
+		}
+		value.Write(chunk.Data)
+		chunks++
+		if chunk.Eof {
+			return value.String(), chunks, nil
+		}
+	}
+}
+
+func TestValueStreamRoundTrip(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+	value := strings.Repeat("0123456789", 1000)
+
+	tests := []struct {
+		name       string
+		value      string
+		chunkSize  int
+		wantChunks int
+	}{
+		{"many chunks", value, 1024, 10},
+		{"exact multiple", value, 2500, 4},
+		{"single chunk", value, 0, 1},
+		{"empty value", "", 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := setStream(t, kv, "blob:1", tt.value, max(tt.chunkSize, 1))
+			if err != nil || !resp.Success {
+				t.Fatalf("SetStream = %v, %v", resp, err)
+			}
+			got, chunks, err := getStream(t, kv, "blob:1", int32(tt.chunkSize))
+			if err != nil {
+				t.Fatalf("GetStream: %v", err)
+			}
+			if got != tt.value {
+				t.Errorf("read back %d bytes, want %d", len(got), len(tt.value))
+			}
+			if chunks != tt.wantChunks {
+				t.Errorf("%d chunks, want %d", chunks, tt.wantChunks)
+			}
+		})
+	}
+}
+
+func TestSetStreamIsOneChange(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+	events := subscribe(t, s, &pb.SubscribeRequest{KeyPattern: "blob:"})
+
+	value := strings.Repeat("x", 5000)
+	if _, err := setStream(t, kv, "blob:1", value, 1000); err != nil {
+		t.Fatalf("SetStream: %v", err)
+	}
+	if event := nextEvent(t, events); event.ChangeType != pb.ChangeEvent_SET || event.Value != value {
+		t.Errorf("event = %v with %d bytes, want one SET of the whole value", event.ChangeType, len(event.Value))
+	}
+}
+
+func TestSetStreamRejectsOversizedValue(t *testing.T) {
+	s := newTestService(t, WithMaxValueSize(4096))
+	kv := newTestClient(t, s)
+
+	_, err := setStream(t, kv, "blob:1", strings.Repeat("x", 4097), 1024)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("err = %v, want InvalidArgument", err)
+	}
+	if resp, _ := s.Get(context.Background(), &pb.GetRequest{Key: "blob:1"}); resp.Found {
+		t.Error("oversized value was stored")
+	}
+
+	// The limit itself is allowed
+	if _, err := setStream(t, kv, "blob:1", strings.Repeat("x", 4096), 1024); err != nil {
+		t.Errorf("value at the limit rejected: %v", err)
+	}
+}
+
+func TestSetStreamRejectsBadChunks(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+
+	tests := []struct {
+		name   string
+		chunks []*pb.SetStreamChunk
+	}{
+		{"no chunks", nil},
+		{"no key", []*pb.SetStreamChunk{{Data: []byte("a")}}},
+		{"gap", []*pb.SetStreamChunk{{Key: "k", Data: []byte("a")}, {Data: []byte("b"), Offset: 5}}},
+		{"key changed", []*pb.SetStreamChunk{{Key: "k", Data: []byte("a")}, {Key: "j", Data: []byte("b"), Offset: 1}}},
+		{"late ttl", []*pb.SetStreamChunk{{Key: "k", Data: []byte("a")}, {Data: []byte("b"), Offset: 1, TtlMs: proto.Int64(1000)}}},
+		{"invalid UTF-8", []*pb.SetStreamChunk{{Key: "k", Data: []byte{0xff}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, err := kv.SetStream(context.Background())
+			if err != nil {
+				t.Fatalf("SetStream: %v", err)
+			}
+			for _, chunk := range tt.chunks {
+				if err := stream.Send(chunk); err != nil {
+					break
+				}
+			}
+			if _, err := stream.CloseAndRecv(); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("err = %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
+
+func TestGetStreamErrors(t *testing.T) {
+	s := newTestService(t)
+	kv := newTestClient(t, s)
+
+	if _, _, err := getStream(t, kv, "blob:missing", 0); status.Code(err) != codes.NotFound {
+		t.Errorf("missing key: err = %v, want NotFound", err)
+	}
+	if _, _, err := getStream(t, kv, "blob:1", maxStreamChunkSize+1); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("oversized chunks: err = %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Retrieve a value with the version to pass back to SetWithVersion
+func (s *KVStoreService) GetWithVersion(ctx context.Context, req *pb.GetWithVersionRequest) (*pb.GetWithVersionResponse, error) {
+	resp, err := s.Get(ctx, &pb.GetRequest{Key: req.Key})
+	if err != nil {
+		return nil, err
+	}
+	return &pb.GetWithVersionResponse{
+		Value:   resp.Value,
+		Found:   resp.Found,
+		Version: resp.Version,
+	}, nil
+}
+
+// Store a value if the key is still at expected_version, or unconditionally
+// when it is 0. A lost race is reported through success rather than an error
+// so callers can re-read and retry
+func (s *KVStoreService) SetWithVersion(ctx context.Context, req *pb.SetWithVersionRequest) (*pb.SetWithVersionResponse, error) {
+	if req.ExpectedVersion < 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_version cannot be negative")
+	}
+	if err := s.shedWrite("SetWithVersion", req.Key); err != nil {
+		return nil, err
+	}
+
+	setReq := &pb.SetRequest{Key: req.Key, Value: req.Value}
+	if req.ExpectedVersion != 0 {
+		setReq.ConflictPolicy = pb.ConflictPolicy_POLICY_CAS
+		setReq.ExpectedVersion = req.ExpectedVersion
+	}
+
+	resp, err := s.Set(ctx, setReq)
+	if status.Code(err) == codes.Aborted {
+		// Set normalized the key, so this reads the same key it compared
+		var current int64
+		s.withStoreRead(func() {
+			current = s.version(setReq.Key)
+		})
+		return &pb.SetWithVersionResponse{Version: current, Success: false}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SetWithVersionResponse{Version: resp.Version, Success: true}, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// Number of mutex stripes guarding per-key read-check-write sequences
+const keyLockStripes = 256
+
+// Striped per-key locks, bounded in memory regardless of key count
+type keyLocks [keyLockStripes]sync.Mutex
+
+// Retrieve the mutex guarding key
+func (l *keyLocks) get(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &l[h.Sum32()%keyLockStripes]
+}
+
+// Run fn holding key's lock and storeMu for reading, as single-key writes
+// do, returning its error. Both are released even if fn panics, so a panic
+// recovered by the server cannot leave the key or the store locked
+func (s *KVStoreService) withKeyLock(key string, fn func() error) error {
+	lock := s.keyLocks.get(key)
+	lock.Lock()
+	defer lock.Unlock()
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+	return fn()
+}
+
+// Run fn holding storeMu for reading, released even if fn panics
+func (s *KVStoreService) withStoreRead(fn func()) {
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+	fn()
+}
+
+// Run fn holding storeMu exclusively so no write interleaves, released even
+// if fn panics
+func (s *KVStoreService) withStoreWrite(fn func()) {
+	s.storeMu.Lock()
+	defer s.storeMu.Unlock()
+	fn()
+}
+
+// Stored value and version of a key, ignoring its TTL
+func (s *KVStoreService) loadVersioned(key string) (string, bool, int64) {
+	s.storeMu.RLock()
+	defer s.storeMu.RUnlock()
+	value, found := s.store.Load(key)
+	return value, found, s.version(key)
+}
+
+// Current version of a key, 0 if it has never been written
+func (s *KVStoreService) version(key string) int64 {
+	if v, ok := s.versions.Load(key); ok {
+		return v.(int64)
+	}
+	return 0
+}
+
+// Advance the version of a key, caller must hold its key lock. Versions come
+// from a store-wide clock, so a caller holding the version of a deleted key
+// cannot match a key later created under the same name
+func (s *KVStoreService) bumpVersion(key string) int64 {
+	next := s.versionClock.Add(1)
+	s.versions.Store(key, next)
+	return next
+}
+
+// Drop the version and last sequence number of a removed key
+func (s *KVStoreService) forgetVersion(key string) {
+	s.versions.Delete(key)
+	s.sequences.Delete(key)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestVersionNotReusedAfterDelete(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+
+	first, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "a"})
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Delete(ctx, &pb.DeleteRequest{Key: "k"}); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "b"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// A CAS against the version read before the delete must not succeed
+	_, err = s.Set(ctx, &pb.SetRequest{Key: "k", Value: "c", ConflictPolicy: pb.ConflictPolicy_POLICY_CAS, ExpectedVersion: first.Version})
+	if status.Code(err) != codes.Aborted {
+		t.Errorf("CAS with a version from before the delete: err = %v, want Aborted", err)
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Stream changes to a single key until ctx is done or the service closes,
+// for callers outside gRPC such as the HTTP gateway. A DELETE_RANGE covering
+// the key arrives as a DELETE of the key. The channel is closed once the
+// watch ends, and like a subscription it drops events while full
+func (s *KVStoreService) Watch(ctx context.Context, key string) (<-chan *pb.ChangeEvent, error) {
+	if key == "" {
+		return nil, status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	key, err := s.normalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	untrack, err := s.trackStream()
+	if err != nil {
+		return nil, err
+	}
+
+	// Registered before returning so the caller can read the current value
+	// afterwards without missing a change
+	sub := &subscriber{
+		pattern: key,
+		events:  make(chan *pb.ChangeEvent, subscriberBuffer),
+	}
+	s.addSubscriber(sub)
+	slog.Info("watching key", "key", key)
+
+	out := make(chan *pb.ChangeEvent)
+	go func() {
+		defer untrack()
+		defer s.dropSubscriber(sub)
+		defer close(out)
+
+		for {
+			var event *pb.ChangeEvent
+			select {
+			case event = <-sub.events:
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			}
+
+			switch {
+			case event.ChangeType == pb.ChangeEvent_DELETE_RANGE && keyInRange(key, event.StartKey, event.EndKey):
+				event = &pb.ChangeEvent{
+					ChangeType:   pb.ChangeEvent_DELETE,
+					Key:          key,
+					Timestamp:    event.Timestamp,
+					Sequence:     event.Sequence,
+					OriginNodeId: event.OriginNodeId,
+				}
+			case event.Key != key:
+				continue
+			}
+
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Subscribe to keys starting with pattern without going through gRPC, for code
+// embedding the service. Events are written straight to the returned
+// channel, which holds bufSize of them (the Subscribe default if 0) and
+// drops further events while full. The channel is closed once ctx is done
+// or the service closes:
+//
+//	events, err := svc.WatchChan(ctx, "user:", 0)
+//	if err != nil {
+//		return err
+//	}
+//	for event := range events {
+//		log.Println(event.ChangeType, event.Key, event.Value)
+//	}
+func (s *KVStoreService) WatchChan(ctx context.Context, pattern string, bufSize int) (<-chan *pb.ChangeEvent, error) {
+	if pattern == "" {
+		return nil, status.Error(codes.InvalidArgument, "pattern cannot be empty")
+	}
+	if bufSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, "buffer size cannot be negative")
+	}
+	if bufSize == 0 {
+		bufSize = subscriberBuffer
+	}
+	pattern, err := s.normalizeKey(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	draining := s.draining
+	s.mu.RUnlock()
+	if draining {
+		return nil, status.Error(codes.Unavailable, "server is shutting down")
+	}
+	untrack, err := s.trackStream()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &subscriber{
+		pattern: pattern,
+		events:  make(chan *pb.ChangeEvent, bufSize),
+	}
+	if s.lockFree {
+		sub.gone = make(chan struct{})
+	}
+	s.addSubscriber(sub)
+	slog.Info("watching pattern", "pattern", pattern, "buffer_size", bufSize)
+
+	// Unregistering closes the channel, or stops forwarding in lock-free mode,
+	// ending the caller's range loop
+	go func() {
+		defer untrack()
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+		s.dropSubscriber(sub)
+	}()
+	if s.lockFree {
+		return s.forwardWatch(sub), nil
+	}
+	return sub.events, nil
+}
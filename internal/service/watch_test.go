@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Fail unless events is closed within 5s, discarding anything still queued
+func waitClosed(t *testing.T, events <-chan *pb.ChangeEvent) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("watch channel not closed within 5s")
+		}
+	}
+}
+
+func TestWatchChanDeliversMatchingEvents(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	events, err := s.WatchChan(ctx, "user:", 0)
+	if err != nil {
+		t.Fatalf("WatchChan: %v", err)
+	}
+
+	for _, key := range []string{"order:1", "user:1"} {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: key, Value: "v"}); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+	if event := nextEvent(t, events); event.ChangeType != pb.ChangeEvent_SET || event.Key != "user:1" {
+		t.Errorf("got %v, want the SET of user:1", event)
+	}
+}
+
+func TestWatchChanHonorsBufSize(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	events, err := s.WatchChan(ctx, "k", 3)
+	if err != nil {
+		t.Fatalf("WatchChan: %v", err)
+	}
+	if cap(events) != 3 {
+		t.Errorf("buffer holds %d events, want 3", cap(events))
+	}
+
+	// Events beyond the buffer are dropped while nobody reads
+	for range 5 {
+		if _, err := s.Set(ctx, &pb.SetRequest{Key: "k", Value: "v"}); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	if n := len(events); n != 3 {
+		t.Errorf("%d events queued, want 3", n)
+	}
+}
+
+func TestWatchChanClosesOnCancel(t *testing.T) {
+	s := newTestService(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := s.WatchChan(ctx, "user:", 0)
+	if err != nil {
+		t.Fatalf("WatchChan: %v", err)
+	}
+	cancel()
+	waitClosed(t, events)
+}
+
+func TestWatchChanClosesOnServiceClose(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"mutex":    nil,
+		"lockfree": {WithLockFreeSubscribers()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newTestService(t, opts...)
+			events, err := s.WatchChan(context.Background(), "user:", 0)
+			if err != nil {
+				t.Fatalf("WatchChan: %v", err)
+			}
+			s.Close()
+			waitClosed(t, events)
+		})
+	}
+}
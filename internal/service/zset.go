@@ -0,0 +1,300 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/btree"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Members returned by ZRange when the request sets no limit, and the most
+// it may ask for
+const (
+	defaultZRangeLimit = 1000
+	maxZRangeLimit     = 10000
+)
+
+// Cap the members of each sorted set at n, rejecting new members once a set
+// is full while still rescoring existing ones. 0 disables the limit
+func WithMaxZSetSize(n int) Option {
+	return func(s *KVStoreService) {
+		s.maxZSetSize = n
+	}
+}
+
+// Cap the number of sorted sets at n, rejecting ZAdd for a new key once
+// reached. 0 disables the limit
+func WithMaxZSets(n int) Option {
+	return func(s *KVStoreService) {
+		s.maxZSets = n
+	}
+}
+
+type scoredMember struct {
+	member string
+	score  float64
+}
+
+// Order by score, then by member so equal scores have a stable order
+func lessScored(a, b scoredMember) bool {
+	if c := cmp.Compare(a.score, b.score); c != 0 {
+		return c < 0
+	}
+	return a.member < b.member
+}
+
+// One sorted set: scores by member, and members in score order
+type sortedSet struct {
+	scores map[string]float64
+	order  *btree.BTreeG[scoredMember]
+}
+
+// Sorted sets by key, kept in memory apart from the string values in the
+// storage backend
+type sortedSets struct {
+	mu   sync.RWMutex
+	sets map[string]*sortedSet
+}
+
+func newSortedSets() *sortedSets {
+	return &sortedSets{sets: make(map[string]*sortedSet)}
+}
+
+// Set the score of member in the set at key, creating it as needed. Reports
+// whether member is new, or errors if that would grow the set past maxSize or
+// the number of sets past maxSets
+func (z *sortedSets) add(key, member string, score float64, maxSize, maxSets int) (bool, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	set := z.sets[key]
+	if set == nil {
+		if maxSets > 0 && len(z.sets) >= maxSets {
+			return false, status.Errorf(codes.ResourceExhausted, "already storing the maximum of %d sorted sets", maxSets)
+		}
+		set = &sortedSet{
+			scores: make(map[string]float64),
+			order:  btree.NewG(32, lessScored),
+		}
+		z.sets[key] = set
+	}
+	old, exists := set.scores[member]
+	if exists {
+		set.order.Delete(scoredMember{member: member, score: old})
+	} else if maxSize > 0 && len(set.scores) >= maxSize {
+		return false, status.Errorf(codes.ResourceExhausted, "sorted set %q already has the maximum of %d members", key, maxSize)
+	}
+	set.scores[member] = score
+	set.order.ReplaceOrInsert(scoredMember{member: member, score: score})
+	return !exists, nil
+}
+
+// Remove member from the set at key, dropping the set once empty. Reports
+// whether member was present
+func (z *sortedSets) remove(key, member string) bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	set := z.sets[key]
+	if set == nil {
+		return false
+	}
+	score, ok := set.scores[member]
+	if !ok {
+		return false
+	}
+	delete(set.scores, member)
+	set.order.Delete(scoredMember{member: member, score: score})
+	if len(set.scores) == 0 {
+		delete(z.sets, key)
+	}
+	return true
+}
+
+// Remove the whole set at key, reporting whether it existed
+func (z *sortedSets) drop(key string) bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if _, ok := z.sets[key]; !ok {
+		return false
+	}
+	delete(z.sets, key)
+	return true
+}
+
+// Keys of the sets for which match returns true, in no particular order
+func (z *sortedSets) keys(match func(key string) bool) []string {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	var keys []string
+	for key := range z.sets {
+		if match(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Score of member in the set at key, false if either does not exist
+func (z *sortedSets) score(key, member string) (float64, bool) {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	set := z.sets[key]
+	if set == nil {
+		return 0, false
+	}
+	score, ok := set.scores[member]
+	return score, ok
+}
+
+// Up to limit members of the set at key with scores in [min, max], lowest first
+func (z *sortedSets) rangeByScore(key string, min, max float64, limit int) []*pb.ScoredMember {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	set := z.sets[key]
+	if set == nil {
+		return nil
+	}
+	var members []*pb.ScoredMember
+	// The empty member sorts first among those scoring min
+	set.order.AscendGreaterOrEqual(scoredMember{score: min}, func(m scoredMember) bool {
+		if m.score > max {
+			return false
+		}
+		members = append(members, &pb.ScoredMember{Member: m.member, Score: m.score})
+		return len(members) < limit
+	})
+	return members
+}
+
+// Add a member to a sorted set or change its score
+func (s *KVStoreService) ZAdd(ctx context.Context, req *pb.ZAddRequest) (*pb.ZAddResponse, error) {
+	key, err := s.zsetKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if req.Member == "" {
+		return nil, status.Error(codes.InvalidArgument, "member cannot be empty")
+	}
+	if math.IsNaN(req.Score) {
+		return nil, status.Error(codes.InvalidArgument, "score cannot be NaN")
+	}
+	// Sets live only in memory, so they would escape the hot tier's bound
+	if _, ok := storage.As[*storage.Tiered](s.store); ok {
+		return nil, status.Error(codes.FailedPrecondition, "sorted sets are kept in memory and are not available with the tiered storage backend")
+	}
+	if err := s.shedWrite("ZAdd", key); err != nil {
+		return nil, err
+	}
+
+	slog.Info("zadd request", "key", key, "member", req.Member)
+
+	newMember, err := s.zsets.add(key, req.Member, req.Score, s.maxZSetSize, s.maxZSets)
+	if err != nil {
+		slog.Warn("sorted set rejected", "key", key, "max_size", s.maxZSetSize, "max_sets", s.maxZSets, "error", err)
+		return nil, err
+	}
+
+	s.notifySubscribers(ctx, &pb.ChangeEvent{
+		ChangeType: pb.ChangeEvent_ZADD,
+		Key:        key,
+		Member:     req.Member,
+		Score:      req.Score,
+		Timestamp:  time.Now().UnixNano(),
+	})
+
+	slog.Info("sorted set member stored", "key", key, "member", req.Member, "new_member", newMember)
+	return &pb.ZAddResponse{NewMember: newMember}, nil
+}
+
+// Retrieve the members of a sorted set scoring between two bounds
+func (s *KVStoreService) ZRange(ctx context.Context, req *pb.ZRangeRequest) (*pb.ZRangeResponse, error) {
+	key, err := s.zsetKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit < 0 || req.Limit > maxZRangeLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "limit must be between 0 and %d", maxZRangeLimit)
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = defaultZRangeLimit
+	}
+	min, max := math.Inf(-1), math.Inf(1)
+	if req.MinScore != nil {
+		min = *req.MinScore
+	}
+	if req.MaxScore != nil {
+		max = *req.MaxScore
+	}
+	if math.IsNaN(min) || math.IsNaN(max) {
+		return nil, status.Error(codes.InvalidArgument, "score bounds cannot be NaN")
+	}
+	if min > max {
+		return nil, status.Error(codes.InvalidArgument, "min_score cannot be greater than max_score")
+	}
+
+	members := s.zsets.rangeByScore(key, min, max, limit)
+	slog.Info("zrange request", "key", key, "min_score", min, "max_score", max, "member_count", len(members))
+	return &pb.ZRangeResponse{Members: members}, nil
+}
+
+// Remove a member from a sorted set
+func (s *KVStoreService) ZRem(ctx context.Context, req *pb.ZRemRequest) (*pb.ZRemResponse, error) {
+	key, err := s.zsetKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if req.Member == "" {
+		return nil, status.Error(codes.InvalidArgument, "member cannot be empty")
+	}
+
+	removed := s.zsets.remove(key, req.Member)
+	if removed {
+		s.notifySubscribers(ctx, &pb.ChangeEvent{
+			ChangeType: pb.ChangeEvent_ZREM,
+			Key:        key,
+			Member:     req.Member,
+			Timestamp:  time.Now().UnixNano(),
+		})
+	}
+
+	slog.Info("zrem request", "key", key, "member", req.Member, "removed", removed)
+	return &pb.ZRemResponse{Removed: removed}, nil
+}
+
+// Retrieve the score of a member of a sorted set
+func (s *KVStoreService) ZScore(ctx context.Context, req *pb.ZScoreRequest) (*pb.ZScoreResponse, error) {
+	key, err := s.zsetKey(req.Key)
+	if err != nil {
+		return nil, err
+	}
+	if req.Member == "" {
+		return nil, status.Error(codes.InvalidArgument, "member cannot be empty")
+	}
+
+	score, found := s.zsets.score(key, req.Member)
+	slog.Info("zscore request", "key", key, "member", req.Member, "found", found)
+	return &pb.ZScoreResponse{Score: score, Found: found}, nil
+}
+
+// Validate and normalize the key of a sorted set request
+func (s *KVStoreService) zsetKey(key string) (string, error) {
+	if key == "" {
+		return "", status.Error(codes.InvalidArgument, "key cannot be empty")
+	}
+	return s.normalizeKey(key)
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/amillerrr/distributed-kv-store/internal/storage"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+func TestZAddLimitsSetCount(t *testing.T) {
+	s := newTestService(t, WithMaxZSets(2))
+	ctx := context.Background()
+
+	for _, key := range []string{"a", "b"} {
+		if _, err := s.ZAdd(ctx, &pb.ZAddRequest{Key: key, Member: "m", Score: 1}); err != nil {
+			t.Fatalf("ZAdd %s: %v", key, err)
+		}
+	}
+	if _, err := s.ZAdd(ctx, &pb.ZAddRequest{Key: "c", Member: "m", Score: 1}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("ZAdd of a third set = %v, want ResourceExhausted", err)
+	}
+	// Existing sets still take new members
+	if _, err := s.ZAdd(ctx, &pb.ZAddRequest{Key: "a", Member: "n", Score: 2}); err != nil {
+		t.Errorf("ZAdd to an existing set: %v", err)
+	}
+}
+
+func TestDeleteRemovesSortedSets(t *testing.T) {
+	s := newTestService(t)
+	ctx := context.Background()
+	for _, key := range []string{"board", "range:1", "range:2", "part:x"} {
+		if _, err := s.ZAdd(ctx, &pb.ZAddRequest{Key: key, Member: "m", Score: 1}); err != nil {
+			t.Fatalf("ZAdd %s: %v", key, err)
+		}
+	}
+
+	resp, err := s.Delete(ctx, &pb.DeleteRequest{Key: "board"})
+	if err != nil || !resp.Deleted {
+		t.Errorf("Delete of a sorted set = %v, %v, want deleted", resp, err)
+	}
+	ranged, err := s.DeleteRange(ctx, &pb.DeleteRangeRequest{StartKey: "range:", EndKey: "range;"})
+	if err != nil || ranged.DeletedCount != 2 {
+		t.Errorf("DeleteRange = %v, %v, want 2 deleted", ranged, err)
+	}
+	part, err := s.DeletePartition(ctx, &pb.DeletePartitionRequest{Prefix: "part"})
+	if err != nil || part.DeletedCount != 1 {
+		t.Errorf("DeletePartition = %v, %v, want 1 deleted", part, err)
+	}
+
+	for _, key := range []string{"board", "range:1", "range:2", "part:x"} {
+		if _, found := s.zsets.score(key, "m"); found {
+			t.Errorf("sorted set %s survived deletion", key)
+		}
+	}
+}
+
+func TestZAddRejectedOnTieredBackend(t *testing.T) {
+	tiered, err := storage.NewTiered(filepath.Join(t.TempDir(), "cold.db"), 10)
+	if err != nil {
+		t.Fatalf("NewTiered: %v", err)
+	}
+	s := newTestService(t, WithStorage(tiered))
+
+	_, err = s.ZAdd(context.Background(), &pb.ZAddRequest{Key: "board", Member: "m", Score: 1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ZAdd on tiered = %v, want FailedPrecondition", err)
+	}
+}
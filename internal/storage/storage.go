@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/google/btree"
+)
+
+// Key/value storage behind the service, safe for concurrent use
+type Backend interface {
+	Load(key string) (string, bool)
+	Store(key, value string)
+	Delete(key string)
+	LoadAndDelete(key string) (string, bool)
+	// Call fn for every pair in no particular order until it returns false
+	Range(fn func(key, value string) bool)
+	Close() error
+}
+
+// Find the first backend in b's chain of wrappers that implements T, similar
+// to errors.As. Wrappers expose the backend they wrap with Unwrap() Backend
+func As[T any](b Backend) (T, bool) {
+	for b != nil {
+		if t, ok := b.(T); ok {
+			return t, true
+		}
+		w, ok := b.(interface{ Unwrap() Backend })
+		if !ok {
+			break
+		}
+		b = w.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
+
+// Unbounded in-memory backend. Keys are also kept in a B-tree so ordered
+// scans avoid a full sort, at the cost of an O(log n) insert for each new key.
+// Compare with go test -bench Memory ./internal/storage
+type Memory struct {
+	m sync.Map
+	// Guards index and keeps it in step with m across writers
+	mu    sync.RWMutex
+	index *btree.BTreeG[string]
+}
+
+func NewMemory() *Memory {
+	return &Memory{index: btree.NewOrderedG[string](32)}
+}
+
+func (m *Memory) Load(key string) (string, bool) {
+	v, ok := m.m.Load(key)
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
+func (m *Memory) Has(key string) bool {
+	_, ok := m.m.Load(key)
+	return ok
+}
+
+// Overwrites only share mu, since the index already holds the key and
+// Delete cannot remove it meanwhile. New keys take mu exclusively
+func (m *Memory) Store(key, value string) {
+	if m.overwrite(key, value) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.m.Store(key, value)
+	m.index.ReplaceOrInsert(key)
+}
+
+// Replace the value of a key already stored, false if it is not
+func (m *Memory) overwrite(key, value string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.m.Load(key); !ok {
+		return false
+	}
+	m.m.Store(key, value)
+	return true
+}
+
+func (m *Memory) Delete(key string) {
+	m.LoadAndDelete(key)
+}
+
+func (m *Memory) LoadAndDelete(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.m.LoadAndDelete(key)
+	if !ok {
+		return "", false
+	}
+	m.index.Delete(key)
+	return v.(string), true
+}
+
+func (m *Memory) Range(fn func(key, value string) bool) {
+	m.m.Range(func(k, v any) bool {
+		return fn(k.(string), v.(string))
+	})
+}
+
+// Call fn in key order for keys in [start, end) until it returns false, in
+// descending order when reverse is set. An empty end has no upper bound. fn
+// must not write to m
+func (m *Memory) RangeOrdered(start, end string, reverse bool, fn func(key, value string) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	visit := func(key string) bool {
+		v, ok := m.m.Load(key)
+		if !ok {
+			return true
+		}
+		return fn(key, v.(string))
+	}
+
+	switch {
+	case !reverse && end == "":
+		m.index.AscendGreaterOrEqual(start, visit)
+	case !reverse:
+		m.index.AscendRange(start, end, visit)
+	default:
+		descend := func(key string) bool {
+			if key == end {
+				return true
+			}
+			if key < start {
+				return false
+			}
+			return visit(key)
+		}
+		if end == "" {
+			m.index.Descend(descend)
+		} else {
+			m.index.DescendLessOrEqual(end, descend)
+		}
+	}
+}
+
+// Call fn in ascending order for keys in [start, end) until it returns false,
+// like RangeOrdered but without loading values. fn must not write to m
+func (m *Memory) KeysOrdered(start, end string, fn func(key string) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if end == "" {
+		m.index.AscendGreaterOrEqual(start, fn)
+	} else {
+		m.index.AscendRange(start, end, fn)
+	}
+}
+
+// Number of keys stored
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.index.Len()
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Memory without its key index, the baseline for the benchmarks
+type unindexed struct{ m sync.Map }
+
+func (u *unindexed) Load(key string) (string, bool) {
+	v, ok := u.m.Load(key)
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+func (u *unindexed) Store(key, value string) { u.m.Store(key, value) }
+func (u *unindexed) Delete(key string)       { u.m.Delete(key) }
+func (u *unindexed) LoadAndDelete(key string) (string, bool) {
+	v, ok := u.m.LoadAndDelete(key)
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+func (u *unindexed) Range(fn func(key, value string) bool) {
+	u.m.Range(func(k, v any) bool { return fn(k.(string), v.(string)) })
+}
+func (u *unindexed) Close() error { return nil }
+
+func TestMemoryIndexFollowsWrites(t *testing.T) {
+	m := NewMemory()
+	var wg sync.WaitGroup
+	for w := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				key := "k" + strconv.Itoa(i)
+				m.Store(key, strconv.Itoa(w))
+				if i%3 == w%3 {
+					m.Delete(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	var stored, indexed []string
+	m.Range(func(key, _ string) bool {
+		stored = append(stored, key)
+		return true
+	})
+	m.KeysOrdered("", "", func(key string) bool {
+		indexed = append(indexed, key)
+		return true
+	})
+	slices.Sort(stored)
+	if !slices.Equal(stored, indexed) {
+		t.Errorf("index has %d keys, map has %d", len(indexed), len(stored))
+	}
+	if m.Len() != len(stored) {
+		t.Errorf("Len() = %d, want %d", m.Len(), len(stored))
+	}
+}
+
+const benchKeys = 100_000
+
+var backends = []struct {
+	name string
+	new  func() Backend
+}{
+	{"index", func() Backend { return NewMemory() }},
+	{"no-index", func() Backend { return &unindexed{} }},
+}
+
+// Writes of new keys, which update the index
+func BenchmarkMemoryInsert(b *testing.B) {
+	for _, backend := range backends {
+		b.Run(backend.name, func(b *testing.B) {
+			store := backend.new()
+			var n atomic.Int64
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					store.Store("key:"+strconv.FormatInt(n.Add(1), 10), "value")
+				}
+			})
+		})
+	}
+}
+
+// Writes to existing keys, which leave the index alone
+func BenchmarkMemoryOverwrite(b *testing.B) {
+	for _, backend := range backends {
+		b.Run(backend.name, func(b *testing.B) {
+			store := backend.new()
+			keys := make([]string, benchKeys)
+			for i := range keys {
+				keys[i] = "key:" + strconv.Itoa(i)
+				store.Store(keys[i], "value")
+			}
+			var n atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(n.Add(1)) * 7919
+				for pb.Next() {
+					store.Store(keys[i%benchKeys], "value")
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package storage
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+const (
+	// How often demoted and removed keys are written to the cold tier
+	coldFlushInterval = 100 * time.Millisecond
+
+	// Pending cold writes that trigger a flush before the interval
+	coldFlushThreshold = 1000
+)
+
+var coldBucket = []byte("kv")
+
+// Ends a cold tier ForEach early
+var errStopRange = errors.New("range stopped")
+
+// Hit rate and key counts per tier
+type TierStats struct {
+	HitRate  float64 `json:"hit_rate"`
+	HotKeys  int     `json:"hot_keys"`
+	ColdKeys int     `json:"cold_keys"`
+}
+
+type hotEntry struct {
+	key   string
+	value string
+}
+
+// Unflushed cold tier state for a key
+type coldWrite struct {
+	value   string
+	deleted bool
+}
+
+// Bounded in-memory LRU in front of a BoltDB file. Each key lives in exactly
+// one tier: least recently used keys are demoted to disk in the background and
+// promoted back on access
+type Tiered struct {
+	db      *bolt.DB
+	maxHot  int
+	flushCh chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+
+	mu  sync.Mutex
+	hot map[string]*list.Element
+	lru *list.List
+	// Cold writes not yet handed to the flusher, and those being written now
+	pending  map[string]coldWrite
+	flushing map[string]coldWrite
+	// Completed flushes, tells Load whether the cold tier changed under it
+	flushes uint64
+
+	hits   int64
+	misses int64
+}
+
+// Create the cold tier at path, keeping at most maxHot keys in memory
+func NewTiered(path string, maxHot int) (*Tiered, error) {
+	if maxHot <= 0 {
+		return nil, fmt.Errorf("hot tier size must be positive, got %d", maxHot)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open cold tier %s: %w", path, err)
+	}
+	// The cold tier only extends memory, so data from a previous run is discarded
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(coldBucket); err != nil && !errors.Is(err, bolterrors.ErrBucketNotFound) {
+			return err
+		}
+		_, err := tx.CreateBucket(coldBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("create cold tier bucket: %w", err)
+	}
+
+	t := &Tiered{
+		db:      db,
+		maxHot:  maxHot,
+		flushCh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		hot:     make(map[string]*list.Element),
+		lru:     list.New(),
+		pending: make(map[string]coldWrite),
+	}
+	go t.runFlusher()
+
+	slog.Info("tiered storage opened", "path", path, "max_hot_keys", maxHot)
+	return t, nil
+}
+
+func (t *Tiered) Load(key string) (string, bool) {
+	t.mu.Lock()
+	if elem, ok := t.hot[key]; ok {
+		t.lru.MoveToFront(elem)
+		t.hits++
+		t.mu.Unlock()
+		return elem.Value.(*hotEntry).value, true
+	}
+	t.misses++
+
+	if value, found, ok := t.unflushed(key); ok {
+		if found {
+			t.promote(key, value)
+		}
+		t.mu.Unlock()
+		return value, found
+	}
+
+	for {
+		flushes := t.flushes
+		t.mu.Unlock()
+
+		value, found := t.loadCold(key)
+		if !found {
+			return "", false
+		}
+
+		t.mu.Lock()
+		// A write may have raced the disk read, the newer state wins
+		if elem, ok := t.hot[key]; ok {
+			t.mu.Unlock()
+			return elem.Value.(*hotEntry).value, true
+		}
+		if value, found, ok := t.unflushed(key); ok {
+			t.mu.Unlock()
+			return value, found
+		}
+		// A flush during the read may have written a delete or newer value
+		// the read missed, so promoting it could resurrect the key
+		if t.flushes != flushes {
+			continue
+		}
+		t.promote(key, value)
+		t.mu.Unlock()
+		return value, true
+	}
+}
+
+// Report whether key is stored without copying its value or promoting it
+func (t *Tiered) Has(key string) bool {
+	t.mu.Lock()
+	if _, ok := t.hot[key]; ok {
+		t.mu.Unlock()
+		return true
+	}
+	if _, found, ok := t.unflushed(key); ok {
+		t.mu.Unlock()
+		return found
+	}
+	t.mu.Unlock()
+
+	var found bool
+	err := t.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(coldBucket).Get([]byte(key)) != nil
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to read cold tier", "key", key, "error", err)
+		return false
+	}
+	return found
+}
+
+func (t *Tiered) Store(key, value string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if elem, ok := t.hot[key]; ok {
+		elem.Value.(*hotEntry).value = value
+		t.lru.MoveToFront(elem)
+		return
+	}
+	t.promote(key, value)
+}
+
+func (t *Tiered) Delete(key string) {
+	t.LoadAndDelete(key)
+}
+
+func (t *Tiered) LoadAndDelete(key string) (string, bool) {
+	value, found := t.Load(key)
+	if !found {
+		return "", false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if elem, ok := t.hot[key]; ok {
+		value = elem.Value.(*hotEntry).value
+		t.lru.Remove(elem)
+		delete(t.hot, key)
+	}
+	t.queueCold(key, coldWrite{deleted: true})
+	return value, true
+}
+
+func (t *Tiered) Range(fn func(key, value string) bool) {
+	// Snapshot memory first so keys moving between tiers are still seen once
+	t.mu.Lock()
+	seen := make(map[string]struct{}, len(t.hot)+len(t.pending)+len(t.flushing))
+	var pairs []hotEntry
+	for key, elem := range t.hot {
+		seen[key] = struct{}{}
+		pairs = append(pairs, hotEntry{key: key, value: elem.Value.(*hotEntry).value})
+	}
+	for _, writes := range []map[string]coldWrite{t.pending, t.flushing} {
+		for key, w := range writes {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			if !w.deleted {
+				pairs = append(pairs, hotEntry{key: key, value: w.value})
+			}
+		}
+	}
+	t.mu.Unlock()
+
+	for _, pair := range pairs {
+		if !fn(pair.key, pair.value) {
+			return
+		}
+	}
+
+	err := t.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(coldBucket).ForEach(func(k, v []byte) error {
+			if _, ok := seen[string(k)]; ok {
+				return nil
+			}
+			if !fn(string(k), string(v)) {
+				return errStopRange
+			}
+			return nil
+		})
+	})
+	if err != nil && err != errStopRange {
+		slog.Error("failed to iterate cold tier", "error", err)
+	}
+}
+
+// Hit rate of the hot tier and the number of keys held in each tier
+func (t *Tiered) TierStats() TierStats {
+	t.mu.Lock()
+	stats := TierStats{HotKeys: len(t.hot)}
+	if total := t.hits + t.misses; total > 0 {
+		stats.HitRate = float64(t.hits) / float64(total)
+	}
+	t.mu.Unlock()
+
+	t.db.View(func(tx *bolt.Tx) error {
+		stats.ColdKeys = tx.Bucket(coldBucket).Stats().KeyN
+		return nil
+	})
+	return stats
+}
+
+// Write out pending demotions and close the cold tier
+func (t *Tiered) Close() error {
+	close(t.done)
+	<-t.stopped
+	return t.db.Close()
+}
+
+// Move a key into the hot tier, demoting the least recently used if full.
+// Caller must hold mu
+func (t *Tiered) promote(key, value string) {
+	t.hot[key] = t.lru.PushFront(&hotEntry{key: key, value: value})
+	// The hot copy is now authoritative, drop any cold one
+	t.queueCold(key, coldWrite{deleted: true})
+
+	for t.lru.Len() > t.maxHot {
+		oldest := t.lru.Back()
+		entry := oldest.Value.(*hotEntry)
+		t.lru.Remove(oldest)
+		delete(t.hot, entry.key)
+		t.queueCold(entry.key, coldWrite{value: entry.value})
+	}
+}
+
+// Latest unflushed cold state of key, ok is false if there is none. Caller must hold mu
+func (t *Tiered) unflushed(key string) (value string, found, ok bool) {
+	w, ok := t.pending[key]
+	if !ok {
+		w, ok = t.flushing[key]
+	}
+	if !ok {
+		return "", false, false
+	}
+	return w.value, !w.deleted, true
+}
+
+// Record a cold tier write for the flusher. Caller must hold mu
+func (t *Tiered) queueCold(key string, w coldWrite) {
+	t.pending[key] = w
+	if len(t.pending) >= coldFlushThreshold {
+		select {
+		case t.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tiered) loadCold(key string) (string, bool) {
+	var value []byte
+	err := t.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(coldBucket).Get([]byte(key)); v != nil {
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil {
+		slog.Error("failed to read cold tier", "key", key, "error", err)
+		return "", false
+	}
+	return string(value), value != nil
+}
+
+// Write pending cold tier changes until closed
+func (t *Tiered) runFlusher() {
+	defer close(t.stopped)
+
+	ticker := time.NewTicker(coldFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.flushCh:
+		case <-t.done:
+			t.flush()
+			return
+		}
+		t.flush()
+	}
+}
+
+// Apply pending writes in a single transaction
+func (t *Tiered) flush() {
+	t.mu.Lock()
+	if len(t.pending) == 0 {
+		t.mu.Unlock()
+		return
+	}
+	t.flushing = t.pending
+	t.pending = make(map[string]coldWrite)
+	batch := t.flushing
+	t.mu.Unlock()
+
+	err := t.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(coldBucket)
+		for key, w := range batch {
+			var err error
+			if w.deleted {
+				err = b.Delete([]byte(key))
+			} else {
+				err = b.Put([]byte(key), []byte(w.value))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		// Keep the batch so it is retried, newer pending writes take precedence
+		slog.Error("failed to write cold tier", "key_count", len(batch), "error", err)
+		for key, w := range batch {
+			if _, ok := t.pending[key]; !ok {
+				t.pending[key] = w
+			}
+		}
+	}
+	t.flushing = nil
+	t.flushes++
+}
@@ -0,0 +1,163 @@
+// Package testutil holds helpers for tests exercising KVStoreService directly.
+package testutil
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/amillerrr/distributed-kv-store/internal/service"
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const (
+	// How long NewEventRecorder waits for its subscription to register
+	subscribeTimeout = 5 * time.Second
+
+	// How long Flush waits for subscriptions to catch up
+	flushTimeout = 5 * time.Second
+)
+
+// Records every event delivered to a subscription for later assertions
+type EventRecorder struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	events []*pb.ChangeEvent
+	// Signalled whenever an event is recorded
+	arrived chan struct{}
+	err     error
+}
+
+// Subscribe to pattern and record events until Close or the end of the test
+func NewEventRecorder(t testing.TB, svc *service.KVStoreService, pattern string) *EventRecorder {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &EventRecorder{
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		arrived: make(chan struct{}, 1),
+	}
+
+	before := svc.Stats().SubscriberCount
+	go func() {
+		defer close(r.done)
+		err := svc.Subscribe(&pb.SubscribeRequest{KeyPattern: pattern}, &recordingStream{ctx: ctx, r: r})
+		r.mu.Lock()
+		r.err = err
+		r.mu.Unlock()
+	}()
+
+	// Events published before registration would be missed, so wait for it
+	deadline := time.Now().Add(subscribeTimeout)
+	for svc.Stats().SubscriberCount <= before {
+		if time.Now().After(deadline) {
+			cancel()
+			t.Fatalf("subscription to %q was not registered within %s", pattern, subscribeTimeout)
+		}
+		select {
+		case <-r.done:
+			t.Fatalf("subscription to %q ended early: %v", pattern, r.Err())
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	t.Cleanup(r.Close)
+	return r
+}
+
+// Wait until every subscription, recorders included, has been sent all events
+// published so far, so counts can be asserted without polling. svc must be
+// created with service.WithFlushSupport
+func Flush(t testing.TB, svc *service.KVStoreService) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := svc.Flush(ctx); err != nil {
+		t.Fatalf("flush subscribers: %v", err)
+	}
+}
+
+// All events recorded so far, oldest first
+func (r *EventRecorder) Events() []*pb.ChangeEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*pb.ChangeEvent(nil), r.events...)
+}
+
+// Block until an event matching pred has been recorded, failing the test after d
+func (r *EventRecorder) WaitForEvent(t testing.TB, d time.Duration, pred func(*pb.ChangeEvent) bool) *pb.ChangeEvent {
+	t.Helper()
+
+	timeout := time.After(d)
+	seen := 0
+	for {
+		events := r.Events()
+		for _, event := range events[seen:] {
+			if pred(event) {
+				return event
+			}
+		}
+		seen = len(events)
+
+		select {
+		case <-r.arrived:
+		case <-timeout:
+			t.Fatalf("no matching event within %s, recorded %d events", d, seen)
+			return nil
+		}
+	}
+}
+
+// Error the subscription ended with, nil while it is running or if closed cleanly
+func (r *EventRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Unsubscribe and wait for the subscription to end
+func (r *EventRecorder) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *EventRecorder) record(event *pb.ChangeEvent) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+
+	select {
+	case r.arrived <- struct{}{}:
+	default:
+	}
+}
+
+// In-process server stream feeding an EventRecorder
+type recordingStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	r   *EventRecorder
+}
+
+var _ grpc.ServerStreamingServer[pb.ChangeEvent] = (*recordingStream)(nil)
+
+func (s *recordingStream) Send(event *pb.ChangeEvent) error {
+	s.r.record(event)
+	return nil
+}
+
+func (s *recordingStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *recordingStream) SetHeader(metadata.MD) error  { return nil }
+func (s *recordingStream) SendHeader(metadata.MD) error { return nil }
+func (s *recordingStream) SetTrailer(metadata.MD)       {}
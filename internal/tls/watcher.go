@@ -0,0 +1,143 @@
+package tls
+
+import (
+	ctls "crypto/tls"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Collapse bursts of file events, e.g. cert and key written back to back
+const reloadDebounce = 100 * time.Millisecond
+
+// Serve the current certificate and reload it when the files change on disk
+type CertWatcher struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *ctls.Certificate
+
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Load the initial key pair and start watching both files
+func NewCertWatcher(certFile, keyFile string) (*CertWatcher, error) {
+	w := &CertWatcher{
+		certFile: filepath.Clean(certFile),
+		keyFile:  filepath.Clean(keyFile),
+		done:     make(chan struct{}),
+	}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("create file watcher: %w", err)
+	}
+
+	// Watch the directories so atomic renames and symlink swaps are seen
+	dirs := map[string]struct{}{
+		filepath.Dir(w.certFile): {},
+		filepath.Dir(w.keyFile):  {},
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("watch %s: %w", dir, err)
+		}
+	}
+	w.watcher = watcher
+
+	w.wg.Add(1)
+	go w.run()
+
+	slog.Info("watching TLS certificate", "cert_file", w.certFile, "key_file", w.keyFile)
+	return w, nil
+}
+
+// Return the current certificate, for use as tls.Config.GetCertificate
+func (w *CertWatcher) GetCertificate(*ctls.ClientHelloInfo) (*ctls.Certificate, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.cert, nil
+}
+
+// Build a server TLS config that always serves the latest certificate
+func (w *CertWatcher) TLSConfig() *ctls.Config {
+	return &ctls.Config{
+		GetCertificate: w.GetCertificate,
+		MinVersion:     ctls.VersionTLS12,
+	}
+}
+
+// Stop watching the certificate files
+func (w *CertWatcher) Close() error {
+	close(w.done)
+	err := w.watcher.Close()
+	w.wg.Wait()
+	return err
+}
+
+// Load the key pair from disk, keeping the previous one on failure
+func (w *CertWatcher) reload() error {
+	cert, err := ctls.LoadX509KeyPair(w.certFile, w.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+
+	w.mu.Lock()
+	w.cert = &cert
+	w.mu.Unlock()
+	return nil
+}
+
+// Reload after changes to either file settle
+func (w *CertWatcher) run() {
+	defer w.wg.Done()
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.relevant(event) {
+				debounce = time.After(reloadDebounce)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("TLS certificate watcher error", "error", err)
+		case <-debounce:
+			debounce = nil
+			if err := w.reload(); err != nil {
+				slog.Error("failed to reload TLS certificate, keeping previous", "error", err)
+				continue
+			}
+			slog.Info("TLS certificate reloaded", "cert_file", w.certFile)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Report whether an event may have changed the cert or key
+func (w *CertWatcher) relevant(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+
+	// Kubernetes secret mounts swap a ..data symlink rather than the files themselves
+	name := filepath.Clean(event.Name)
+	return name == w.certFile || name == w.keyFile || filepath.Base(name) == "..data"
+}
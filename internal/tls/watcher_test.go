@@ -0,0 +1,106 @@
+package tls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Write a self-signed certificate for localhost with the given serial
+func writeCert(t *testing.T, certFile, keyFile string, serial int64) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	// Replace both files by rename, the way cert-manager and kubelet do
+	for path, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDER},
+	} {
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("write %s: %v", tmp, err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatalf("rename %s: %v", tmp, err)
+		}
+	}
+}
+
+// Serial of the certificate a new connection to addr is served
+func servedSerial(t *testing.T, addr string) int64 {
+	t.Helper()
+	conn, err := ctls.Dial("tcp", addr, &ctls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+func TestCertWatcherServesReplacedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeCert(t, certFile, keyFile, 1)
+
+	w, err := NewCertWatcher(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertWatcher: %v", err)
+	}
+	defer w.Close()
+
+	lis, err := ctls.Listen("tcp", "127.0.0.1:0", w.TLSConfig())
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*ctls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	if serial := servedSerial(t, lis.Addr().String()); serial != 1 {
+		t.Fatalf("initial serial = %d, want 1", serial)
+	}
+
+	writeCert(t, certFile, keyFile, 2)
+	deadline := time.Now().Add(5 * time.Second)
+	for servedSerial(t, lis.Addr().String()) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("new connections still get the old certificate")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
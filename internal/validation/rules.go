@@ -0,0 +1,363 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+// Validators for the KeyValueStore requests. Values larger than maxValueSize
+// bytes are rejected, 0 disables the limit
+func Default(maxValueSize int) map[string]Validator {
+	return map[string]Validator{
+		"kvstore.GetRequest": func(m proto.Message) error {
+			req := m.(*pb.GetRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if req.IfModifiedSinceMs < 0 {
+				errs = append(errs, fieldError("if_modified_since_ms", "cannot be negative"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.SetRequest": func(m proto.Message) error {
+			req := m.(*pb.SetRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if maxValueSize > 0 && len(req.Value) > maxValueSize {
+				errs = append(errs, fieldError("value", "exceeds maximum size of %d bytes", maxValueSize))
+			}
+			if req.GetTtlMs() < 0 {
+				errs = append(errs, fieldError("ttl_ms", "cannot be negative"))
+			}
+			if req.AckTimeoutMs < 0 {
+				errs = append(errs, fieldError("ack_timeout_ms", "cannot be negative"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.GetStreamRequest": func(m proto.Message) error {
+			req := m.(*pb.GetStreamRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if req.ChunkSizeBytes < 0 {
+				errs = append(errs, fieldError("chunk_size_bytes", "cannot be negative"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.SetStreamChunk": func(m proto.Message) error {
+			req := m.(*pb.SetStreamChunk)
+			var errs []error
+			if req.Offset < 0 {
+				errs = append(errs, fieldError("offset", "cannot be negative"))
+			}
+			if req.GetTtlMs() < 0 {
+				errs = append(errs, fieldError("ttl_ms", "cannot be negative"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.GetWithVersionRequest": func(m proto.Message) error {
+			req := m.(*pb.GetWithVersionRequest)
+			if req.Key == "" {
+				return fieldError("key", "cannot be empty")
+			}
+			return nil
+		},
+		"kvstore.SetWithVersionRequest": func(m proto.Message) error {
+			req := m.(*pb.SetWithVersionRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if maxValueSize > 0 && len(req.Value) > maxValueSize {
+				errs = append(errs, fieldError("value", "exceeds maximum size of %d bytes", maxValueSize))
+			}
+			if req.ExpectedVersion < 0 {
+				errs = append(errs, fieldError("expected_version", "cannot be negative"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.SetMultiRequest": func(m proto.Message) error {
+			req := m.(*pb.SetMultiRequest)
+			if len(req.Pairs) == 0 {
+				return fieldError("pairs", "cannot be empty")
+			}
+			var errs []error
+			for i, pair := range req.Pairs {
+				if pair.Key == "" {
+					errs = append(errs, fieldError(fmt.Sprintf("pairs[%d].key", i), "cannot be empty"))
+				}
+				if maxValueSize > 0 && len(pair.Value) > maxValueSize {
+					errs = append(errs, fieldError(fmt.Sprintf("pairs[%d].value", i), "exceeds maximum size of %d bytes", maxValueSize))
+				}
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.SetOrderedRequest": func(m proto.Message) error {
+			req := m.(*pb.SetOrderedRequest)
+			if len(req.Pairs) == 0 {
+				return fieldError("pairs", "cannot be empty")
+			}
+			var errs []error
+			for i, pair := range req.Pairs {
+				if pair.Key == "" {
+					errs = append(errs, fieldError(fmt.Sprintf("pairs[%d].key", i), "cannot be empty"))
+				}
+				if maxValueSize > 0 && len(pair.Value) > maxValueSize {
+					errs = append(errs, fieldError(fmt.Sprintf("pairs[%d].value", i), "exceeds maximum size of %d bytes", maxValueSize))
+				}
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.AppendRequest": func(m proto.Message) error {
+			req := m.(*pb.AppendRequest)
+			if req.Key == "" {
+				return fieldError("key", "cannot be empty")
+			}
+			return nil
+		},
+		"kvstore.SetMetaRequest": func(m proto.Message) error {
+			req := m.(*pb.SetMetaRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if _, ok := req.Meta[""]; ok {
+				errs = append(errs, fieldError("meta", "label names cannot be empty"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.GetMetaRequest": func(m proto.Message) error {
+			req := m.(*pb.GetMetaRequest)
+			if req.Key == "" {
+				return fieldError("key", "cannot be empty")
+			}
+			return nil
+		},
+		"kvstore.SearchMetaRequest": func(m proto.Message) error {
+			req := m.(*pb.SearchMetaRequest)
+			if len(req.Labels) == 0 {
+				return fieldError("labels", "cannot be empty")
+			}
+			return nil
+		},
+		"kvstore.GetSnapshotRequest": func(m proto.Message) error {
+			req := m.(*pb.GetSnapshotRequest)
+			if len(req.Keys) == 0 {
+				return fieldError("keys", "cannot be empty")
+			}
+			var errs []error
+			for i, key := range req.Keys {
+				if key == "" {
+					errs = append(errs, fieldError(fmt.Sprintf("keys[%d]", i), "cannot be empty"))
+				}
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.MergePatchRequest": func(m proto.Message) error {
+			req := m.(*pb.MergePatchRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if !json.Valid([]byte(req.Patch)) {
+				errs = append(errs, fieldError("patch", "must be valid JSON"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.SubscribeRequest": func(m proto.Message) error {
+			req := m.(*pb.SubscribeRequest)
+			var errs []error
+			if req.KeyPattern == "" {
+				errs = append(errs, fieldError("key_pattern", "cannot be empty"))
+			}
+			if req.TtlWarnThresholdMs < 0 {
+				errs = append(errs, fieldError("ttl_warn_threshold_ms", "cannot be negative"))
+			}
+			if req.StreamTimeoutMs < 0 {
+				errs = append(errs, fieldError("stream_timeout_ms", "cannot be negative"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.SubscribeOnceRequest": func(m proto.Message) error {
+			req := m.(*pb.SubscribeOnceRequest)
+			var errs []error
+			if req.KeyPattern == "" {
+				errs = append(errs, fieldError("key_pattern", "cannot be empty"))
+			}
+			if req.TimeoutMs <= 0 || req.TimeoutMs > 60000 {
+				errs = append(errs, fieldError("timeout_ms", "must be between 1 and 60000"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.SetExpiryRequest": func(m proto.Message) error {
+			req := m.(*pb.SetExpiryRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if req.TtlMs < 0 {
+				errs = append(errs, fieldError("ttl_ms", "cannot be negative"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.MultiWatchRequest": func(m proto.Message) error {
+			req := m.(*pb.MultiWatchRequest)
+			if len(req.Patterns) > 100 {
+				return fieldError("patterns", "cannot have more than 100 entries")
+			}
+			for i, pattern := range req.Patterns {
+				if pattern == "" {
+					return fieldError(fmt.Sprintf("patterns[%d]", i), "cannot be empty")
+				}
+			}
+			return nil
+		},
+		"kvstore.AcknowledgeRequest": func(m proto.Message) error {
+			req := m.(*pb.AcknowledgeRequest)
+			var errs []error
+			if req.SubscriptionId == "" {
+				errs = append(errs, fieldError("subscription_id", "cannot be empty"))
+			}
+			if req.Sequence <= 0 {
+				errs = append(errs, fieldError("sequence", "must be positive"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.CompactHistoryRequest": func(m proto.Message) error {
+			req := m.(*pb.CompactHistoryRequest)
+			var errs []error
+			if req.RetainLast < 0 {
+				errs = append(errs, fieldError("retain_last", "cannot be negative"))
+			}
+			if req.RetainSince < 0 {
+				errs = append(errs, fieldError("retain_since", "cannot be negative"))
+			}
+			if req.RetainLast == 0 && req.RetainSince == 0 {
+				errs = append(errs, fieldError("retain_last", "or retain_since is required"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.PrefixStatsRequest": func(m proto.Message) error {
+			req := m.(*pb.PrefixStatsRequest)
+			if len(req.Prefixes) > 100 {
+				return fieldError("prefixes", "at most 100 may be requested")
+			}
+			if slices.Contains(req.Prefixes, "") {
+				return fieldError("prefixes", "cannot contain an empty prefix")
+			}
+			return nil
+		},
+		"kvstore.RandomKeysRequest": func(m proto.Message) error {
+			req := m.(*pb.RandomKeysRequest)
+			if req.Count <= 0 || req.Count > 10000 {
+				return fieldError("count", "must be between 1 and 10000")
+			}
+			return nil
+		},
+		"kvstore.ZAddRequest": func(m proto.Message) error {
+			req := m.(*pb.ZAddRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if req.Member == "" {
+				errs = append(errs, fieldError("member", "cannot be empty"))
+			}
+			if math.IsNaN(req.Score) {
+				errs = append(errs, fieldError("score", "cannot be NaN"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.ZRangeRequest": func(m proto.Message) error {
+			req := m.(*pb.ZRangeRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if req.Limit < 0 || req.Limit > 10000 {
+				errs = append(errs, fieldError("limit", "must be between 0 and 10000"))
+			}
+			if req.MinScore != nil && req.MaxScore != nil && *req.MinScore > *req.MaxScore {
+				errs = append(errs, fieldError("min_score", "cannot be greater than max_score"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.ZRemRequest": func(m proto.Message) error {
+			req := m.(*pb.ZRemRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if req.Member == "" {
+				errs = append(errs, fieldError("member", "cannot be empty"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.ZScoreRequest": func(m proto.Message) error {
+			req := m.(*pb.ZScoreRequest)
+			var errs []error
+			if req.Key == "" {
+				errs = append(errs, fieldError("key", "cannot be empty"))
+			}
+			if req.Member == "" {
+				errs = append(errs, fieldError("member", "cannot be empty"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.MigrateRequest": func(m proto.Message) error {
+			req := m.(*pb.MigrateRequest)
+			var errs []error
+			if req.FromPrefix == "" {
+				errs = append(errs, fieldError("from_prefix", "cannot be empty"))
+			}
+			if req.ToPrefix == "" {
+				errs = append(errs, fieldError("to_prefix", "cannot be empty"))
+			}
+			return errors.Join(errs...)
+		},
+		"kvstore.GetManyRequest": func(m proto.Message) error {
+			req := m.(*pb.GetManyRequest)
+			if req.Pattern == "" {
+				return fieldError("pattern", "cannot be empty")
+			}
+			switch req.MatchMode {
+			case pb.MatchMode_MATCH_GLOB:
+				if _, err := path.Match(req.Pattern, ""); err != nil {
+					return fieldError("pattern", "invalid glob: %v", err)
+				}
+			case pb.MatchMode_MATCH_REGEX:
+				if _, err := regexp.Compile(req.Pattern); err != nil {
+					return fieldError("pattern", "invalid regex: %v", err)
+				}
+			}
+			return nil
+		},
+		"kvstore.AggregateRequest": func(m proto.Message) error {
+			req := m.(*pb.AggregateRequest)
+			var errs []error
+			if req.Pattern == "" {
+				errs = append(errs, fieldError("pattern", "cannot be empty"))
+			}
+			if req.Operation == pb.AggregateOp_AGGREGATE_UNSPECIFIED {
+				errs = append(errs, fieldError("operation", "must be set"))
+			}
+			if req.ValueType != pb.ValueType_VALUE_TYPE_INT64 && req.ValueType != pb.ValueType_VALUE_TYPE_FLOAT64 {
+				errs = append(errs, fieldError("value_type", "must be VALUE_TYPE_INT64 or VALUE_TYPE_FLOAT64"))
+			}
+			return errors.Join(errs...)
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Check a request message, returning one or more FieldErrors joined with
+// errors.Join when it is invalid
+type Validator func(proto.Message) error
+
+// A single invalid field, Field is its path within the message, e.g. key
+type FieldError struct {
+	Field       string
+	Description string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Description
+}
+
+// Shorthand for building a FieldError
+func fieldError(field, format string, args ...any) error {
+	return &FieldError{Field: field, Description: fmt.Sprintf(format, args...)}
+}
+
+// Reject requests that fail the validator registered for their message type,
+// keyed by full message name, e.g. kvstore.GetRequest
+func NewInterceptor(validators map[string]Validator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := validate(validators, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Streaming variant of NewInterceptor, validating every received message
+func StreamServerInterceptor(validators map[string]Validator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: ss, validators: validators, method: info.FullMethod})
+	}
+}
+
+type validatingStream struct {
+	grpc.ServerStream
+	validators map[string]Validator
+	method     string
+}
+
+func (s *validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validate(s.validators, s.method, m)
+}
+
+// Run the validator for req, converting failures into codes.InvalidArgument
+// with a BadRequest detail listing every invalid field
+func validate(validators map[string]Validator, method string, req any) error {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return nil
+	}
+	name := string(msg.ProtoReflect().Descriptor().FullName())
+	validator, ok := validators[name]
+	if !ok {
+		return nil
+	}
+	err := validator(msg)
+	if err == nil {
+		return nil
+	}
+
+	var violations []*errdetails.BadRequest_FieldViolation
+	var messages []string
+	for _, fe := range fieldErrors(err) {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       fe.Field,
+			Description: fe.Description,
+		})
+		messages = append(messages, fe.Error())
+	}
+	if len(violations) == 0 {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Description: err.Error()})
+		messages = append(messages, err.Error())
+	}
+
+	slog.Warn("request failed validation", "method", method, "message", name, "violations", messages)
+	st := status.New(codes.InvalidArgument, fmt.Sprintf("invalid %s: %s", name, strings.Join(messages, "; ")))
+	if detailed, detailErr := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// Flatten err into the FieldErrors it contains, looking inside joined errors
+func fieldErrors(err error) []*FieldError {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var out []*FieldError
+		for _, e := range joined.Unwrap() {
+			out = append(out, fieldErrors(e)...)
+		}
+		return out
+	}
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		return []*FieldError{fe}
+	}
+	return nil
+}
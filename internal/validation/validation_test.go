@@ -0,0 +1,219 @@
+package validation
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/amillerrr/distributed-kv-store/proto"
+)
+
+const testMaxValueSize = 16
+
+// Run req through the unary interceptor, returning whether the handler ran
+func intercept(req proto.Message) (bool, error) {
+	interceptor := NewInterceptor(Default(testMaxValueSize))
+	called := false
+	_, err := interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/kvstore.KeyValueStore/Test"},
+		func(ctx context.Context, req any) (any, error) {
+			called = true
+			return nil, nil
+		})
+	return called, err
+}
+
+// Fields named in the BadRequest detail of err
+func violatedFields(t *testing.T, err error) []string {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.InvalidArgument {
+		t.Fatalf("err = %v, want InvalidArgument", err)
+	}
+	var fields []string
+	for _, detail := range st.Details() {
+		if br, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations {
+				fields = append(fields, v.Field)
+			}
+		}
+	}
+	return fields
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// A valid request for every registered message
+var validRequests = []proto.Message{
+	&pb.GetRequest{Key: "k"},
+	&pb.SetRequest{Key: "k", Value: "v", TtlMs: ptr(int64(1000))},
+	&pb.GetStreamRequest{Key: "k", ChunkSizeBytes: 1024},
+	&pb.SetStreamChunk{Key: "k", Data: []byte("v")},
+	&pb.GetWithVersionRequest{Key: "k"},
+	&pb.SetWithVersionRequest{Key: "k", Value: "v", ExpectedVersion: 1},
+	&pb.SetMultiRequest{Pairs: []*pb.KeyValuePair{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}}},
+	&pb.SetOrderedRequest{Pairs: []*pb.KeyValuePair{{Key: "a", Value: "1"}}},
+	&pb.AppendRequest{Key: "k", Value: "v"},
+	&pb.SetMetaRequest{Key: "k", Meta: map[string]string{"team": "a"}},
+	&pb.GetMetaRequest{Key: "k"},
+	&pb.SearchMetaRequest{Labels: map[string]string{"team": "a"}},
+	&pb.GetSnapshotRequest{Keys: []string{"a", "b"}},
+	&pb.MergePatchRequest{Key: "k", Patch: `{"a":1}`},
+	&pb.SubscribeRequest{KeyPattern: "user:"},
+	&pb.SubscribeOnceRequest{KeyPattern: "user:", TimeoutMs: 1000},
+	&pb.SetExpiryRequest{Key: "k", TtlMs: 1000},
+	&pb.MultiWatchRequest{Patterns: []string{"user:", "order:"}},
+	&pb.AcknowledgeRequest{SubscriptionId: "id", Sequence: 1},
+	&pb.CompactHistoryRequest{RetainLast: 10},
+	&pb.PrefixStatsRequest{Prefixes: []string{"user:"}},
+	&pb.RandomKeysRequest{Count: 10},
+	&pb.ZAddRequest{Key: "k", Member: "m", Score: 1},
+	&pb.ZRangeRequest{Key: "k", Limit: 10, MinScore: ptr(1.0), MaxScore: ptr(2.0)},
+	&pb.ZRemRequest{Key: "k", Member: "m"},
+	&pb.ZScoreRequest{Key: "k", Member: "m"},
+	&pb.MigrateRequest{FromPrefix: "old:", ToPrefix: "new:"},
+	&pb.GetManyRequest{Pattern: "user:[0-9]+", MatchMode: pb.MatchMode_MATCH_REGEX},
+	&pb.AggregateRequest{Pattern: "count:", Operation: pb.AggregateOp_AGGREGATE_SUM, ValueType: pb.ValueType_VALUE_TYPE_INT64},
+}
+
+func TestValidRequestsPass(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, req := range validRequests {
+		name := string(req.ProtoReflect().Descriptor().FullName())
+		covered[name] = true
+		t.Run(name, func(t *testing.T) {
+			called, err := intercept(req)
+			if err != nil || !called {
+				t.Errorf("valid request rejected: %v", err)
+			}
+		})
+	}
+	for name := range Default(testMaxValueSize) {
+		if !covered[name] {
+			t.Errorf("no valid request for %s", name)
+		}
+	}
+}
+
+func TestInvalidRequestsRejected(t *testing.T) {
+	tooLarge := strings.Repeat("x", testMaxValueSize+1)
+	tests := []struct {
+		name       string
+		req        proto.Message
+		wantFields []string
+	}{
+		{"get empty key", &pb.GetRequest{IfModifiedSinceMs: -1}, []string{"key", "if_modified_since_ms"}},
+		{"set oversized value", &pb.SetRequest{Key: "k", Value: tooLarge}, []string{"value"}},
+		{"set every field", &pb.SetRequest{TtlMs: ptr(int64(-1)), AckTimeoutMs: -1}, []string{"key", "ttl_ms", "ack_timeout_ms"}},
+		{"get stream", &pb.GetStreamRequest{ChunkSizeBytes: -1}, []string{"key", "chunk_size_bytes"}},
+		{"set stream chunk", &pb.SetStreamChunk{Offset: -1, TtlMs: ptr(int64(-1))}, []string{"offset", "ttl_ms"}},
+		{"get with version", &pb.GetWithVersionRequest{}, []string{"key"}},
+		{"set with version", &pb.SetWithVersionRequest{Value: tooLarge, ExpectedVersion: -1}, []string{"key", "value", "expected_version"}},
+		{"set multi no pairs", &pb.SetMultiRequest{}, []string{"pairs"}},
+		{"set multi bad pair", &pb.SetMultiRequest{Pairs: []*pb.KeyValuePair{{Key: "a"}, {Value: tooLarge}}}, []string{"pairs[1].key", "pairs[1].value"}},
+		{"set ordered no pairs", &pb.SetOrderedRequest{}, []string{"pairs"}},
+		{"set ordered bad pair", &pb.SetOrderedRequest{Pairs: []*pb.KeyValuePair{{Value: "v"}}}, []string{"pairs[0].key"}},
+		{"append", &pb.AppendRequest{Value: "v"}, []string{"key"}},
+		{"set meta", &pb.SetMetaRequest{Meta: map[string]string{"": "a"}}, []string{"key", "meta"}},
+		{"get meta", &pb.GetMetaRequest{}, []string{"key"}},
+		{"search meta", &pb.SearchMetaRequest{}, []string{"labels"}},
+		{"snapshot no keys", &pb.GetSnapshotRequest{}, []string{"keys"}},
+		{"snapshot empty key", &pb.GetSnapshotRequest{Keys: []string{"a", ""}}, []string{"keys[1]"}},
+		{"merge patch", &pb.MergePatchRequest{Patch: "{"}, []string{"key", "patch"}},
+		{"subscribe", &pb.SubscribeRequest{TtlWarnThresholdMs: -1, StreamTimeoutMs: -1}, []string{"key_pattern", "ttl_warn_threshold_ms", "stream_timeout_ms"}},
+		{"subscribe once", &pb.SubscribeOnceRequest{TimeoutMs: 60001}, []string{"key_pattern", "timeout_ms"}},
+		{"set expiry", &pb.SetExpiryRequest{TtlMs: -1}, []string{"key", "ttl_ms"}},
+		{"multi watch too many", &pb.MultiWatchRequest{Patterns: slices.Repeat([]string{"p"}, 101)}, []string{"patterns"}},
+		{"multi watch empty pattern", &pb.MultiWatchRequest{Patterns: []string{"p", ""}}, []string{"patterns[1]"}},
+		{"acknowledge", &pb.AcknowledgeRequest{}, []string{"subscription_id", "sequence"}},
+		{"compact history negative", &pb.CompactHistoryRequest{RetainLast: -1, RetainSince: 1}, []string{"retain_last"}},
+		{"compact history nothing retained", &pb.CompactHistoryRequest{}, []string{"retain_last"}},
+		{"prefix stats too many", &pb.PrefixStatsRequest{Prefixes: slices.Repeat([]string{"p:"}, 101)}, []string{"prefixes"}},
+		{"prefix stats empty prefix", &pb.PrefixStatsRequest{Prefixes: []string{""}}, []string{"prefixes"}},
+		{"random keys", &pb.RandomKeysRequest{Count: 10001}, []string{"count"}},
+		{"zadd", &pb.ZAddRequest{Score: math.NaN()}, []string{"key", "member", "score"}},
+		{"zrange", &pb.ZRangeRequest{Limit: -1, MinScore: ptr(2.0), MaxScore: ptr(1.0)}, []string{"key", "limit", "min_score"}},
+		{"zrem", &pb.ZRemRequest{}, []string{"key", "member"}},
+		{"zscore", &pb.ZScoreRequest{}, []string{"key", "member"}},
+		{"migrate", &pb.MigrateRequest{}, []string{"from_prefix", "to_prefix"}},
+		{"get many empty pattern", &pb.GetManyRequest{}, []string{"pattern"}},
+		{"get many bad glob", &pb.GetManyRequest{Pattern: "[", MatchMode: pb.MatchMode_MATCH_GLOB}, []string{"pattern"}},
+		{"get many bad regex", &pb.GetManyRequest{Pattern: "(", MatchMode: pb.MatchMode_MATCH_REGEX}, []string{"pattern"}},
+		{"aggregate", &pb.AggregateRequest{}, []string{"pattern", "operation", "value_type"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called, err := intercept(tt.req)
+			if called {
+				t.Fatal("handler ran for an invalid request")
+			}
+			if got := violatedFields(t, err); !slices.Equal(got, tt.wantFields) {
+				t.Errorf("violated fields = %q, want %q", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestUnregisteredMessagesPass(t *testing.T) {
+	called, err := intercept(&pb.DeleteRequest{})
+	if err != nil || !called {
+		t.Errorf("request without a validator rejected: %v", err)
+	}
+}
+
+func TestValueLimitDisabled(t *testing.T) {
+	validator := Default(0)["kvstore.SetRequest"]
+	if err := validator(&pb.SetRequest{Key: "k", Value: strings.Repeat("x", 1<<20)}); err != nil {
+		t.Errorf("large value rejected with no limit: %v", err)
+	}
+}
+
+// ServerStream receiving a fixed message
+type recvStream struct {
+	grpc.ServerStream
+	msg proto.Message
+}
+
+func (s *recvStream) RecvMsg(m any) error {
+	proto.Merge(m.(proto.Message), s.msg)
+	return nil
+}
+
+func TestStreamInterceptorValidatesEveryMessage(t *testing.T) {
+	interceptor := StreamServerInterceptor(Default(testMaxValueSize))
+	info := &grpc.StreamServerInfo{FullMethod: "/kvstore.KeyValueStore/SetStream"}
+
+	tests := []struct {
+		name      string
+		msg       proto.Message
+		wantField string
+	}{
+		{"valid", &pb.SetStreamChunk{Key: "k", Data: []byte("v")}, ""},
+		{"negative offset", &pb.SetStreamChunk{Key: "k", Offset: -1}, "offset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := interceptor(nil, &recvStream{msg: tt.msg}, info, func(srv any, stream grpc.ServerStream) error {
+				return stream.RecvMsg(&pb.SetStreamChunk{})
+			})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("valid message rejected: %v", err)
+				}
+				return
+			}
+			if got := violatedFields(t, err); !slices.Equal(got, []string{tt.wantField}) {
+				t.Errorf("violated fields = %q, want %s", got, tt.wantField)
+			}
+		})
+	}
+}
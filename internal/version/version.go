@@ -0,0 +1,47 @@
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Overridden at build time with -ldflags "-X .../internal/version.Version=v1.2.3"
+var (
+	Version = ""
+	Commit  = ""
+)
+
+// Build identity reported by health endpoints
+type Info struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Go      string `json:"go"`
+}
+
+// Resolve build identity from module build info, falling back to ldflags values
+func Get() Info {
+	info := Info{
+		Version: Version,
+		Commit:  Commit,
+		Go:      runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if v := bi.Main.Version; v != "" && v != "(devel)" {
+			info.Version = v
+		}
+		for _, setting := range bi.Settings {
+			if setting.Key == "vcs.revision" && setting.Value != "" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	return info
+}
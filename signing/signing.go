@@ -0,0 +1,279 @@
+// Package signing authenticates requests with an HMAC-SHA256 signature over
+// the method, a hash of the request body, a timestamp and the metadata that
+// changes how a request is handled, so captured requests cannot be altered or
+// replayed. Streams are signed when they open, over an empty body.
+package signing
+
+import (
+	"container/list"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Metadata headers carrying the signature, when it was made as Unix ns,
+	// and which key made it
+	SignatureHeader = "x-signature"
+	TimestampHeader = "x-timestamp"
+	KeyIDHeader     = "x-key-id"
+
+	// Key ID sent by signers not given one
+	DefaultKeyID = "default"
+
+	// Oldest signature accepted when no max age is given
+	DefaultMaxAge = 30 * time.Second
+
+	// Most recent signatures remembered for replay detection
+	defaultNonceCacheSize = 100000
+)
+
+// Metadata covered by the signature, so a captured request cannot be sent on
+// with another caller's token, namespace or node ID. Each is signed with all
+// its values, an absent header as empty
+var SignedHeaders = []string{"authorization", "x-namespace", "x-kvstore-node-id", "x-request-id", KeyIDHeader}
+
+var (
+	errReplayed       = status.Error(codes.Unauthenticated, "request replayed")
+	errNonceCacheFull = status.Error(codes.ResourceExhausted, "too many signed requests in flight, retry later")
+)
+
+// Configure a request signer
+type SignerOption func(*signer)
+
+// Identify the key to the server, which looks it up by this ID
+func WithKeyID(id string) SignerOption {
+	return func(s *signer) {
+		s.keyID = id
+	}
+}
+
+type signer struct {
+	key   []byte
+	keyID string
+}
+
+func newSigner(privateKey []byte, opts []SignerOption) *signer {
+	s := &signer{key: privateKey, keyID: DefaultKeyID}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Add the signature headers for a call to method with req to ctx. Metadata
+// added to ctx afterwards is not signed, so the signer goes last in the chain
+func (s *signer) signContext(ctx context.Context, method string, req any) (context.Context, error) {
+	timestamp := strconv.FormatInt(time.Now().UnixNano(), 10)
+	ctx = metadata.AppendToOutgoingContext(ctx, KeyIDHeader, s.keyID)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	sig, err := sign(s.key, method, req, timestamp, md)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "sign request: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, SignatureHeader, sig, TimestampHeader, timestamp), nil
+}
+
+// Sign every outgoing unary request with key. Add it after any interceptor
+// setting headers in SignedHeaders, such as client.BearerTokenInterceptor
+func NewRequestSigner(privateKey []byte, opts ...SignerOption) grpc.UnaryClientInterceptor {
+	s := newSigner(privateKey, opts)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := s.signContext(ctx, method, req)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Streaming variant of NewRequestSigner, signing each stream as it opens
+func NewStreamRequestSigner(privateKey []byte, opts ...SignerOption) grpc.StreamClientInterceptor {
+	s := newSigner(privateKey, opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := s.signContext(ctx, method, nil)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// Reject unary requests without a valid, fresh and unseen signature from one
+// of keys, which are indexed by key ID. maxAge of 0 uses DefaultMaxAge
+func NewVerificationInterceptor(keys map[string][]byte, maxAge time.Duration) grpc.UnaryServerInterceptor {
+	v := newVerifier(keys, maxAge)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := v.verify(ctx, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Streaming variant of NewVerificationInterceptor, checking the signature
+// made when the stream opened. Messages sent on the stream are not signed
+func NewStreamVerificationInterceptor(keys map[string][]byte, maxAge time.Duration) grpc.StreamServerInterceptor {
+	v := newVerifier(keys, maxAge)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := v.verify(ss.Context(), info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func newVerifier(keys map[string][]byte, maxAge time.Duration) *verifier {
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &verifier{
+		keys:   keys,
+		maxAge: maxAge,
+		seen:   newNonceCache(defaultNonceCacheSize),
+	}
+}
+
+type verifier struct {
+	keys   map[string][]byte
+	maxAge time.Duration
+	seen   *nonceCache
+}
+
+func (v *verifier) verify(ctx context.Context, method string, req any) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	sig, timestamp, keyID := first(md, SignatureHeader), first(md, TimestampHeader), first(md, KeyIDHeader)
+	if sig == "" || timestamp == "" {
+		return status.Error(codes.Unauthenticated, "request is not signed")
+	}
+	if keyID == "" {
+		keyID = DefaultKeyID
+	}
+	key, ok := v.keys[keyID]
+	if !ok {
+		return status.Error(codes.Unauthenticated, "unknown signing key")
+	}
+
+	ns, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, "invalid signature timestamp")
+	}
+	now := time.Now()
+	signedAt := time.Unix(0, ns)
+	if age := now.Sub(signedAt); age > v.maxAge || age < -v.maxAge {
+		return status.Error(codes.Unauthenticated, "signature expired")
+	}
+
+	expected, err := sign(key, method, req, timestamp, md)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, "invalid request body")
+	}
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return status.Error(codes.Unauthenticated, "invalid signature")
+	}
+
+	// Checked last so forged requests cannot fill the cache
+	return v.seen.add(keyID+"/"+sig, signedAt, now.Add(-v.maxAge))
+}
+
+// Hex HMAC-SHA256 of the method, the request body hash, the timestamp and
+// the values of SignedHeaders in md
+func sign(key []byte, method string, req any, timestamp string, md metadata.MD) (string, error) {
+	var body []byte
+	if msg, ok := req.(proto.Message); ok {
+		var err error
+		body, err = proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return "", err
+		}
+	} else if req != nil {
+		return "", fmt.Errorf("unsupported request type %T", req)
+	}
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(method))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(hex.EncodeToString(bodyHash[:])))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(timestamp))
+	for _, header := range SignedHeaders {
+		mac.Write([]byte{'\n'})
+		mac.Write([]byte(header))
+		for _, value := range md.Get(header) {
+			// Length-prefixed so values cannot be split differently
+			fmt.Fprintf(mac, ":%d:%s", len(value), value)
+		}
+	}
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Bounded set of recently accepted signatures. Only expired ones are evicted,
+// so a replay is always caught within the max age
+type nonceCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type nonceEntry struct {
+	nonce    string
+	signedAt time.Time
+}
+
+func newNonceCache(max int) *nonceCache {
+	return &nonceCache{
+		max:     max,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Remember nonce, failing if it was already seen or if the cache is full of
+// signatures still within their max age. Entries signed before cutoff are
+// dropped since their signatures no longer verify anyway
+func (c *nonceCache) add(nonce string, signedAt, cutoff time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[nonce]; ok {
+		return errReplayed
+	}
+
+	// Entries are in arrival order, which trails signing order by at most the
+	// clock skew the max age allows, so scanning stops at the first live one
+	for back := c.order.Back(); back != nil; back = c.order.Back() {
+		entry := back.Value.(*nonceEntry)
+		if !entry.signedAt.Before(cutoff) {
+			break
+		}
+		c.order.Remove(back)
+		delete(c.entries, entry.nonce)
+	}
+	// Evicting a live entry would let its request be replayed
+	if c.order.Len() >= c.max {
+		return errNonceCacheFull
+	}
+
+	c.entries[nonce] = c.order.PushFront(&nonceEntry{nonce: nonce, signedAt: signedAt})
+	return nil
+}
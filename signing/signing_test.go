@@ -0,0 +1,243 @@
+package signing
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const testMethod = "/kvstore.KeyValueStore/Set"
+
+var testKey = []byte("secret")
+
+// Incoming context of a request to method with req as signed by key, with
+// pairs added as metadata before signing
+func signedContext(t *testing.T, key []byte, req any, pairs ...string) context.Context {
+	t.Helper()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), pairs...)
+	ctx, err := newSigner(key, nil).signContext(ctx, testMethod, req)
+	if err != nil {
+		t.Fatalf("signContext: %v", err)
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestVerifyAcceptsValidSignature(t *testing.T) {
+	v := newVerifier(map[string][]byte{DefaultKeyID: testKey}, 0)
+	req := wrapperspb.String("value")
+	if err := v.verify(signedContext(t, testKey, req, "authorization", "Bearer t"), testMethod, req); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	req := wrapperspb.String("value")
+	tests := []struct {
+		name string
+		ctx  func() context.Context
+		req  any
+		keys map[string][]byte
+	}{
+		{
+			name: "body",
+			ctx:  func() context.Context { return signedContext(t, testKey, req) },
+			req:  wrapperspb.String("other"),
+		},
+		{
+			name: "header",
+			ctx: func() context.Context {
+				ctx := signedContext(t, testKey, req, "authorization", "Bearer mine")
+				md, _ := metadata.FromIncomingContext(ctx)
+				md.Set("authorization", "Bearer theirs")
+				return metadata.NewIncomingContext(ctx, md)
+			},
+			req: req,
+		},
+		{
+			name: "wrong key",
+			ctx:  func() context.Context { return signedContext(t, []byte("guess"), req) },
+			req:  req,
+		},
+		{
+			name: "unknown key id",
+			ctx:  func() context.Context { return signedContext(t, testKey, req) },
+			req:  req,
+			keys: map[string][]byte{"other": testKey},
+		},
+		{
+			name: "unsigned",
+			ctx:  context.Background,
+			req:  req,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := tt.keys
+			if keys == nil {
+				keys = map[string][]byte{DefaultKeyID: testKey}
+			}
+			err := newVerifier(keys, 0).verify(tt.ctx(), testMethod, tt.req)
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("verify = %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTimestampsOutsideMaxAge(t *testing.T) {
+	const maxAge = time.Minute
+	v := newVerifier(map[string][]byte{DefaultKeyID: testKey}, maxAge)
+	req := wrapperspb.String("value")
+
+	for name, offset := range map[string]time.Duration{"stale": -2 * maxAge, "future": 2 * maxAge} {
+		t.Run(name, func(t *testing.T) {
+			// Correctly signed, only the time is off
+			timestamp := strconv.FormatInt(time.Now().Add(offset).UnixNano(), 10)
+			md := metadata.Pairs(KeyIDHeader, DefaultKeyID, TimestampHeader, timestamp)
+			sig, err := sign(testKey, testMethod, req, timestamp, md)
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+			md.Set(SignatureHeader, sig)
+
+			err = v.verify(metadata.NewIncomingContext(context.Background(), md), testMethod, req)
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("verify = %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsReplay(t *testing.T) {
+	v := newVerifier(map[string][]byte{DefaultKeyID: testKey}, 0)
+	req := wrapperspb.String("value")
+	ctx := signedContext(t, testKey, req)
+
+	if err := v.verify(ctx, testMethod, req); err != nil {
+		t.Fatalf("first verify: %v", err)
+	}
+	if err := v.verify(ctx, testMethod, req); !errors.Is(err, errReplayed) {
+		t.Errorf("replayed verify = %v, want %v", err, errReplayed)
+	}
+}
+
+func TestNonceCacheRefusesWhenFullOfLiveEntries(t *testing.T) {
+	c := newNonceCache(2)
+	now := time.Now()
+	cutoff := now.Add(-time.Minute)
+
+	for _, nonce := range []string{"a", "b"} {
+		if err := c.add(nonce, now, cutoff); err != nil {
+			t.Fatalf("add %s: %v", nonce, err)
+		}
+	}
+	if err := c.add("c", now, cutoff); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("add to a full cache = %v, want ResourceExhausted", err)
+	}
+	// Refused nonces are not remembered, both live ones still are
+	for _, nonce := range []string{"a", "b"} {
+		if err := c.add(nonce, now, cutoff); !errors.Is(err, errReplayed) {
+			t.Errorf("re-adding %s = %v, want %v", nonce, err, errReplayed)
+		}
+	}
+}
+
+func TestNonceCacheEvictsOnlyExpiredEntries(t *testing.T) {
+	c := newNonceCache(2)
+	now := time.Now()
+	cutoff := now.Add(-time.Minute)
+
+	if err := c.add("old", now.Add(-2*time.Minute), now.Add(-3*time.Minute)); err != nil {
+		t.Fatalf("add old: %v", err)
+	}
+	if err := c.add("live", now, cutoff); err != nil {
+		t.Fatalf("add live: %v", err)
+	}
+
+	// Full, but old is past the cutoff and makes room
+	if err := c.add("new", now, cutoff); err != nil {
+		t.Errorf("add with an expired entry to evict: %v", err)
+	}
+	if err := c.add("live", now, cutoff); !errors.Is(err, errReplayed) {
+		t.Errorf("live entry was evicted, re-adding = %v", err)
+	}
+	if err := c.add("another", now, cutoff); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("add with only live entries = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestInterceptorsSignAndVerify(t *testing.T) {
+	keys := map[string][]byte{"k1": testKey}
+	req := wrapperspb.String("value")
+
+	t.Run("unary", func(t *testing.T) {
+		var sent context.Context
+		invoker := func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+			sent = ctx
+			return nil
+		}
+		if err := NewRequestSigner(testKey, WithKeyID("k1"))(context.Background(), testMethod, req, nil, nil, invoker); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		md, _ := metadata.FromOutgoingContext(sent)
+		ctx := metadata.NewIncomingContext(context.Background(), md)
+
+		called := false
+		handler := func(context.Context, any) (any, error) {
+			called = true
+			return nil, nil
+		}
+		verify := NewVerificationInterceptor(keys, 0)
+		if _, err := verify(ctx, req, &grpc.UnaryServerInfo{FullMethod: testMethod}, handler); err != nil || !called {
+			t.Errorf("verify = %v, handler called %v", err, called)
+		}
+		if _, err := verify(ctx, wrapperspb.String("other"), &grpc.UnaryServerInfo{FullMethod: testMethod}, handler); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("verify of another body = %v, want Unauthenticated", err)
+		}
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		var sent context.Context
+		streamer := func(ctx context.Context, _ *grpc.StreamDesc, _ *grpc.ClientConn, _ string, _ ...grpc.CallOption) (grpc.ClientStream, error) {
+			sent = ctx
+			return nil, nil
+		}
+		if _, err := NewStreamRequestSigner(testKey, WithKeyID("k1"))(context.Background(), &grpc.StreamDesc{}, nil, testMethod, streamer); err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		md, _ := metadata.FromOutgoingContext(sent)
+		stream := &contextStream{ctx: metadata.NewIncomingContext(context.Background(), md)}
+
+		called := false
+		handler := func(any, grpc.ServerStream) error {
+			called = true
+			return nil
+		}
+		verify := NewStreamVerificationInterceptor(keys, 0)
+		if err := verify(nil, stream, &grpc.StreamServerInfo{FullMethod: testMethod}, handler); err != nil || !called {
+			t.Errorf("verify = %v, handler called %v", err, called)
+		}
+		// Signed for one method, the stream cannot be opened on another
+		other := &grpc.StreamServerInfo{FullMethod: "/kvstore.KeyValueStore/Subscribe"}
+		if err := NewStreamVerificationInterceptor(keys, 0)(nil, stream, other, handler); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("verify on another method = %v, want Unauthenticated", err)
+		}
+	})
+}
+
+// Server stream that only carries a context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context { return s.ctx }